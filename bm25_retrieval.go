@@ -0,0 +1,478 @@
+package batch_0001
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// BM25默认参数
+const (
+	DefaultBM25K1        = 1.5
+	DefaultBM25B         = 0.75
+	bm25IndexVersion     = 1
+	maxDictionaryWordLen = 8 // 词典最大匹配长度（字数）
+)
+
+// ChineseTokenizer 中文分词器
+//
+// 不依赖外部分词库，采用"词典正向最大匹配 + 字符二元组"的组合策略：
+// - 词典词：菜名、食材名等领域词汇整体作为一个词项，提高精确匹配的权重
+// - 二元组：对所有连续汉字生成二元组，保证词典外词汇的召回
+// - 英文/数字：按连续字母数字切分并转为小写
+type ChineseTokenizer struct {
+	dictionary map[string]bool
+	maxWordLen int
+}
+
+// NewChineseTokenizer 创建中文分词器
+//
+// Args:
+//   - words: 领域词典，通常为菜谱名称和食材名称
+func NewChineseTokenizer(words []string) *ChineseTokenizer {
+	t := &ChineseTokenizer{
+		dictionary: make(map[string]bool),
+		maxWordLen: 1,
+	}
+	t.AddWords(words)
+	return t
+}
+
+// AddWords 向词典中添加词汇
+func (t *ChineseTokenizer) AddWords(words []string) {
+	for _, word := range words {
+		word = strings.TrimSpace(strings.ToLower(word))
+		length := len([]rune(word))
+		if length < 2 || length > maxDictionaryWordLen {
+			continue
+		}
+		t.dictionary[word] = true
+		if length > t.maxWordLen {
+			t.maxWordLen = length
+		}
+	}
+}
+
+// Words 返回词典中的所有词汇（已排序）
+func (t *ChineseTokenizer) Words() []string {
+	words := make([]string, 0, len(t.dictionary))
+	for word := range t.dictionary {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
+
+// Tokenize 将文本切分为词项
+func (t *ChineseTokenizer) Tokenize(text string) []string {
+	var tokens []string
+	var hanRun []rune
+	var wordRun []rune
+
+	flushWord := func() {
+		if len(wordRun) > 0 {
+			tokens = append(tokens, string(wordRun))
+			wordRun = wordRun[:0]
+		}
+	}
+	flushHan := func() {
+		if len(hanRun) > 0 {
+			tokens = append(tokens, t.tokenizeHan(hanRun)...)
+			hanRun = hanRun[:0]
+		}
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			hanRun = append(hanRun, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			wordRun = append(wordRun, r)
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+
+	return tokens
+}
+
+// tokenizeHan 对一段连续汉字做词典匹配并生成二元组
+func (t *ChineseTokenizer) tokenizeHan(run []rune) []string {
+	var tokens []string
+
+	// 词典正向最大匹配
+	for i := 0; i < len(run); {
+		matched := 0
+		for l := min(t.maxWordLen, len(run)-i); l >= 2; l-- {
+			if t.dictionary[string(run[i:i+l])] {
+				matched = l
+				break
+			}
+		}
+		if matched > 0 {
+			tokens = append(tokens, string(run[i:i+matched]))
+			i += matched
+		} else {
+			i++
+		}
+	}
+
+	// 字符二元组
+	if len(run) == 1 {
+		tokens = append(tokens, string(run))
+	}
+	for i := 0; i+1 < len(run); i++ {
+		tokens = append(tokens, string(run[i:i+2]))
+	}
+
+	return tokens
+}
+
+// bm25Document 索引中的单个文档
+type bm25Document struct {
+	ID        string                 `json:"id"`
+	Content   string                 `json:"content"`
+	Metadata  map[string]interface{} `json:"metadata"`
+	TermFreqs map[string]int         `json:"term_freqs"`
+	Length    int                    `json:"length"`
+}
+
+// bm25IndexFile 持久化到磁盘的索引文件格式
+type bm25IndexFile struct {
+	Version    int             `json:"version"`
+	CorpusHash string          `json:"corpus_hash"`
+	K1         float64         `json:"k1"`
+	B          float64         `json:"b"`
+	AvgDocLen  float64         `json:"avg_doc_len"`
+	DocFreqs   map[string]int  `json:"doc_freqs"`
+	Documents  []*bm25Document `json:"documents"`
+	Dictionary []string        `json:"dictionary"`
+}
+
+// BM25Retriever BM25文本检索器
+//
+// 基于文档块构建的进程内倒排统计，为混合检索提供关键词匹配通道。
+// 与向量检索互补：对菜名、食材名等精确词汇的匹配更稳定。
+//
+// 特点：
+// - 中文分词：词典最大匹配 + 字符二元组
+// - 持久化：索引以JSON格式保存到磁盘，启动时按语料指纹判断是否可复用
+// - 独立得分：返回原始BM25得分和按最高分归一化后的得分
+type BM25Retriever struct {
+	k1        float64
+	b         float64
+	tokenizer *ChineseTokenizer
+
+	documents  []*bm25Document
+	docFreqs   map[string]int
+	avgDocLen  float64
+	corpusHash string
+}
+
+// NewBM25Retriever 创建BM25检索器
+//
+// Args:
+//   - tokenizer: 中文分词器，为nil时使用空词典的分词器
+//   - k1: 词频饱和参数，<=0时使用默认值1.5
+//   - b: 文档长度归一化参数，不在[0,1]内时使用默认值0.75
+func NewBM25Retriever(tokenizer *ChineseTokenizer, k1, b float64) *BM25Retriever {
+	if tokenizer == nil {
+		tokenizer = NewChineseTokenizer(nil)
+	}
+	if k1 <= 0 {
+		k1 = DefaultBM25K1
+	}
+	if b < 0 || b > 1 {
+		b = DefaultBM25B
+	}
+	return &BM25Retriever{
+		k1:        k1,
+		b:         b,
+		tokenizer: tokenizer,
+		docFreqs:  make(map[string]int),
+	}
+}
+
+// Build 基于文档块构建BM25索引
+func (r *BM25Retriever) Build(chunks []*schema.Document) {
+	r.documents = make([]*bm25Document, 0, len(chunks))
+	for i, chunk := range chunks {
+		if chunk == nil {
+			continue
+		}
+		r.documents = append(r.documents, r.newDocument(chunk, i))
+	}
+	r.corpusHash = bm25CorpusHash(chunks)
+	r.recomputeStats()
+
+	log.Printf("BM25索引构建完成，文档数量: %d，词项数量: %d", len(r.documents), len(r.docFreqs))
+}
+
+// ReplaceDocuments 增量更新索引
+//
+// 移除所属节点（parent_id或node_id）在parentIDs中的文档，再加入新的文档块，
+// 然后重新计算文档频率和平均长度。未变化的文档不重新分词。
+func (r *BM25Retriever) ReplaceDocuments(parentIDs []string, chunks []*schema.Document) {
+	replaced := make(map[string]bool, len(parentIDs))
+	for _, id := range parentIDs {
+		replaced[id] = true
+	}
+
+	kept := make([]*bm25Document, 0, len(r.documents)+len(chunks))
+	removed := 0
+	for _, doc := range r.documents {
+		parentID := fmt.Sprintf("%v", doc.Metadata["parent_id"])
+		nodeID := fmt.Sprintf("%v", doc.Metadata["node_id"])
+		if replaced[parentID] || replaced[nodeID] {
+			removed++
+			continue
+		}
+		kept = append(kept, doc)
+	}
+	for _, chunk := range chunks {
+		if chunk == nil {
+			continue
+		}
+		kept = append(kept, r.newDocument(chunk, len(kept)))
+	}
+
+	r.documents = kept
+	r.corpusHash = bm25DocumentsHash(r.documents)
+	r.recomputeStats()
+
+	log.Printf("BM25索引增量更新完成: 移除 %d 个文档，加入 %d 个文档，当前文档数量: %d", removed, len(chunks), len(r.documents))
+}
+
+// newDocument 对文档块分词，生成索引文档
+func (r *BM25Retriever) newDocument(chunk *schema.Document, index int) *bm25Document {
+	docID := chunk.ID
+	if docID == "" {
+		docID = fmt.Sprintf("bm25_%d", index)
+	}
+
+	tokens := r.tokenizer.Tokenize(chunk.Content)
+	termFreqs := make(map[string]int)
+	for _, token := range tokens {
+		termFreqs[token]++
+	}
+
+	metadata := make(map[string]interface{})
+	for k, v := range chunk.MetaData {
+		metadata[k] = v
+	}
+
+	return &bm25Document{
+		ID:        docID,
+		Content:   chunk.Content,
+		Metadata:  metadata,
+		TermFreqs: termFreqs,
+		Length:    len(tokens),
+	}
+}
+
+// recomputeStats 重新计算文档频率和平均文档长度
+func (r *BM25Retriever) recomputeStats() {
+	r.docFreqs = make(map[string]int)
+	totalLen := 0
+	for _, doc := range r.documents {
+		for term := range doc.TermFreqs {
+			r.docFreqs[term]++
+		}
+		totalLen += doc.Length
+	}
+
+	if len(r.documents) > 0 {
+		r.avgDocLen = float64(totalLen) / float64(len(r.documents))
+	} else {
+		r.avgDocLen = 0
+	}
+}
+
+// Size 返回索引中的文档数量
+func (r *BM25Retriever) Size() int {
+	return len(r.documents)
+}
+
+// MatchesCorpus 判断索引是否由给定的文档块构建
+func (r *BM25Retriever) MatchesCorpus(chunks []*schema.Document) bool {
+	return r.corpusHash != "" && r.corpusHash == bm25CorpusHash(chunks)
+}
+
+// Search BM25检索
+//
+// 返回的文档元数据中包含：
+//   - bm25_score: 原始BM25得分
+//   - normalized_score: 按本次检索最高分归一化到(0,1]的得分
+//   - search_type: "bm25"
+func (r *BM25Retriever) Search(query string, topK int) []*schema.Document {
+	if len(r.documents) == 0 || topK <= 0 {
+		return []*schema.Document{}
+	}
+
+	// 查询词项去重，避免二元组重复放大得分
+	queryTerms := make(map[string]bool)
+	for _, token := range r.tokenizer.Tokenize(query) {
+		queryTerms[token] = true
+	}
+
+	type scoredDoc struct {
+		doc   *bm25Document
+		score float64
+	}
+
+	n := float64(len(r.documents))
+	var scored []scoredDoc
+	for _, doc := range r.documents {
+		score := 0.0
+		for term := range queryTerms {
+			tf := float64(doc.TermFreqs[term])
+			if tf == 0 {
+				continue
+			}
+			df := float64(r.docFreqs[term])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			norm := 1 - r.b
+			if r.avgDocLen > 0 {
+				norm += r.b * float64(doc.Length) / r.avgDocLen
+			}
+			score += idf * tf * (r.k1 + 1) / (tf + r.k1*norm)
+		}
+		if score > 0 {
+			scored = append(scored, scoredDoc{doc: doc, score: score})
+		}
+	}
+
+	sort.Slice(scored, func(i, j int) bool {
+		return scored[i].score > scored[j].score
+	})
+	if len(scored) > topK {
+		scored = scored[:topK]
+	}
+
+	documents := make([]*schema.Document, 0, len(scored))
+	for _, item := range scored {
+		metadata := make(map[string]interface{})
+		for k, v := range item.doc.Metadata {
+			metadata[k] = v
+		}
+		metadata["bm25_score"] = item.score
+		metadata["normalized_score"] = item.score / scored[0].score
+		metadata["search_type"] = "bm25"
+
+		documents = append(documents, &schema.Document{
+			ID:       item.doc.ID,
+			Content:  item.doc.Content,
+			MetaData: metadata,
+		})
+	}
+
+	return documents
+}
+
+// SaveToFile 将索引保存到磁盘
+func (r *BM25Retriever) SaveToFile(path string) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("创建索引目录失败: %w", err)
+		}
+	}
+
+	data, err := json.Marshal(&bm25IndexFile{
+		Version:    bm25IndexVersion,
+		CorpusHash: r.corpusHash,
+		K1:         r.k1,
+		B:          r.b,
+		AvgDocLen:  r.avgDocLen,
+		DocFreqs:   r.docFreqs,
+		Documents:  r.documents,
+		Dictionary: r.tokenizer.Words(),
+	})
+	if err != nil {
+		return fmt.Errorf("序列化BM25索引失败: %w", err)
+	}
+
+	// 先写临时文件再重命名，避免中断时留下不完整的索引
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("写入BM25索引失败: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("保存BM25索引失败: %w", err)
+	}
+
+	log.Printf("BM25索引已保存: %s", path)
+	return nil
+}
+
+// LoadFromFile 从磁盘加载索引
+func (r *BM25Retriever) LoadFromFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取BM25索引失败: %w", err)
+	}
+
+	var file bm25IndexFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("解析BM25索引失败: %w", err)
+	}
+	if file.Version != bm25IndexVersion {
+		return fmt.Errorf("BM25索引版本不匹配: %d != %d", file.Version, bm25IndexVersion)
+	}
+
+	r.k1 = file.K1
+	r.b = file.B
+	r.avgDocLen = file.AvgDocLen
+	r.docFreqs = file.DocFreqs
+	r.documents = file.Documents
+	r.corpusHash = file.CorpusHash
+	r.tokenizer.AddWords(file.Dictionary)
+
+	if r.docFreqs == nil {
+		r.docFreqs = make(map[string]int)
+	}
+
+	log.Printf("BM25索引已加载: %s，文档数量: %d", path, len(r.documents))
+	return nil
+}
+
+// bm25CorpusHash 计算文档块集合的指纹，用于判断磁盘索引是否过期
+func bm25CorpusHash(chunks []*schema.Document) string {
+	h := sha256.New()
+	for _, chunk := range chunks {
+		if chunk == nil {
+			continue
+		}
+		h.Write([]byte(chunk.ID))
+		h.Write([]byte{0})
+		h.Write([]byte(chunk.Content))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// bm25DocumentsHash 计算索引文档集合的指纹，与bm25CorpusHash的计算方式一致
+func bm25DocumentsHash(documents []*bm25Document) string {
+	h := sha256.New()
+	for _, doc := range documents {
+		h.Write([]byte(doc.ID))
+		h.Write([]byte{0})
+		h.Write([]byte(doc.Content))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,470 @@
+package batch_0001
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 分块策略类型
+const (
+	ChunkerSection     = "section"      // 按章节分块，超长章节按步骤、句子边界切分（默认）
+	ChunkerStep        = "step"         // 每个制作步骤单独成块，其余章节按章节分块
+	ChunkerIngredient  = "ingredient"   // 食材清单单独成块，其余内容按句子边界切分
+	ChunkerRecursive   = "recursive"    // 不区分章节，按分隔符优先级递归切分
+	ChunkerParentChild = "parent_child" // 小块用于检索，生成时通过parent_id返回完整菜谱
+)
+
+// 父子分块参数
+const (
+	DefaultChildChunkSize = 150           // 子块大小，不超过配置的块大小
+	childChunkDocType     = "child_chunk" // 子块的文档类型，检索结果据此展开为父文档
+)
+
+// Chunker 文档分块策略
+//
+// 所有实现生成的文档块都满足：
+// - ID为"<节点ID>_chunk_<序号>"，同一文档重新分块时保持稳定
+// - parent_id为所属菜谱的节点ID，chunk_index/total_chunks描述块在文档内的位置
+// - 除整篇文档块外，内容开头都带有菜谱标题
+type Chunker interface {
+	// Name 分块策略名称
+	Name() string
+	// Chunk 对单个文档分块
+	Chunk(doc *schema.Document) []*schema.Document
+}
+
+// NewChunker 根据类型创建分块策略
+//
+// splitter决定块大小、重叠和计量单位；父子分块的子块大小取DefaultChildChunkSize与块大小中较小者。
+// 未知类型时记录日志并使用按章节分块。
+func NewChunker(kind string, splitter *TextSplitter) Chunker {
+	switch kind {
+	case "", ChunkerSection:
+		return NewSectionChunker(splitter)
+	case ChunkerStep:
+		return NewStepChunker(splitter)
+	case ChunkerIngredient:
+		return NewIngredientChunker(splitter)
+	case ChunkerRecursive:
+		return NewRecursiveChunker(splitter, nil)
+	case ChunkerParentChild:
+		return NewParentChildChunker(splitter, DefaultChildChunkSize)
+	default:
+		log.Printf("未知分块策略: %s，使用 %s", kind, ChunkerSection)
+		return NewSectionChunker(splitter)
+	}
+}
+
+// ========== 按章节分块 ==========
+
+// SectionChunker 按章节分块
+//
+// 1. 文档不超过块大小：整个文档作为一个块
+// 2. 有二级标题：按章节分块，超长章节再按步骤、句子边界切分，续块补上章节标题
+// 3. 没有二级标题：按步骤、句子边界切分
+type SectionChunker struct {
+	splitter *TextSplitter
+}
+
+// NewSectionChunker 创建按章节分块策略
+func NewSectionChunker(splitter *TextSplitter) *SectionChunker {
+	return &SectionChunker{splitter: splitter}
+}
+
+// Name 分块策略名称
+func (c *SectionChunker) Name() string {
+	return ChunkerSection
+}
+
+// Chunk 对单个文档分块
+func (c *SectionChunker) Chunk(doc *schema.Document) []*schema.Document {
+	content := strings.TrimSpace(doc.Content)
+	if c.splitter.Length(content) <= c.splitter.ChunkSize {
+		// 文档较短，无需分块，直接将整个文档作为一个块，保持完整性
+		return buildChunks(doc, c.splitter, []chunkPiece{{content: content}})
+	}
+
+	title, sections := splitRecipeSections(content)
+	if !hasSectionHeadings(sections) {
+		// 没有二级标题，按步骤、句子边界切分
+		_, body := splitDocumentTitle(content)
+		return buildChunks(doc, c.splitter, splitWithHeader(c.splitter, title, "", body, "sentence_based", ""))
+	}
+
+	var pieces []chunkPiece
+	for _, section := range sections {
+		pieces = append(pieces, sectionPieces(c.splitter, title, section, "section_based")...)
+	}
+	return buildChunks(doc, c.splitter, pieces)
+}
+
+// ========== 按步骤分块 ==========
+
+// StepChunker 按步骤分块
+//
+// "制作步骤"章节中的每个步骤（### 第N步）单独成块，块内带有菜谱标题和章节标题；
+// 超长步骤按句子边界继续切分。其余章节按章节分块。
+type StepChunker struct {
+	splitter *TextSplitter
+}
+
+// NewStepChunker 创建按步骤分块策略
+func NewStepChunker(splitter *TextSplitter) *StepChunker {
+	return &StepChunker{splitter: splitter}
+}
+
+// Name 分块策略名称
+func (c *StepChunker) Name() string {
+	return ChunkerStep
+}
+
+// Chunk 对单个文档分块
+func (c *StepChunker) Chunk(doc *schema.Document) []*schema.Document {
+	title, sections := splitRecipeSections(strings.TrimSpace(doc.Content))
+
+	var pieces []chunkPiece
+	for _, section := range sections {
+		if section.title != "制作步骤" {
+			pieces = append(pieces, sectionPieces(c.splitter, title, section, "section_based")...)
+			continue
+		}
+
+		header := joinChunkHeader(title, section.heading())
+		for i, step := range splitKeepSeparator(section.body, "\n### ") {
+			stepPieces := splitWithHeader(c.splitter, header, "", step, "step_based", section.title)
+			for j := range stepPieces {
+				stepPieces[j].stepIndex = i + 1
+			}
+			pieces = append(pieces, stepPieces...)
+		}
+	}
+	return buildChunks(doc, c.splitter, pieces)
+}
+
+// ========== 食材清单分块 ==========
+
+// IngredientChunker 食材清单单独成块
+//
+// "所需食材"章节作为独立的块（适合"需要哪些食材"类查询），
+// 其余内容合并后按步骤、句子边界切分。
+type IngredientChunker struct {
+	splitter *TextSplitter
+}
+
+// NewIngredientChunker 创建食材清单分块策略
+func NewIngredientChunker(splitter *TextSplitter) *IngredientChunker {
+	return &IngredientChunker{splitter: splitter}
+}
+
+// Name 分块策略名称
+func (c *IngredientChunker) Name() string {
+	return ChunkerIngredient
+}
+
+// Chunk 对单个文档分块
+func (c *IngredientChunker) Chunk(doc *schema.Document) []*schema.Document {
+	title, sections := splitRecipeSections(strings.TrimSpace(doc.Content))
+
+	var pieces []chunkPiece
+	var rest []string
+	for _, section := range sections {
+		if section.title == "所需食材" {
+			pieces = append(pieces, sectionPieces(c.splitter, title, section, "ingredient_list")...)
+			continue
+		}
+		rest = append(rest, section.text())
+	}
+
+	pieces = append(pieces, splitWithHeader(c.splitter, title, "", strings.Join(rest, "\n\n"), "sentence_based", "")...)
+	return buildChunks(doc, c.splitter, pieces)
+}
+
+// ========== 递归切分 ==========
+
+// recursiveSeparators 递归切分的分隔符优先级：章节 > 步骤 > 段落 > 换行 > 标点
+var recursiveSeparators = append([]string{"\n## "}, defaultSeparators...)
+
+// RecursiveChunker 递归字符切分
+//
+// 不区分章节，按分隔符优先级递归切分整篇文档，再贪心合并到块大小。
+type RecursiveChunker struct {
+	splitter *TextSplitter
+}
+
+// NewRecursiveChunker 创建递归切分策略，separators为空时使用默认分隔符
+func NewRecursiveChunker(splitter *TextSplitter, separators []string) *RecursiveChunker {
+	if len(separators) == 0 {
+		separators = recursiveSeparators
+	}
+	return &RecursiveChunker{splitter: splitter.WithSeparators(separators)}
+}
+
+// Name 分块策略名称
+func (c *RecursiveChunker) Name() string {
+	return ChunkerRecursive
+}
+
+// Chunk 对单个文档分块
+func (c *RecursiveChunker) Chunk(doc *schema.Document) []*schema.Document {
+	title, body := splitDocumentTitle(strings.TrimSpace(doc.Content))
+	return buildChunks(doc, c.splitter, splitWithHeader(c.splitter, title, "", body, "recursive", ""))
+}
+
+// ========== 父子分块 ==========
+
+// ParentChildChunker 父子分块
+//
+// 文档按章节切分为较小的子块用于检索（小块的向量更聚焦），子块的doc_type为child_chunk；
+// 检索命中子块后，由ExpandParentDocuments按parent_id替换为完整菜谱文档用于生成。
+type ParentChildChunker struct {
+	child *SectionChunker
+}
+
+// NewParentChildChunker 创建父子分块策略
+//
+// 子块大小取childSize与splitter块大小中较小者，重叠按比例缩小。
+func NewParentChildChunker(splitter *TextSplitter, childSize int) *ParentChildChunker {
+	if childSize <= 0 || childSize > splitter.ChunkSize {
+		childSize = splitter.ChunkSize
+	}
+	overlap := splitter.ChunkOverlap * childSize / splitter.ChunkSize
+	return &ParentChildChunker{
+		child: NewSectionChunker(NewTextSplitter(childSize, overlap, splitter.Unit)),
+	}
+}
+
+// Name 分块策略名称
+func (c *ParentChildChunker) Name() string {
+	return ChunkerParentChild
+}
+
+// Chunk 对单个文档分块
+func (c *ParentChildChunker) Chunk(doc *schema.Document) []*schema.Document {
+	chunks := c.child.Chunk(doc)
+	for _, chunk := range chunks {
+		chunk.MetaData["doc_type"] = childChunkDocType
+		chunk.MetaData["chunking_method"] = ChunkerParentChild
+	}
+	return chunks
+}
+
+// ParentDocumentResolver 按菜谱ID获取完整菜谱文档
+type ParentDocumentResolver func(parentIDs []string) map[string]*schema.Document
+
+// ExpandParentDocuments 将检索结果中的子块替换为所属的完整菜谱文档
+//
+// 同一菜谱的多个子块只保留排名最靠前的一个；得分等检索元数据沿用子块的，
+// 命中的子块ID记录在matched_chunk_id中。找不到父文档的子块原样保留。
+func ExpandParentDocuments(documents []*schema.Document, resolve ParentDocumentResolver) []*schema.Document {
+	if resolve == nil {
+		return documents
+	}
+
+	var parentIDs []string
+	for _, doc := range documents {
+		if metadataString(doc, "doc_type") == childChunkDocType {
+			if parentID := metadataString(doc, "parent_id"); parentID != "" {
+				parentIDs = append(parentIDs, parentID)
+			}
+		}
+	}
+	if len(parentIDs) == 0 {
+		return documents
+	}
+
+	parents := resolve(parentIDs)
+	expanded := make([]*schema.Document, 0, len(documents))
+	seen := make(map[string]bool)
+	for _, doc := range documents {
+		parentID := metadataString(doc, "parent_id")
+		parent, ok := parents[parentID]
+		if metadataString(doc, "doc_type") != childChunkDocType || !ok {
+			expanded = append(expanded, doc)
+			continue
+		}
+		if seen[parentID] {
+			continue
+		}
+		seen[parentID] = true
+
+		metadata := make(map[string]interface{}, len(parent.MetaData)+len(doc.MetaData))
+		for k, v := range doc.MetaData {
+			metadata[k] = v
+		}
+		for k, v := range parent.MetaData {
+			metadata[k] = v
+		}
+		metadata["matched_chunk_id"] = doc.ID
+
+		expanded = append(expanded, &schema.Document{
+			ID:       parent.ID,
+			Content:  parent.Content,
+			MetaData: metadata,
+		})
+	}
+	return expanded
+}
+
+// ========== 公共辅助 ==========
+
+// chunkPiece 待生成文档块的内容及其章节信息
+type chunkPiece struct {
+	content      string
+	method       string
+	sectionTitle string
+	stepIndex    int
+}
+
+// recipeSection 菜谱文档中的一个二级章节
+type recipeSection struct {
+	title string // 章节标题，开头无标题的部分为"主标题"
+	body  string // 章节正文（不含标题行）
+	intro bool   // 是否为第一个二级标题之前的部分
+}
+
+// heading 章节的Markdown标题行
+func (s recipeSection) heading() string {
+	if s.intro {
+		return ""
+	}
+	return "## " + s.title
+}
+
+// text 章节完整文本（标题行 + 正文）
+func (s recipeSection) text() string {
+	return joinChunkHeader(s.heading(), s.body)
+}
+
+// splitRecipeSections 拆分文档标题和二级章节，忽略空章节
+func splitRecipeSections(content string) (string, []recipeSection) {
+	title, body := splitDocumentTitle(content)
+
+	var sections []recipeSection
+	for i, part := range strings.Split(body, "\n## ") {
+		section := recipeSection{title: "主标题", body: part, intro: true}
+		if i > 0 {
+			lines := strings.SplitN(part, "\n", 2)
+			section = recipeSection{title: strings.TrimSpace(lines[0])}
+			if len(lines) == 2 {
+				section.body = lines[1]
+			}
+		}
+		section.body = strings.TrimSpace(section.body)
+		if section.body == "" && section.intro {
+			continue
+		}
+		sections = append(sections, section)
+	}
+	return title, sections
+}
+
+// hasSectionHeadings 文档中是否有二级标题
+func hasSectionHeadings(sections []recipeSection) bool {
+	for _, section := range sections {
+		if !section.intro {
+			return true
+		}
+	}
+	return false
+}
+
+// sectionPieces 将一个章节切分为块：首块带菜谱标题，续块同时带菜谱标题和章节标题
+func sectionPieces(splitter *TextSplitter, title string, section recipeSection, method string) []chunkPiece {
+	return splitWithHeader(splitter, title, section.heading(), section.text(), method, section.title)
+}
+
+// splitWithHeader 切分正文并为每块加上前缀
+//
+// 首块加header，续块加header+continuation（续块需要补上章节标题时使用），
+// 按较长的前缀从块大小中预留空间。
+func splitWithHeader(splitter *TextSplitter, header, continuation, text, method, sectionTitle string) []chunkPiece {
+	longest := joinChunkHeader(header, continuation)
+	texts := splitter.SplitWithLimit(text, chunkLimit(splitter, longest))
+
+	pieces := make([]chunkPiece, 0, len(texts))
+	for i, part := range texts {
+		prefix := header
+		if i > 0 {
+			prefix = longest
+		}
+		pieces = append(pieces, chunkPiece{
+			content:      joinChunkHeader(prefix, part),
+			method:       method,
+			sectionTitle: sectionTitle,
+		})
+	}
+	return pieces
+}
+
+// buildChunks 由切分结果生成文档块，继承原文档元数据并补充分块信息
+func buildChunks(doc *schema.Document, splitter *TextSplitter, pieces []chunkPiece) []*schema.Document {
+	nodeID := doc.MetaData["node_id"]
+	chunks := make([]*schema.Document, 0, len(pieces))
+	for i, p := range pieces {
+		chunkID := fmt.Sprintf("%v_chunk_%d", nodeID, i)
+
+		metadata := make(map[string]interface{})
+		for k, v := range doc.MetaData {
+			metadata[k] = v
+		}
+		metadata["chunk_id"] = chunkID
+		metadata["parent_id"] = nodeID
+		metadata["chunk_index"] = i
+		metadata["total_chunks"] = len(pieces)
+		metadata["chunk_size"] = splitter.Length(p.content)
+		metadata["chunk_unit"] = string(splitter.Unit)
+		metadata["doc_type"] = "chunk"
+		if p.method != "" {
+			metadata["chunking_method"] = p.method
+		}
+		if p.sectionTitle != "" {
+			metadata["section_title"] = p.sectionTitle
+		}
+		if p.stepIndex > 0 {
+			metadata["step_index"] = p.stepIndex
+		}
+
+		chunks = append(chunks, &schema.Document{
+			ID:       chunkID,
+			Content:  p.content,
+			MetaData: metadata,
+		})
+	}
+	return chunks
+}
+
+// splitDocumentTitle 拆分文档开头的一级标题（# 菜名）和正文
+func splitDocumentTitle(content string) (string, string) {
+	if !strings.HasPrefix(content, "# ") {
+		return "", content
+	}
+	parts := strings.SplitN(content, "\n", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// chunkLimit 扣除块前缀后正文可用的长度，至少保留块大小的一半
+func chunkLimit(splitter *TextSplitter, header string) int {
+	limit := splitter.ChunkSize
+	if header != "" {
+		limit -= splitter.Length(header) + 1
+	}
+	return max(limit, splitter.ChunkSize/2)
+}
+
+// joinChunkHeader 在块内容前加上标题前缀
+func joinChunkHeader(header, text string) string {
+	switch {
+	case header == "":
+		return text
+	case text == "":
+		return header
+	default:
+		return header + "\n" + text
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	batch "batch-0001"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileEnv 配置文件路径的环境变量，--config参数优先
+const configFileEnv = "GRAPH_RAG_CONFIG"
+
+// 密钥名，与对应配置项的环境变量名一致
+const (
+	secretArkAPIKey      = "ARK_API_KEY"
+	secretNeo4jPassword  = "NEO4J_PASSWORD"
+	secretMilvusPassword = "MILVUS_PASSWORD"
+)
+
+// configField GraphRAGConfig的一个可配置字段
+type configField struct {
+	index  int    // 结构体字段下标
+	key    string // json键，如neo4j_uri
+	env    string // 环境变量名，如NEO4J_URI
+	flag   string // 命令行参数名，如neo4j-uri
+	secret bool   // 是否为密钥
+}
+
+// configFields 列出GraphRAGConfig的全部可配置字段
+func configFields() []configField {
+	configType := reflect.TypeOf(GraphRAGConfig{})
+	fields := make([]configField, 0, configType.NumField())
+	for i := 0; i < configType.NumField(); i++ {
+		structField := configType.Field(i)
+		key := strings.Split(structField.Tag.Get("json"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+		field := configField{
+			index:  i,
+			key:    key,
+			env:    structField.Tag.Get("env"),
+			flag:   strings.ReplaceAll(key, "_", "-"),
+			secret: structField.Tag.Get("secret") == "true",
+		}
+		if field.env == "" {
+			field.env = strings.ToUpper(key)
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// LoadConfig 按 默认值 → 配置文件 → 环境变量 → 命令行参数 的顺序加载配置并校验
+//
+// args为不含程序名的命令行参数，返回解析全局参数后剩余的参数（子命令及其参数）。
+// 配置文件通过--config或环境变量GRAPH_RAG_CONFIG指定，按扩展名识别YAML或JSON。
+// 各层都未设置的密钥最后从密钥来源链解析；仍然缺失的密钥在使用前由RequireSecrets报错。
+func LoadConfig(args []string) (*GraphRAGConfig, []string, error) {
+	fields := configFields()
+
+	flags := flag.NewFlagSet("graph-rag", flag.ContinueOnError)
+	configPath := flags.String("config", os.Getenv(configFileEnv), "配置文件路径（.yaml/.yml/.json）")
+	overrides := make(map[int]string)
+	for _, field := range fields {
+		usage := fmt.Sprintf("覆盖配置项 %s（环境变量 %s）", field.key, field.env)
+		set := func(value string) error {
+			overrides[field.index] = value
+			return nil
+		}
+		if reflect.TypeOf(GraphRAGConfig{}).Field(field.index).Type.Kind() == reflect.Bool {
+			flags.BoolFunc(field.flag, usage, set)
+		} else {
+			flags.Func(field.flag, usage, set)
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	config := DefaultGraphRAGConfig()
+	if *configPath != "" {
+		if err := loadConfigFile(config, *configPath); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	value := reflect.ValueOf(config).Elem()
+	for _, field := range fields {
+		raw, ok := os.LookupEnv(field.env)
+		if !ok || raw == "" || field.secret {
+			continue
+		}
+		if err := setConfigValue(value.Field(field.index), raw); err != nil {
+			return nil, nil, fmt.Errorf("环境变量 %s: %w", field.env, err)
+		}
+	}
+	for _, field := range fields {
+		raw, ok := overrides[field.index]
+		if !ok {
+			continue
+		}
+		if err := setConfigValue(value.Field(field.index), raw); err != nil {
+			return nil, nil, fmt.Errorf("参数 --%s: %w", field.flag, err)
+		}
+	}
+
+	if err := resolveSecrets(config, fields); err != nil {
+		return nil, nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return config, flags.Args(), nil
+}
+
+// resolveSecrets 从密钥来源链填充未设置的密钥，并登记全部密钥值用于日志脱敏
+//
+// 来源链中都找不到的密钥保持为空，不是每个子命令都需要全部密钥。
+func resolveSecrets(config *GraphRAGConfig, fields []configField) error {
+	providers := batch.DefaultSecretProviders(config.SecretsDir)
+	value := reflect.ValueOf(config).Elem()
+	for _, field := range fields {
+		if !field.secret {
+			continue
+		}
+		if current := value.Field(field.index).String(); current != "" {
+			batch.RegisterSecret(current)
+			continue
+		}
+		secret, err := providers.Resolve(field.env)
+		if errors.Is(err, batch.ErrSecretNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		value.Field(field.index).SetString(secret)
+	}
+	return nil
+}
+
+// RequireSecrets 检查指定的密钥都已提供，缺失时一次列出全部缺失项和提供方式
+func (c *GraphRAGConfig) RequireSecrets(names ...string) error {
+	providers := batch.DefaultSecretProviders(c.SecretsDir)
+	value := reflect.ValueOf(c).Elem()
+	var errs []error
+	for _, field := range configFields() {
+		if !field.secret || !containsValue(field.env, names...) || value.Field(field.index).String() != "" {
+			continue
+		}
+		// 再查一次来源链，得到带提供方式说明的错误
+		if _, err := providers.Resolve(field.env); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("密钥检查失败: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// systemSecrets 完整系统运行需要的密钥：Ark API Key总是需要，
+// 不使用CSV图数据时需要Neo4j密码，配置了Milvus用户时需要Milvus密码
+func (c *GraphRAGConfig) systemSecrets() []string {
+	names := []string{secretArkAPIKey}
+	if c.GraphCSVDir == "" {
+		names = append(names, secretNeo4jPassword)
+	}
+	if c.MilvusUser != "" {
+		names = append(names, secretMilvusPassword)
+	}
+	return names
+}
+
+// loadConfigFile 读取配置文件覆盖到config上，未知的配置项视为错误
+func loadConfigFile(config *GraphRAGConfig, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		// 先转成JSON，YAML和JSON配置共用json标签
+		var values map[string]interface{}
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return fmt.Errorf("解析YAML配置文件失败: %w", err)
+		}
+		if data, err = json.Marshal(values); err != nil {
+			return fmt.Errorf("转换YAML配置文件失败: %w", err)
+		}
+	case ".json":
+	default:
+		return fmt.Errorf("不支持的配置文件格式: %s（支持.yaml/.yml/.json）", path)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+	}
+	return nil
+}
+
+// setConfigValue 把字符串形式的值写入配置字段
+func setConfigValue(field reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("无效的整数 %q", raw)
+		}
+		field.SetInt(value)
+	case reflect.Float32, reflect.Float64:
+		value, err := strconv.ParseFloat(raw, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("无效的数值 %q", raw)
+		}
+		field.SetFloat(value)
+	case reflect.Bool:
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("无效的布尔值 %q", raw)
+		}
+		field.SetBool(value)
+	default:
+		return fmt.Errorf("不支持的配置类型 %s", field.Kind())
+	}
+	return nil
+}
+
+// Validate 校验配置取值，返回全部不合法的配置项
+func (c *GraphRAGConfig) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.GraphCSVDir != "" || c.Neo4jURI != "", "neo4j_uri和graph_csv_dir不能同时为空")
+	check(c.MilvusHost != "", "milvus_host不能为空")
+	check(c.MilvusDimension > 0, "milvus_dimension必须大于0: %d", c.MilvusDimension)
+	check(c.LLMModel != "", "llm_model不能为空")
+	check(c.Temperature >= 0 && c.Temperature <= 2, "temperature必须在0到2之间: %g", c.Temperature)
+	check(c.MaxTokens > 0, "max_tokens必须大于0: %d", c.MaxTokens)
+
+	check(c.TopK > 0, "top_k必须大于0: %d", c.TopK)
+	check(c.ChunkSize > 0, "chunk_size必须大于0: %d", c.ChunkSize)
+	check(c.ChunkOverlap >= 0 && c.ChunkOverlap < c.ChunkSize, "chunk_overlap必须在0到chunk_size之间: %d", c.ChunkOverlap)
+	check(containsValue(c.ChunkUnit, string(batch.ChunkUnitRune), string(batch.ChunkUnitToken)),
+		"chunk_unit取值无效: %q", c.ChunkUnit)
+	check(containsValue(c.Chunker, batch.ChunkerSection, batch.ChunkerStep, batch.ChunkerIngredient, batch.ChunkerRecursive, batch.ChunkerParentChild),
+		"chunker取值无效: %q", c.Chunker)
+	check(containsValue(c.Reranker, batch.RerankerLexical, batch.RerankerLLMPointwise, batch.RerankerLLMListwise, batch.RerankerNone),
+		"reranker取值无效: %q", c.Reranker)
+
+	check(c.MaxPerRecipe >= 0, "max_per_recipe不能为负数: %d", c.MaxPerRecipe)
+	check(c.MaxPerCuisine >= 0, "max_per_cuisine不能为负数: %d", c.MaxPerCuisine)
+	check(c.MMRLambda >= 0 && c.MMRLambda <= 1, "mmr_lambda必须在0到1之间: %g", c.MMRLambda)
+	check(containsValue(c.GroundingMode, "", string(batch.GroundingOff), string(batch.GroundingMark), string(batch.GroundingStrip)),
+		"grounding_mode取值无效: %q", c.GroundingMode)
+
+	check(c.LLMRelationKeyWorkers >= 0, "llm_relation_key_workers不能为负数: %d", c.LLMRelationKeyWorkers)
+	check(c.LLMRelationKeyRPS >= 0, "llm_relation_key_rps不能为负数: %g", c.LLMRelationKeyRPS)
+	check(c.RequestTimeoutSeconds >= 0, "request_timeout_seconds不能为负数: %d", c.RequestTimeoutSeconds)
+	check(c.RebuildTimeoutSeconds >= 0, "rebuild_timeout_seconds不能为负数: %d", c.RebuildTimeoutSeconds)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("配置校验失败: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Redacted 返回密钥脱敏后的配置副本
+func (c *GraphRAGConfig) Redacted() *GraphRAGConfig {
+	redacted := *c
+	value := reflect.ValueOf(&redacted).Elem()
+	for _, field := range configFields() {
+		if field.secret && value.Field(field.index).String() != "" {
+			value.Field(field.index).SetString(batch.RedactedSecret)
+		}
+	}
+	return &redacted
+}
+
+// PrintConfig 以JSON格式输出生效的配置，密钥脱敏
+func PrintConfig(w io.Writer, config *GraphRAGConfig) error {
+	data, err := json.MarshalIndent(config.Redacted(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化配置失败: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// containsValue 判断value是否为候选值之一
+func containsValue(value string, candidates ...string) bool {
+	for _, candidate := range candidates {
+		if value == candidate {
+			return true
+		}
+	}
+	return false
+}
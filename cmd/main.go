@@ -3,30 +3,47 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	batch "batch-0001"
 
 	"github.com/cloudwego/eino-ext/components/model/ark"
+	"github.com/cloudwego/eino/schema"
 )
 
 // GraphRAGConfig 系统配置结构体
+//
+// 配置按 默认值 → 配置文件 → 环境变量 → 命令行参数 逐层覆盖，见LoadConfig。
+// 环境变量名为json键的大写形式（可用env标签指定），命令行参数名为json键把下划线换成连字符；
+// 带secret标签的字段是密钥：不读取同名环境变量的通用规则，而是通过密钥来源链
+// （环境变量 → <NAME>_FILE → secrets_dir目录）解析，并在打印配置、日志和错误信息中脱敏。
 type GraphRAGConfig struct {
 	// Neo4j配置
 	Neo4jURI      string `json:"neo4j_uri"`
 	Neo4jUser     string `json:"neo4j_user"`
-	Neo4jPassword string `json:"neo4j_password"`
+	Neo4jPassword string `json:"neo4j_password" secret:"true"`
 	Neo4jDatabase string `json:"neo4j_database"`
 
+	// CSV图数据目录（包含nodes.csv和relationships.csv），设置后数据准备不连接Neo4j
+	GraphCSVDir string `json:"graph_csv_dir"`
+
 	// Milvus配置
 	MilvusHost           string `json:"milvus_host"`
 	MilvusPort           string `json:"milvus_port"`
 	MilvusCollectionName string `json:"milvus_collection_name"`
 	MilvusDimension      int    `json:"milvus_dimension"`
+	MilvusUser           string `json:"milvus_user"` // 为空时不认证
+	MilvusPassword       string `json:"milvus_password" secret:"true"`
 
 	// LLM配置
 	LLMModel       string  `json:"llm_model"`
@@ -39,28 +56,98 @@ type GraphRAGConfig struct {
 	ChunkSize    int `json:"chunk_size"`
 	ChunkOverlap int `json:"chunk_overlap"`
 
-	//ark api key
-	ApiKey string `json:"api_key"`
+	// 块大小计量单位：rune（字符）/ token（估算的模型token）
+	ChunkUnit string `json:"chunk_unit"`
+
+	// 分块策略：section / step / ingredient / recursive / parent_child
+	Chunker string `json:"chunker"`
+
+	// BM25索引持久化路径
+	BM25IndexPath string `json:"bm25_index_path"`
+
+	// 图索引（实体/关系键值对）快照路径
+	GraphIndexPath string `json:"graph_index_path"`
+
+	// 图索引结构声明文件（索引哪些节点标签、关系类型及其内容和主题键），为空时使用内置结构
+	GraphIndexSchemaPath string `json:"graph_index_schema_path"`
+
+	// 图索引去重审计报告路径，为空时不去重；dedup-revert子命令据此撤销去重
+	DedupReportPath string `json:"dedup_report_path"`
+
+	// 去重同义词表（JSON对象：别名 -> 标准名，如 {"番茄": "西红柿"}），为空时只按规范化名称合并
+	DedupSynonymsPath string `json:"dedup_synonyms_path"`
+
+	// LLM增强关系索引键：是否开启，并发数和每秒请求数（超过1000时不限流）
+	EnableLLMRelationKeys bool    `json:"enable_llm_relation_keys"`
+	LLMRelationKeyWorkers int     `json:"llm_relation_key_workers"`
+	LLMRelationKeyRPS     float64 `json:"llm_relation_key_rps"`
+
+	// 知识库增量同步状态文件路径
+	SyncStatePath string `json:"sync_state_path"`
+
+	// 重排序器：lexical / llm_pointwise / llm_listwise / none
+	Reranker string `json:"reranker"`
+
+	// 结果多样性：每个菜谱/菜系最多保留的文档数（0表示不限制）
+	MaxPerRecipe  int     `json:"max_per_recipe"`
+	MaxPerCuisine int     `json:"max_per_cuisine"`
+	MMRLambda     float64 `json:"mmr_lambda"`
+
+	// 忠实度配置：off / mark / strip
+	GroundingMode      string `json:"grounding_mode"`
+	GroundingLLMVerify bool   `json:"grounding_llm_verify"`
+
+	// HTTP服务配置：监听地址，问答请求超时和重建请求超时（秒）
+	ServerAddr            string `json:"server_addr"`
+	RequestTimeoutSeconds int    `json:"request_timeout_seconds"`
+	RebuildTimeoutSeconds int    `json:"rebuild_timeout_seconds"`
+
+	// 方舟API配置：生成、嵌入、重排序和图检索共用同一个API Key
+	ApiKey     string `json:"api_key" env:"ARK_API_KEY" secret:"true"`
+	ArkBaseURL string `json:"ark_base_url"`
+
+	// 密钥文件目录（Docker secrets / Kubernetes secret卷），文件名为密钥名的小写形式
+	SecretsDir string `json:"secrets_dir"`
 }
 
-// DefaultConfig 默认配置
-var DefaultConfig = &GraphRAGConfig{
-	Neo4jURI:             "bolt://localhost:7687",
-	Neo4jUser:            "neo4j",
-	Neo4jPassword:        "rootroot",
-	Neo4jDatabase:        "neo4j",
-	MilvusHost:           "localhost",
-	MilvusPort:           "19530",
-	MilvusCollectionName: "cooking_recipes",
-	MilvusDimension:      1536,
-	LLMModel:             "doubao-1-5-pro-256k-250115",
-	EmbeddingModel:       "doubao-seed-1-6-thinking-250715",
-	ApiKey:               "be0d94ab-5e0f-48ca-87bf-5522007ba28e",
-	Temperature:          0.1,
-	MaxTokens:            2048,
-	TopK:                 5,
-	ChunkSize:            512,
-	ChunkOverlap:         50,
+// noRelevantAnswer 没有检索到相关文档时的回答
+const noRelevantAnswer = "抱歉，没有找到相关的烹饪信息。请尝试其他问题。"
+
+// DefaultGraphRAGConfig 默认配置
+func DefaultGraphRAGConfig() *GraphRAGConfig {
+	return &GraphRAGConfig{
+		Neo4jURI:              "bolt://localhost:7687",
+		Neo4jUser:             "neo4j",
+		Neo4jDatabase:         "neo4j",
+		MilvusHost:            "localhost",
+		MilvusPort:            "19530",
+		MilvusCollectionName:  "cooking_recipes",
+		MilvusDimension:       1536,
+		LLMModel:              "doubao-1-5-pro-256k-250115",
+		EmbeddingModel:        "doubao-seed-1-6-thinking-250715",
+		Temperature:           0.1,
+		MaxTokens:             2048,
+		TopK:                  5,
+		ChunkSize:             512,
+		ChunkOverlap:          50,
+		ChunkUnit:             string(batch.ChunkUnitRune),
+		Chunker:               batch.ChunkerSection,
+		BM25IndexPath:         "./data/bm25_index.json",
+		GraphIndexPath:        "./data/graph_index.json",
+		DedupReportPath:       "./data/graph_index_dedup.json",
+		SyncStatePath:         "./data/kb_sync_state.json",
+		Reranker:              batch.RerankerLexical,
+		MaxPerRecipe:          batch.DefaultMaxPerRecipe,
+		MaxPerCuisine:         batch.DefaultMaxPerCuisine,
+		MMRLambda:             batch.DefaultMMRLambda,
+		GroundingMode:         string(batch.GroundingOff),
+		ServerAddr:            ":8080",
+		RequestTimeoutSeconds: 120,
+		RebuildTimeoutSeconds: 3600,
+		SecretsDir:            batch.DefaultSecretsDir,
+		LLMRelationKeyWorkers: batch.DefaultLLMRelationKeyWorkers,
+		LLMRelationKeyRPS:     batch.DefaultLLMRelationKeyRPS,
+	}
 }
 
 // AdvancedGraphRAGSystem 高级图RAG系统
@@ -78,6 +165,8 @@ type AdvancedGraphRAGSystem struct {
 	dataModule       *batch.GraphDataPreparationModule
 	indexModule      *batch.MilvusIndexConstructionModule
 	generationModule *batch.GenerationIntegrationModule
+	syncer           *batch.KnowledgeBaseSyncer
+	graphStore       batch.GraphStore
 
 	// 检索引擎
 	traditionalRetrieval *batch.HybridRetrievalModule
@@ -87,18 +176,20 @@ type AdvancedGraphRAGSystem struct {
 	//llm
 	model *ark.ChatModel
 
-	// 系统状态
-	systemReady bool
+	// 系统状态：HTTP服务的处理器并发读取，重建时清除
+	systemReady atomic.Bool
+
+	// 交互模式下在终端打印用户问题、路由和检索信息；HTTP服务不打印
+	interactive bool
 }
 
 // NewAdvancedGraphRAGSystem 创建高级图RAG系统
 func NewAdvancedGraphRAGSystem(config *GraphRAGConfig) *AdvancedGraphRAGSystem {
 	if config == nil {
-		config = DefaultConfig
+		config = DefaultGraphRAGConfig()
 	}
 	return &AdvancedGraphRAGSystem{
-		config:      config,
-		systemReady: false,
+		config: config,
 	}
 }
 
@@ -106,6 +197,11 @@ func NewAdvancedGraphRAGSystem(config *GraphRAGConfig) *AdvancedGraphRAGSystem {
 func (s *AdvancedGraphRAGSystem) InitializeSystem(ctx context.Context) error {
 	log.Println("启动高级图RAG系统...")
 
+	// 缺少密钥时立即失败，不等到第一次调用模型或连接数据库
+	if err := s.config.RequireSecrets(s.config.systemSecrets()...); err != nil {
+		return err
+	}
+
 	// 1. 数据准备模块
 	fmt.Println("初始化数据准备模块...")
 	var err error
@@ -120,26 +216,38 @@ func (s *AdvancedGraphRAGSystem) InitializeSystem(ctx context.Context) error {
 		return fmt.Errorf("初始化LLM模型失败: %v", err)
 	}
 
-	s.dataModule, err = batch.NewGraphDataPreparationModule(
-		s.config.Neo4jURI,
-		s.config.Neo4jUser,
-		s.config.Neo4jPassword,
-		s.config.Neo4jDatabase,
-	)
+	s.dataModule, err = newDataModule(s.config)
 	if err != nil {
 		return fmt.Errorf("初始化数据准备模块失败: %v", err)
 	}
 
+	// 图存储：CSV模式使用内存图，否则与数据准备模块共用Neo4j连接
+	if graph := s.dataModule.CSVGraph(); graph != nil {
+		s.graphStore = batch.NewMemoryGraphStore(graph)
+	} else {
+		s.graphStore = batch.NewNeo4jGraphStore(s.dataModule.Driver, s.config.Neo4jDatabase)
+	}
+
 	// 2. 向量索引模块
 	fmt.Println("初始化Milvus向量索引...")
 	s.indexModule = batch.NewMilvusIndexConstructionModule(
 		s.config.MilvusHost,
 		s.config.MilvusPort,
 		s.config.MilvusCollectionName,
-		0,
+		int64(s.config.MilvusDimension),
 		s.config.EmbeddingModel,
 		s.config.ApiKey,
 	)
+	s.indexModule.SetCredentials(s.config.MilvusUser, s.config.MilvusPassword)
+
+	// 知识库增量同步器
+	s.syncer = batch.NewKnowledgeBaseSyncer(
+		s.dataModule,
+		s.indexModule,
+		s.config.SyncStatePath,
+		s.config.ChunkSize,
+		s.config.ChunkOverlap,
+	)
 
 	// 3. 生成模块
 	fmt.Println("初始化生成模块...")
@@ -155,17 +263,17 @@ func (s *AdvancedGraphRAGSystem) InitializeSystem(ctx context.Context) error {
 		return fmt.Errorf("初始化生成模块失败: %v", err)
 	}
 
-	// 4. 创建系统配置
-	systemConfig := &batch.Config{
-		Neo4jURI:      s.config.Neo4jURI,
-		Neo4jUser:     s.config.Neo4jUser,
-		Neo4jPassword: s.config.Neo4jPassword,
-		LLMModel:      s.config.LLMModel,
-		ArkAPIKey:     os.Getenv("ARK_API_KEY"),
-		ArkBaseURL:    os.Getenv("ARK_BASE_URL"),
-		Constraints:   make(map[string]interface{}),
+	// 忠实度模式（可选）
+	if mode := batch.GroundingMode(s.config.GroundingMode); mode != "" && mode != batch.GroundingOff {
+		s.generationModule.EnableGrounding(&batch.GroundingConfig{
+			Mode:           mode,
+			UseLLMVerifier: s.config.GroundingLLMVerify,
+		})
 	}
 
+	// 4. 创建系统配置
+	systemConfig := newSystemConfig(s.config)
+
 	// 5. 传统混合检索模块
 	fmt.Println("初始化传统混合检索...")
 	s.traditionalRetrieval = batch.NewHybridRetrievalModule(
@@ -174,10 +282,12 @@ func (s *AdvancedGraphRAGSystem) InitializeSystem(ctx context.Context) error {
 		s.dataModule,
 		s.model,
 	)
+	s.traditionalRetrieval.SetGraphStore(s.graphStore)
 
 	// 6. 图RAG检索模块
 	fmt.Println("初始化图RAG检索引擎...")
 	s.graphRAGRetrieval = batch.NewGraphRAGRetrieval(systemConfig)
+	s.graphRAGRetrieval.SetGraphStore(s.graphStore)
 
 	// 7. 智能查询路由器
 	fmt.Println("初始化智能查询路由器...")
@@ -188,10 +298,266 @@ func (s *AdvancedGraphRAGSystem) InitializeSystem(ctx context.Context) error {
 		systemConfig,
 	)
 
+	// 8. 融合结果重排序器
+	reranker := batch.NewReranker(s.config.Reranker, s.model)
+	s.traditionalRetrieval.SetReranker(reranker)
+	s.queryRouter.SetReranker(reranker)
+
+	// 9. 结果多样性配置
+	diversity := batch.DefaultDiversityConfig()
+	diversity.MaxPerRecipe = s.config.MaxPerRecipe
+	diversity.MaxPerCuisine = s.config.MaxPerCuisine
+	diversity.Lambda = s.config.MMRLambda
+	s.queryRouter.SetDiversity(diversity)
+
+	// 10. 父子分块：检索命中子块后展开为完整菜谱用于生成
+	if s.config.Chunker == batch.ChunkerParentChild {
+		s.queryRouter.SetParentResolver(s.dataModule.ParentDocuments)
+	}
+
 	fmt.Println("✅ 高级图RAG系统初始化完成！")
 	return nil
 }
 
+// newDataModule 按配置创建数据准备模块：设置了GraphCSVDir时从CSV文件加载，否则连接Neo4j
+func newDataModule(config *GraphRAGConfig) (*batch.GraphDataPreparationModule, error) {
+	var module *batch.GraphDataPreparationModule
+	var err error
+	if config.GraphCSVDir != "" {
+		module, err = batch.NewCSVGraphDataPreparationModule(
+			filepath.Join(config.GraphCSVDir, "nodes.csv"),
+			filepath.Join(config.GraphCSVDir, "relationships.csv"),
+		)
+	} else {
+		module, err = batch.NewGraphDataPreparationModule(
+			config.Neo4jURI,
+			config.Neo4jUser,
+			config.Neo4jPassword,
+			config.Neo4jDatabase,
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	module.ChunkUnit = batch.ChunkUnit(config.ChunkUnit)
+	module.ChunkStrategy = config.Chunker
+	return module, nil
+}
+
+// PrepareOffline 离线运行数据准备流程：从CSV文件加载图数据、构建菜谱文档并分块
+//
+// 不需要Neo4j、Milvus和LLM服务，用于检查CSV数据和分块效果。
+func PrepareOffline(config *GraphRAGConfig, csvDir string) error {
+	offline := *config
+	offline.GraphCSVDir = csvDir
+
+	dataModule, err := newDataModule(&offline)
+	if err != nil {
+		return fmt.Errorf("初始化数据准备模块失败: %v", err)
+	}
+	if _, err := dataModule.LoadGraphData(); err != nil {
+		return fmt.Errorf("加载图数据失败: %v", err)
+	}
+	if _, err := dataModule.BuildRecipeDocuments(); err != nil {
+		return fmt.Errorf("构建菜谱文档失败: %v", err)
+	}
+	if _, err := dataModule.ChunkDocuments(config.ChunkSize, config.ChunkOverlap); err != nil {
+		return fmt.Errorf("文档分块失败: %v", err)
+	}
+
+	stats := dataModule.GetStatistics()
+	fmt.Println("\n离线数据准备完成:")
+	fmt.Printf("   菜谱数量: %v\n", stats["total_recipes"])
+	fmt.Printf("   食材数量: %v\n", stats["total_ingredients"])
+	fmt.Printf("   烹饪步骤: %v\n", stats["total_cooking_steps"])
+	fmt.Printf("   文档数量: %v\n", stats["total_documents"])
+	fmt.Printf("   文本块数: %v\n", stats["total_chunks"])
+	if avg, ok := stats["avg_chunk_size"].(float64); ok {
+		fmt.Printf("   平均块大小: %.1f\n", avg)
+	}
+	return nil
+}
+
+// ImportGraph 把图数据导入Neo4j：校验数据、创建约束和索引、批量写入节点和关系
+//
+// 存在悬空关系、未知关系编码等问题时默认中止导入，skipInvalid为true时跳过问题数据继续导入。
+// prune为true时导入后删除Neo4j中已不在图里的节点和关系。
+func ImportGraph(ctx context.Context, config *GraphRAGConfig, graph *batch.CSVGraph, skipInvalid, prune bool) error {
+	if err := config.RequireSecrets(secretNeo4jPassword); err != nil {
+		return err
+	}
+
+	fmt.Printf("CSV校验: %s\n", graph.Validation.String())
+	if graph.Validation.HasErrors() && !skipInvalid {
+		return fmt.Errorf("CSV数据存在问题，修复后重试或使用 --skip-invalid 跳过问题数据")
+	}
+
+	dataModule, err := batch.NewGraphDataPreparationModule(
+		config.Neo4jURI,
+		config.Neo4jUser,
+		config.Neo4jPassword,
+		config.Neo4jDatabase,
+	)
+	if err != nil {
+		return fmt.Errorf("连接Neo4j失败: %v", err)
+	}
+	defer dataModule.Close()
+
+	importer := batch.NewNeo4jGraphImporter(dataModule.Driver, config.Neo4jDatabase, 0)
+	if err := importer.CreateSchema(ctx, graph); err != nil {
+		return err
+	}
+	report, err := importer.Import(ctx, graph)
+	if err != nil {
+		return err
+	}
+	if prune {
+		if err := importer.Prune(ctx, graph, report); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("\n图数据导入完成:")
+	for label, count := range report.NodesWritten {
+		fmt.Printf("   %s 节点: %d\n", label, count)
+	}
+	for relationType, count := range report.RelationsWritten {
+		fmt.Printf("   %s 关系: %d\n", relationType, count)
+	}
+	fmt.Printf("   新建节点: %d, 新建关系: %d, 设置属性: %d\n",
+		report.NodesCreated, report.RelationshipsCreated, report.PropertiesSet)
+	if prune {
+		fmt.Printf("   删除节点: %d, 删除关系: %d\n", report.NodesDeleted, report.RelationshipsDeleted)
+	}
+	fmt.Printf("   耗时: %v\n", report.Duration)
+	return nil
+}
+
+// newSystemConfig 按配置创建检索模块共用的系统配置
+func newSystemConfig(config *GraphRAGConfig) *batch.Config {
+	return &batch.Config{
+		Neo4jURI:             config.Neo4jURI,
+		Neo4jUser:            config.Neo4jUser,
+		Neo4jPassword:        config.Neo4jPassword,
+		LLMModel:             config.LLMModel,
+		ArkAPIKey:            config.ApiKey,
+		ArkBaseURL:           config.ArkBaseURL,
+		BM25IndexPath:        config.BM25IndexPath,
+		GraphIndexPath:       config.GraphIndexPath,
+		GraphIndexSchemaPath: config.GraphIndexSchemaPath,
+		DedupReportPath:      config.DedupReportPath,
+		DedupSynonymsPath:    config.DedupSynonymsPath,
+		Constraints: map[string]interface{}{
+			"enable_llm_relation_keys": config.EnableLLMRelationKeys,
+			"llm_relation_key_workers": config.LLMRelationKeyWorkers,
+			"llm_relation_key_rps":     config.LLMRelationKeyRPS,
+		},
+	}
+}
+
+// MarkdownConvertOptions markdown子命令的参数
+type MarkdownConvertOptions struct {
+	MarkdownDir string // Markdown菜谱目录
+	OutputDir   string // CSV输出目录，必填
+	BaseDir     string // 已有CSV目录，复用其中的节点ID、关系ID和Markdown中没有的属性；为空时从头生成
+	Force       bool   // 输出目录中已有CSV文件时覆盖
+	DropMissing bool   // 丢弃已有CSV中没有对应Markdown文件的菜谱（默认原样保留）
+	SkipInvalid bool   // 跳过无法解析的Markdown文件（默认中止）
+	Import      bool   // 同时导入Neo4j，并删除Neo4j中不在输出里的节点和关系
+}
+
+// ConvertMarkdownRecipes 解析Markdown菜谱目录，生成 nodes.csv 和 relationships.csv
+func ConvertMarkdownRecipes(ctx context.Context, config *GraphRAGConfig, options MarkdownConvertOptions) error {
+	if options.OutputDir == "" {
+		return fmt.Errorf("未指定CSV输出目录")
+	}
+	nodesPath := filepath.Join(options.OutputDir, "nodes.csv")
+	relationshipsPath := filepath.Join(options.OutputDir, "relationships.csv")
+	if !options.Force {
+		for _, path := range []string{nodesPath, relationshipsPath} {
+			if _, err := os.Stat(path); err == nil {
+				return fmt.Errorf("输出文件已存在: %s，使用 --force 覆盖", path)
+			}
+		}
+	}
+
+	recipes, err := batch.ParseMarkdownRecipeDir(options.MarkdownDir)
+	var parseErr *batch.MarkdownRecipesError
+	switch {
+	case errors.As(err, &parseErr) && options.SkipInvalid:
+		fmt.Printf("⚠️ 跳过 %d 个无法解析的菜谱文件: %s\n", len(parseErr.FailedFiles), strings.Join(parseErr.FailedFiles, ", "))
+	case err != nil:
+		return fmt.Errorf("解析Markdown菜谱失败（可使用 --skip-invalid 跳过无法解析的文件）: %v", err)
+	}
+	if len(recipes) == 0 {
+		return fmt.Errorf("目录中没有可解析的菜谱: %s", options.MarkdownDir)
+	}
+
+	var existing *batch.CSVGraph
+	if options.BaseDir != "" {
+		existing, err = batch.LoadCSVGraph(
+			filepath.Join(options.BaseDir, "nodes.csv"),
+			filepath.Join(options.BaseDir, "relationships.csv"),
+		)
+		if err != nil {
+			return fmt.Errorf("加载已有CSV失败: %v", err)
+		}
+	}
+
+	builder := batch.NewRecipeGraphBuilder(existing)
+	builder.SetDropMissingRecipes(options.DropMissing)
+	rows := builder.Build(recipes)
+	if err := rows.WriteCSV(options.OutputDir); err != nil {
+		return err
+	}
+	fmt.Printf("已解析 %d 个菜谱，写出 %d 个节点、%d 条关系到 %s\n",
+		len(recipes), len(rows.Nodes), len(rows.Relations), options.OutputDir)
+	if len(rows.KeptRecipes) > 0 {
+		fmt.Printf("保留 %d 个没有Markdown文件的已有菜谱（使用 --drop-missing 丢弃）\n", len(rows.KeptRecipes))
+	}
+	if len(rows.DroppedRecipes) > 0 {
+		fmt.Printf("丢弃 %d 个没有Markdown文件的已有菜谱: %s\n", len(rows.DroppedRecipes), strings.Join(rows.DroppedRecipes, ", "))
+	}
+
+	if options.Import {
+		return ImportGraph(ctx, config, rows.Graph(), false, true)
+	}
+	return nil
+}
+
+// RevertGraphIndexDedup 按去重审计报告撤销图索引快照中的去重
+//
+// 不连接任何服务：加载GraphIndexPath的快照，恢复报告中记录的实体和关系后写回快照。
+// 撤销后的快照在图数据变化触发重建前一直生效；要长期关闭去重，需同时清空dedup_report_path。
+func RevertGraphIndexDedup(config *GraphRAGConfig, reportPath string) error {
+	if config.GraphIndexPath == "" {
+		return fmt.Errorf("未配置图索引快照路径")
+	}
+	report, err := batch.LoadDedupReport(reportPath)
+	if err != nil {
+		return err
+	}
+
+	index := batch.NewGraphIndexingModule(newSystemConfig(config), ark.ChatModel{})
+	if err := index.LoadConfigFiles(); err != nil {
+		return err
+	}
+	if _, err := index.LoadSnapshot(config.GraphIndexPath); err != nil {
+		return err
+	}
+	if err := index.RevertDeduplication(report); err != nil {
+		return err
+	}
+	if err := index.SaveSnapshot(config.GraphIndexPath); err != nil {
+		return err
+	}
+
+	fmt.Printf("已撤销去重: 恢复 %d 个实体、%d 个关系，快照已写回 %s\n",
+		len(report.OriginalEntities), len(report.OriginalRelations), config.GraphIndexPath)
+	return nil
+}
+
 // BuildKnowledgeBase 构建知识库
 func (s *AdvancedGraphRAGSystem) BuildKnowledgeBase(ctx context.Context) error {
 	fmt.Println("\n检查知识库状态...")
@@ -205,7 +571,7 @@ func (s *AdvancedGraphRAGSystem) BuildKnowledgeBase(ctx context.Context) error {
 	if exists {
 		fmt.Println("✅ 发现已存在的知识库，尝试加载...")
 		if err := s.indexModule.LoadCollection(ctx); err != nil {
-			fmt.Println("❌ 知识库加载失败，开始重建...")
+			fmt.Printf("❌ 知识库加载失败（%s），开始重建...\n", batch.RedactError(err))
 		} else {
 			fmt.Println("知识库加载成功！")
 			return s.initializeRetrievers(ctx)
@@ -214,8 +580,8 @@ func (s *AdvancedGraphRAGSystem) BuildKnowledgeBase(ctx context.Context) error {
 
 	fmt.Println("未找到已存在的集合，开始构建新的知识库...")
 
-	// 从Neo4j加载图数据
-	fmt.Println("从Neo4j加载图数据...")
+	// 加载图数据（Neo4j或CSV文件）
+	fmt.Println("加载图数据...")
 	_, err = s.dataModule.LoadGraphData()
 	if err != nil {
 		return fmt.Errorf("加载图数据失败: %v", err)
@@ -241,6 +607,11 @@ func (s *AdvancedGraphRAGSystem) BuildKnowledgeBase(ctx context.Context) error {
 		return fmt.Errorf("构建向量索引失败: %v", err)
 	}
 
+	// 记录同步基线，之后可通过sync只同步变化的菜谱
+	if err := s.syncer.SaveBaseline(ctx); err != nil {
+		log.Printf("保存同步基线失败: %v", err)
+	}
+
 	// 初始化检索器
 	if err := s.initializeRetrievers(ctx); err != nil {
 		return fmt.Errorf("初始化检索器失败: %v", err)
@@ -253,6 +624,45 @@ func (s *AdvancedGraphRAGSystem) BuildKnowledgeBase(ctx context.Context) error {
 	return nil
 }
 
+// SyncKnowledgeBase 增量同步知识库
+//
+// 检测新增、变化、删除的菜谱、食材和步骤节点，只重新生成受影响的菜谱文档并更新Milvus，
+// 然后刷新检索器的内存缓存。检索器尚未初始化时（如sync子命令）先从已有索引初始化。
+func (s *AdvancedGraphRAGSystem) SyncKnowledgeBase(ctx context.Context) error {
+	fmt.Println("\n增量同步知识库...")
+
+	report, err := s.syncer.Sync(ctx)
+	if err != nil {
+		return fmt.Errorf("同步知识库失败: %v", err)
+	}
+
+	// CSV模式下同步时重新读取了CSV文件，内存图存储随之更新
+	if store, ok := s.graphStore.(*batch.MemoryGraphStore); ok {
+		store.Load(s.dataModule.CSVGraph())
+	}
+
+	if !s.systemReady.Load() {
+		// 不传文档块，BM25从磁盘索引加载后再应用本次变更
+		if err := s.traditionalRetrieval.Initialize(ctx, nil); err != nil {
+			return fmt.Errorf("初始化传统检索器失败: %v", err)
+		}
+		if err := s.graphRAGRetrieval.Initialize(ctx); err != nil {
+			return fmt.Errorf("初始化图RAG检索器失败: %v", err)
+		}
+		s.systemReady.Store(true)
+	}
+
+	if err := s.traditionalRetrieval.Refresh(ctx, report); err != nil {
+		return fmt.Errorf("刷新传统检索器失败: %v", err)
+	}
+	if err := s.graphRAGRetrieval.Refresh(ctx); err != nil {
+		return fmt.Errorf("刷新图RAG检索器失败: %v", err)
+	}
+
+	fmt.Printf("✅ 知识库同步完成: %s\n", report)
+	return nil
+}
+
 // initializeRetrievers 初始化检索器
 func (s *AdvancedGraphRAGSystem) initializeRetrievers(ctx context.Context) error {
 	fmt.Println("初始化检索引擎...")
@@ -270,7 +680,7 @@ func (s *AdvancedGraphRAGSystem) initializeRetrievers(ctx context.Context) error
 		return fmt.Errorf("初始化图RAG检索器失败: %v", err)
 	}
 
-	s.systemReady = true
+	s.systemReady.Store(true)
 	fmt.Println("✅ 检索引擎初始化完成！")
 	return nil
 }
@@ -308,23 +718,31 @@ func (s *AdvancedGraphRAGSystem) showKnowledgeBaseStats(ctx context.Context) {
 	fmt.Printf("   路由统计: 总查询 %d 次\n", routeStats.TotalQueries)
 }
 
-// AskQuestionWithRouting 智能问答：自动选择最佳检索策略
-func (s *AdvancedGraphRAGSystem) AskQuestionWithRouting(ctx context.Context, question string, stream bool, explainRouting bool) (string, *batch.QueryAnalysis, error) {
-	if !s.systemReady {
-		return "", nil, fmt.Errorf("系统未就绪，请先构建知识库")
+// retrieveWithRouting 智能路由检索，交互模式下显示路由与检索信息
+func (s *AdvancedGraphRAGSystem) retrieveWithRouting(ctx context.Context, question string) ([]*schema.Document, *batch.QueryAnalysis, error) {
+	if !s.systemReady.Load() {
+		return nil, nil, fmt.Errorf("系统未就绪，请先构建知识库")
 	}
 
-	fmt.Printf("\n❓ 用户问题: %s\n", question)
-
-	startTime := time.Now()
+	if s.interactive {
+		fmt.Printf("\n❓ 用户问题: %s\n", question)
+		fmt.Println("执行智能查询路由...")
+	}
 
 	// 1. 智能路由检索
-	fmt.Println("执行智能查询路由...")
 	relevantDocs, analysis, err := s.queryRouter.RouteQuery(ctx, question, s.config.TopK)
 	if err != nil {
-		return "", nil, fmt.Errorf("路由查询失败: %v", err)
+		return nil, nil, fmt.Errorf("路由查询失败: %v", err)
 	}
 
+	if s.interactive {
+		printRoutingResult(relevantDocs, analysis)
+	}
+	return relevantDocs, analysis, nil
+}
+
+// printRoutingResult 在终端显示路由策略和检索到的文档
+func printRoutingResult(relevantDocs []*schema.Document, analysis *batch.QueryAnalysis) {
 	// 2. 显示路由信息
 	strategyIcons := map[batch.SearchStrategy]string{
 		batch.HybridTraditional: "🔍",
@@ -358,28 +776,79 @@ func (s *AdvancedGraphRAGSystem) AskQuestionWithRouting(ctx context.Context, que
 		for i, info := range docInfo {
 			fmt.Printf("    %d. %s\n", i+1, info)
 		}
-	} else {
-		return "抱歉，没有找到相关的烹饪信息。请尝试其他问题。", analysis, nil
 	}
+}
 
-	// 4. 生成回答
-	fmt.Println("🎯 智能生成回答...")
+// AskQuestionWithRouting 交互式智能问答：自动选择最佳检索策略，在终端打印生成进度和耗时
+func (s *AdvancedGraphRAGSystem) AskQuestionWithRouting(ctx context.Context, question string, stream bool, explainRouting bool) (string, *batch.QueryAnalysis, error) {
+	startTime := time.Now()
+	var onChunk func(chunk string)
+	if stream {
+		// 流式输出：检索信息打印完后，收到第一个片段时打印提示，之后实时打印回答片段
+		started := false
+		onChunk = func(chunk string) {
+			if !started {
+				started = true
+				fmt.Println("🎯 智能生成回答...")
+			}
+			fmt.Print(chunk)
+		}
+	}
+	result, analysis, err := s.AskQuestionStream(ctx, question, onChunk)
+	if stream {
+		fmt.Println()
+	}
+	if err != nil {
+		return "", analysis, err
+	}
+
+	duration := time.Since(startTime)
+	fmt.Printf("\n⏱️ 问答完成，耗时: %.2f秒\n", duration.Seconds())
+	return result, analysis, nil
+}
+
+// AskQuestionStream 智能问答，onChunk不为nil时流式生成并逐段回调，返回完整回答
+//
+// 对话历史、温度等单次生成参数通过batch.WithGenerationOptions放入ctx。
+func (s *AdvancedGraphRAGSystem) AskQuestionStream(ctx context.Context, question string, onChunk func(chunk string)) (string, *batch.QueryAnalysis, error) {
+	relevantDocs, analysis, err := s.retrieveWithRouting(ctx, question)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(relevantDocs) == 0 {
+		if onChunk != nil {
+			onChunk(noRelevantAnswer)
+		}
+		return noRelevantAnswer, analysis, nil
+	}
 
+	// 4. 生成回答
 	var result string
-	if stream {
+	if onChunk != nil {
 		// 流式输出
-		resultChan := make(chan string, 100)
+		resultChan := make(chan batch.StreamEvent, 100)
 		go func() {
 			s.generationModule.GenerateAdaptiveAnswerStream(ctx, question, relevantDocs, 3, resultChan)
 		}()
 
-		// 实时输出
+		// 只把回答片段交给onChunk，重试等进度提示已由生成模块写入日志
 		var chunks []string
-		for chunk := range resultChan {
-			fmt.Print(chunk)
-			chunks = append(chunks, chunk)
+		var streamErr error
+		for event := range resultChan {
+			switch event.Type {
+			case batch.StreamEventChunk:
+				onChunk(event.Content)
+				chunks = append(chunks, event.Content)
+			case batch.StreamEventError:
+				streamErr = event.Err
+			}
+		}
+		if streamErr == nil {
+			streamErr = ctx.Err()
+		}
+		if streamErr != nil {
+			return "", analysis, fmt.Errorf("生成回答失败: %w", streamErr)
 		}
-		fmt.Println()
 		result = strings.Join(chunks, "")
 	} else {
 		// 非流式输出
@@ -389,23 +858,51 @@ func (s *AdvancedGraphRAGSystem) AskQuestionWithRouting(ctx context.Context, que
 		}
 	}
 
-	// 5. 性能统计
+	return result, analysis, nil
+}
+
+// AskQuestionWithGrounding 忠实度问答：生成答案并检查每个句子/步骤的知识库依据
+//
+// 答案中无依据的内容会按配置的忠实度模式被标注或删除，
+// 返回结果中包含忠实度得分和逐句检查明细。
+func (s *AdvancedGraphRAGSystem) AskQuestionWithGrounding(ctx context.Context, question string) (*batch.GroundedAnswer, *batch.QueryAnalysis, error) {
+	startTime := time.Now()
+
+	relevantDocs, analysis, err := s.retrieveWithRouting(ctx, question)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(relevantDocs) == 0 {
+		return &batch.GroundedAnswer{
+			Answer:       noRelevantAnswer,
+			Groundedness: 1.0,
+		}, analysis, nil
+	}
+
+	fmt.Println("🎯 生成回答并检查知识库依据...")
+	grounded, err := s.generationModule.GenerateGroundedAnswer(ctx, question, relevantDocs)
+	if err != nil {
+		return nil, analysis, fmt.Errorf("生成回答失败: %v", err)
+	}
+
 	duration := time.Since(startTime)
 	fmt.Printf("\n⏱️ 问答完成，耗时: %.2f秒\n", duration.Seconds())
 
-	return result, analysis, nil
+	return grounded, analysis, nil
 }
 
 // RunInteractive 运行交互式问答
 func (s *AdvancedGraphRAGSystem) RunInteractive(ctx context.Context) {
-	if !s.systemReady {
+	if !s.systemReady.Load() {
 		fmt.Println("❌ 系统未就绪，请先构建知识库")
 		return
 	}
+	s.interactive = true
 
 	fmt.Println("\n欢迎使用尝尝咸淡RAG烹饪助手！")
 	fmt.Println("可用功能：")
 	fmt.Println("   - 'stats' : 查看系统统计")
+	fmt.Println("   - 'sync' : 增量同步知识库")
 	fmt.Println("   - 'rebuild' : 重建知识库")
 	fmt.Println("   - 'quit' : 退出系统")
 	fmt.Println("\n" + strings.Repeat("=", 50))
@@ -430,11 +927,29 @@ func (s *AdvancedGraphRAGSystem) RunInteractive(ctx context.Context) {
 		case "stats":
 			s.showSystemStats(ctx)
 			continue
+		case "sync":
+			if err := s.SyncKnowledgeBase(ctx); err != nil {
+				fmt.Printf("❌ %v\n", batch.RedactError(err))
+			}
+			continue
 		case "rebuild":
 			s.rebuildKnowledgeBase(ctx)
 			continue
 		}
 
+		// 忠实度模式：答案需要完整生成后才能逐句检查，因此不使用流式输出
+		if s.generationModule.GroundingEnabled() {
+			fmt.Println("\n回答:")
+			grounded, _, err := s.AskQuestionWithGrounding(ctx, userInput)
+			if err != nil {
+				fmt.Printf("处理问题时出错: %v\n", batch.RedactError(err))
+				continue
+			}
+			fmt.Printf("%s\n", grounded.Answer)
+			fmt.Printf("\n🔎 忠实度得分: %.2f（无依据片段 %d 处）\n", grounded.Groundedness, len(grounded.UnsupportedSegments()))
+			continue
+		}
+
 		// 普通问答
 		useStream := true
 		explainRouting := false
@@ -442,7 +957,7 @@ func (s *AdvancedGraphRAGSystem) RunInteractive(ctx context.Context) {
 		fmt.Println("\n回答:")
 		result, analysis, err := s.AskQuestionWithRouting(ctx, userInput, useStream, explainRouting)
 		if err != nil {
-			fmt.Printf("处理问题时出错: %v\n", err)
+			fmt.Printf("处理问题时出错: %v\n", batch.RedactError(err))
 			continue
 		}
 
@@ -491,22 +1006,30 @@ func (s *AdvancedGraphRAGSystem) rebuildKnowledgeBase(ctx context.Context) {
 		return
 	}
 
+	if err := s.RebuildKnowledgeBase(ctx); err != nil {
+		fmt.Printf("❌ 重建失败: %v\n", batch.RedactError(err))
+		fmt.Println("建议：请检查Milvus服务状态后重试")
+		return
+	}
+
+	fmt.Println("✅ 知识库重建完成！")
+}
+
+// RebuildKnowledgeBase 删除现有的Milvus集合并重新构建知识库，不做交互确认
+//
+// 删除集合前标记系统未就绪，重建成功后才恢复就绪，失败时不再对缺失的集合提供问答。
+func (s *AdvancedGraphRAGSystem) RebuildKnowledgeBase(ctx context.Context) error {
+	s.systemReady.Store(false)
 	fmt.Println("删除现有的Milvus集合...")
 	if err := s.indexModule.DeleteCollection(ctx); err != nil {
-		fmt.Printf("删除集合时出现问题: %v，继续重建...\n", err)
+		fmt.Printf("删除集合时出现问题: %v，继续重建...\n", batch.RedactError(err))
 	} else {
 		fmt.Println("✅ 现有集合已删除")
 	}
 
 	// 重新构建知识库
 	fmt.Println("开始重建知识库...")
-	if err := s.BuildKnowledgeBase(ctx); err != nil {
-		fmt.Printf("❌ 重建失败: %v\n", err)
-		fmt.Println("建议：请检查Milvus服务状态后重试")
-		return
-	}
-
-	fmt.Println("✅ 知识库重建完成！")
+	return s.BuildKnowledgeBase(ctx)
 }
 
 // Cleanup 清理资源
@@ -528,24 +1051,141 @@ func (s *AdvancedGraphRAGSystem) Cleanup(ctx context.Context) {
 func main() {
 	ctx := context.Background()
 
-	fmt.Println("启动高级图RAG系统...")
+	// 日志输出前替换已登记的密钥值
+	log.SetOutput(batch.NewRedactingWriter(os.Stderr))
 
-	// 从环境变量加载配置
-	config := DefaultConfig
-	if uri := os.Getenv("NEO4J_URI"); uri != "" {
-		config.Neo4jURI = uri
+	// 加载配置：默认值 → 配置文件 → 环境变量 → 命令行参数
+	config, args, err := LoadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
 	}
-	if user := os.Getenv("NEO4J_USER"); user != "" {
-		config.Neo4jUser = user
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
 	}
-	if password := os.Getenv("NEO4J_PASSWORD"); password != "" {
-		config.Neo4jPassword = password
+	command := ""
+	if len(args) > 0 {
+		command = args[0]
 	}
-	if host := os.Getenv("MILVUS_HOST"); host != "" {
-		config.MilvusHost = host
+
+	// config print子命令：打印生效的配置，密钥脱敏
+	if command == "config" {
+		if len(args) < 2 || args[1] != "print" {
+			log.Fatalf("用法: config print")
+		}
+		if err := PrintConfig(os.Stdout, config); err != nil {
+			log.Fatalf("打印配置失败: %v", err)
+		}
+		return
 	}
-	if model := os.Getenv("LLM_MODEL"); model != "" {
-		config.LLMModel = model
+
+	fmt.Println("启动高级图RAG系统...")
+
+	// prepare子命令：离线从CSV构建文档并分块，不连接任何服务
+	if command == "prepare" {
+		csvDir := config.GraphCSVDir
+		if len(args) > 1 {
+			csvDir = args[1]
+		}
+		if csvDir == "" {
+			csvDir = "./cypher"
+		}
+		if err := PrepareOffline(config, csvDir); err != nil {
+			log.Fatalf("离线数据准备失败: %v", err)
+		}
+		return
+	}
+
+	// import子命令：把CSV图数据导入Neo4j，替代 neo4j_import.cypher
+	if command == "import" {
+		csvDir := config.GraphCSVDir
+		skipInvalid, prune := false, false
+		for _, arg := range args[1:] {
+			switch arg {
+			case "--skip-invalid":
+				skipInvalid = true
+			case "--prune":
+				prune = true
+			default:
+				csvDir = arg
+			}
+		}
+		if csvDir == "" {
+			csvDir = "./cypher"
+		}
+		graph, err := batch.LoadCSVGraph(
+			filepath.Join(csvDir, "nodes.csv"),
+			filepath.Join(csvDir, "relationships.csv"),
+		)
+		if err != nil {
+			log.Fatalf("加载CSV图数据失败: %v", err)
+		}
+		if err := ImportGraph(ctx, config, graph, skipInvalid, prune); err != nil {
+			log.Fatalf("导入图数据失败: %v", err)
+		}
+		return
+	}
+
+	// markdown子命令：从HowToCook风格的Markdown菜谱重新生成CSV，可选同时导入Neo4j
+	if command == "markdown" {
+		const usage = "用法: markdown <菜谱目录> --out <CSV输出目录> [--base <已有CSV目录>] [--force] [--drop-missing] [--skip-invalid] [--import]"
+		options := MarkdownConvertOptions{BaseDir: config.GraphCSVDir}
+		if options.BaseDir == "" {
+			options.BaseDir = "./cypher"
+		}
+		rest := args[1:]
+		for i := 0; i < len(rest); i++ {
+			switch arg := rest[i]; arg {
+			case "--out", "--base":
+				if i+1 >= len(rest) {
+					log.Fatalf("%s 缺少目录参数\n%s", arg, usage)
+				}
+				i++
+				if arg == "--out" {
+					options.OutputDir = rest[i]
+				} else {
+					options.BaseDir = rest[i]
+				}
+			case "--force":
+				options.Force = true
+			case "--drop-missing":
+				options.DropMissing = true
+			case "--skip-invalid":
+				options.SkipInvalid = true
+			case "--import":
+				options.Import = true
+			default:
+				if options.MarkdownDir != "" {
+					log.Fatalf("多余的参数: %s\n%s", arg, usage)
+				}
+				options.MarkdownDir = arg
+			}
+		}
+		if options.MarkdownDir == "" || options.OutputDir == "" {
+			log.Fatalf(usage)
+		}
+		// 默认的已有CSV目录不存在时从头生成
+		if _, err := os.Stat(filepath.Join(options.BaseDir, "nodes.csv")); err != nil {
+			options.BaseDir = ""
+		}
+		if err := ConvertMarkdownRecipes(ctx, config, options); err != nil {
+			log.Fatalf("转换Markdown菜谱失败: %v", err)
+		}
+		return
+	}
+
+	// dedup-revert子命令：按审计报告撤销图索引快照中的去重
+	if command == "dedup-revert" {
+		reportPath := config.DedupReportPath
+		if len(args) > 1 {
+			reportPath = args[1]
+		}
+		if reportPath == "" {
+			log.Fatalf("用法: dedup-revert [去重报告路径]")
+		}
+		if err := RevertGraphIndexDedup(config, reportPath); err != nil {
+			log.Fatalf("撤销去重失败: %v", err)
+		}
+		return
 	}
 
 	// 创建高级图RAG系统
@@ -557,11 +1197,29 @@ func main() {
 		log.Fatalf("初始化失败: %v", err)
 	}
 
+	// sync子命令：增量同步知识库后退出
+	if command == "sync" {
+		if err := ragSystem.SyncKnowledgeBase(ctx); err != nil {
+			log.Fatalf("同步知识库失败: %v", err)
+		}
+		return
+	}
+
 	// 构建知识库
 	if err := ragSystem.BuildKnowledgeBase(ctx); err != nil {
 		log.Fatalf("构建知识库失败: %v", err)
 	}
 
+	// serve子命令：以HTTP服务方式运行，收到SIGINT/SIGTERM后优雅退出
+	if command == "serve" {
+		serveCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := NewAPIServer(ragSystem).Run(serveCtx); err != nil {
+			log.Fatalf("HTTP服务异常退出: %v", err)
+		}
+		return
+	}
+
 	// 运行交互式问答
 	ragSystem.RunInteractive(ctx)
 }
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	batch "batch-0001"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// chatModelName 以OpenAI协议对外提供的模型名称
+const chatModelName = "graph-rag-cooking"
+
+// chatCompletionRequest OpenAI /v1/chat/completions 请求，未列出的字段忽略
+type chatCompletionRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Stream      bool          `json:"stream"`
+	Temperature *float32      `json:"temperature,omitempty"`
+	MaxTokens   *int          `json:"max_tokens,omitempty"`
+}
+
+// chatMessage 请求中的一条消息，content可以是字符串或内容片段数组
+type chatMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// chatCompletionResponse 同步响应（object为chat.completion）和流式片段（chat.completion.chunk）
+type chatCompletionResponse struct {
+	ID      string                 `json:"id"`
+	Object  string                 `json:"object"`
+	Created int64                  `json:"created"`
+	Model   string                 `json:"model"`
+	Choices []chatCompletionChoice `json:"choices"`
+	Usage   *chatCompletionUsage   `json:"usage,omitempty"`
+}
+
+// chatCompletionChoice 同步响应使用Message，流式片段使用Delta
+type chatCompletionChoice struct {
+	Index        int                    `json:"index"`
+	Message      *chatCompletionMessage `json:"message,omitempty"`
+	Delta        *chatCompletionMessage `json:"delta,omitempty"`
+	FinishReason *string                `json:"finish_reason"`
+}
+
+// chatCompletionMessage 回答消息
+type chatCompletionMessage struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content"`
+}
+
+// chatCompletionUsage token用量，按字符估算
+type chatCompletionUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// openAIError OpenAI格式的错误响应
+type openAIError struct {
+	Error openAIErrorBody `json:"error"`
+}
+
+type openAIErrorBody struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+// handleModels 列出可用模型，供OpenAI客户端探测
+func (a *APIServer) handleModels(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"object": "list",
+		"data": []map[string]interface{}{
+			{"id": chatModelName, "object": "model", "owned_by": "graph-rag"},
+		},
+	})
+}
+
+// handleChatCompletions OpenAI兼容的对话接口
+//
+// 最后一条user消息作为本轮问题走智能路由检索和生成，之前的user/assistant消息作为对话历史，
+// 客户端的system消息不使用（系统提示词由生成模块决定）。temperature和max_tokens覆盖本次生成参数。
+// 忠实度模式下答案需要完整生成后才能检查，流式请求也只返回一个内容片段。
+func (a *APIServer) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var request chatCompletionRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+	if err := decoder.Decode(&request); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, fmt.Sprintf("解析请求失败: %v", err))
+		return
+	}
+	question, history, err := splitChatMessages(request.Messages)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if request.Temperature != nil && (*request.Temperature < 0 || *request.Temperature > 2) {
+		writeOpenAIError(w, http.StatusBadRequest, "temperature必须在0到2之间")
+		return
+	}
+	if request.MaxTokens != nil && *request.MaxTokens <= 0 {
+		writeOpenAIError(w, http.StatusBadRequest, "max_tokens必须大于0")
+		return
+	}
+
+	if a.rebuilding.Load() {
+		writeOpenAIError(w, http.StatusServiceUnavailable, "知识库正在重建，请稍后重试")
+		return
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if !a.system.systemReady.Load() {
+		writeOpenAIError(w, http.StatusServiceUnavailable, "系统未就绪，请先构建知识库")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), a.requestTimeout)
+	defer cancel()
+	ctx = batch.WithGenerationOptions(ctx, &batch.GenerationRequestOptions{
+		History:     history,
+		Temperature: request.Temperature,
+		MaxTokens:   request.MaxTokens,
+	})
+
+	model := request.Model
+	if model == "" {
+		model = chatModelName
+	}
+	completion := &chatCompletionResponse{
+		ID:      newCompletionID(),
+		Created: time.Now().Unix(),
+		Model:   model,
+	}
+
+	if request.Stream {
+		a.streamChatCompletion(ctx, cancel, w, completion, question)
+		return
+	}
+
+	answer, err := a.answer(ctx, question, nil)
+	if err != nil {
+		writeOpenAIError(w, contextErrorStatus(ctx), contextErrorMessage(ctx, err))
+		return
+	}
+
+	stop := "stop"
+	completion.Object = "chat.completion"
+	completion.Choices = []chatCompletionChoice{{
+		Message:      &chatCompletionMessage{Role: string(schema.Assistant), Content: answer},
+		FinishReason: &stop,
+	}}
+	completion.Usage = estimateUsage(request.Messages, answer)
+	writeJSON(w, http.StatusOK, completion)
+}
+
+// streamChatCompletion 以OpenAI SSE格式流式返回：首个片段带role，之后逐段返回内容，
+// 最后一个片段带finish_reason，以 data: [DONE] 结束
+func (a *APIServer) streamChatCompletion(ctx context.Context, cancel context.CancelFunc, w http.ResponseWriter, completion *chatCompletionResponse, question string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeOpenAIError(w, http.StatusInternalServerError, "当前连接不支持流式响应")
+		return
+	}
+	completion.Object = "chat.completion.chunk"
+
+	started := false
+	clientGone := false
+	send := func(delta *chatCompletionMessage, finishReason *string) {
+		if clientGone {
+			return
+		}
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("Connection", "keep-alive")
+			w.Header().Set("X-Accel-Buffering", "no")
+			w.WriteHeader(http.StatusOK)
+			// 首个片段只带角色
+			if !writeData(w, flusher, chunkOf(completion, &chatCompletionMessage{Role: string(schema.Assistant)}, nil)) {
+				clientGone = true
+				cancel()
+				return
+			}
+		}
+		// 客户端断开后取消生成，后续片段直接丢弃
+		if !writeData(w, flusher, chunkOf(completion, delta, finishReason)) {
+			clientGone = true
+			cancel()
+		}
+	}
+
+	_, err := a.answer(ctx, question, func(chunk string) {
+		send(&chatCompletionMessage{Content: chunk}, nil)
+	})
+	if clientGone {
+		return
+	}
+	if err != nil {
+		if !started {
+			// 尚未写出片段，仍可返回普通的错误响应
+			writeOpenAIError(w, contextErrorStatus(ctx), contextErrorMessage(ctx, err))
+			return
+		}
+		writeData(w, flusher, openAIError{Error: openAIErrorBody{Message: contextErrorMessage(ctx, err), Type: "server_error"}})
+		return
+	}
+	if err := ctx.Err(); err != nil {
+		writeData(w, flusher, openAIError{Error: openAIErrorBody{Message: contextErrorMessage(ctx, err), Type: "server_error"}})
+		return
+	}
+
+	stop := "stop"
+	send(&chatCompletionMessage{}, &stop)
+	if !clientGone {
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		flusher.Flush()
+	}
+}
+
+// answer 生成回答，onChunk不为nil时流式回调；忠实度模式下完整生成后一次性回调
+func (a *APIServer) answer(ctx context.Context, question string, onChunk func(string)) (string, error) {
+	if a.system.generationModule.GroundingEnabled() {
+		grounded, _, err := a.system.AskQuestionWithGrounding(ctx, question)
+		if err != nil {
+			return "", err
+		}
+		if onChunk != nil {
+			onChunk(grounded.Answer)
+		}
+		return grounded.Answer, nil
+	}
+	answer, _, err := a.system.AskQuestionStream(ctx, question, onChunk)
+	return answer, err
+}
+
+// splitChatMessages 取最后一条user消息作为问题，之前的user/assistant消息作为对话历史
+func splitChatMessages(messages []chatMessage) (string, []*schema.Message, error) {
+	last := -1
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == string(schema.User) {
+			last = i
+			break
+		}
+	}
+	if last < 0 {
+		return "", nil, fmt.Errorf("messages中缺少user消息")
+	}
+
+	question, err := messageText(messages[last].Content)
+	if err != nil {
+		return "", nil, err
+	}
+	if question = strings.TrimSpace(question); question == "" {
+		return "", nil, fmt.Errorf("最后一条user消息内容为空")
+	}
+
+	var history []*schema.Message
+	for _, message := range messages[:last] {
+		var role schema.RoleType
+		switch message.Role {
+		case string(schema.User):
+			role = schema.User
+		case string(schema.Assistant):
+			role = schema.Assistant
+		default:
+			continue
+		}
+		content, err := messageText(message.Content)
+		if err != nil {
+			return "", nil, err
+		}
+		if strings.TrimSpace(content) != "" {
+			history = append(history, &schema.Message{Role: role, Content: content})
+		}
+	}
+	return question, history, nil
+}
+
+// messageText 提取消息文本：字符串，或内容片段数组中所有text片段的拼接
+func messageText(content json.RawMessage) (string, error) {
+	if len(content) == 0 || string(content) == "null" {
+		return "", nil
+	}
+	var text string
+	if err := json.Unmarshal(content, &text); err == nil {
+		return text, nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(content, &parts); err != nil {
+		return "", fmt.Errorf("无法解析消息content: %w", err)
+	}
+	var texts []string
+	for _, part := range parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// chunkOf 生成一个流式片段
+func chunkOf(completion *chatCompletionResponse, delta *chatCompletionMessage, finishReason *string) *chatCompletionResponse {
+	chunk := *completion
+	chunk.Choices = []chatCompletionChoice{{Delta: delta, FinishReason: finishReason}}
+	return &chunk
+}
+
+// estimateUsage 按字符估算token用量
+func estimateUsage(messages []chatMessage, answer string) *chatCompletionUsage {
+	usage := &chatCompletionUsage{CompletionTokens: batch.EstimateTokens(answer)}
+	for _, message := range messages {
+		text, _ := messageText(message.Content)
+		usage.PromptTokens += batch.EstimateTokens(text)
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// newCompletionID 生成chatcmpl-前缀的随机ID
+func newCompletionID() string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano())
+	}
+	return "chatcmpl-" + hex.EncodeToString(buf)
+}
+
+// writeData 写入一条 data: 行，返回是否写入成功
+func writeData(w http.ResponseWriter, flusher http.Flusher, data interface{}) bool {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("序列化流式片段失败: %v", err)
+		return false
+	}
+	if _, err := fmt.Fprintf(w, "data: %s\n\n", payload); err != nil {
+		log.Printf("写入流式片段失败: %v", err)
+		return false
+	}
+	flusher.Flush()
+	return true
+}
+
+// writeOpenAIError 写入OpenAI格式的错误响应，错误信息中的密钥脱敏
+func writeOpenAIError(w http.ResponseWriter, status int, message string) {
+	errorType := "invalid_request_error"
+	if status >= http.StatusInternalServerError {
+		errorType = "server_error"
+	}
+	writeJSON(w, status, openAIError{Error: openAIErrorBody{Message: batch.RedactSecrets(message), Type: errorType}})
+}
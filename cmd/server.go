@@ -0,0 +1,502 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	batch "batch-0001"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 服务默认参数
+const (
+	defaultRequestTimeout = 2 * time.Minute
+	defaultRebuildTimeout = time.Hour
+	serverShutdownTimeout = 30 * time.Second
+	maxRequestBodyBytes   = 1 << 20
+	streamChunkBuffer     = 100
+)
+
+// APIServer 图RAG系统的HTTP接口
+//
+// 接口列表：
+//
+//	POST /api/query           同步问答
+//	POST /api/query/stream    流式问答（SSE）
+//	POST /api/route/explain   解释路由决策
+//	GET  /api/stats           路由与知识库统计
+//	POST /api/rebuild         重建知识库
+//	GET  /healthz             健康检查
+//	POST /v1/chat/completions OpenAI兼容的对话接口
+//	GET  /v1/models           OpenAI兼容的模型列表
+//
+// 每个请求使用独立的超时上下文，错误统一返回JSON。问答请求之间并发执行，
+// 重建时等待进行中的问答完成，重建期间的新请求直接返回503。
+type APIServer struct {
+	system         *AdvancedGraphRAGSystem
+	addr           string
+	requestTimeout time.Duration
+	rebuildTimeout time.Duration
+	baseCtx        context.Context // 服务的基础上下文，重建不随请求断开而取消
+
+	mu         sync.RWMutex // 问答和统计持读锁，重建持写锁
+	rebuilding atomic.Bool  // 是否正在重建，重建期间拒绝新请求
+}
+
+// queryRequest 问答请求
+type queryRequest struct {
+	Question string `json:"question"`
+}
+
+// querySource 回答引用的检索文档
+type querySource struct {
+	RecipeName string  `json:"recipe_name"`
+	SearchType string  `json:"search_type"`
+	Score      float64 `json:"score"`
+	Content    string  `json:"content"`
+}
+
+// queryResponse 同步问答响应
+type queryResponse struct {
+	Answer     string                `json:"answer"`
+	Analysis   *batch.QueryAnalysis  `json:"analysis,omitempty"`
+	Grounding  *batch.GroundedAnswer `json:"grounding,omitempty"` // 忠实度模式下的逐句检查结果
+	Sources    []querySource         `json:"sources"`
+	DurationMs int64                 `json:"duration_ms"`
+}
+
+// errorResponse 错误响应
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewAPIServer 创建HTTP服务，系统需已完成初始化和知识库构建
+func NewAPIServer(system *AdvancedGraphRAGSystem) *APIServer {
+	server := &APIServer{
+		system:         system,
+		addr:           system.config.ServerAddr,
+		requestTimeout: time.Duration(system.config.RequestTimeoutSeconds) * time.Second,
+		rebuildTimeout: time.Duration(system.config.RebuildTimeoutSeconds) * time.Second,
+		baseCtx:        context.Background(),
+	}
+	if server.addr == "" {
+		server.addr = ":8080"
+	}
+	if server.requestTimeout <= 0 {
+		server.requestTimeout = defaultRequestTimeout
+	}
+	if server.rebuildTimeout <= 0 {
+		server.rebuildTimeout = defaultRebuildTimeout
+	}
+	return server
+}
+
+// Handler 返回路由好的HTTP处理器
+func (a *APIServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/query", a.allow(http.MethodPost, a.handleQuery))
+	mux.HandleFunc("/api/query/stream", a.allow(http.MethodPost, a.handleQueryStream))
+	mux.HandleFunc("/api/route/explain", a.allow(http.MethodPost, a.handleExplain))
+	mux.HandleFunc("/api/stats", a.allow(http.MethodGet, a.handleStats))
+	mux.HandleFunc("/api/rebuild", a.allow(http.MethodPost, a.handleRebuild))
+	mux.HandleFunc("/healthz", a.allow(http.MethodGet, a.handleHealth))
+
+	// OpenAI兼容接口
+	mux.HandleFunc("/v1/chat/completions", a.allow(http.MethodPost, a.handleChatCompletions))
+	mux.HandleFunc("/v1/models", a.allow(http.MethodGet, a.handleModels))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("接口不存在: %s", r.URL.Path))
+	})
+	return mux
+}
+
+// Run 启动HTTP服务，ctx取消后停止接收新连接，并等待进行中的请求完成
+func (a *APIServer) Run(ctx context.Context) error {
+	a.baseCtx = ctx
+	server := &http.Server{
+		Addr:              a.addr,
+		Handler:           a.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		// 不设置WriteTimeout，流式问答和重建的耗时由请求超时控制
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("HTTP服务已启动: %s", a.addr)
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("HTTP服务启动失败: %w", err)
+	case <-ctx.Done():
+	}
+
+	log.Println("正在关闭HTTP服务，等待进行中的请求完成...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("关闭HTTP服务失败: %w", err)
+	}
+	log.Println("HTTP服务已关闭")
+	return nil
+}
+
+// handleQuery 同步问答
+func (a *APIServer) handleQuery(w http.ResponseWriter, r *http.Request) {
+	question, ok := decodeQuestion(w, r)
+	if !ok {
+		return
+	}
+	release, ok := a.acquire(w)
+	if !ok {
+		return
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(r.Context(), a.requestTimeout)
+	defer cancel()
+
+	startTime := time.Now()
+	documents, analysis, err := a.system.retrieveWithRouting(ctx, question)
+	if err != nil {
+		writeContextError(w, ctx, err)
+		return
+	}
+
+	response := &queryResponse{Analysis: analysis, Sources: sourcesOf(documents)}
+	switch {
+	case len(documents) == 0:
+		response.Answer = noRelevantAnswer
+	case a.system.generationModule.GroundingEnabled():
+		grounded, err := a.system.generationModule.GenerateGroundedAnswer(ctx, question, documents)
+		if err != nil {
+			writeContextError(w, ctx, fmt.Errorf("生成回答失败: %w", err))
+			return
+		}
+		response.Answer = grounded.Answer
+		response.Grounding = grounded
+	default:
+		answer, err := a.system.generationModule.GenerateAdaptiveAnswer(ctx, question, documents)
+		if err != nil {
+			writeContextError(w, ctx, fmt.Errorf("生成回答失败: %w", err))
+			return
+		}
+		response.Answer = answer
+	}
+	response.DurationMs = time.Since(startTime).Milliseconds()
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleQueryStream 流式问答，以SSE事件依次返回：
+//
+//	analysis  路由分析结果
+//	sources   检索到的文档
+//	chunk     回答片段 {"content": "..."}
+//	status    重试、降级等进度提示，不属于回答内容 {"message": "..."}
+//	done      结束 {"duration_ms": 123}
+//	error     出错 {"error": "..."}
+//
+// 忠实度模式下答案需要完整生成后才能检查，整段答案作为一个chunk返回。
+func (a *APIServer) handleQueryStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "当前连接不支持流式响应")
+		return
+	}
+	question, ok := decodeQuestion(w, r)
+	if !ok {
+		return
+	}
+	release, ok := a.acquire(w)
+	if !ok {
+		return
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(r.Context(), a.requestTimeout)
+	defer cancel()
+
+	startTime := time.Now()
+	documents, analysis, err := a.system.retrieveWithRouting(ctx, question)
+	if err != nil {
+		// 尚未写出事件，仍可返回普通的JSON错误
+		writeContextError(w, ctx, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event string, data interface{}) bool {
+		if err := writeEvent(w, event, data); err != nil {
+			log.Printf("写入SSE事件失败: %v", err)
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+	done := func() {
+		send("done", map[string]int64{"duration_ms": time.Since(startTime).Milliseconds()})
+	}
+
+	if !send("analysis", analysis) || !send("sources", sourcesOf(documents)) {
+		return
+	}
+
+	switch {
+	case len(documents) == 0:
+		send("chunk", map[string]string{"content": noRelevantAnswer})
+		done()
+		return
+	case a.system.generationModule.GroundingEnabled():
+		grounded, err := a.system.generationModule.GenerateGroundedAnswer(ctx, question, documents)
+		if err != nil {
+			send("error", errorResponse{Error: contextErrorMessage(ctx, fmt.Errorf("生成回答失败: %w", err))})
+			return
+		}
+		send("chunk", map[string]interface{}{"content": grounded.Answer, "grounding": grounded})
+		done()
+		return
+	}
+
+	resultChan := make(chan batch.StreamEvent, streamChunkBuffer)
+	go a.system.generationModule.GenerateAdaptiveAnswerStream(ctx, question, documents, 3, resultChan)
+
+	clientGone, failed := false, false
+	for event := range resultChan {
+		// 客户端断开或生成失败后继续读完通道，让生成协程正常退出
+		if clientGone || failed {
+			continue
+		}
+		var ok bool
+		switch event.Type {
+		case batch.StreamEventChunk:
+			ok = send("chunk", map[string]string{"content": event.Content})
+		case batch.StreamEventStatus:
+			ok = send("status", map[string]string{"message": event.Content})
+		case batch.StreamEventError:
+			failed = true
+			ok = send("error", errorResponse{Error: contextErrorMessage(ctx, event.Err)})
+		}
+		if !ok {
+			clientGone = true
+			cancel()
+		}
+	}
+	if clientGone || failed {
+		return
+	}
+	if err := ctx.Err(); err != nil {
+		send("error", errorResponse{Error: contextErrorMessage(ctx, err)})
+		return
+	}
+	done()
+}
+
+// handleExplain 解释路由决策，不执行检索
+func (a *APIServer) handleExplain(w http.ResponseWriter, r *http.Request) {
+	question, ok := decodeQuestion(w, r)
+	if !ok {
+		return
+	}
+	release, ok := a.acquire(w)
+	if !ok {
+		return
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(r.Context(), a.requestTimeout)
+	defer cancel()
+
+	router := a.system.queryRouter
+	analysis, err := router.AnalyzeQuery(ctx, question)
+	if err != nil {
+		writeContextError(w, ctx, fmt.Errorf("分析查询失败: %w", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"question":    question,
+		"analysis":    analysis,
+		"explanation": router.ExplainRoutingDecision(ctx, question),
+	})
+}
+
+// handleStats 路由统计和知识库统计
+func (a *APIServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	release, ok := a.acquire(w)
+	if !ok {
+		return
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(r.Context(), a.requestTimeout)
+	defer cancel()
+
+	stats := map[string]interface{}{
+		"routing":   a.system.queryRouter.GetRouteStatistics(),
+		"knowledge": a.system.dataModule.GetStatistics(),
+	}
+	if collectionStats, err := a.system.indexModule.GetCollectionStats(ctx); err == nil {
+		stats["vector_index"] = collectionStats
+	} else {
+		stats["vector_index"] = errorResponse{Error: batch.RedactError(err)}
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleRebuild 重建知识库，同一时间只允许一个重建请求
+func (a *APIServer) handleRebuild(w http.ResponseWriter, r *http.Request) {
+	if !a.rebuilding.CompareAndSwap(false, true) {
+		writeError(w, http.StatusConflict, "知识库正在重建")
+		return
+	}
+	defer a.rebuilding.Store(false)
+
+	// 等待进行中的问答完成
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// 重建会先删除集合，客户端断开时不能中途取消
+	ctx, cancel := context.WithTimeout(a.baseCtx, a.rebuildTimeout)
+	defer cancel()
+
+	startTime := time.Now()
+	if err := a.system.RebuildKnowledgeBase(ctx); err != nil {
+		writeContextError(w, ctx, fmt.Errorf("重建知识库失败: %w", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":      "rebuilt",
+		"duration_ms": time.Since(startTime).Milliseconds(),
+	})
+}
+
+// handleHealth 健康检查，系统未就绪或正在重建时返回503
+func (a *APIServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case a.rebuilding.Load():
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "rebuilding"})
+	case !a.system.systemReady.Load():
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "not_ready"})
+	default:
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	}
+}
+
+// acquire 获取读锁，正在重建或系统未就绪时写入503并返回false
+func (a *APIServer) acquire(w http.ResponseWriter) (release func(), ok bool) {
+	if a.rebuilding.Load() {
+		writeError(w, http.StatusServiceUnavailable, "知识库正在重建，请稍后重试")
+		return nil, false
+	}
+	a.mu.RLock()
+	if !a.system.systemReady.Load() {
+		a.mu.RUnlock()
+		writeError(w, http.StatusServiceUnavailable, "系统未就绪，请先构建知识库")
+		return nil, false
+	}
+	return a.mu.RUnlock, true
+}
+
+// allow 限定请求方法，其他方法返回405
+func (a *APIServer) allow(method string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("不支持的请求方法: %s", r.Method))
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// decodeQuestion 解析请求体中的问题，失败时写入400并返回false
+func decodeQuestion(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var request queryRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+	if err := decoder.Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("解析请求失败: %v", err))
+		return "", false
+	}
+	question := strings.TrimSpace(request.Question)
+	if question == "" {
+		writeError(w, http.StatusBadRequest, "question不能为空")
+		return "", false
+	}
+	return question, true
+}
+
+// sourcesOf 提取文档的来源信息
+func sourcesOf(documents []*schema.Document) []querySource {
+	sources := make([]querySource, 0, len(documents))
+	for _, doc := range documents {
+		source := querySource{Content: doc.Content}
+		source.RecipeName, _ = doc.MetaData["recipe_name"].(string)
+		source.SearchType, _ = doc.MetaData["search_type"].(string)
+		source.Score, _ = doc.MetaData["final_score"].(float64)
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+// writeJSON 写入JSON响应
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("写入响应失败: %v", err)
+	}
+}
+
+// writeError 写入JSON错误响应，错误信息中的密钥脱敏
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: batch.RedactSecrets(message)})
+}
+
+// writeContextError 写入错误响应，状态码见contextErrorStatus
+func writeContextError(w http.ResponseWriter, ctx context.Context, err error) {
+	writeError(w, contextErrorStatus(ctx), contextErrorMessage(ctx, err))
+}
+
+// contextErrorStatus 请求超时返回504，客户端取消返回499，其他错误返回500
+func contextErrorStatus(ctx context.Context) int {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(ctx.Err(), context.Canceled):
+		return 499 // 客户端已断开，沿用nginx的约定
+	}
+	return http.StatusInternalServerError
+}
+
+// contextErrorMessage 超时或取消时给出明确的错误信息，密钥脱敏
+func contextErrorMessage(ctx context.Context, err error) string {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return batch.RedactSecrets(fmt.Sprintf("请求超时: %v", err))
+	}
+	return batch.RedactError(err)
+}
+
+// writeEvent 写入一个SSE事件，数据编码为单行JSON
+func writeEvent(w http.ResponseWriter, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("序列化事件数据失败: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
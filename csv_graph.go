@@ -0,0 +1,689 @@
+package batch_0001
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// hierarchyNodeIDBoundary 层次结构节点与具体实例的分界：nodeId按字符串比较小于该值的是层次结构节点
+const hierarchyNodeIDBoundary = "200000000"
+
+// relationships.csv 中的关系类型编码
+const (
+	relationCodeRequires        = "801000001" // 菜谱需要食材
+	relationCodeContainsStep    = "801000003" // 菜谱包含步骤
+	relationCodeBelongsTo       = "801000004" // 属于分类
+	relationCodeDifficultyLevel = "801000005" // 难度等级
+)
+
+// difficultyLevels 难度等级节点名称对应的等级
+var difficultyLevels = map[string]int{
+	"一星": 1, "二星": 2, "三星": 3, "四星": 4, "五星": 5,
+}
+
+// relationTypeForCode 将关系类型编码映射为图中的关系类型，未知编码原样使用
+func relationTypeForCode(code string) string {
+	switch code {
+	case relationCodeRequires:
+		return "REQUIRES"
+	case relationCodeContainsStep:
+		return "CONTAINS_STEP"
+	case relationCodeBelongsTo:
+		return "BELONGS_TO"
+	case relationCodeDifficultyLevel:
+		return "DIFFICULTY_LEVEL"
+	default:
+		return code
+	}
+}
+
+// knownRelationCodes 导入脚本定义了映射的关系类型编码
+var knownRelationCodes = map[string]bool{
+	relationCodeRequires:        true,
+	relationCodeContainsStep:    true,
+	relationCodeBelongsTo:       true,
+	relationCodeDifficultyLevel: true,
+}
+
+// CSVValidation CSV数据校验结果
+//
+// 悬空关系和标签不匹配的关系不会进入内存图；未知编码的关系按导入脚本的规则以编码作为关系类型保留。
+type CSVValidation struct {
+	InvalidNodeRows      int            `json:"invalid_node_rows"`      // 缺少nodeId或名称的节点行
+	DuplicateNodeIDs     []string       `json:"duplicate_node_ids"`     // 重复的nodeId，只保留第一行
+	InvalidRelationRows  int            `json:"invalid_relation_rows"`  // 缺少端点或类型的关系行
+	DanglingRelations    []string       `json:"dangling_relations"`     // 端点节点不存在的关系
+	LabelMismatches      []string       `json:"label_mismatches"`       // 端点标签与关系类型不匹配的关系
+	UnknownRelationCodes map[string]int `json:"unknown_relation_codes"` // 未知关系类型编码 -> 出现次数
+}
+
+// HasErrors 是否存在会导致关系丢失或类型错误的问题
+func (v *CSVValidation) HasErrors() bool {
+	return len(v.DanglingRelations) > 0 || len(v.LabelMismatches) > 0 || len(v.UnknownRelationCodes) > 0
+}
+
+// String 校验结果的可读描述
+func (v *CSVValidation) String() string {
+	var parts []string
+	if v.InvalidNodeRows > 0 {
+		parts = append(parts, fmt.Sprintf("无效节点行 %d", v.InvalidNodeRows))
+	}
+	if len(v.DuplicateNodeIDs) > 0 {
+		parts = append(parts, fmt.Sprintf("重复节点 %d", len(v.DuplicateNodeIDs)))
+	}
+	if v.InvalidRelationRows > 0 {
+		parts = append(parts, fmt.Sprintf("无效关系行 %d", v.InvalidRelationRows))
+	}
+	if len(v.DanglingRelations) > 0 {
+		parts = append(parts, fmt.Sprintf("悬空关系 %d（如 %s）", len(v.DanglingRelations), v.DanglingRelations[0]))
+	}
+	if len(v.LabelMismatches) > 0 {
+		parts = append(parts, fmt.Sprintf("标签不匹配的关系 %d（如 %s）", len(v.LabelMismatches), v.LabelMismatches[0]))
+	}
+	if len(v.UnknownRelationCodes) > 0 {
+		codes := make([]string, 0, len(v.UnknownRelationCodes))
+		for code, count := range v.UnknownRelationCodes {
+			codes = append(codes, fmt.Sprintf("%s×%d", code, count))
+		}
+		sort.Strings(codes)
+		parts = append(parts, "未知关系编码 "+strings.Join(codes, ", "))
+	}
+	if len(parts) == 0 {
+		return "无问题"
+	}
+	return strings.Join(parts, "；")
+}
+
+// CSVGraph 从 nodes.csv / relationships.csv 解析出的内存图
+//
+// 按 neo4j_import.cypher 的规则构建：层次结构节点与实例节点的区分、菜谱/食材/步骤的属性类型转换、
+// 关系类型编码映射，以及由数据派生的分类、概念类型、难度等级、步骤顺序关系和计算属性。
+// 脚本中按LIMIT截断的相似性关系（SIMILAR、USES_SAME_TOOL、USES_SAME_METHOD）和统计节点不参与检索，不做构建。
+type CSVGraph struct {
+	Nodes      []GraphNode     // 全部节点，按文件顺序，派生的分类和概念类型节点在最后
+	Relations  []GraphRelation // 全部关系
+	Validation CSVValidation   // 数据校验结果
+
+	nodeIndex map[string]int   // nodeId -> Nodes下标
+	outgoing  map[string][]int // 起始nodeId -> Relations下标
+}
+
+// LoadCSVGraph 读取节点和关系CSV文件并构建内存图
+func LoadCSVGraph(nodesPath, relationshipsPath string) (*CSVGraph, error) {
+	nodeRows, err := readCSVRows(nodesPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取节点文件失败: %w", err)
+	}
+	relationRows, err := readCSVRows(relationshipsPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取关系文件失败: %w", err)
+	}
+
+	graph := NewCSVGraphFromRows(nodeRows, relationRows)
+	log.Printf("从CSV加载图数据: %d 个节点, %d 条关系", len(graph.Nodes), len(graph.Relations))
+	if graph.Validation.HasErrors() {
+		log.Printf("CSV数据校验发现问题: %s", graph.Validation.String())
+	}
+	return graph, nil
+}
+
+// NewCSVGraphFromRows 从 列名->值 形式的节点行和关系行构建内存图，转换规则与 LoadCSVGraph 相同
+func NewCSVGraphFromRows(nodeRows, relationRows []map[string]string) *CSVGraph {
+	graph := &CSVGraph{
+		Validation: CSVValidation{UnknownRelationCodes: make(map[string]int)},
+		nodeIndex:  make(map[string]int),
+		outgoing:   make(map[string][]int),
+	}
+	for _, row := range nodeRows {
+		graph.addNodeRow(row)
+	}
+	for _, row := range relationRows {
+		graph.addRelationRow(row)
+	}
+	graph.deriveStructure()
+	return graph
+}
+
+// readCSVRows 读取带表头的CSV文件，每行转换为 列名->值 的映射，空值不出现在映射中
+func readCSVRows(path string) ([]map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("读取表头失败: %w", err)
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]string, len(header))
+		for i, value := range record {
+			if i < len(header) && value != "" {
+				row[header[i]] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Node 按nodeId获取节点
+func (c *CSVGraph) Node(nodeID string) (*GraphNode, bool) {
+	index, ok := c.nodeIndex[nodeID]
+	if !ok {
+		return nil, false
+	}
+	return &c.Nodes[index], true
+}
+
+// NodesByLabel 获取指定标签的实例节点（不含层次结构节点），按nodeId排序
+func (c *CSVGraph) NodesByLabel(label string) []GraphNode {
+	var nodes []GraphNode
+	for _, node := range c.Nodes {
+		if node.NodeID >= hierarchyNodeIDBoundary && hasLabel(node, label) {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].NodeID < nodes[j].NodeID })
+	return nodes
+}
+
+// Outgoing 获取节点指定类型的出边，relationType为空时返回全部出边
+func (c *CSVGraph) Outgoing(nodeID, relationType string) []GraphRelation {
+	var relations []GraphRelation
+	for _, index := range c.outgoing[nodeID] {
+		if relationType == "" || c.Relations[index].RelationType == relationType {
+			relations = append(relations, c.Relations[index])
+		}
+	}
+	return relations
+}
+
+// addNodeRow 按节点类型转换一行节点数据
+func (c *CSVGraph) addNodeRow(row map[string]string) {
+	nodeID, name, label := row["nodeId"], row["name"], row["labels"]
+	if nodeID == "" || name == "" {
+		c.Validation.InvalidNodeRows++
+		return
+	}
+	if _, exists := c.nodeIndex[nodeID]; exists {
+		c.Validation.DuplicateNodeIDs = append(c.Validation.DuplicateNodeIDs, nodeID)
+		return
+	}
+
+	var keys []string
+	switch {
+	case nodeID < hierarchyNodeIDBoundary:
+		keys = []string{"preferredTerm", "fsn", "conceptType", "synonyms", "category", "description"}
+	case label == "Recipe":
+		keys = []string{"preferredTerm", "fsn", "description", "category", "conceptType", "cuisineType",
+			"prepTime", "cookTime", "servings", "tags", "filePath", "synonyms"}
+	case label == "Ingredient":
+		keys = []string{"preferredTerm", "fsn", "description", "category", "conceptType", "amount", "unit", "synonyms"}
+	case label == "CookingStep":
+		keys = []string{"preferredTerm", "fsn", "description", "category", "conceptType",
+			"methods", "tools", "timeEstimate", "synonyms"}
+	default:
+		keys = []string{"description", "category", "conceptType"}
+	}
+
+	properties := map[string]interface{}{
+		"nodeId":         nodeID,
+		"name":           name,
+		"originalLabels": label,
+	}
+	for _, key := range keys {
+		if value, ok := row[key]; ok {
+			properties[key] = value
+		}
+	}
+
+	switch {
+	case nodeID < hierarchyNodeIDBoundary:
+		properties["isHierarchyNode"] = true
+	case label == "Recipe":
+		if difficulty, err := strconv.ParseFloat(row["difficulty"], 64); err == nil {
+			properties["difficulty"] = difficulty
+		}
+		properties["textForEmbedding"] = strings.Join([]string{
+			name, row["preferredTerm"], row["description"], row["tags"],
+		}, " ")
+	case label == "Ingredient":
+		if isMain, err := strconv.ParseBool(row["isMain"]); err == nil {
+			properties["isMain"] = isMain
+		}
+	case label == "CookingStep":
+		if stepNumber, err := strconv.ParseFloat(row["stepNumber"], 64); err == nil {
+			properties["stepNumber"] = stepNumber
+		}
+	}
+
+	c.addNode(GraphNode{
+		NodeID:     nodeID,
+		Labels:     []string{label},
+		Name:       name,
+		Properties: properties,
+	})
+}
+
+// addRelationRow 按关系类型编码转换一行关系数据
+//
+// 需要食材、包含步骤关系要求两端节点标签匹配且同一对节点只保留一条；其他关系只要求两端节点存在。
+func (c *CSVGraph) addRelationRow(row map[string]string) {
+	startID, endID, code := row["startNodeId"], row["endNodeId"], row["relationshipType"]
+	if startID == "" || endID == "" || code == "" {
+		c.Validation.InvalidRelationRows++
+		return
+	}
+	description := fmt.Sprintf("%s(%s->%s)", row["relationshipId"], startID, endID)
+	start, startOK := c.Node(startID)
+	end, endOK := c.Node(endID)
+	if !startOK || !endOK {
+		c.Validation.DanglingRelations = append(c.Validation.DanglingRelations, description)
+		return
+	}
+	if !knownRelationCodes[code] {
+		c.Validation.UnknownRelationCodes[code]++
+	}
+
+	relationType := relationTypeForCode(code)
+	properties := map[string]interface{}{
+		"relationshipId": row["relationshipId"],
+		"originalType":   code,
+	}
+	if stepOrder, err := strconv.ParseFloat(row["step_order"], 64); err == nil {
+		properties["stepOrder"] = stepOrder
+	}
+
+	if code != relationCodeContainsStep {
+		for _, key := range []string{"amount", "unit"} {
+			if value, ok := row[key]; ok {
+				properties[key] = value
+			}
+		}
+	}
+
+	switch code {
+	case relationCodeRequires:
+		if !hasLabel(*start, "Recipe") || !hasLabel(*end, "Ingredient") {
+			c.Validation.LabelMismatches = append(c.Validation.LabelMismatches, description)
+			return
+		}
+		delete(properties, "stepOrder")
+	case relationCodeContainsStep:
+		if !hasLabel(*start, "Recipe") || !hasLabel(*end, "CookingStep") {
+			c.Validation.LabelMismatches = append(c.Validation.LabelMismatches, description)
+			return
+		}
+	default:
+		c.addRelation(startID, endID, relationType, properties)
+		return
+	}
+
+	c.mergeRelation(startID, endID, relationType, properties)
+}
+
+// deriveStructure 构建导入脚本中由数据派生的节点、关系和属性
+func (c *CSVGraph) deriveStructure() {
+	nodeCount := len(c.Nodes)
+
+	// 分类节点（支持逗号分隔的多重分类）和概念类型节点
+	for i := 0; i < nodeCount; i++ {
+		node := c.Nodes[i]
+		for _, category := range strings.Split(getStringFromMap(node.Properties, "category", ""), ",") {
+			if category = strings.TrimSpace(category); category != "" {
+				categoryID := c.ensureNamedNode("Category", category)
+				c.mergeRelation(node.NodeID, categoryID, "BELONGS_TO_CATEGORY", nil)
+			}
+		}
+		if conceptType := getStringFromMap(node.Properties, "conceptType", ""); conceptType != "" {
+			conceptID := c.ensureNamedNode("ConceptType", conceptType)
+			c.mergeRelation(node.NodeID, conceptID, "HAS_CONCEPT_TYPE", nil)
+		}
+		if synonyms := getStringFromMap(node.Properties, "synonyms", ""); synonyms != "" && synonyms != "[]" {
+			node.Properties["hasSynonyms"] = true
+		}
+	}
+
+	// 难度等级
+	levelNodes := make(map[int]string)
+	for i := 0; i < nodeCount; i++ {
+		if node := c.Nodes[i]; hasLabel(node, "DifficultyLevel") {
+			level := difficultyLevels[node.Name]
+			node.Properties["level"] = level
+			levelNodes[level] = node.NodeID
+		}
+	}
+
+	for i := 0; i < nodeCount; i++ {
+		recipe := c.Nodes[i]
+		if !hasLabel(recipe, "Recipe") {
+			continue
+		}
+
+		if difficulty, ok := recipe.Properties["difficulty"].(float64); ok {
+			if levelID, ok := levelNodes[int(difficulty)]; ok {
+				c.mergeRelation(recipe.NodeID, levelID, "HAS_DIFFICULTY_LEVEL", nil)
+			}
+		}
+
+		// 相邻编号步骤之间的顺序关系
+		steps := c.Outgoing(recipe.NodeID, "CONTAINS_STEP")
+		for _, first := range steps {
+			for _, second := range steps {
+				firstNode, _ := c.Node(first.EndNodeID)
+				secondNode, _ := c.Node(second.EndNodeID)
+				firstNumber, ok1 := firstNode.Properties["stepNumber"].(float64)
+				secondNumber, ok2 := secondNode.Properties["stepNumber"].(float64)
+				if ok1 && ok2 && secondNumber == firstNumber+1 {
+					c.mergeRelation(firstNode.NodeID, secondNode.NodeID, "NEXT_STEP", map[string]interface{}{
+						"sequence":       true,
+						"timeDifference": secondNumber - firstNumber,
+					})
+				}
+			}
+		}
+
+		// 计算属性：总时间、食材数量、步骤数量
+		if totalTime, ok := csvTotalTime(recipe.Properties); ok {
+			recipe.Properties["totalTime"] = totalTime
+		}
+		recipe.Properties["ingredientCount"] = len(c.Outgoing(recipe.NodeID, "REQUIRES"))
+		recipe.Properties["stepCount"] = len(steps)
+	}
+}
+
+// csvTotalTime 按导入脚本的规则计算总时间：准备时间和烹饪时间都以"N分钟"开头时求和
+func csvTotalTime(properties map[string]interface{}) (int, bool) {
+	total := 0
+	for _, key := range []string{"prepTime", "cookTime"} {
+		value := getStringFromMap(properties, key, "")
+		if !strings.Contains(value, "分钟") {
+			return 0, false
+		}
+		minutes, err := strconv.Atoi(strings.TrimSpace(strings.Split(value, "分钟")[0]))
+		if err != nil {
+			return 0, false
+		}
+		total += minutes
+	}
+	return total, true
+}
+
+// ensureNamedNode 获取或创建按名称合并的派生节点（分类、概念类型），返回其nodeId
+func (c *CSVGraph) ensureNamedNode(label, name string) string {
+	nodeID := derivedNodeID(label, name)
+	if _, ok := c.nodeIndex[nodeID]; !ok {
+		c.addNode(GraphNode{
+			NodeID:     nodeID,
+			Labels:     []string{label},
+			Name:       name,
+			Properties: map[string]interface{}{"name": name},
+		})
+	}
+	return nodeID
+}
+
+// derivedNodeID 派生节点没有CSV中的nodeId，使用"标签:名称"作为标识
+func derivedNodeID(label, name string) string {
+	return label + ":" + name
+}
+
+func (c *CSVGraph) addNode(node GraphNode) {
+	c.nodeIndex[node.NodeID] = len(c.Nodes)
+	c.Nodes = append(c.Nodes, node)
+}
+
+func (c *CSVGraph) addRelation(startID, endID, relationType string, properties map[string]interface{}) {
+	if properties == nil {
+		properties = make(map[string]interface{})
+	}
+	c.outgoing[startID] = append(c.outgoing[startID], len(c.Relations))
+	c.Relations = append(c.Relations, GraphRelation{
+		StartNodeID:  startID,
+		EndNodeID:    endID,
+		RelationType: relationType,
+		Properties:   properties,
+	})
+}
+
+// mergeRelation 同一对节点之间同类型的关系只保留一条，重复时更新属性
+func (c *CSVGraph) mergeRelation(startID, endID, relationType string, properties map[string]interface{}) {
+	for _, index := range c.outgoing[startID] {
+		relation := &c.Relations[index]
+		if relation.EndNodeID == endID && relation.RelationType == relationType {
+			for k, v := range properties {
+				relation.Properties[k] = v
+			}
+			return
+		}
+	}
+	c.addRelation(startID, endID, relationType, properties)
+}
+
+// hasLabel 判断节点是否带有指定标签
+func hasLabel(node GraphNode, label string) bool {
+	for _, l := range node.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// recipeCategories 获取菜谱所属的分类名称，按关系创建顺序
+func (c *CSVGraph) recipeCategories(recipeID string) []interface{} {
+	var categories []interface{}
+	for _, relation := range c.Outgoing(recipeID, "BELONGS_TO_CATEGORY") {
+		if category, ok := c.Node(relation.EndNodeID); ok {
+			categories = append(categories, category.Name)
+		}
+	}
+	return categories
+}
+
+// recipeNodes 以LoadGraphData相同的形式返回菜谱节点：附加主分类和全部分类
+func (c *CSVGraph) recipeNodes() []GraphNode {
+	recipes := c.NodesByLabel("Recipe")
+	for i, recipe := range recipes {
+		properties := make(map[string]interface{}, len(recipe.Properties)+2)
+		for k, v := range recipe.Properties {
+			properties[k] = v
+		}
+
+		categories := c.recipeCategories(recipe.NodeID)
+		if len(categories) == 0 {
+			categories = []interface{}{getStringFromMap(recipe.Properties, "category", "未知")}
+		}
+		properties["category"] = categories[0]
+		properties["all_categories"] = categories
+
+		recipes[i].Properties = properties
+	}
+	return recipes
+}
+
+// linkedEntries 以recipeDetailsQuery相同的字段返回菜谱关联的食材（按名称排序）和步骤（按顺序排序）
+func (c *CSVGraph) linkedEntries(recipeID string) (ingredients, steps []map[string]interface{}) {
+	type linked struct {
+		node     *GraphNode
+		relation GraphRelation
+	}
+
+	var ingredientLinks, stepLinks []linked
+	for _, relation := range c.Outgoing(recipeID, "") {
+		node, ok := c.Node(relation.EndNodeID)
+		if !ok {
+			continue
+		}
+		switch {
+		case relation.RelationType == "REQUIRES" && hasLabel(*node, "Ingredient"):
+			ingredientLinks = append(ingredientLinks, linked{node, relation})
+		case relation.RelationType == "CONTAINS_STEP" && hasLabel(*node, "CookingStep"):
+			stepLinks = append(stepLinks, linked{node, relation})
+		}
+	}
+
+	sort.SliceStable(ingredientLinks, func(i, j int) bool {
+		return ingredientLinks[i].node.Name < ingredientLinks[j].node.Name
+	})
+	stepOrder := func(link linked) float64 {
+		if order, ok := link.relation.Properties["stepOrder"].(float64); ok {
+			return order
+		}
+		if number, ok := link.node.Properties["stepNumber"].(float64); ok {
+			return number
+		}
+		return 999
+	}
+	sort.SliceStable(stepLinks, func(i, j int) bool {
+		return stepOrder(stepLinks[i]) < stepOrder(stepLinks[j])
+	})
+
+	for _, link := range ingredientLinks {
+		ingredients = append(ingredients, map[string]interface{}{
+			"nodeId":      link.node.NodeID,
+			"name":        link.node.Name,
+			"amount":      link.relation.Properties["amount"],
+			"unit":        link.relation.Properties["unit"],
+			"description": link.node.Properties["description"],
+		})
+	}
+	for _, link := range stepLinks {
+		steps = append(steps, map[string]interface{}{
+			"nodeId":       link.node.NodeID,
+			"name":         link.node.Name,
+			"description":  link.node.Properties["description"],
+			"methods":      link.node.Properties["methods"],
+			"tools":        link.node.Properties["tools"],
+			"timeEstimate": link.node.Properties["timeEstimate"],
+		})
+	}
+	return ingredients, steps
+}
+
+// recipeDetails 由内存图生成菜谱的食材和步骤描述文本
+func (c *CSVGraph) recipeDetails(recipes []GraphNode) map[string]*recipeDetails {
+	details := make(map[string]*recipeDetails, len(recipes))
+	for _, recipe := range recipes {
+		ingredients, steps := c.linkedEntries(recipe.NodeID)
+
+		detail := &recipeDetails{}
+		for _, entry := range ingredients {
+			detail.ingredients = append(detail.ingredients, formatIngredientText(entry))
+		}
+		for _, entry := range steps {
+			detail.steps = append(detail.steps, formatStepText(entry))
+		}
+		details[recipe.NodeID] = detail
+	}
+	return details
+}
+
+// fingerprints 以GraphFingerprints查询相同的结构计算内存图的指纹
+func (c *CSVGraph) fingerprints() *GraphFingerprints {
+	fingerprints := newGraphFingerprints()
+	for _, recipe := range c.NodesByLabel("Recipe") {
+		var ingredients, steps []interface{}
+		for _, relation := range c.Outgoing(recipe.NodeID, "") {
+			node, ok := c.Node(relation.EndNodeID)
+			if !ok {
+				continue
+			}
+			entry := map[string]interface{}{
+				"nodeId": node.NodeID,
+				"node":   node.Properties,
+				"rel":    relation.Properties,
+			}
+			switch {
+			case relation.RelationType == "REQUIRES" && hasLabel(*node, "Ingredient"):
+				ingredients = append(ingredients, entry)
+			case relation.RelationType == "CONTAINS_STEP" && hasLabel(*node, "CookingStep"):
+				steps = append(steps, entry)
+			}
+		}
+
+		fingerprints.addRecipe(recipe.NodeID, recipe.Properties, c.recipeCategories(recipe.NodeID), ingredients, steps)
+	}
+
+	log.Printf("图数据指纹计算完成: %d 个菜谱", len(fingerprints.Recipes))
+	return fingerprints
+}
+
+// NewCSVGraphDataPreparationModule 创建不连接Neo4j、从CSV文件加载图数据的数据准备模块
+//
+// 加载、文档构建、分块和增量同步的指纹计算都基于内存图完成；
+// 每次LoadGraphData都会重新读取CSV文件，以便同步时发现文件的变化。
+func NewCSVGraphDataPreparationModule(nodesPath, relationshipsPath string) (*GraphDataPreparationModule, error) {
+	module := &GraphDataPreparationModule{
+		Documents:            make([]*schema.Document, 0),
+		Chunks:               make([]*schema.Document, 0),
+		Recipes:              make([]GraphNode, 0),
+		Ingredients:          make([]GraphNode, 0),
+		CookingSteps:         make([]GraphNode, 0),
+		ChunkUnit:            ChunkUnitRune,
+		ChunkStrategy:        ChunkerSection,
+		csvNodesPath:         nodesPath,
+		csvRelationshipsPath: relationshipsPath,
+	}
+
+	graph, err := LoadCSVGraph(nodesPath, relationshipsPath)
+	if err != nil {
+		return nil, err
+	}
+	module.csvGraph = graph
+	return module, nil
+}
+
+// loadGraphDataFromCSV 重新读取CSV文件并加载菜谱、食材和步骤节点
+func (g *GraphDataPreparationModule) loadGraphDataFromCSV() (map[string]interface{}, error) {
+	log.Println("正在从CSV文件加载图数据...")
+
+	graph, err := LoadCSVGraph(g.csvNodesPath, g.csvRelationshipsPath)
+	if err != nil {
+		return nil, fmt.Errorf("加载CSV图数据失败: %w", err)
+	}
+	g.csvGraph = graph
+
+	g.Recipes = graph.recipeNodes()
+	g.Ingredients = graph.NodesByLabel("Ingredient")
+	g.CookingSteps = graph.NodesByLabel("CookingStep")
+	log.Printf("加载了 %d 个菜谱节点, %d 个食材节点, %d 个烹饪步骤节点",
+		len(g.Recipes), len(g.Ingredients), len(g.CookingSteps))
+
+	return map[string]interface{}{
+		"recipes":       len(g.Recipes),
+		"ingredients":   len(g.Ingredients),
+		"cooking_steps": len(g.CookingSteps),
+	}, nil
+}
+
+// CSVGraph 返回CSV模式下最近一次加载的内存图，连接Neo4j时返回nil
+func (g *GraphDataPreparationModule) CSVGraph() *CSVGraph {
+	return g.csvGraph
+}
@@ -0,0 +1,209 @@
+package batch_0001
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 多样性默认参数
+const (
+	DefaultMMRLambda       = 0.7 // 相关性与多样性的权衡系数，越大越偏向相关性
+	DefaultMaxPerRecipe    = 1   // 同一菜谱最多保留的文档数
+	DefaultMaxPerCuisine   = 0   // 同一菜系最多保留的文档数，0表示不限制
+	DefaultDiversityFactor = 3   // 检索时多取的倍数，为多样性筛选留出候选空间
+)
+
+// DiversityConfig 结果多样性配置
+type DiversityConfig struct {
+	Enabled       bool    `json:"enabled"`
+	Lambda        float64 `json:"lambda"`          // MMR权衡系数(0-1]
+	MaxPerRecipe  int     `json:"max_per_recipe"`  // 同一菜谱（parent_id）最多保留的文档数，0表示不限制
+	MaxPerCuisine int     `json:"max_per_cuisine"` // 同一菜系最多保留的文档数，0表示不限制
+	OverFetch     int     `json:"over_fetch"`      // 检索候选数量为topK的倍数
+}
+
+// DefaultDiversityConfig 默认多样性配置
+func DefaultDiversityConfig() *DiversityConfig {
+	return &DiversityConfig{
+		Enabled:       true,
+		Lambda:        DefaultMMRLambda,
+		MaxPerRecipe:  DefaultMaxPerRecipe,
+		MaxPerCuisine: DefaultMaxPerCuisine,
+		OverFetch:     DefaultDiversityFactor,
+	}
+}
+
+// FetchK 计算为多样性筛选需要检索的候选数量
+func (c *DiversityConfig) FetchK(topK int) int {
+	if c == nil || !c.Enabled || c.OverFetch <= 1 {
+		return topK
+	}
+	return topK * c.OverFetch
+}
+
+// DiversifyResults 基于最大边际相关性(MMR)的结果多样化
+//
+// 贪心地逐个选择文档，每次选择"相关性 - 与已选文档最大相似度"综合得分最高者：
+//
+//	mmr = λ * relevance - (1-λ) * max_sim(doc, selected)
+//
+// 同一菜谱的文档相似度记为1，其余按内容字符二元组的Jaccard系数计算。
+// 同时对每个菜谱、每个菜系的文档数量做上限约束；满足上限的候选不足topK时，
+// 按MMR顺序用被上限过滤的文档补足，避免上下文过少。
+//
+// Args:
+//   - documents: 按相关性排序的候选文档
+//   - config: 多样性配置
+//   - topK: 返回结果数量
+//
+// Returns:
+//   - []*schema.Document: 多样化后的文档，元数据中包含mmr_score和diversity_rank
+func DiversifyResults(documents []*schema.Document, config *DiversityConfig, topK int) []*schema.Document {
+	if config == nil || !config.Enabled || len(documents) == 0 {
+		if topK > 0 && len(documents) > topK {
+			return documents[:topK]
+		}
+		return documents
+	}
+	if topK <= 0 || topK > len(documents) {
+		topK = len(documents)
+	}
+
+	lambda := config.Lambda
+	if lambda <= 0 || lambda > 1 {
+		lambda = DefaultMMRLambda
+	}
+
+	type candidate struct {
+		doc       *schema.Document
+		relevance float64
+		recipe    string
+		cuisine   string
+		grams     map[string]bool
+	}
+
+	candidates := make([]*candidate, 0, len(documents))
+	for i, doc := range documents {
+		if doc == nil {
+			continue
+		}
+		if doc.MetaData == nil {
+			doc.MetaData = make(map[string]interface{})
+		}
+		candidates = append(candidates, &candidate{
+			doc:       doc,
+			relevance: documentRelevance(doc, i, len(documents)),
+			recipe:    recipeKeyOf(doc),
+			cuisine:   metadataString(doc, "cuisine_type"),
+			grams:     toGramSet(doc.Content),
+		})
+	}
+
+	similarity := func(a, b *candidate) float64 {
+		if a.recipe != "" && a.recipe == b.recipe {
+			return 1.0
+		}
+		return jaccardSimilarity(a.grams, b.grams)
+	}
+
+	var selected []*candidate
+	var deferred []*candidate
+	recipeCount := make(map[string]int)
+	cuisineCount := make(map[string]int)
+	remaining := candidates
+
+	for len(selected) < topK && len(remaining) > 0 {
+		bestIdx := -1
+		bestScore := 0.0
+		for i, cand := range remaining {
+			maxSim := 0.0
+			for _, sel := range selected {
+				if sim := similarity(cand, sel); sim > maxSim {
+					maxSim = sim
+				}
+			}
+			score := lambda*cand.relevance - (1-lambda)*maxSim
+			if bestIdx < 0 || score > bestScore {
+				bestIdx = i
+				bestScore = score
+			}
+		}
+
+		best := remaining[bestIdx]
+		remaining = append(remaining[:bestIdx:bestIdx], remaining[bestIdx+1:]...)
+
+		overRecipe := config.MaxPerRecipe > 0 && best.recipe != "" && recipeCount[best.recipe] >= config.MaxPerRecipe
+		overCuisine := config.MaxPerCuisine > 0 && best.cuisine != "" && cuisineCount[best.cuisine] >= config.MaxPerCuisine
+		if overRecipe || overCuisine {
+			deferred = append(deferred, best)
+			continue
+		}
+
+		best.doc.MetaData["mmr_score"] = bestScore
+		selected = append(selected, best)
+		recipeCount[best.recipe]++
+		cuisineCount[best.cuisine]++
+	}
+
+	// 候选不足时用被上限过滤的文档补足
+	for _, cand := range deferred {
+		if len(selected) >= topK {
+			break
+		}
+		cand.doc.MetaData["diversity_backfill"] = true
+		selected = append(selected, cand)
+	}
+
+	result := make([]*schema.Document, 0, len(selected))
+	for i, cand := range selected {
+		cand.doc.MetaData["diversity_rank"] = i
+		result = append(result, cand.doc)
+	}
+	return result
+}
+
+// documentRelevance 读取文档相关性得分，没有得分时按原始排名线性递减
+func documentRelevance(doc *schema.Document, rank, total int) float64 {
+	for _, key := range []string{"final_score", "relevance_score"} {
+		if score, ok := doc.MetaData[key].(float64); ok {
+			return clampScore(score)
+		}
+	}
+	return 1.0 - float64(rank)/float64(total)
+}
+
+// recipeKeyOf 获取文档所属菜谱的标识：优先parent_id，其次菜名，最后node_id
+func recipeKeyOf(doc *schema.Document) string {
+	for _, key := range []string{"parent_id", "recipe_name", "node_id"} {
+		if value := metadataString(doc, key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// metadataString 以字符串形式读取元数据字段
+func metadataString(doc *schema.Document, key string) string {
+	value, exists := doc.MetaData[key]
+	if !exists || value == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprintf("%v", value))
+}
+
+// jaccardSimilarity 计算两个二元组集合的Jaccard系数
+func jaccardSimilarity(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	intersection := 0
+	for gram := range a {
+		if b[gram] {
+			intersection++
+		}
+	}
+	union := len(a) + len(b) - intersection
+	return float64(intersection) / float64(union)
+}
@@ -2,13 +2,15 @@ package batch_0001
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/cloudwego/eino-ext/components/model/ark"
+	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
 	arkModel "github.com/volcengine/volcengine-go-sdk/service/arkruntime/model"
 )
@@ -20,6 +22,29 @@ type GenerationConfig struct {
 	MaxTokens   int     `json:"max_tokens"`  // 最大生成token数，控制答案长度
 	APIKey      string  `json:"api_key"`     // API密钥
 	BaseURL     string  `json:"base_url"`    // API基础URL
+
+	// 忠实度模式：开启后提示词禁止编造步骤，并对答案做逐句依据检查
+	Grounding *GroundingConfig `json:"grounding,omitempty"`
+}
+
+// GenerationRequestOptions 单次生成的可选参数，通过WithGenerationOptions放入上下文
+type GenerationRequestOptions struct {
+	History     []*schema.Message // 之前的对话轮次，按时间顺序放在本轮问题之前
+	Temperature *float32          // 覆盖生成温度，为nil时使用模型默认值
+	MaxTokens   *int              // 覆盖最大生成token数，为nil时使用模型默认值
+}
+
+type generationOptionsKey struct{}
+
+// WithGenerationOptions 将单次生成的参数放入上下文，同步和流式生成都会读取
+func WithGenerationOptions(ctx context.Context, options *GenerationRequestOptions) context.Context {
+	return context.WithValue(ctx, generationOptionsKey{}, options)
+}
+
+// GenerationOptionsFromContext 从上下文中读取单次生成的参数
+func GenerationOptionsFromContext(ctx context.Context) (*GenerationRequestOptions, bool) {
+	options, ok := ctx.Value(generationOptionsKey{}).(*GenerationRequestOptions)
+	return options, ok && options != nil
 }
 
 // GenerationIntegrationModule 生成集成模块 - RAG系统的答案生成引擎
@@ -41,14 +66,15 @@ type GenerationConfig struct {
 type GenerationIntegrationModule struct {
 	config    *GenerationConfig
 	chatModel *ark.ChatModel
+	grounding *GroundingChecker // 忠实度检查器，未开启忠实度模式时为nil
 }
 
+// NewGenerationIntegrationModule 创建生成模块
+//
+// 模型名称由调用方从配置（llm_model，环境变量LLM_MODEL）传入，为空时使用默认模型。
 func NewGenerationIntegrationModule(modelName string, apiKey string, temperature float32, maxTokens int) *GenerationIntegrationModule {
 	if modelName == "" {
-		modelName = os.Getenv("ARK_MODEL_ID")
-		if modelName == "" {
-			modelName = "ep-20241227100717-lhcjj" // 默认模型
-		}
+		modelName = "ep-20241227100717-lhcjj" // 默认模型
 	}
 	if temperature == 0 {
 		temperature = 0.1
@@ -83,95 +109,65 @@ func (g *GenerationIntegrationModule) Initialize(ctx context.Context) error {
 	}
 
 	g.chatModel = chatModel
+	if g.grounding != nil {
+		g.grounding.chatModel = chatModel
+	}
 	log.Printf("Ark ChatModel初始化完成")
 	return nil
 }
 
-func (g *GenerationIntegrationModule) GenerateAdaptiveAnswer(ctx context.Context, question string, documents []*schema.Document) (string, error) {
-	// 确保模型已初始化
-	if g.chatModel == nil {
-		if err := g.Initialize(ctx); err != nil {
-			return "", fmt.Errorf("初始化生成模块失败: %w", err)
-		}
-	}
-
-	// 构建上下文 - 整合所有检索到的文档
-	var contextParts []string
-
-	for _, doc := range documents {
-		content := doc.Content
-		if content != "" {
-			// 添加检索层级信息（如果有的话）
-			// 这有助于LLM理解信息的重要性和可靠性
-			if level, exists := doc.MetaData["retrieval_level"]; exists {
-				if levelStr, ok := level.(string); ok {
-					// 为不同检索层级添加标识，帮助LLM理解信息层次
-					contextParts = append(contextParts, fmt.Sprintf("[%s] %s", strings.ToUpper(levelStr), content))
-				} else {
-					contextParts = append(contextParts, content)
-				}
-			} else {
-				contextParts = append(contextParts, content)
-			}
-		}
+// EnableGrounding 开启忠实度模式
+//
+// 开启后生成提示词不再要求模型补全缺失步骤，并可通过GenerateGroundedAnswer
+// 获得逐句依据检查结果和忠实度得分。传入nil或Mode为off时关闭忠实度模式。
+func (g *GenerationIntegrationModule) EnableGrounding(config *GroundingConfig) {
+	if config == nil || config.Mode == GroundingOff {
+		g.config.Grounding = nil
+		g.grounding = nil
+		log.Printf("忠实度模式已关闭")
+		return
 	}
 
-	// 将所有文档内容合并为统一的上下文
-	context := strings.Join(contextParts, "\n\n")
-
-	// 优化的烹饪助手提示词 - 专门处理菜谱信息的理解和生成
-	prompt := fmt.Sprintf(`你是一位专业的烹饪助手，请基于检索到的信息为用户提供实用的烹饪指导。
-
-检索到的相关信息：
-%s
-
-用户问题：%s
-
-重要指导原则：
-1. **菜谱识别准确性**：
-   - 仔细识别每个菜谱的正确名称，不要混淆不同菜品
-   - 如果信息来自多个不同菜谱，请明确区分并分别介绍
-
-2. **推荐数量要求**：
-   - 如果用户要求推荐菜品，至少推荐3个不同的菜品
-   - 每个菜品都要提供完整的制作步骤和营养特点
+	g.grounding = NewGroundingChecker(config, g.chatModel)
+	g.config.Grounding = g.grounding.config
+	log.Printf("忠实度模式已开启: %s", config.Mode)
+}
 
-3. **烹饪步骤智能补全**：
-   - 根据检索到的信息，结合常见烹饪知识，提供完整的制作步骤
-   - 如果检索到的步骤不连续，请基于食材和烹饪方法智能推理缺失步骤
-   - 不要标注推理信息，直接提供完整的制作步骤，让回答看起来更自然
+// GroundingEnabled 是否开启了忠实度模式
+func (g *GenerationIntegrationModule) GroundingEnabled() bool {
+	return g.grounding != nil
+}
 
-4. **信息准确性与实用性平衡**：
-   - 优先使用检索到的准确信息
-   - 当信息不完整时，基于烹饪常识合理补充，让用户能实际操作
-   - 所有步骤都应该看起来是专业的烹饪指导
+// GenerateGroundedAnswer 生成答案并检查每个句子/步骤是否有知识库依据
+//
+// 未开启忠实度模式时使用默认的标注模式进行检查，不影响GenerateAdaptiveAnswer的行为。
+func (g *GenerationIntegrationModule) GenerateGroundedAnswer(ctx context.Context, question string, documents []*schema.Document) (*GroundedAnswer, error) {
+	answer, err := g.GenerateAdaptiveAnswer(ctx, question, documents)
+	if err != nil {
+		return nil, err
+	}
 
-5. **回答格式**：
-   - 减肥餐推荐：提供至少3个菜品名称、营养特点、完整制作步骤
-   - 制作方法：按步骤顺序清晰列出，标明步骤编号
-   - 确保每个菜谱都有可操作的完整步骤
+	checker := g.grounding
+	if checker == nil {
+		checker = NewGroundingChecker(nil, g.chatModel)
+	}
 
-请根据以上原则提供准确、实用的回答：`, context, question)
+	return checker.Check(ctx, answer, documents), nil
+}
 
-	// 构建消息
-	messages := []*schema.Message{
-		{
-			Role:    schema.System,
-			Content: "你是一位专业的烹饪助手，能够基于提供的信息为用户提供准确、实用的回答。",
-		},
-		{
-			Role:    schema.User,
-			Content: prompt,
-		},
+func (g *GenerationIntegrationModule) GenerateAdaptiveAnswer(ctx context.Context, question string, documents []*schema.Document) (string, error) {
+	// 确保模型已初始化
+	if g.chatModel == nil {
+		if err := g.Initialize(ctx); err != nil {
+			return "", fmt.Errorf("初始化生成模块失败: %w", err)
+		}
 	}
 
-	// 配置生成选项
-	thinking := &arkModel.Thinking{
-		Type: arkModel.ThinkingTypeDisabled,
-	}
+	// 构建提示消息
+	messages := g.buildAnswerMessages(ctx, question, documents)
 
 	// 调用ChatModel生成答案
-	response, err := g.chatModel.Generate(ctx, messages, ark.WithThinking(thinking))
+	response, err := g.chatModel.Generate(ctx, messages, g.modelOptions(ctx)...)
 	if err != nil {
 		log.Printf("LightRAG答案生成失败: %v", err)
 		return fmt.Sprintf("抱歉，生成回答时出现错误：%v", err), err
@@ -185,13 +181,35 @@ func (g *GenerationIntegrationModule) GenerateAdaptiveAnswer(ctx context.Context
 	return strings.TrimSpace(response.Content), nil
 }
 
-func (g *GenerationIntegrationModule) GenerateAdaptiveAnswerStream(ctx context.Context, question string, documents []*schema.Document, maxRetries int, resultChan chan<- string) {
+// StreamEventType 流式生成事件类型
+type StreamEventType string
+
+const (
+	StreamEventChunk  StreamEventType = "chunk"  // 模型生成的回答片段
+	StreamEventStatus StreamEventType = "status" // 重试、降级等进度提示，不属于回答内容
+	StreamEventError  StreamEventType = "error"  // 生成失败，之后不再有事件
+)
+
+// StreamEvent 流式生成事件
+type StreamEvent struct {
+	Type    StreamEventType
+	Content string // 回答片段或进度提示
+	Err     error  // 生成失败的原因
+}
+
+// GenerateAdaptiveAnswerStream 流式生成回答，事件依次写入resultChan，结束后关闭通道
+//
+// 只有StreamEventChunk事件是回答内容；还没有发出回答片段时流式生成失败会按递增间隔重试，
+// 重试和降级提示作为StreamEventStatus事件发送，全部重试和非流式后备都失败时发送StreamEventError事件。
+// 已经发出部分片段后失败时不再重试（否则调用方会收到重复的回答），直接发送StreamEventError事件。
+// ctx取消后不再重试，直接关闭通道，调用方通过ctx.Err()判断。
+func (g *GenerationIntegrationModule) GenerateAdaptiveAnswerStream(ctx context.Context, question string, documents []*schema.Document, maxRetries int, resultChan chan<- StreamEvent) {
 	defer close(resultChan)
 
 	// 确保模型已初始化
 	if g.chatModel == nil {
 		if err := g.Initialize(ctx); err != nil {
-			resultChan <- fmt.Sprintf("初始化生成模块失败: %v", err)
+			resultChan <- StreamEvent{Type: StreamEventError, Err: fmt.Errorf("初始化生成模块失败: %w", err)}
 			return
 		}
 	}
@@ -200,14 +218,114 @@ func (g *GenerationIntegrationModule) GenerateAdaptiveAnswerStream(ctx context.C
 		maxRetries = 3
 	}
 
-	// 构建上下文 - 与同步版本相同的逻辑
+	// 构建提示消息 - 与同步版本相同的逻辑
+	messages := g.buildAnswerMessages(ctx, question, documents)
+	options := g.modelOptions(ctx)
+
+	status := func(format string, args ...interface{}) {
+		message := fmt.Sprintf(format, args...)
+		log.Print(message)
+		resultChan <- StreamEvent{Type: StreamEventStatus, Content: message}
+	}
+
+	// 重试循环 - 最多尝试maxRetries次
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		if attempt > 0 {
+			status("第%d次尝试流式生成...", attempt+1)
+		}
+
+		emitted, err := g.streamAnswer(ctx, messages, options, resultChan)
+		if err == nil {
+			return
+		}
+		log.Printf("流式生成第%d次尝试失败: %v", attempt+1, err)
+		if emitted {
+			resultChan <- StreamEvent{Type: StreamEventError, Err: fmt.Errorf("生成回答中断: %w", err)}
+			return
+		}
+		if attempt == maxRetries-1 {
+			break
+		}
+
+		// 还有重试机会，等待后继续
+		waitTime := time.Duration(attempt+1) * 2 * time.Second // 递增等待时间：2s, 4s, 6s...
+		status("连接中断，%v后重试...", waitTime)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(waitTime):
+		}
+	}
+
+	// 所有重试都失败，降级到同步生成
+	status("流式生成失败，切换到标准模式...")
+	fallbackResponse, err := g.GenerateAdaptiveAnswer(ctx, question, documents)
+	if err != nil {
+		log.Printf("后备生成也失败: %v", err)
+		resultChan <- StreamEvent{Type: StreamEventError, Err: fmt.Errorf("生成回答失败: %w", err)}
+		return
+	}
+	resultChan <- StreamEvent{Type: StreamEventChunk, Content: fallbackResponse}
+}
+
+// streamAnswer 调用一次流式生成，把回答片段写入resultChan，流正常结束时返回nil，
+// emitted表示是否已经发出过回答片段
+func (g *GenerationIntegrationModule) streamAnswer(ctx context.Context, messages []*schema.Message, options []model.Option, resultChan chan<- StreamEvent) (emitted bool, err error) {
+	streamReader, err := g.chatModel.Stream(ctx, messages, options...)
+	if err != nil {
+		return false, err
+	}
+	defer streamReader.Close()
+
+	for {
+		message, err := streamReader.Recv()
+		if errors.Is(err, io.EOF) {
+			return emitted, nil
+		}
+		if err != nil {
+			return emitted, fmt.Errorf("读取流式响应失败: %w", err)
+		}
+		if message != nil && message.Content != "" {
+			resultChan <- StreamEvent{Type: StreamEventChunk, Content: message.Content}
+			emitted = true
+		}
+	}
+}
+
+// modelOptions 生成选项：关闭深度思考，并应用上下文中的单次生成参数
+func (g *GenerationIntegrationModule) modelOptions(ctx context.Context) []model.Option {
+	thinking := &arkModel.Thinking{
+		Type: arkModel.ThinkingTypeDisabled,
+	}
+	options := []model.Option{ark.WithThinking(thinking)}
+
+	if requestOptions, ok := GenerationOptionsFromContext(ctx); ok {
+		if requestOptions.Temperature != nil {
+			options = append(options, model.WithTemperature(*requestOptions.Temperature))
+		}
+		if requestOptions.MaxTokens != nil {
+			options = append(options, model.WithMaxTokens(*requestOptions.MaxTokens))
+		}
+	}
+	return options
+}
+
+// buildAnswerMessages 构建答案生成的提示消息
+//
+// 同步与流式生成共用同一套上下文和提示词；忠实度模式下替换步骤补全相关的指导原则，
+// 要求模型只使用检索到的信息。上下文中带有对话历史时，历史消息放在系统消息和本轮提示之间。
+func (g *GenerationIntegrationModule) buildAnswerMessages(ctx context.Context, question string, documents []*schema.Document) []*schema.Message {
+	// 构建上下文 - 整合所有检索到的文档
 	var contextParts []string
 
 	for _, doc := range documents {
 		content := doc.Content
 		if content != "" {
+			// 添加检索层级信息（如果有的话）
+			// 这有助于LLM理解信息的重要性和可靠性
 			if level, exists := doc.MetaData["retrieval_level"]; exists {
 				if levelStr, ok := level.(string); ok {
+					// 为不同检索层级添加标识，帮助LLM理解信息层次
 					contextParts = append(contextParts, fmt.Sprintf("[%s] %s", strings.ToUpper(levelStr), content))
 				} else {
 					contextParts = append(contextParts, content)
@@ -218,9 +336,45 @@ func (g *GenerationIntegrationModule) GenerateAdaptiveAnswerStream(ctx context.C
 		}
 	}
 
+	// 将所有文档内容合并为统一的上下文
 	context := strings.Join(contextParts, "\n\n")
 
-	// 优化的烹饪助手提示词 - 与同步版本保持一致
+	// 推荐数量、步骤补全与准确性、回答格式原则：默认要求完整步骤并允许基于常识补全，
+	// 忠实度模式下只要求给出检索信息中有的步骤
+	recommendRules := `2. **推荐数量要求**：
+   - 如果用户要求推荐菜品，至少推荐3个不同的菜品
+   - 每个菜品都要提供完整的制作步骤和营养特点`
+	formatRules := `5. **回答格式**：
+   - 减肥餐推荐：提供至少3个菜品名称、营养特点、完整制作步骤
+   - 制作方法：按步骤顺序清晰列出，标明步骤编号
+   - 确保每个菜谱都有可操作的完整步骤`
+	completionRules := `3. **烹饪步骤智能补全**：
+   - 根据检索到的信息，结合常见烹饪知识，提供完整的制作步骤
+   - 如果检索到的步骤不连续，请基于食材和烹饪方法智能推理缺失步骤
+   - 不要标注推理信息，直接提供完整的制作步骤，让回答看起来更自然
+
+4. **信息准确性与实用性平衡**：
+   - 优先使用检索到的准确信息
+   - 当信息不完整时，基于烹饪常识合理补充，让用户能实际操作
+   - 所有步骤都应该看起来是专业的烹饪指导`
+	if g.grounding != nil {
+		completionRules = `3. **严格依据检索信息**：
+   - 只使用检索到的信息中出现的食材、用量和制作步骤
+   - 不要编造检索信息中没有的步骤、用量或食材
+   - 如果检索到的步骤不完整，请明确说明"知识库中缺少该部分信息"，不要自行补全
+
+4. **信息准确性优先**：
+   - 尽量沿用检索信息中的原始表述，便于核对来源
+   - 无法从检索信息中确认的内容宁可不写`
+		recommendRules = `2. **推荐数量要求**：
+   - 如果用户要求推荐菜品，至少推荐3个不同的菜品
+   - 每个菜品给出检索信息中有的制作步骤和营养特点`
+		formatRules = `5. **回答格式**：
+   - 减肥餐推荐：提供至少3个菜品名称、营养特点和检索信息中的制作步骤
+   - 制作方法：按步骤顺序清晰列出，标明步骤编号`
+	}
+
+	// 优化的烹饪助手提示词 - 专门处理菜谱信息的理解和生成
 	prompt := fmt.Sprintf(`你是一位专业的烹饪助手，请基于检索到的信息为用户提供实用的烹饪指导。
 
 检索到的相关信息：
@@ -233,26 +387,13 @@ func (g *GenerationIntegrationModule) GenerateAdaptiveAnswerStream(ctx context.C
    - 仔细识别每个菜谱的正确名称，不要混淆不同菜品
    - 如果信息来自多个不同菜谱，请明确区分并分别介绍
 
-2. **推荐数量要求**：
-   - 如果用户要求推荐菜品，至少推荐3个不同的菜品
-   - 每个菜品都要提供完整的制作步骤和营养特点
-
-3. **烹饪步骤智能补全**：
-   - 根据检索到的信息，结合常见烹饪知识，提供完整的制作步骤
-   - 如果检索到的步骤不连续，请基于食材和烹饪方法智能推理缺失步骤
-   - 不要标注推理信息，直接提供完整的制作步骤，让回答看起来更自然
+%s
 
-4. **信息准确性与实用性平衡**：
-   - 优先使用检索到的准确信息
-   - 当信息不完整时，基于烹饪常识合理补充，让用户能实际操作
-   - 所有步骤都应该看起来是专业的烹饪指导
+%s
 
-5. **回答格式**：
-   - 减肥餐推荐：提供至少3个菜品名称、营养特点、完整制作步骤
-   - 制作方法：按步骤顺序清晰列出，标明步骤编号
-   - 确保每个菜谱都有可操作的完整步骤
+%s
 
-请根据以上原则提供准确、实用的回答：`, context, question)
+请根据以上原则提供准确、实用的回答：`, context, question, recommendRules, completionRules, formatRules)
 
 	// 构建消息
 	messages := []*schema.Message{
@@ -260,90 +401,12 @@ func (g *GenerationIntegrationModule) GenerateAdaptiveAnswerStream(ctx context.C
 			Role:    schema.System,
 			Content: "你是一位专业的烹饪助手，能够基于提供的信息为用户提供准确、实用的回答。",
 		},
-		{
-			Role:    schema.User,
-			Content: prompt,
-		},
 	}
-
-	// 配置生成选项
-	thinking := &arkModel.Thinking{
-		Type: arkModel.ThinkingTypeDisabled,
-	}
-
-	// 重试循环 - 最多尝试maxRetries次
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		// 显示重试信息（如果不是第一次尝试）
-		if attempt == 0 {
-			resultChan <- "开始流式生成回答...\n\n"
-		} else {
-			resultChan <- fmt.Sprintf("第%d次尝试流式生成...\n\n", attempt+1)
-		}
-
-		// 调用流式生成
-		streamReader, err := g.chatModel.Stream(ctx, messages, ark.WithThinking(thinking))
-		if err != nil {
-			log.Printf("流式生成第%d次尝试失败: %v", attempt+1, err)
-
-			if attempt < maxRetries-1 {
-				// 还有重试机会，等待后继续
-				waitTime := time.Duration(attempt+1) * 2 * time.Second // 递增等待时间：2s, 4s, 6s...
-				resultChan <- fmt.Sprintf("⚠️ 连接中断，%v后重试...\n", waitTime)
-				time.Sleep(waitTime)
-				continue
-			} else {
-				// 所有重试都失败，使用非流式作为后备方案
-				log.Printf("流式生成完全失败，尝试非流式后备方案")
-				resultChan <- "⚠️ 流式生成失败，切换到标准模式...\n"
-
-				// 降级到同步生成
-				fallbackResponse, fallbackErr := g.GenerateAdaptiveAnswer(ctx, question, documents)
-				if fallbackErr != nil {
-					log.Printf("后备生成也失败: %v", fallbackErr)
-					resultChan <- fmt.Sprintf("抱歉，生成回答时出现网络错误，请稍后重试。错误信息：%v", err)
-				} else {
-					resultChan <- fallbackResponse
-				}
-				return
-			}
-		}
-
-		// 处理流式响应
-		for {
-			message, streamErr := streamReader.Recv()
-			if streamErr != nil {
-				if streamErr.Error() == "EOF" {
-					// 正常结束流式生成
-					return
-				}
-				log.Printf("读取流式响应失败: %v", streamErr)
-				break // 跳出内层循环，进入重试逻辑
-			}
-
-			if message != nil && message.Content != "" {
-				resultChan <- message.Content
-			}
-		}
-
-		// 如果到这里说明流式读取出现了错误，继续重试逻辑
-		if attempt < maxRetries-1 {
-			waitTime := time.Duration(attempt+1) * 2 * time.Second
-			resultChan <- fmt.Sprintf("⚠️ 流式读取中断，%v后重试...\n", waitTime)
-			time.Sleep(waitTime)
-			continue
-		} else {
-			// 最后一次重试也失败，使用后备方案
-			log.Printf("流式生成完全失败，尝试非流式后备方案")
-			resultChan <- "⚠️ 流式生成失败，切换到标准模式...\n"
-
-			fallbackResponse, fallbackErr := g.GenerateAdaptiveAnswer(ctx, question, documents)
-			if fallbackErr != nil {
-				log.Printf("后备生成也失败: %v", fallbackErr)
-				resultChan <- fmt.Sprintf("抱歉，生成回答时出现网络错误，请稍后重试。")
-			} else {
-				resultChan <- fallbackResponse
-			}
-			return
-		}
+	if requestOptions, ok := GenerationOptionsFromContext(ctx); ok {
+		messages = append(messages, requestOptions.History...)
 	}
+	return append(messages, &schema.Message{
+		Role:    schema.User,
+		Content: prompt,
+	})
 }
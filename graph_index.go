@@ -3,16 +3,13 @@ package batch_0001
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"strings"
-
-	"github.com/cloudwego/eino/components/model"
-
-	"github.com/cloudwego/eino/components/prompt"
+	"sort"
+	"sync"
 
 	"github.com/cloudwego/eino-ext/components/model/ark"
-	"github.com/cloudwego/eino/schema"
 )
 
 // EntityKeyValue 实体键值对数据结构
@@ -135,6 +132,20 @@ type GraphIndexingModule struct {
 	// 索引映射：从检索键到实体/关系ID的快速映射
 	keyToEntities  map[string][]string // 索引键 -> 实体ID列表
 	keyToRelations map[string][]string // 索引键 -> 关系ID列表
+
+	schema         *GraphIndexSchema // 索引结构声明
+	entitySynonyms map[string]string // 去重用的同义词表，规范化别名 -> 规范化标准名
+	sourceHash     string            // 索引对应的源图数据指纹，用于判断磁盘快照是否过期
+
+	// 索引映射发布后只读：构建、去重、撤销和导入都在未发布的副本上进行，完成后在indexMu写锁下整体替换；
+	// 查询在读锁下取出当前映射后即可无锁读取。updateMu串行化各种更新，避免并发更新相互覆盖。
+	indexMu  sync.RWMutex
+	updateMu sync.Mutex
+
+	// 索引键的模糊查找结构，键集合变化后置空，查询时按需重建
+	keyIndexMu       sync.Mutex
+	entityKeyIndex   *keyIndex
+	relationKeyIndex *keyIndex
 }
 
 // LLMKeywordsResponse LLM关键词生成响应
@@ -151,137 +162,131 @@ func NewGraphIndexingModule(config *Config, llmClient ark.ChatModel) *GraphIndex
 		relationKVStore: make(map[string]*RelationKeyValue),
 		keyToEntities:   make(map[string][]string),
 		keyToRelations:  make(map[string][]string),
+		schema:          DefaultGraphIndexSchema(),
 	}
 }
 
-// CreateEntityKeyValues 为实体创建键值对结构
-// 每个实体使用其名称作为唯一索引键
-func (g *GraphIndexingModule) CreateEntityKeyValues(recipes []*Recipe, ingredients []*Ingredient, cookingSteps []*CookingStep) map[string]*EntityKeyValue {
-	log.Println("开始创建实体键值对...")
-
-	// 处理菜谱实体
-	for _, recipe := range recipes {
-		entityID := recipe.GetNodeID()
-		entityName := recipe.GetName()
-		if entityName == "" {
-			entityName = fmt.Sprintf("菜谱_%s", entityID)
-		}
-
-		// 构建详细内容
-		contentParts := []string{fmt.Sprintf("菜品名称: %s", entityName)}
-
-		props := recipe.GetProperties()
-		if description, exists := props["description"]; exists {
-			contentParts = append(contentParts, fmt.Sprintf("描述: %v", description))
-		}
-		if category, exists := props["category"]; exists {
-			contentParts = append(contentParts, fmt.Sprintf("分类: %v", category))
-		}
-		if cuisineType, exists := props["cuisineType"]; exists {
-			contentParts = append(contentParts, fmt.Sprintf("菜系: %v", cuisineType))
-		}
-		if difficulty, exists := props["difficulty"]; exists {
-			contentParts = append(contentParts, fmt.Sprintf("难度: %v", difficulty))
-		}
-		if cookingTime, exists := props["cookingTime"]; exists {
-			contentParts = append(contentParts, fmt.Sprintf("制作时间: %v", cookingTime))
-		}
+// SetSchema 设置索引结构声明，在构建索引前调用；nil表示使用默认结构
+func (g *GraphIndexingModule) SetSchema(schema *GraphIndexSchema) {
+	if schema == nil {
+		schema = DefaultGraphIndexSchema()
+	}
+	g.schema = schema
+}
 
-		// 创建键值对
-		entityKV := &EntityKeyValue{
-			EntityName:   entityName,
-			IndexKeys:    []string{entityName}, // 使用名称作为唯一索引键
-			ValueContent: strings.Join(contentParts, "\n"),
-			EntityType:   "Recipe",
-			Metadata: map[string]interface{}{
-				"node_id":    entityID,
-				"properties": props,
-			},
+// LoadConfigFiles 按配置加载索引结构声明（GraphIndexSchemaPath）和去重同义词表（DedupSynonymsPath），
+// 在构建或加载索引前调用；未配置的文件使用默认值
+func (g *GraphIndexingModule) LoadConfigFiles() error {
+	if g.config == nil {
+		return nil
+	}
+	if g.config.GraphIndexSchemaPath != "" {
+		indexSchema, err := LoadGraphIndexSchema(g.config.GraphIndexSchemaPath)
+		if err != nil {
+			return err
 		}
-
-		g.entityKVStore[entityID] = entityKV
-		g.keyToEntities[entityName] = append(g.keyToEntities[entityName], entityID)
+		g.SetSchema(indexSchema)
 	}
-
-	// 处理食材实体
-	for _, ingredient := range ingredients {
-		entityID := ingredient.GetNodeID()
-		entityName := ingredient.GetName()
-		if entityName == "" {
-			entityName = fmt.Sprintf("食材_%s", entityID)
+	if g.config.DedupSynonymsPath != "" {
+		synonyms, err := LoadEntitySynonyms(g.config.DedupSynonymsPath)
+		if err != nil {
+			return err
 		}
+		g.SetEntitySynonyms(synonyms)
+	}
+	return nil
+}
 
-		contentParts := []string{fmt.Sprintf("食材名称: %s", entityName)}
+// Schema 当前使用的索引结构声明
+func (g *GraphIndexingModule) Schema() *GraphIndexSchema {
+	return g.schema
+}
 
-		props := ingredient.GetProperties()
-		if category, exists := props["category"]; exists {
-			contentParts = append(contentParts, fmt.Sprintf("类别: %v", category))
-		}
-		if nutrition, exists := props["nutrition"]; exists {
-			contentParts = append(contentParts, fmt.Sprintf("营养信息: %v", nutrition))
-		}
-		if storage, exists := props["storage"]; exists {
-			contentParts = append(contentParts, fmt.Sprintf("储存方式: %v", storage))
-		}
+// CreateEntityKeyValues 为实体创建键值对结构
+// 每个实体使用其名称作为唯一索引键
+func (g *GraphIndexingModule) CreateEntityKeyValues(recipes []*Recipe, ingredients []*Ingredient, cookingSteps []*CookingStep) map[string]*EntityKeyValue {
+	g.updateMu.Lock()
+	defer g.updateMu.Unlock()
 
-		entityKV := &EntityKeyValue{
-			EntityName:   entityName,
-			IndexKeys:    []string{entityName},
-			ValueContent: strings.Join(contentParts, "\n"),
-			EntityType:   "Ingredient",
-			Metadata: map[string]interface{}{
-				"node_id":    entityID,
-				"properties": props,
-			},
-		}
+	log.Println("开始创建实体键值对...")
+	next := g.cloneIndex()
+	next.createEntityKeyValues("Recipe", entityNodes(recipes))
+	next.createEntityKeyValues("Ingredient", entityNodes(ingredients))
+	next.createEntityKeyValues("CookingStep", entityNodes(cookingSteps))
+	g.publishIndex(next)
+	log.Printf("实体键值对创建完成，共 %d 个实体", len(next.entityKVStore))
+	return next.entityKVStore
+}
 
-		g.entityKVStore[entityID] = entityKV
-		g.keyToEntities[entityName] = append(g.keyToEntities[entityName], entityID)
+// entityNodes 将实体转换为图节点
+func entityNodes[T GraphEntity](entities []T) []GraphNode {
+	nodes := make([]GraphNode, 0, len(entities))
+	for _, entity := range entities {
+		nodes = append(nodes, GraphNode{NodeID: entity.GetNodeID(), Name: entity.GetName(), Properties: entity.GetProperties()})
 	}
+	return nodes
+}
 
-	// 处理烹饪步骤实体
-	for _, step := range cookingSteps {
-		entityID := step.GetNodeID()
-		entityName := fmt.Sprintf("步骤_%s", entityID)
-
-		contentParts := []string{fmt.Sprintf("烹饪步骤: %s", entityName)}
-
-		props := step.GetProperties()
-		if description, exists := props["description"]; exists {
-			contentParts = append(contentParts, fmt.Sprintf("步骤描述: %v", description))
-		}
-		if order, exists := props["order"]; exists {
-			contentParts = append(contentParts, fmt.Sprintf("步骤顺序: %v", order))
-		}
-		if technique, exists := props["technique"]; exists {
-			contentParts = append(contentParts, fmt.Sprintf("技巧: %v", technique))
+// createEntityKeyValues 按索引结构中该标签的声明为一组节点创建实体键值对，未声明的标签跳过
+//
+// 只在未发布的索引上调用。
+func (g *GraphIndexingModule) createEntityKeyValues(label string, nodes []GraphNode) int {
+	spec := g.schema.entity(label)
+	if spec == nil {
+		return 0
+	}
+
+	created := 0
+	for _, node := range nodes {
+		entityID := node.NodeID
+		entityName := spec.entityName(node)
+		if entityID == "" || entityName == "" {
+			continue
 		}
-		if time, exists := props["time"]; exists {
-			contentParts = append(contentParts, fmt.Sprintf("时间: %v", time))
+		// 同一节点带多个已声明的标签时只索引一次
+		if _, exists := g.entityKVStore[entityID]; exists {
+			continue
 		}
 
-		entityKV := &EntityKeyValue{
+		props := node.Properties
+		indexKeys := g.uniqueStrings(spec.indexKeys(entityName, props))
+		g.entityKVStore[entityID] = &EntityKeyValue{
 			EntityName:   entityName,
-			IndexKeys:    []string{entityName},
-			ValueContent: strings.Join(contentParts, "\n"),
-			EntityType:   "CookingStep",
+			IndexKeys:    indexKeys,
+			ValueContent: spec.content(entityName, props),
+			EntityType:   label,
 			Metadata: map[string]interface{}{
 				"node_id":    entityID,
 				"properties": props,
 			},
 		}
-
-		g.entityKVStore[entityID] = entityKV
-		g.keyToEntities[entityName] = append(g.keyToEntities[entityName], entityID)
+		for _, key := range indexKeys {
+			g.keyToEntities[key] = append(g.keyToEntities[key], entityID)
+		}
+		created++
 	}
-
-	log.Printf("实体键值对创建完成，共 %d 个实体", len(g.entityKVStore))
-	return g.entityKVStore
+	return created
 }
 
 // CreateRelationKeyValues 为关系创建键值对结构
 // 关系可能有多个索引键，包含从LLM增强的全局主题
 func (g *GraphIndexingModule) CreateRelationKeyValues(ctx context.Context, relationships []*Relationship) map[string]*RelationKeyValue {
+	g.updateMu.Lock()
+	defer g.updateMu.Unlock()
+
+	next := g.cloneIndex()
+	if err := next.createRelationKeyValues(ctx, relationships); err != nil {
+		log.Printf("创建关系键值对未完成: %v", err)
+	}
+	g.publishIndex(next)
+	return next.relationKVStore
+}
+
+// createRelationKeyValues 创建关系键值对，启用LLM关系键时再并发增强全局主题
+//
+// LLM增强被取消或部分失败时返回错误，此时已生成的关系键值对仍然保留；
+// 部分失败的错误包装errLLMRelationKeysIncomplete。只在未发布的索引上调用。
+func (g *GraphIndexingModule) createRelationKeyValues(ctx context.Context, relationships []*Relationship) error {
 	log.Println("开始创建关系键值对...")
 
 	for i, rel := range relationships {
@@ -295,21 +300,14 @@ func (g *GraphIndexingModule) CreateRelationKeyValues(ctx context.Context, relat
 			continue
 		}
 
-		// 构建关系描述
-		contentParts := []string{
-			fmt.Sprintf("关系类型: %s", rel.RelationType),
-			fmt.Sprintf("源实体: %s (%s)", sourceEntity.EntityName, sourceEntity.EntityType),
-			fmt.Sprintf("目标实体: %s (%s)", targetEntity.EntityName, targetEntity.EntityType),
-		}
-
 		// 生成多个索引键（包含全局主题）
-		indexKeys := g.generateRelationIndexKeys(ctx, sourceEntity, targetEntity, rel.RelationType)
+		indexKeys := g.generateRelationIndexKeys(sourceEntity, targetEntity, rel.RelationType)
 
 		// 创建关系键值对
 		relationKV := &RelationKeyValue{
 			RelationID:   relationID,
 			IndexKeys:    indexKeys,
-			ValueContent: strings.Join(contentParts, "\n"),
+			ValueContent: relationContent(rel.RelationType, sourceEntity, targetEntity),
 			RelationType: rel.RelationType,
 			SourceEntity: rel.SourceID,
 			TargetEntity: rel.TargetID,
@@ -329,186 +327,218 @@ func (g *GraphIndexingModule) CreateRelationKeyValues(ctx context.Context, relat
 	}
 
 	log.Printf("关系键值对创建完成，共 %d 个关系", len(g.relationKVStore))
-	return g.relationKVStore
-}
 
-// generateRelationIndexKeys 为关系生成多个索引键，包含全局主题
-func (g *GraphIndexingModule) generateRelationIndexKeys(ctx context.Context, sourceEntity *EntityKeyValue, targetEntity *EntityKeyValue, relationType string) []string {
-	keys := []string{relationType} // 基础关系类型键
-
-	// 根据关系类型和实体类型生成主题键
-	switch relationType {
-	case "REQUIRES":
-		// 菜谱-食材关系的主题键
-		keys = append(keys,
-			"食材搭配",
-			"烹饪原料",
-			fmt.Sprintf("%s_食材", sourceEntity.EntityName),
-			targetEntity.EntityName,
-		)
-	case "HAS_STEP":
-		// 菜谱-步骤关系的主题键
-		keys = append(keys,
-			"制作步骤",
-			"烹饪过程",
-			fmt.Sprintf("%s_步骤", sourceEntity.EntityName),
-			"制作方法",
-		)
-	case "BELONGS_TO_CATEGORY":
-		// 分类关系的主题键
-		keys = append(keys,
-			"菜品分类",
-			"美食类别",
-			targetEntity.EntityName,
-		)
+	// 使用LLM增强关系索引键（可选）
+	if g.llmRelationKeysEnabled() {
+		return g.enhanceRelationKeys(ctx)
 	}
+	return nil
+}
 
-	// 使用LLM增强关系索引键（可选）
-	if g.config != nil {
-		if enableLLMKeys, exists := g.config.Constraints["enable_llm_relation_keys"]; exists {
-			if enable, ok := enableLLMKeys.(bool); ok && enable {
-				enhancedKeys := g.llmEnhanceRelationKeys(ctx, sourceEntity, targetEntity, relationType)
-				keys = append(keys, enhancedKeys...)
-			}
-		}
+// generateRelationIndexKeys 为关系生成多个索引键，包含全局主题
+//
+// 主题键由索引结构中该关系类型的声明展开，未声明的类型只以关系类型作为索引键。
+func (g *GraphIndexingModule) generateRelationIndexKeys(sourceEntity *EntityKeyValue, targetEntity *EntityKeyValue, relationType string) []string {
+	spec := g.schema.relation(relationType)
+	if spec == nil {
+		return []string{relationType}
 	}
 
 	// 去重并返回
-	return g.uniqueStrings(keys)
+	return g.uniqueStrings(spec.themeKeys(relationType, sourceEntity, targetEntity))
 }
 
-// llmEnhanceRelationKeys 使用LLM增强关系索引键，生成全局主题
-func (g *GraphIndexingModule) llmEnhanceRelationKeys(ctx context.Context, sourceEntity *EntityKeyValue, targetEntity *EntityKeyValue, relationType string) []string {
-
-	template := prompt.FromMessages(schema.FString,
-		schema.SystemMessage("你是一个{role}。"),
-		&schema.Message{
-			Role: schema.User,
-			Content: `分析以下实体关系，生成相关的主题关键词：
-			源实体: {source_name} ({source_type})
-			目标实体: {target_name} ({target_type}) 
-			关系类型: {relation_type}
-
-			请生成3-5个相关的主题关键词，用于索引和检索。
-			返回JSON格式：{"keywords": ["关键词1", "关键词2", "关键词3"]}`,
-		},
-	)
-
-	values := map[string]interface{}{
-		"source_name":   sourceEntity.EntityName,
-		"source_type":   sourceEntity.EntityType,
-		"target_name":   targetEntity.EntityName,
-		"target_type":   targetEntity.EntityType,
-		"relation_type": relationType,
+// llmRelationKeysEnabled 是否启用LLM增强关系索引键（配置项 enable_llm_relation_keys）
+func (g *GraphIndexingModule) llmRelationKeysEnabled() bool {
+	if g.config == nil {
+		return false
 	}
+	enable, _ := g.config.Constraints["enable_llm_relation_keys"].(bool)
+	return enable
+}
 
-	messages, err := template.Format(context.Background(), values)
+// BuildFromDataModule 从图数据准备模块的输出构建键值对索引
+//
+// 索引结构中声明的每个标签的节点生成实体键值对，声明的关系类型生成关系键值对，
+// 两端都是已索引实体的关系才进入索引。重复调用时先清空已有索引。
+//
+// 配置了GraphIndexPath时，若磁盘快照与当前图数据、索引配置一致则直接加载快照，
+// 避免每次启动重新生成（和付费调用LLM生成）关系索引键；否则重新构建并保存快照。
+//
+// 配置了DedupReportPath时，新索引在发布前去重，审计报告保存到该路径，可用RevertDeduplication撤销。
+//
+// 新索引在单独的映射中构建，完成后整体替换，构建期间查询继续使用旧索引。
+func (g *GraphIndexingModule) BuildFromDataModule(ctx context.Context, dataModule *GraphDataPreparationModule) error {
+	g.updateMu.Lock()
+	defer g.updateMu.Unlock()
+
+	nodesByLabel := make(map[string][]GraphNode)
+	var allNodes []GraphNode
+	for _, label := range g.schema.Labels() {
+		nodes, err := dataModule.NodesByLabel(ctx, label)
+		if err != nil {
+			return err
+		}
+		nodesByLabel[label] = nodes
+		allNodes = append(allNodes, nodes...)
+	}
+	relationships, err := dataModule.RelationshipsByType(ctx, g.schema.RelationTypes())
 	if err != nil {
-		log.Printf("LLM增强关系索引键失败: %v", err)
-		return []string{}
+		return err
 	}
+	sourceHash := graphIndexSourceHash(allNodes, relationships)
 
-	response, err := g.llmClient.Generate(context.Background(), messages, model.WithTemperature(0.1), model.WithMaxTokens(200))
+	snapshotPath := ""
+	if g.config != nil {
+		snapshotPath = g.config.GraphIndexPath
+	}
+	if snapshotPath != "" && g.loadFreshSnapshot(snapshotPath, sourceHash) {
+		return nil
+	}
 
-	if err != nil {
-		log.Printf("LLM增强关系索引键失败: %v", err)
-		return []string{}
+	next := g.newIndex()
+	next.sourceHash = sourceHash
+
+	log.Println("开始创建实体键值对...")
+	for _, label := range g.schema.Labels() {
+		if created := next.createEntityKeyValues(label, nodesByLabel[label]); created > 0 {
+			log.Printf("  %s: %d 个实体", label, created)
+		}
 	}
+	log.Printf("实体键值对创建完成，共 %d 个实体", len(next.entityKVStore))
 
-	var result LLMKeywordsResponse
-	if err := json.Unmarshal([]byte(response.Content), &result); err != nil {
-		log.Printf("解析LLM响应失败: %v", err)
-		return []string{}
+	relationErr := next.createRelationKeyValues(ctx, relationships)
+	if relationErr != nil && !errors.Is(relationErr, errLLMRelationKeysIncomplete) {
+		return fmt.Errorf("构建图索引失败: %w", relationErr)
+	}
+	next.deduplicateIfConfigured()
+	g.publishIndex(next)
+	if relationErr != nil {
+		// 部分关系缺少LLM主题键时索引仍可使用，但不保存快照，下次启动从检查点补齐
+		log.Printf("图索引不完整，跳过快照保存: %v", relationErr)
+		return nil
+	}
+
+	if snapshotPath != "" {
+		if err := g.SaveSnapshot(snapshotPath); err != nil {
+			log.Printf("图索引快照保存失败: %v", err)
+		}
 	}
+	return nil
+}
+
+// GetEntity 按实体ID获取实体
+func (g *GraphIndexingModule) GetEntity(entityID string) (*EntityKeyValue, bool) {
+	g.indexMu.RLock()
+	defer g.indexMu.RUnlock()
 
-	return result.Keywords
+	entity, exists := g.entityKVStore[entityID]
+	return entity, exists
 }
 
-// DeduplicateEntitiesAndRelations 去重相同的实体和关系，优化图操作
-func (g *GraphIndexingModule) DeduplicateEntitiesAndRelations() {
-	log.Println("开始去重实体和关系...")
+// EntitiesByType 获取指定类型的实体，entityType为空时返回全部实体，按实体ID排序
+func (g *GraphIndexingModule) EntitiesByType(entityType string) []*EntityKeyValue {
+	g.indexMu.RLock()
+	defer g.indexMu.RUnlock()
 
-	// 实体去重：基于名称
-	nameToEntities := make(map[string][]string)
-	for entityID, entityKV := range g.entityKVStore {
-		nameToEntities[entityKV.EntityName] = append(nameToEntities[entityKV.EntityName], entityID)
-	}
-
-	// 合并重复实体
-	var entitiesToRemove []string
-	for _, entityIDs := range nameToEntities {
-		if len(entityIDs) > 1 {
-			// 保留第一个，合并其他的内容
-			primaryID := entityIDs[0]
-			primaryEntity := g.entityKVStore[primaryID]
-
-			for _, entityID := range entityIDs[1:] {
-				duplicateEntity := g.entityKVStore[entityID]
-				// 合并内容
-				primaryEntity.ValueContent += fmt.Sprintf("\n\n补充信息: %s", duplicateEntity.ValueContent)
-				// 标记删除
-				entitiesToRemove = append(entitiesToRemove, entityID)
-			}
+	entityIDs := make([]string, 0, len(g.entityKVStore))
+	for entityID, entity := range g.entityKVStore {
+		if entityType == "" || entity.EntityType == entityType {
+			entityIDs = append(entityIDs, entityID)
 		}
 	}
+	sort.Strings(entityIDs)
 
-	// 删除重复实体
-	for _, entityID := range entitiesToRemove {
-		delete(g.entityKVStore, entityID)
+	entities := make([]*EntityKeyValue, 0, len(entityIDs))
+	for _, entityID := range entityIDs {
+		entities = append(entities, g.entityKVStore[entityID])
 	}
+	return entities
+}
 
-	// 关系去重：基于源-目标-类型
-	relationSignatureToIDs := make(map[string][]string)
-	for relationID, relationKV := range g.relationKVStore {
-		signature := fmt.Sprintf("%s_%s_%s", relationKV.SourceEntity, relationKV.TargetEntity, relationKV.RelationType)
-		relationSignatureToIDs[signature] = append(relationSignatureToIDs[signature], relationID)
-	}
-
-	// 合并重复关系
-	var relationsToRemove []string
-	for _, relationIDs := range relationSignatureToIDs {
-		if len(relationIDs) > 1 {
-			// 保留第一个，删除其他
-			for _, relationID := range relationIDs[1:] {
-				relationsToRemove = append(relationsToRemove, relationID)
-			}
-		}
+// newIndex 创建与当前模块配置相同、索引为空的模块，用于在发布前构建新索引
+func (g *GraphIndexingModule) newIndex() *GraphIndexingModule {
+	return &GraphIndexingModule{
+		config:          g.config,
+		llmClient:       g.llmClient,
+		schema:          g.schema,
+		entitySynonyms:  g.entitySynonyms,
+		entityKVStore:   make(map[string]*EntityKeyValue),
+		relationKVStore: make(map[string]*RelationKeyValue),
+		keyToEntities:   make(map[string][]string),
+		keyToRelations:  make(map[string][]string),
 	}
+}
 
-	// 删除重复关系
-	for _, relationID := range relationsToRemove {
-		delete(g.relationKVStore, relationID)
-	}
+// cloneIndex 复制当前索引用于修改后发布
+//
+// 键值对与已发布的索引共享，修改某个键值对前需先复制（copyEntityKeyValue、copyRelationKeyValue）。
+func (g *GraphIndexingModule) cloneIndex() *GraphIndexingModule {
+	next := g.newIndex()
+
+	g.indexMu.RLock()
+	defer g.indexMu.RUnlock()
 
-	// 重建索引映射
-	g.rebuildKeyMappings()
+	for entityID, entityKV := range g.entityKVStore {
+		next.entityKVStore[entityID] = entityKV
+	}
+	for relationID, relationKV := range g.relationKVStore {
+		next.relationKVStore[relationID] = relationKV
+	}
+	for key, entityIDs := range g.keyToEntities {
+		next.keyToEntities[key] = append([]string(nil), entityIDs...)
+	}
+	for key, relationIDs := range g.keyToRelations {
+		next.keyToRelations[key] = append([]string(nil), relationIDs...)
+	}
+	next.sourceHash = g.sourceHash
+	return next
+}
 
-	log.Printf("去重完成 - 删除了 %d 个重复实体，%d 个重复关系", len(entitiesToRemove), len(relationsToRemove))
+// publishIndex 用next中构建好的索引整体替换当前索引，next之后不能再修改
+func (g *GraphIndexingModule) publishIndex(next *GraphIndexingModule) {
+	g.indexMu.Lock()
+	g.entityKVStore = next.entityKVStore
+	g.relationKVStore = next.relationKVStore
+	g.keyToEntities = next.keyToEntities
+	g.keyToRelations = next.keyToRelations
+	g.sourceHash = next.sourceHash
+	g.indexMu.Unlock()
+
+	g.invalidateKeyIndexes()
 }
 
-// rebuildKeyMappings 重建键到实体/关系的映射
+// rebuildKeyMappings 按实体和关系重建键到实体/关系的映射，只在未发布的索引上调用
+//
+// 同一键下的ID按字典序排列，保证从快照或JSON加载后的查询结果顺序稳定。
 func (g *GraphIndexingModule) rebuildKeyMappings() {
-	// 清空现有映射
 	g.keyToEntities = make(map[string][]string)
 	g.keyToRelations = make(map[string][]string)
 
-	// 重建实体映射
 	for entityID, entityKV := range g.entityKVStore {
 		for _, key := range entityKV.IndexKeys {
 			g.keyToEntities[key] = append(g.keyToEntities[key], entityID)
 		}
 	}
-
-	// 重建关系映射
 	for relationID, relationKV := range g.relationKVStore {
 		for _, key := range relationKV.IndexKeys {
 			g.keyToRelations[key] = append(g.keyToRelations[key], relationID)
 		}
 	}
+
+	for _, entityIDs := range g.keyToEntities {
+		sort.Strings(entityIDs)
+	}
+	for _, relationIDs := range g.keyToRelations {
+		sort.Strings(relationIDs)
+	}
 }
 
 // GetEntitiesByKey 根据索引键获取实体
 func (g *GraphIndexingModule) GetEntitiesByKey(key string) []*EntityKeyValue {
+	g.indexMu.RLock()
+	defer g.indexMu.RUnlock()
+
 	entityIDs := g.keyToEntities[key]
 	var entities []*EntityKeyValue
 
@@ -523,6 +553,9 @@ func (g *GraphIndexingModule) GetEntitiesByKey(key string) []*EntityKeyValue {
 
 // GetRelationsByKey 根据索引键获取关系
 func (g *GraphIndexingModule) GetRelationsByKey(key string) []*RelationKeyValue {
+	g.indexMu.RLock()
+	defer g.indexMu.RUnlock()
+
 	relationIDs := g.keyToRelations[key]
 	var relations []*RelationKeyValue
 
@@ -537,6 +570,9 @@ func (g *GraphIndexingModule) GetRelationsByKey(key string) []*RelationKeyValue
 
 // GetStatistics 获取键值对存储统计信息
 func (g *GraphIndexingModule) GetStatistics() map[string]interface{} {
+	g.indexMu.RLock()
+	defer g.indexMu.RUnlock()
+
 	totalEntityKeys := 0
 	for _, entityKV := range g.entityKVStore {
 		totalEntityKeys += len(entityKV.IndexKeys)
@@ -547,17 +583,18 @@ func (g *GraphIndexingModule) GetStatistics() map[string]interface{} {
 		totalRelationKeys += len(relationKV.IndexKeys)
 	}
 
-	// 统计实体类型
-	entityTypes := map[string]int{
-		"Recipe":      0,
-		"Ingredient":  0,
-		"CookingStep": 0,
+	// 统计实体类型，索引结构中声明的类型即使没有实体也列出
+	entityTypes := make(map[string]int)
+	for _, label := range g.schema.Labels() {
+		entityTypes[label] = 0
 	}
-
 	for _, entityKV := range g.entityKVStore {
-		if count, exists := entityTypes[entityKV.EntityType]; exists {
-			entityTypes[entityKV.EntityType] = count + 1
-		}
+		entityTypes[entityKV.EntityType]++
+	}
+
+	relationTypes := make(map[string]int)
+	for _, relationKV := range g.relationKVStore {
+		relationTypes[relationKV.RelationType]++
 	}
 
 	return map[string]interface{}{
@@ -566,26 +603,19 @@ func (g *GraphIndexingModule) GetStatistics() map[string]interface{} {
 		"total_entity_keys":   totalEntityKeys,
 		"total_relation_keys": totalRelationKeys,
 		"entity_types":        entityTypes,
+		"relation_types":      relationTypes,
 	}
 }
 
 // SearchByKeyword 根据关键词搜索实体和关系
+//
+// 支持精确、前缀、子串、拼音（全拼、首字母）和编辑距离匹配，结果按键的匹配得分排序。
 func (g *GraphIndexingModule) SearchByKeyword(keyword string) (entities []*EntityKeyValue, relations []*RelationKeyValue) {
-	// 精确匹配
-	entities = append(entities, g.GetEntitiesByKey(keyword)...)
-	relations = append(relations, g.GetRelationsByKey(keyword)...)
-
-	// 模糊匹配（包含关键词的键）
-	for key := range g.keyToEntities {
-		if strings.Contains(strings.ToLower(key), strings.ToLower(keyword)) && key != keyword {
-			entities = append(entities, g.GetEntitiesByKey(key)...)
-		}
+	for _, match := range g.MatchEntityKeys(keyword, 0) {
+		entities = append(entities, g.GetEntitiesByKey(match.Key)...)
 	}
-
-	for key := range g.keyToRelations {
-		if strings.Contains(strings.ToLower(key), strings.ToLower(keyword)) && key != keyword {
-			relations = append(relations, g.GetRelationsByKey(key)...)
-		}
+	for _, match := range g.MatchRelationKeys(keyword, 0) {
+		relations = append(relations, g.GetRelationsByKey(match.Key)...)
 	}
 
 	// 去重
@@ -595,8 +625,56 @@ func (g *GraphIndexingModule) SearchByKeyword(keyword string) (entities []*Entit
 	return entities, relations
 }
 
+// MatchEntityKeys 查找与关键词匹配的实体索引键，按得分排序，limit<=0时返回全部
+func (g *GraphIndexingModule) MatchEntityKeys(keyword string, limit int) []KeyMatch {
+	entityIndex, _ := g.keyIndexes()
+	return entityIndex.Search(keyword, limit)
+}
+
+// MatchRelationKeys 查找与关键词匹配的关系索引键，按得分排序，limit<=0时返回全部
+func (g *GraphIndexingModule) MatchRelationKeys(keyword string, limit int) []KeyMatch {
+	_, relationIndex := g.keyIndexes()
+	return relationIndex.Search(keyword, limit)
+}
+
+// keyIndexes 返回键查找结构，键集合变化后首次查询时重建
+func (g *GraphIndexingModule) keyIndexes() (*keyIndex, *keyIndex) {
+	g.keyIndexMu.Lock()
+	defer g.keyIndexMu.Unlock()
+
+	g.indexMu.RLock()
+	defer g.indexMu.RUnlock()
+
+	if g.entityKeyIndex == nil {
+		keys := make([]string, 0, len(g.keyToEntities))
+		for key := range g.keyToEntities {
+			keys = append(keys, key)
+		}
+		g.entityKeyIndex = newKeyIndex(keys)
+	}
+	if g.relationKeyIndex == nil {
+		keys := make([]string, 0, len(g.keyToRelations))
+		for key := range g.keyToRelations {
+			keys = append(keys, key)
+		}
+		g.relationKeyIndex = newKeyIndex(keys)
+	}
+	return g.entityKeyIndex, g.relationKeyIndex
+}
+
+// invalidateKeyIndexes 键集合变化后置空键查找结构
+func (g *GraphIndexingModule) invalidateKeyIndexes() {
+	g.keyIndexMu.Lock()
+	g.entityKeyIndex = nil
+	g.relationKeyIndex = nil
+	g.keyIndexMu.Unlock()
+}
+
 // GetAllEntityKeys 获取所有实体索引键
 func (g *GraphIndexingModule) GetAllEntityKeys() []string {
+	g.indexMu.RLock()
+	defer g.indexMu.RUnlock()
+
 	var keys []string
 	for key := range g.keyToEntities {
 		keys = append(keys, key)
@@ -606,6 +684,9 @@ func (g *GraphIndexingModule) GetAllEntityKeys() []string {
 
 // GetAllRelationKeys 获取所有关系索引键
 func (g *GraphIndexingModule) GetAllRelationKeys() []string {
+	g.indexMu.RLock()
+	defer g.indexMu.RUnlock()
+
 	var keys []string
 	for key := range g.keyToRelations {
 		keys = append(keys, key)
@@ -663,47 +744,53 @@ func (g *GraphIndexingModule) uniqueRelations(relations []*RelationKeyValue) []*
 
 // ExportToJSON 导出索引数据到JSON
 func (g *GraphIndexingModule) ExportToJSON() (map[string]interface{}, error) {
+	g.indexMu.RLock()
+	entities, relations := g.entityKVStore, g.relationKVStore
+	g.indexMu.RUnlock()
+
 	return map[string]interface{}{
-		"entities":   g.entityKVStore,
-		"relations":  g.relationKVStore,
+		"entities":   entities,
+		"relations":  relations,
 		"statistics": g.GetStatistics(),
 	}, nil
 }
 
 // ImportFromJSON 从JSON导入索引数据
+//
+// 数据格式与 ExportToJSON 一致；任一实体或关系解析失败时返回错误且不修改当前索引。
 func (g *GraphIndexingModule) ImportFromJSON(data map[string]interface{}) error {
-	// 清空现有数据
-	g.entityKVStore = make(map[string]*EntityKeyValue)
-	g.relationKVStore = make(map[string]*RelationKeyValue)
+	entities := make(map[string]*EntityKeyValue)
+	relations := make(map[string]*RelationKeyValue)
 
 	// 导入实体数据
 	if entitiesData, exists := data["entities"]; exists {
-		if entitiesMap, ok := entitiesData.(map[string]interface{}); ok {
-			for entityID, entityData := range entitiesMap {
-				entityBytes, _ := json.Marshal(entityData)
-				var entity EntityKeyValue
-				if err := json.Unmarshal(entityBytes, &entity); err == nil {
-					g.entityKVStore[entityID] = &entity
-				}
-			}
+		entityBytes, err := json.Marshal(entitiesData)
+		if err != nil {
+			return fmt.Errorf("序列化实体数据失败: %w", err)
+		}
+		if err := json.Unmarshal(entityBytes, &entities); err != nil {
+			return fmt.Errorf("解析实体数据失败: %w", err)
 		}
 	}
 
 	// 导入关系数据
 	if relationsData, exists := data["relations"]; exists {
-		if relationsMap, ok := relationsData.(map[string]interface{}); ok {
-			for relationID, relationData := range relationsMap {
-				relationBytes, _ := json.Marshal(relationData)
-				var relation RelationKeyValue
-				if err := json.Unmarshal(relationBytes, &relation); err == nil {
-					g.relationKVStore[relationID] = &relation
-				}
-			}
+		relationBytes, err := json.Marshal(relationsData)
+		if err != nil {
+			return fmt.Errorf("序列化关系数据失败: %w", err)
+		}
+		if err := json.Unmarshal(relationBytes, &relations); err != nil {
+			return fmt.Errorf("解析关系数据失败: %w", err)
 		}
 	}
 
-	// 重建索引映射
-	g.rebuildKeyMappings()
+	g.updateMu.Lock()
+	defer g.updateMu.Unlock()
 
+	next := g.newIndex()
+	next.entityKVStore = entities
+	next.relationKVStore = relations
+	next.rebuildKeyMappings()
+	g.publishIndex(next)
 	return nil
 }
@@ -0,0 +1,437 @@
+package batch_0001
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/width"
+)
+
+// dedupSkipTypes 不参与去重的实体类型：步骤名称由节点ID生成，同名即同一节点
+var dedupSkipTypes = map[string]bool{
+	"CookingStep": true,
+}
+
+// 实体合并原因
+const (
+	MergeReasonNormalizedName = "normalized_name" // 规范化后的名称相同（空白、全半角、大小写）
+	MergeReasonSynonym        = "synonym"         // 通过同义词表归并到同一名称
+)
+
+// DedupReport 一次去重的审计报告
+//
+// 记录每次实体合并、关系端点改写和关系合并，并保存所有受影响实体和关系在去重前的副本，
+// 可以保存到文件供人工审核，也可以用RevertDeduplication整体撤销。
+type DedupReport struct {
+	CreatedAt        time.Time         `json:"created_at"`
+	EntityMerges     []EntityMerge     `json:"entity_merges"`
+	RelationMerges   []RelationMerge   `json:"relation_merges"`
+	RewrittenEdges   []RelationRewrite `json:"rewritten_edges"`    // 端点被改写到保留实体的关系
+	DroppedSelfLoops []string          `json:"dropped_self_loops"` // 改写后两端相同而删除的关系
+
+	OriginalEntities  map[string]*EntityKeyValue   `json:"original_entities"`  // 受影响实体去重前的副本
+	OriginalRelations map[string]*RelationKeyValue `json:"original_relations"` // 受影响关系去重前的副本
+}
+
+// EntityMerge 一组被合并的同类实体
+type EntityMerge struct {
+	EntityType     string   `json:"entity_type"`
+	NormalizedName string   `json:"normalized_name"`
+	SurvivorID     string   `json:"survivor_id"`
+	SurvivorName   string   `json:"survivor_name"`
+	MergedIDs      []string `json:"merged_ids"`
+	MergedNames    []string `json:"merged_names"`
+	Reason         string   `json:"reason"`
+}
+
+// RelationMerge 一组端点和类型都相同的关系
+type RelationMerge struct {
+	RelationType string   `json:"relation_type"`
+	SourceEntity string   `json:"source_entity"`
+	TargetEntity string   `json:"target_entity"`
+	SurvivorID   string   `json:"survivor_id"`
+	MergedIDs    []string `json:"merged_ids"`
+}
+
+// RelationRewrite 关系端点改写记录
+type RelationRewrite struct {
+	RelationID string `json:"relation_id"`
+	OldSource  string `json:"old_source"`
+	OldTarget  string `json:"old_target"`
+	NewSource  string `json:"new_source"`
+	NewTarget  string `json:"new_target"`
+}
+
+// SetEntitySynonyms 设置去重使用的同义词表：别名 -> 标准名，如"番茄" -> "西红柿"
+//
+// 只有同类实体之间才按同义词合并。
+func (g *GraphIndexingModule) SetEntitySynonyms(synonyms map[string]string) {
+	g.entitySynonyms = make(map[string]string, len(synonyms))
+	for alias, canonical := range synonyms {
+		g.entitySynonyms[normalizeEntityName(alias)] = normalizeEntityName(canonical)
+	}
+}
+
+// LoadEntitySynonyms 读取去重同义词表，文件内容为JSON对象：{"番茄": "西红柿"}
+func LoadEntitySynonyms(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取去重同义词表失败: %w", err)
+	}
+	var synonyms map[string]string
+	if err := json.Unmarshal(data, &synonyms); err != nil {
+		return nil, fmt.Errorf("解析去重同义词表失败: %w", err)
+	}
+	return synonyms, nil
+}
+
+// DeduplicateEntitiesAndRelations 去重相同的实体和关系，优化图操作
+//
+// 实体只在同一类型内按规范化名称（去空白、全角转半角、小写，再查同义词表）合并，
+// 保留关系最多的实体（相同时取ID最小的），被合并实体的名称和索引键并入保留实体作为别名键。
+// 指向被合并实体的关系改写到保留实体，改写后端点和类型都相同的关系再合并。
+// 去重在索引副本上进行，完成后整体替换当前索引。
+func (g *GraphIndexingModule) DeduplicateEntitiesAndRelations() *DedupReport {
+	g.updateMu.Lock()
+	defer g.updateMu.Unlock()
+
+	next := g.cloneIndex()
+	report := next.deduplicate()
+	g.publishIndex(next)
+	return report
+}
+
+// dedupEnabled 是否在构建索引后去重：配置了去重审计报告路径时开启
+func (g *GraphIndexingModule) dedupEnabled() bool {
+	return g.config != nil && g.config.DedupReportPath != ""
+}
+
+// deduplicateIfConfigured 开启去重时在未发布的索引上去重并保存审计报告
+func (g *GraphIndexingModule) deduplicateIfConfigured() {
+	if !g.dedupEnabled() {
+		return
+	}
+	report := g.deduplicate()
+	if err := report.Save(g.config.DedupReportPath); err != nil {
+		log.Printf("去重报告保存失败: %v", err)
+		return
+	}
+	log.Printf("去重报告已保存: %s", g.config.DedupReportPath)
+}
+
+// deduplicate 在未发布的索引上去重，修改共享的键值对前先复制
+func (g *GraphIndexingModule) deduplicate() *DedupReport {
+	log.Println("开始去重实体和关系...")
+
+	report := &DedupReport{
+		CreatedAt:         time.Now(),
+		OriginalEntities:  make(map[string]*EntityKeyValue),
+		OriginalRelations: make(map[string]*RelationKeyValue),
+	}
+
+	// 实体度数，用于选择保留实体
+	degree := make(map[string]int)
+	for _, relationKV := range g.relationKVStore {
+		degree[relationKV.SourceEntity]++
+		degree[relationKV.TargetEntity]++
+	}
+
+	// 实体去重：同类型、同规范化名称
+	type entityGroup struct {
+		entityType string
+		name       string
+		ids        []string
+		synonym    bool
+	}
+	groups := make(map[string]*entityGroup)
+	for entityID, entityKV := range g.entityKVStore {
+		if dedupSkipTypes[entityKV.EntityType] {
+			continue
+		}
+		name := normalizeEntityName(entityKV.EntityName)
+		canonical, synonym := g.entitySynonyms[name]
+		if !synonym {
+			canonical = name
+		}
+		key := entityKV.EntityType + "|" + canonical
+		group, exists := groups[key]
+		if !exists {
+			group = &entityGroup{entityType: entityKV.EntityType, name: canonical}
+			groups[key] = group
+		}
+		group.ids = append(group.ids, entityID)
+		group.synonym = group.synonym || synonym
+	}
+
+	groupKeys := make([]string, 0, len(groups))
+	for key, group := range groups {
+		if len(group.ids) > 1 {
+			groupKeys = append(groupKeys, key)
+		}
+	}
+	sort.Strings(groupKeys)
+
+	survivorOf := make(map[string]string) // 被合并实体ID -> 保留实体ID
+	for _, key := range groupKeys {
+		group := groups[key]
+		sort.Slice(group.ids, func(i, j int) bool {
+			if degree[group.ids[i]] != degree[group.ids[j]] {
+				return degree[group.ids[i]] > degree[group.ids[j]]
+			}
+			return group.ids[i] < group.ids[j]
+		})
+
+		survivorID := group.ids[0]
+		report.OriginalEntities[survivorID] = copyEntityKeyValue(g.entityKVStore[survivorID])
+		survivor := copyEntityKeyValue(g.entityKVStore[survivorID])
+		g.entityKVStore[survivorID] = survivor
+
+		merge := EntityMerge{
+			EntityType:     group.entityType,
+			NormalizedName: group.name,
+			SurvivorID:     survivorID,
+			SurvivorName:   survivor.EntityName,
+			Reason:         MergeReasonNormalizedName,
+		}
+		if group.synonym {
+			merge.Reason = MergeReasonSynonym
+		}
+
+		mergedIDs := metadataStrings(survivor.Metadata, "merged_ids")
+		for _, entityID := range group.ids[1:] {
+			duplicate := g.entityKVStore[entityID]
+			report.OriginalEntities[entityID] = copyEntityKeyValue(duplicate)
+
+			// 被合并实体的名称和索引键作为保留实体的别名键
+			survivor.IndexKeys = g.uniqueStrings(append(append(survivor.IndexKeys, duplicate.EntityName), duplicate.IndexKeys...))
+			mergedIDs = append(mergedIDs, entityID)
+			survivorOf[entityID] = survivorID
+			delete(g.entityKVStore, entityID)
+
+			merge.MergedIDs = append(merge.MergedIDs, entityID)
+			merge.MergedNames = append(merge.MergedNames, duplicate.EntityName)
+		}
+		survivor.Metadata["merged_ids"] = mergedIDs
+		report.EntityMerges = append(report.EntityMerges, merge)
+	}
+
+	// 关系端点改写到保留实体
+	relationIDs := make([]string, 0, len(g.relationKVStore))
+	for relationID := range g.relationKVStore {
+		relationIDs = append(relationIDs, relationID)
+	}
+	sort.Strings(relationIDs)
+
+	for _, relationID := range relationIDs {
+		relationKV := g.relationKVStore[relationID]
+		newSource, sourceMerged := survivorOf[relationKV.SourceEntity]
+		newTarget, targetMerged := survivorOf[relationKV.TargetEntity]
+		if !sourceMerged && !targetMerged {
+			continue
+		}
+		if !sourceMerged {
+			newSource = relationKV.SourceEntity
+		}
+		if !targetMerged {
+			newTarget = relationKV.TargetEntity
+		}
+
+		report.OriginalRelations[relationID] = copyRelationKeyValue(relationKV)
+		if newSource == newTarget {
+			delete(g.relationKVStore, relationID)
+			report.DroppedSelfLoops = append(report.DroppedSelfLoops, relationID)
+			continue
+		}
+
+		report.RewrittenEdges = append(report.RewrittenEdges, RelationRewrite{
+			RelationID: relationID,
+			OldSource:  relationKV.SourceEntity,
+			OldTarget:  relationKV.TargetEntity,
+			NewSource:  newSource,
+			NewTarget:  newTarget,
+		})
+		source, target := g.entityKVStore[newSource], g.entityKVStore[newTarget]
+		relationKV = copyRelationKeyValue(relationKV)
+		g.relationKVStore[relationID] = relationKV
+		relationKV.SourceEntity = newSource
+		relationKV.TargetEntity = newTarget
+		relationKV.ValueContent = relationContent(relationKV.RelationType, source, target)
+		relationKV.Metadata["source_name"] = source.EntityName
+		relationKV.Metadata["target_name"] = target.EntityName
+	}
+
+	// 关系去重：基于源-目标-类型，保留ID最小的关系并合并索引键
+	relationSignatureToIDs := make(map[string][]string)
+	var signatures []string
+	for _, relationID := range relationIDs {
+		relationKV, exists := g.relationKVStore[relationID]
+		if !exists {
+			continue
+		}
+		signature := relationKV.SourceEntity + "|" + relationKV.TargetEntity + "|" + relationKV.RelationType
+		if _, seen := relationSignatureToIDs[signature]; !seen {
+			signatures = append(signatures, signature)
+		}
+		relationSignatureToIDs[signature] = append(relationSignatureToIDs[signature], relationID)
+	}
+
+	removedRelations := 0
+	for _, signature := range signatures {
+		ids := relationSignatureToIDs[signature]
+		if len(ids) < 2 {
+			continue
+		}
+		survivor := g.relationKVStore[ids[0]]
+		if _, recorded := report.OriginalRelations[ids[0]]; !recorded {
+			report.OriginalRelations[ids[0]] = copyRelationKeyValue(survivor)
+		}
+		survivor = copyRelationKeyValue(survivor)
+		g.relationKVStore[ids[0]] = survivor
+		for _, relationID := range ids[1:] {
+			duplicate := g.relationKVStore[relationID]
+			if _, recorded := report.OriginalRelations[relationID]; !recorded {
+				report.OriginalRelations[relationID] = copyRelationKeyValue(duplicate)
+			}
+			survivor.IndexKeys = g.uniqueStrings(append(survivor.IndexKeys, duplicate.IndexKeys...))
+			delete(g.relationKVStore, relationID)
+			removedRelations++
+		}
+		report.RelationMerges = append(report.RelationMerges, RelationMerge{
+			RelationType: survivor.RelationType,
+			SourceEntity: survivor.SourceEntity,
+			TargetEntity: survivor.TargetEntity,
+			SurvivorID:   ids[0],
+			MergedIDs:    ids[1:],
+		})
+	}
+
+	// 重建索引映射
+	g.rebuildKeyMappings()
+
+	for _, merge := range report.EntityMerges {
+		log.Printf("  合并%s实体 %s(%s) <- %v", merge.EntityType, merge.SurvivorName, merge.SurvivorID, merge.MergedNames)
+	}
+	log.Printf("去重完成 - 合并了 %d 组实体（删除 %d 个），改写 %d 个关系端点，删除 %d 个重复关系和 %d 个自环关系",
+		len(report.EntityMerges), len(survivorOf), len(report.RewrittenEdges), removedRelations, len(report.DroppedSelfLoops))
+	return report
+}
+
+// RevertDeduplication 撤销一次去重：恢复报告中记录的全部实体和关系
+//
+// 去重之后索引被重新构建过（保留实体已不存在）时返回错误且不做修改。
+func (g *GraphIndexingModule) RevertDeduplication(report *DedupReport) error {
+	g.updateMu.Lock()
+	defer g.updateMu.Unlock()
+
+	next := g.cloneIndex()
+	for _, merge := range report.EntityMerges {
+		if _, exists := next.entityKVStore[merge.SurvivorID]; !exists {
+			return fmt.Errorf("无法撤销去重: 保留实体 %s 已不存在", merge.SurvivorID)
+		}
+	}
+
+	for entityID, entityKV := range report.OriginalEntities {
+		next.entityKVStore[entityID] = copyEntityKeyValue(entityKV)
+	}
+	for relationID, relationKV := range report.OriginalRelations {
+		next.relationKVStore[relationID] = copyRelationKeyValue(relationKV)
+	}
+	next.rebuildKeyMappings()
+	g.publishIndex(next)
+
+	log.Printf("已撤销去重: 恢复 %d 个实体，%d 个关系", len(report.OriginalEntities), len(report.OriginalRelations))
+	return nil
+}
+
+// Save 保存审计报告
+func (r *DedupReport) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("创建去重报告目录失败: %w", err)
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化去重报告失败: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("保存去重报告失败: %w", err)
+	}
+	return nil
+}
+
+// LoadDedupReport 读取审计报告，用于审核或撤销
+func LoadDedupReport(path string) (*DedupReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取去重报告失败: %w", err)
+	}
+	var report DedupReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("解析去重报告失败: %w", err)
+	}
+	return &report, nil
+}
+
+// normalizeEntityName 去重用的名称规范化：去掉空白，全角转半角，字母小写
+func normalizeEntityName(name string) string {
+	name = width.Fold.String(name)
+	return strings.ToLower(strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, name))
+}
+
+// relationContent 关系键值对的描述内容
+func relationContent(relationType string, source, target *EntityKeyValue) string {
+	return strings.Join([]string{
+		fmt.Sprintf("关系类型: %s", relationType),
+		fmt.Sprintf("源实体: %s (%s)", source.EntityName, source.EntityType),
+		fmt.Sprintf("目标实体: %s (%s)", target.EntityName, target.EntityType),
+	}, "\n")
+}
+
+// metadataStrings 读取字符串列表元数据，兼容从JSON快照加载后的[]interface{}
+func metadataStrings(metadata map[string]interface{}, key string) []string {
+	switch values := metadata[key].(type) {
+	case []string:
+		return append([]string(nil), values...)
+	case []interface{}:
+		strs := make([]string, 0, len(values))
+		for _, value := range values {
+			strs = append(strs, fmt.Sprint(value))
+		}
+		return strs
+	}
+	return nil
+}
+
+// copyMetadata 浅拷贝元数据，避免修改与其他键值对共享的映射
+func copyMetadata(metadata map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(metadata)+1)
+	for k, v := range metadata {
+		copied[k] = v
+	}
+	return copied
+}
+
+func copyEntityKeyValue(entity *EntityKeyValue) *EntityKeyValue {
+	copied := *entity
+	copied.IndexKeys = append([]string(nil), entity.IndexKeys...)
+	copied.Metadata = copyMetadata(entity.Metadata)
+	return &copied
+}
+
+func copyRelationKeyValue(relation *RelationKeyValue) *RelationKeyValue {
+	copied := *relation
+	copied.IndexKeys = append([]string(nil), relation.IndexKeys...)
+	copied.Metadata = copyMetadata(relation.Metadata)
+	return &copied
+}
@@ -0,0 +1,153 @@
+package batch_0001
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino-ext/components/model/ark"
+)
+
+// testDedupDataModule 两道菜谱分别需要"豆腐"和写法不同的"豆 腐"，第三道菜谱同时需要两者
+func testDedupDataModule() *GraphDataPreparationModule {
+	nodeRows := []map[string]string{
+		{"nodeId": "202000001", "labels": "Recipe", "name": "麻婆豆腐", "category": "荤菜"},
+		{"nodeId": "202000002", "labels": "Ingredient", "name": "豆腐", "category": "豆制品"},
+		{"nodeId": "203000001", "labels": "Recipe", "name": "家常豆腐", "category": "素菜"},
+		{"nodeId": "203000002", "labels": "Ingredient", "name": "豆 腐", "category": "豆制品"},
+		{"nodeId": "204000001", "labels": "Recipe", "name": "小葱拌豆腐", "category": "素菜"},
+	}
+	relationRows := []map[string]string{
+		{"startNodeId": "202000001", "endNodeId": "202000002", "relationshipType": relationCodeRequires},
+		{"startNodeId": "203000001", "endNodeId": "203000002", "relationshipType": relationCodeRequires},
+		{"startNodeId": "204000001", "endNodeId": "202000002", "relationshipType": relationCodeRequires},
+		{"startNodeId": "204000001", "endNodeId": "203000002", "relationshipType": relationCodeRequires},
+	}
+	graph := NewCSVGraphFromRows(nodeRows, relationRows)
+	return &GraphDataPreparationModule{
+		Recipes:     graph.NodesByLabel("Recipe"),
+		Ingredients: graph.NodesByLabel("Ingredient"),
+		csvGraph:    graph,
+	}
+}
+
+// indexSignature 索引中全部实体、关系和键映射的可比较摘要
+func indexSignature(g *GraphIndexingModule) string {
+	var parts []string
+	for entityID, entity := range g.entityKVStore {
+		parts = append(parts, "E "+entityID+" "+entity.EntityName+" "+strings.Join(entity.IndexKeys, ","))
+	}
+	for relationID, relation := range g.relationKVStore {
+		parts = append(parts, "R "+relationID+" "+relation.SourceEntity+"->"+relation.TargetEntity+" "+relation.ValueContent)
+	}
+	for key, entityIDs := range g.keyToEntities {
+		parts = append(parts, "KE "+key+" "+strings.Join(entityIDs, ","))
+	}
+	for key, relationIDs := range g.keyToRelations {
+		parts = append(parts, "KR "+key+" "+strings.Join(relationIDs, ","))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "\n")
+}
+
+func TestBuildFromDataModuleDeduplicatesAndReverts(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	plain := NewGraphIndexingModule(&Config{}, ark.ChatModel{})
+	if err := plain.BuildFromDataModule(ctx, testDedupDataModule()); err != nil {
+		t.Fatalf("BuildFromDataModule: %v", err)
+	}
+	original := indexSignature(plain)
+
+	reportPath := filepath.Join(dir, "dedup.json")
+	index := NewGraphIndexingModule(&Config{DedupReportPath: reportPath}, ark.ChatModel{})
+	if err := index.BuildFromDataModule(ctx, testDedupDataModule()); err != nil {
+		t.Fatalf("BuildFromDataModule: %v", err)
+	}
+
+	if _, exists := index.GetEntity("203000002"); exists {
+		t.Error("豆 腐应合并到豆腐")
+	}
+	matches := index.GetEntitiesByKey("豆 腐")
+	if len(matches) != 1 || matches[0].Metadata["node_id"] != "202000002" {
+		t.Errorf("被合并实体的名称应作为保留实体的别名键，得到 %d 个实体", len(matches))
+	}
+	// 小葱拌豆腐的两条食材关系、两种豆腐到豆制品分类的关系各合并为一条
+	before, after := plain.GetStatistics()["total_relations"].(int), index.GetStatistics()["total_relations"].(int)
+	if before-after != 2 {
+		t.Errorf("应合并掉2条重复关系，去重前 %d 条，去重后 %d 条", before, after)
+	}
+
+	report, err := LoadDedupReport(reportPath)
+	if err != nil {
+		t.Fatalf("构建索引后应保存去重报告: %v", err)
+	}
+	if len(report.EntityMerges) != 1 || len(report.RelationMerges) != 2 {
+		t.Fatalf("报告应记录1组实体合并和2组关系合并，得到 %d 和 %d", len(report.EntityMerges), len(report.RelationMerges))
+	}
+
+	if err := index.RevertDeduplication(report); err != nil {
+		t.Fatalf("RevertDeduplication: %v", err)
+	}
+	if got := indexSignature(index); got != original {
+		t.Errorf("撤销去重后索引应与未去重时一致\n得到:\n%s\n期望:\n%s", got, original)
+	}
+	if matches := index.GetEntitiesByKey("豆 腐"); len(matches) != 1 || matches[0].Metadata["node_id"] != "203000002" {
+		t.Errorf("撤销后豆 腐应重新指向原实体，得到 %d 个实体", len(matches))
+	}
+}
+
+func TestBuildFromDataModuleMergesConfiguredSynonyms(t *testing.T) {
+	dir := t.TempDir()
+	synonymsPath := filepath.Join(dir, "synonyms.json")
+	if err := os.WriteFile(synonymsPath, []byte(`{"番茄": "西红柿"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	graph := NewCSVGraphFromRows([]map[string]string{
+		{"nodeId": "205000001", "labels": "Recipe", "name": "番茄炒蛋"},
+		{"nodeId": "205000002", "labels": "Ingredient", "name": "番茄"},
+		{"nodeId": "206000001", "labels": "Recipe", "name": "西红柿蛋汤"},
+		{"nodeId": "206000002", "labels": "Ingredient", "name": "西红柿"},
+	}, []map[string]string{
+		{"startNodeId": "205000001", "endNodeId": "205000002", "relationshipType": relationCodeRequires},
+		{"startNodeId": "206000001", "endNodeId": "206000002", "relationshipType": relationCodeRequires},
+	})
+	dataModule := &GraphDataPreparationModule{
+		Recipes:     graph.NodesByLabel("Recipe"),
+		Ingredients: graph.NodesByLabel("Ingredient"),
+		csvGraph:    graph,
+	}
+
+	reportPath := filepath.Join(dir, "dedup.json")
+	plain := NewGraphIndexingModule(&Config{DedupReportPath: reportPath}, ark.ChatModel{})
+	index := NewGraphIndexingModule(&Config{DedupReportPath: reportPath, DedupSynonymsPath: synonymsPath}, ark.ChatModel{})
+	if err := index.LoadConfigFiles(); err != nil {
+		t.Fatalf("LoadConfigFiles: %v", err)
+	}
+	if index.configHash() == plain.configHash() {
+		t.Error("同义词表应计入索引配置指纹，否则修改同义词表后会加载过期快照")
+	}
+
+	if err := index.BuildFromDataModule(context.Background(), dataModule); err != nil {
+		t.Fatalf("BuildFromDataModule: %v", err)
+	}
+	report, err := LoadDedupReport(reportPath)
+	if err != nil {
+		t.Fatalf("LoadDedupReport: %v", err)
+	}
+	if len(report.EntityMerges) != 1 {
+		t.Fatalf("番茄和西红柿应合并为1组，得到 %d 组", len(report.EntityMerges))
+	}
+	merge := report.EntityMerges[0]
+	if merge.Reason != MergeReasonSynonym || merge.SurvivorID != "205000002" || strings.Join(merge.MergedNames, ",") != "西红柿" {
+		t.Errorf("合并结果错误: %+v", merge)
+	}
+	if matches := index.GetEntitiesByKey("西红柿"); len(matches) != 1 || matches[0].Metadata["node_id"] != "205000002" {
+		t.Errorf("西红柿应作为番茄实体的别名键，得到 %d 个实体", len(matches))
+	}
+}
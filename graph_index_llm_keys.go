@@ -0,0 +1,356 @@
+package batch_0001
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/prompt"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	DefaultLLMRelationKeyWorkers   = 4                // 默认并发请求数（配置项 llm_relation_key_workers）
+	DefaultLLMRelationKeyRPS       = 5.0              // 默认每秒请求数上限（配置项 llm_relation_key_rps）
+	maxLimitedLLMRelationKeyRPS    = 1000.0           // 每秒请求数超过该值时不限流
+	llmRelationKeyCheckpointEvery  = 50               // 每完成多少个关系签名保存一次检查点
+	llmRelationKeyProgressInterval = 10 * time.Second // 进度日志间隔
+)
+
+// errLLMRelationKeysIncomplete 部分关系签名的LLM主题键生成失败
+var errLLMRelationKeysIncomplete = errors.New("LLM关系索引键未全部生成")
+
+// relationKeySignature LLM主题键的缓存签名
+//
+// 同一类源实体以同一关系指向同一目标时，LLM生成的主题键相同，
+// 如所有菜谱REQUIRES"鸡胸肉"只需请求一次。
+type relationKeySignature struct {
+	SourceType   string
+	TargetName   string
+	TargetType   string
+	RelationType string
+}
+
+// String 签名的缓存键
+func (s relationKeySignature) String() string {
+	return s.SourceType + "|" + s.RelationType + "|" + s.TargetType + ":" + s.TargetName
+}
+
+// llmRelationKeyCheckpoint LLM主题键检查点文件，中断后重新构建时跳过已生成的签名
+type llmRelationKeyCheckpoint struct {
+	Model    string              `json:"model"`    // 生成主题键的模型，模型变化时检查点作废
+	Keywords map[string][]string `json:"keywords"` // 关系签名 -> 主题关键词
+}
+
+// llmRelationKeyResult 单个关系签名的生成结果
+type llmRelationKeyResult struct {
+	signature string
+	keywords  []string
+	err       error
+}
+
+// enhanceRelationKeys 使用LLM为已创建的关系键值对并发生成全局主题键
+//
+// 关系按签名分组，每个签名只请求一次；请求通过工作池并发执行并按每秒请求数限流。
+// 已生成的签名定期写入检查点，中断（ctx取消）后重新构建时从检查点继续。
+// 生成失败的签名不写入检查点，返回包装errLLMRelationKeysIncomplete的错误。
+func (g *GraphIndexingModule) enhanceRelationKeys(ctx context.Context) error {
+	signatures := make(map[string]relationKeySignature)
+	groups := make(map[string][]string) // 签名 -> 关系ID列表
+	for relationID, relationKV := range g.relationKVStore {
+		sourceEntity := g.entityKVStore[relationKV.SourceEntity]
+		targetEntity := g.entityKVStore[relationKV.TargetEntity]
+		if sourceEntity == nil || targetEntity == nil {
+			continue
+		}
+		signature := relationKeySignature{
+			SourceType:   sourceEntity.EntityType,
+			TargetName:   targetEntity.EntityName,
+			TargetType:   targetEntity.EntityType,
+			RelationType: relationKV.RelationType,
+		}
+		key := signature.String()
+		signatures[key] = signature
+		groups[key] = append(groups[key], relationID)
+	}
+
+	checkpointPath := g.llmRelationKeyCheckpointPath()
+	checkpoint := g.loadLLMRelationKeyCheckpoint(checkpointPath)
+
+	var pending []string
+	for key := range signatures {
+		if _, ok := checkpoint.Keywords[key]; !ok {
+			pending = append(pending, key)
+		}
+	}
+	sort.Strings(pending)
+	log.Printf("开始LLM增强关系索引键: %d 个关系，%d 个关系签名，已缓存 %d 个，待生成 %d 个",
+		len(g.relationKVStore), len(signatures), len(signatures)-len(pending), len(pending))
+
+	failed := 0
+	if len(pending) > 0 {
+		failed = g.generateRelationKeywords(ctx, pending, signatures, checkpoint, checkpointPath)
+	}
+
+	// 已生成的主题键合并到关系索引键，部分失败或中断时也合并已完成的部分
+	enhanced := 0
+	for key, relationIDs := range groups {
+		keywords, ok := checkpoint.Keywords[key]
+		if !ok || len(keywords) == 0 {
+			continue
+		}
+		for _, relationID := range relationIDs {
+			g.addRelationIndexKeys(relationID, keywords)
+			enhanced++
+		}
+	}
+	log.Printf("LLM增强关系索引键完成: %d 个关系已增强", enhanced)
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("LLM增强关系索引键被中断: %w", err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d/%d 个关系签名失败", errLLMRelationKeysIncomplete, failed, len(pending))
+	}
+	return nil
+}
+
+// generateRelationKeywords 工作池并发生成待处理签名的主题键，结果写入checkpoint，返回失败数
+func (g *GraphIndexingModule) generateRelationKeywords(ctx context.Context, pending []string, signatures map[string]relationKeySignature, checkpoint *llmRelationKeyCheckpoint, checkpointPath string) int {
+	workers, rps := g.llmRelationKeyLimits()
+	// 每秒请求数过大时间隔会取整为0，NewTicker会panic，此时不限流
+	var limiter <-chan time.Time
+	if rps <= maxLimitedLLMRelationKeyRPS {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rps))
+		defer ticker.Stop()
+		limiter = ticker.C
+	}
+
+	jobs := make(chan string)
+	results := make(chan llmRelationKeyResult)
+
+	go func() {
+		defer close(jobs)
+		for _, key := range pending {
+			select {
+			case jobs <- key:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < min(workers, len(pending)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range jobs {
+				if limiter != nil {
+					select {
+					case <-limiter:
+					case <-ctx.Done():
+						return
+					}
+				}
+				keywords, err := g.llmEnhanceRelationKeys(ctx, signatures[key])
+				results <- llmRelationKeyResult{signature: key, keywords: keywords, err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var done, failed, unsaved int
+	lastProgress := time.Now()
+	for result := range results {
+		done++
+		if result.err != nil {
+			// 取消导致的失败不计入，下次构建时重试
+			if ctx.Err() == nil {
+				failed++
+				log.Printf("LLM增强关系索引键失败 (%s): %v", result.signature, result.err)
+			}
+		} else {
+			checkpoint.Keywords[result.signature] = result.keywords
+			unsaved++
+		}
+
+		if unsaved >= llmRelationKeyCheckpointEvery {
+			g.saveLLMRelationKeyCheckpoint(checkpointPath, checkpoint)
+			unsaved = 0
+		}
+		if time.Since(lastProgress) >= llmRelationKeyProgressInterval {
+			log.Printf("LLM增强关系索引键进度: %d/%d，失败 %d", done, len(pending), failed)
+			lastProgress = time.Now()
+		}
+	}
+	if unsaved > 0 {
+		g.saveLLMRelationKeyCheckpoint(checkpointPath, checkpoint)
+	}
+	return failed
+}
+
+// addRelationIndexKeys 为关系追加索引键并更新键映射，已有的键跳过
+//
+// 只在未发布的索引上调用；键值对可能与已发布的索引共享，有新键时先复制再修改。
+func (g *GraphIndexingModule) addRelationIndexKeys(relationID string, keys []string) {
+	relationKV := g.relationKVStore[relationID]
+	copied := false
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" || containsString(relationKV.IndexKeys, key) {
+			continue
+		}
+		if !copied {
+			relationKV = copyRelationKeyValue(relationKV)
+			g.relationKVStore[relationID] = relationKV
+			copied = true
+		}
+		relationKV.IndexKeys = append(relationKV.IndexKeys, key)
+		g.keyToRelations[key] = append(g.keyToRelations[key], relationID)
+	}
+}
+
+// llmEnhanceRelationKeys 使用LLM为一个关系签名生成全局主题关键词
+func (g *GraphIndexingModule) llmEnhanceRelationKeys(ctx context.Context, signature relationKeySignature) ([]string, error) {
+	template := prompt.FromMessages(schema.FString,
+		schema.SystemMessage("你是一个{role}。"),
+		&schema.Message{
+			Role: schema.User,
+			Content: `分析以下实体关系，生成相关的主题关键词：
+			源实体类型: {source_type}
+			目标实体: {target_name} ({target_type})
+			关系类型: {relation_type}
+
+			请生成3-5个相关的主题关键词，用于索引和检索。
+			返回JSON格式：{{"keywords": ["关键词1", "关键词2", "关键词3"]}}`,
+		},
+	)
+
+	values := map[string]interface{}{
+		"role":          "烹饪知识图谱专家",
+		"source_type":   signature.SourceType,
+		"target_name":   signature.TargetName,
+		"target_type":   signature.TargetType,
+		"relation_type": signature.RelationType,
+	}
+
+	messages, err := template.Format(ctx, values)
+	if err != nil {
+		return nil, fmt.Errorf("构建提示词失败: %w", err)
+	}
+
+	response, err := g.llmClient.Generate(ctx, messages, model.WithTemperature(0.1), model.WithMaxTokens(200))
+	if err != nil {
+		return nil, fmt.Errorf("调用LLM失败: %w", err)
+	}
+
+	var result LLMKeywordsResponse
+	if err := json.Unmarshal([]byte(cleanJSONResponse(response.Content)), &result); err != nil {
+		return nil, fmt.Errorf("解析LLM响应失败: %w", err)
+	}
+	return result.Keywords, nil
+}
+
+// llmRelationKeyLimits 并发请求数和每秒请求数上限
+func (g *GraphIndexingModule) llmRelationKeyLimits() (workers int, rps float64) {
+	workers, rps = DefaultLLMRelationKeyWorkers, DefaultLLMRelationKeyRPS
+	if g.config == nil {
+		return workers, rps
+	}
+	if value, ok := constraintNumber(g.config.Constraints, "llm_relation_key_workers"); ok && value >= 1 {
+		workers = int(value)
+	}
+	if value, ok := constraintNumber(g.config.Constraints, "llm_relation_key_rps"); ok && value > 0 {
+		rps = value
+	}
+	return workers, rps
+}
+
+// constraintNumber 以数值形式读取配置项，兼容JSON解码得到的float64
+func constraintNumber(constraints map[string]interface{}, key string) (float64, bool) {
+	switch v := constraints[key].(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// llmRelationKeyCheckpointPath 检查点文件路径，与图索引快照放在一起；未配置快照路径时不保存检查点
+func (g *GraphIndexingModule) llmRelationKeyCheckpointPath() string {
+	if g.config == nil || g.config.GraphIndexPath == "" {
+		return ""
+	}
+	path := g.config.GraphIndexPath
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".llm_keys.json"
+}
+
+// loadLLMRelationKeyCheckpoint 读取检查点，文件不存在、损坏或模型不一致时返回空检查点
+func (g *GraphIndexingModule) loadLLMRelationKeyCheckpoint(path string) *llmRelationKeyCheckpoint {
+	checkpoint := &llmRelationKeyCheckpoint{Model: g.config.LLMModel, Keywords: make(map[string][]string)}
+	if path == "" {
+		return checkpoint
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("读取LLM关系键检查点失败: %v", err)
+		}
+		return checkpoint
+	}
+
+	var saved llmRelationKeyCheckpoint
+	if err := json.Unmarshal(data, &saved); err != nil {
+		log.Printf("解析LLM关系键检查点失败，重新生成: %v", err)
+		return checkpoint
+	}
+	if saved.Model != checkpoint.Model {
+		log.Printf("LLM关系键检查点的模型已变化 (%s -> %s)，重新生成", saved.Model, checkpoint.Model)
+		return checkpoint
+	}
+	if saved.Keywords != nil {
+		checkpoint.Keywords = saved.Keywords
+	}
+	return checkpoint
+}
+
+// saveLLMRelationKeyCheckpoint 保存检查点，失败只记录日志
+func (g *GraphIndexingModule) saveLLMRelationKeyCheckpoint(path string, checkpoint *llmRelationKeyCheckpoint) {
+	if path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Printf("创建LLM关系键检查点目录失败: %v", err)
+		return
+	}
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		log.Printf("序列化LLM关系键检查点失败: %v", err)
+		return
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		log.Printf("写入LLM关系键检查点失败: %v", err)
+		return
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		log.Printf("保存LLM关系键检查点失败: %v", err)
+	}
+}
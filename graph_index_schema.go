@@ -0,0 +1,338 @@
+package batch_0001
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// GraphIndexSchema 图索引的结构声明：哪些标签的节点生成实体键值对、内容包含哪些属性，
+// 以及哪些类型的关系生成关系键值对、带哪些主题键
+//
+// 未声明的标签和关系类型不进入索引。默认结构见DefaultGraphIndexSchema，
+// 也可以通过配置项graph_index_schema_path从JSON文件加载。
+type GraphIndexSchema struct {
+	Entities  []EntityIndexSchema   `json:"entities"`
+	Relations []RelationIndexSchema `json:"relations"`
+}
+
+// EntityIndexSchema 一类实体的索引声明
+//
+// 名称模板和主题键模板中，{name}为节点名称，{nodeId}为节点ID，其余{xxx}为节点属性；
+// 模板引用的值为空时整个模板视为空。
+type EntityIndexSchema struct {
+	Label        string           `json:"label"`         // 节点标签，同时作为实体类型
+	Title        string           `json:"title"`         // 内容首行的名称标题，如"菜品名称"
+	NameTemplate string           `json:"name_template"` // 实体名称模板，为空时使用{name}
+	DefaultName  string           `json:"default_name"`  // 名称为空时的名称模板，如"菜谱_{nodeId}"
+	Fields       []IndexFieldSpec `json:"fields"`        // 写入内容的属性，按顺序，缺失的属性跳过
+	KeyFields    []string         `json:"key_fields"`    // 额外作为索引键的属性，逗号分隔的值拆成多个键
+}
+
+// IndexFieldSpec 写入实体内容的一个属性
+type IndexFieldSpec struct {
+	Property string `json:"property"` // 属性名
+	Title    string `json:"title"`    // 内容中的标题，如"菜系"
+}
+
+// RelationIndexSchema 一类或几类关系的索引声明
+//
+// 主题键模板中，{source}、{target}为源、目标实体名称，{source.xxx}、{target.xxx}为其节点属性。
+// 关系类型本身总是作为第一个索引键。
+type RelationIndexSchema struct {
+	Types     []string `json:"types"`      // 关系类型，如["HAS_STEP", "CONTAINS_STEP"]
+	ThemeKeys []string `json:"theme_keys"` // 主题键模板
+}
+
+// indexTemplatePattern 模板中的{变量}
+var indexTemplatePattern = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// DefaultGraphIndexSchema 覆盖导入脚本生成的全部节点和关系类型的默认索引结构
+//
+// 层次结构节点（nodeId小于200000000）和派生的概念类型、步骤顺序关系不参与检索，不做声明。
+func DefaultGraphIndexSchema() *GraphIndexSchema {
+	return &GraphIndexSchema{
+		Entities: []EntityIndexSchema{
+			{
+				Label:       "Recipe",
+				Title:       "菜品名称",
+				DefaultName: "菜谱_{nodeId}",
+				Fields: []IndexFieldSpec{
+					{Property: "description", Title: "描述"},
+					{Property: "category", Title: "分类"},
+					{Property: "cuisineType", Title: "菜系"},
+					{Property: "difficulty", Title: "难度"},
+					{Property: "prepTime", Title: "准备时间"},
+					{Property: "cookTime", Title: "制作时间"},
+					{Property: "servings", Title: "份量"},
+					{Property: "tags", Title: "标签"},
+				},
+			},
+			{
+				Label:       "Ingredient",
+				Title:       "食材名称",
+				DefaultName: "食材_{nodeId}",
+				Fields: []IndexFieldSpec{
+					{Property: "category", Title: "类别"},
+					{Property: "nutrition", Title: "营养信息"},
+					{Property: "storage", Title: "储存方式"},
+				},
+			},
+			{
+				Label:        "CookingStep",
+				Title:        "烹饪步骤",
+				NameTemplate: "步骤_{nodeId}",
+				Fields: []IndexFieldSpec{
+					{Property: "description", Title: "步骤描述"},
+					{Property: "stepNumber", Title: "步骤顺序"},
+					{Property: "methods", Title: "烹饪方法"},
+					{Property: "tools", Title: "工具"},
+					{Property: "timeEstimate", Title: "时间"},
+				},
+			},
+			{
+				Label: "CookingMethod",
+				Title: "烹饪方法",
+				Fields: []IndexFieldSpec{
+					{Property: "fsn", Title: "全称"},
+					{Property: "description", Title: "描述"},
+				},
+				KeyFields: []string{"synonyms"},
+			},
+			{
+				Label: "CookingTool",
+				Title: "烹饪工具",
+				Fields: []IndexFieldSpec{
+					{Property: "fsn", Title: "全称"},
+					{Property: "description", Title: "描述"},
+				},
+				KeyFields: []string{"synonyms"},
+			},
+			{
+				Label: "DifficultyLevel",
+				Title: "难度等级",
+				Fields: []IndexFieldSpec{
+					{Property: "fsn", Title: "全称"},
+					{Property: "level", Title: "星级"},
+				},
+			},
+			{
+				Label: "RecipeCategory",
+				Title: "菜谱分类",
+				Fields: []IndexFieldSpec{
+					{Property: "fsn", Title: "全称"},
+					{Property: "description", Title: "描述"},
+				},
+			},
+			{
+				Label: "Category",
+				Title: "菜品分类",
+			},
+		},
+		Relations: []RelationIndexSchema{
+			{
+				Types:     []string{"REQUIRES"},
+				ThemeKeys: []string{"食材搭配", "烹饪原料", "{source}_食材", "{target}"},
+			},
+			{
+				Types:     []string{"HAS_STEP", "CONTAINS_STEP"},
+				ThemeKeys: []string{"制作步骤", "烹饪过程", "{source}_步骤", "制作方法"},
+			},
+			{
+				// 菜系也作为菜谱的主题键
+				Types:     []string{"BELONGS_TO_CATEGORY"},
+				ThemeKeys: []string{"菜品分类", "美食类别", "{target}", "{source.cuisineType}"},
+			},
+			{
+				Types:     []string{"BELONGS_TO"},
+				ThemeKeys: []string{"菜品分类", "菜谱分类", "{target}"},
+			},
+			{
+				Types:     []string{"DIFFICULTY_LEVEL", "HAS_DIFFICULTY_LEVEL"},
+				ThemeKeys: []string{"难度等级", "制作难度", "{target}", "{target}难度"},
+			},
+		},
+	}
+}
+
+// LoadGraphIndexSchema 从JSON文件加载索引结构
+func LoadGraphIndexSchema(path string) (*GraphIndexSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取图索引结构失败: %w", err)
+	}
+	var schema GraphIndexSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("解析图索引结构失败: %w", err)
+	}
+	if err := schema.Validate(); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// Validate 检查标签和关系类型非空且不重复
+func (s *GraphIndexSchema) Validate() error {
+	labels := make(map[string]bool)
+	for i, entity := range s.Entities {
+		if entity.Label == "" {
+			return fmt.Errorf("图索引结构第 %d 个实体缺少label", i+1)
+		}
+		if labels[entity.Label] {
+			return fmt.Errorf("图索引结构的实体标签重复: %s", entity.Label)
+		}
+		labels[entity.Label] = true
+	}
+	types := make(map[string]bool)
+	for i, relation := range s.Relations {
+		if len(relation.Types) == 0 {
+			return fmt.Errorf("图索引结构第 %d 个关系缺少types", i+1)
+		}
+		for _, relationType := range relation.Types {
+			if types[relationType] {
+				return fmt.Errorf("图索引结构的关系类型重复: %s", relationType)
+			}
+			types[relationType] = true
+		}
+	}
+	return nil
+}
+
+// Labels 声明的全部节点标签
+func (s *GraphIndexSchema) Labels() []string {
+	labels := make([]string, 0, len(s.Entities))
+	for _, entity := range s.Entities {
+		labels = append(labels, entity.Label)
+	}
+	return labels
+}
+
+// RelationTypes 声明的全部关系类型
+func (s *GraphIndexSchema) RelationTypes() []string {
+	var types []string
+	for _, relation := range s.Relations {
+		types = append(types, relation.Types...)
+	}
+	return types
+}
+
+// entity 按标签查找实体声明
+func (s *GraphIndexSchema) entity(label string) *EntityIndexSchema {
+	for i := range s.Entities {
+		if s.Entities[i].Label == label {
+			return &s.Entities[i]
+		}
+	}
+	return nil
+}
+
+// relation 按关系类型查找关系声明
+func (s *GraphIndexSchema) relation(relationType string) *RelationIndexSchema {
+	for i := range s.Relations {
+		if containsString(s.Relations[i].Types, relationType) {
+			return &s.Relations[i]
+		}
+	}
+	return nil
+}
+
+// entityName 按声明生成节点的实体名称
+func (e *EntityIndexSchema) entityName(node GraphNode) string {
+	lookup := nodeTemplateLookup(node)
+	template := e.NameTemplate
+	if template == "" {
+		template = "{name}"
+	}
+	if name := expandIndexTemplate(template, lookup); name != "" {
+		return name
+	}
+	return expandIndexTemplate(e.DefaultName, lookup)
+}
+
+// content 按声明生成实体内容
+func (e *EntityIndexSchema) content(name string, properties map[string]interface{}) string {
+	title := e.Title
+	if title == "" {
+		title = e.Label
+	}
+	parts := []string{fmt.Sprintf("%s: %s", title, name)}
+	for _, field := range e.Fields {
+		if value, exists := properties[field.Property]; exists && value != nil && fmt.Sprint(value) != "" {
+			parts = append(parts, fmt.Sprintf("%s: %v", field.Title, value))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// indexKeys 实体的索引键：名称和声明的额外属性
+func (e *EntityIndexSchema) indexKeys(name string, properties map[string]interface{}) []string {
+	keys := []string{name}
+	for _, property := range e.KeyFields {
+		value := strings.Trim(getStringFromMap(properties, property, ""), "[]")
+		for _, key := range strings.Split(value, ",") {
+			if key = strings.Trim(strings.TrimSpace(key), `"'`); key != "" {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// themeKeys 按声明展开关系的主题键，关系类型本身为第一个键
+func (r *RelationIndexSchema) themeKeys(relationType string, source, target *EntityKeyValue) []string {
+	lookup := func(name string) string {
+		switch name {
+		case "source":
+			return source.EntityName
+		case "target":
+			return target.EntityName
+		}
+		entity := source
+		if strings.HasPrefix(name, "target.") {
+			entity = target
+		} else if !strings.HasPrefix(name, "source.") {
+			return ""
+		}
+		properties, _ := entity.Metadata["properties"].(map[string]interface{})
+		return getStringFromMap(properties, name[strings.Index(name, ".")+1:], "")
+	}
+
+	keys := []string{relationType}
+	for _, template := range r.ThemeKeys {
+		if key := expandIndexTemplate(template, lookup); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// nodeTemplateLookup 节点模板变量：name、nodeId和节点属性
+func nodeTemplateLookup(node GraphNode) func(string) string {
+	return func(name string) string {
+		switch name {
+		case "name":
+			return node.Name
+		case "nodeId":
+			return node.NodeID
+		}
+		return getStringFromMap(node.Properties, name, "")
+	}
+}
+
+// expandIndexTemplate 展开模板，任一变量为空时返回空字符串
+func expandIndexTemplate(template string, lookup func(string) string) string {
+	missing := false
+	expanded := indexTemplatePattern.ReplaceAllStringFunc(template, func(match string) string {
+		value := strings.TrimSpace(lookup(match[1 : len(match)-1]))
+		if value == "" {
+			missing = true
+		}
+		return value
+	})
+	if missing {
+		return ""
+	}
+	return strings.TrimSpace(expanded)
+}
@@ -0,0 +1,230 @@
+package batch_0001
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// graphIndexSnapshotVersion 快照格式版本，索引键的生成规则变化时递增
+const graphIndexSnapshotVersion = 1
+
+// graphIndexSnapshotFile 图索引快照文件
+//
+// Payload单独保存为原始JSON，校验和针对Payload的字节计算，加载时先校验再解析。
+type graphIndexSnapshotFile struct {
+	Version    int             `json:"version"`
+	CreatedAt  time.Time       `json:"created_at"`
+	SourceHash string          `json:"source_hash"` // 源图数据指纹
+	ConfigHash string          `json:"config_hash"` // 索引配置指纹
+	Checksum   string          `json:"checksum"`    // Payload的SHA-256
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// graphIndexSnapshotPayload 快照中的索引数据，键映射加载后重建
+type graphIndexSnapshotPayload struct {
+	Entities  map[string]*EntityKeyValue   `json:"entities"`
+	Relations map[string]*RelationKeyValue `json:"relations"`
+}
+
+// GraphIndexSnapshotInfo 快照的元信息
+type GraphIndexSnapshotInfo struct {
+	Version    int       `json:"version"`
+	CreatedAt  time.Time `json:"created_at"`
+	SourceHash string    `json:"source_hash"`
+	ConfigHash string    `json:"config_hash"`
+	Entities   int       `json:"entities"`
+	Relations  int       `json:"relations"`
+}
+
+// SaveSnapshot 把索引保存为磁盘快照，记录源图数据指纹和索引配置指纹
+func (g *GraphIndexingModule) SaveSnapshot(path string) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("创建快照目录失败: %w", err)
+		}
+	}
+
+	g.indexMu.RLock()
+	entities, relations, sourceHash := g.entityKVStore, g.relationKVStore, g.sourceHash
+	g.indexMu.RUnlock()
+
+	payload, err := json.Marshal(&graphIndexSnapshotPayload{
+		Entities:  entities,
+		Relations: relations,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化图索引失败: %w", err)
+	}
+	checksum := sha256.Sum256(payload)
+
+	data, err := json.Marshal(&graphIndexSnapshotFile{
+		Version:    graphIndexSnapshotVersion,
+		CreatedAt:  time.Now(),
+		SourceHash: sourceHash,
+		ConfigHash: g.configHash(),
+		Checksum:   hex.EncodeToString(checksum[:]),
+		Payload:    payload,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化图索引快照失败: %w", err)
+	}
+
+	// 先写临时文件再重命名，避免中断时留下不完整的快照
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("写入图索引快照失败: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("保存图索引快照失败: %w", err)
+	}
+
+	log.Printf("图索引快照已保存: %s", path)
+	return nil
+}
+
+// LoadSnapshot 从磁盘快照加载索引
+//
+// 版本不一致、校验和不匹配或索引配置指纹不一致（如更换了LLM模型、开关了LLM关系键）时返回错误，
+// 且不修改当前索引。快照是否与源图数据一致由调用方比对返回的SourceHash判断。
+func (g *GraphIndexingModule) LoadSnapshot(path string) (*GraphIndexSnapshotInfo, error) {
+	g.updateMu.Lock()
+	defer g.updateMu.Unlock()
+
+	file, payload, err := g.readSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+	g.applySnapshot(file, payload)
+	log.Printf("图索引快照已加载: %s，%d 个实体，%d 个关系", path, len(payload.Entities), len(payload.Relations))
+	return snapshotInfo(file, payload), nil
+}
+
+// readSnapshot 读取并校验快照文件
+func (g *GraphIndexingModule) readSnapshot(path string) (*graphIndexSnapshotFile, *graphIndexSnapshotPayload, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取图索引快照失败: %w", err)
+	}
+
+	var file graphIndexSnapshotFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, nil, fmt.Errorf("解析图索引快照失败: %w", err)
+	}
+	if file.Version != graphIndexSnapshotVersion {
+		return nil, nil, fmt.Errorf("图索引快照版本不匹配: %d != %d", file.Version, graphIndexSnapshotVersion)
+	}
+	checksum := sha256.Sum256(file.Payload)
+	if hex.EncodeToString(checksum[:]) != file.Checksum {
+		return nil, nil, fmt.Errorf("图索引快照校验和不匹配，文件可能已损坏")
+	}
+	if file.ConfigHash != g.configHash() {
+		return nil, nil, fmt.Errorf("图索引快照的索引配置已变化")
+	}
+
+	var payload graphIndexSnapshotPayload
+	if err := json.Unmarshal(file.Payload, &payload); err != nil {
+		return nil, nil, fmt.Errorf("解析图索引数据失败: %w", err)
+	}
+	if payload.Entities == nil {
+		payload.Entities = make(map[string]*EntityKeyValue)
+	}
+	if payload.Relations == nil {
+		payload.Relations = make(map[string]*RelationKeyValue)
+	}
+	return &file, &payload, nil
+}
+
+// applySnapshot 用快照数据替换当前索引，调用方持有updateMu
+func (g *GraphIndexingModule) applySnapshot(file *graphIndexSnapshotFile, payload *graphIndexSnapshotPayload) {
+	next := g.newIndex()
+	next.entityKVStore = payload.Entities
+	next.relationKVStore = payload.Relations
+	next.sourceHash = file.SourceHash
+	next.rebuildKeyMappings()
+	g.publishIndex(next)
+}
+
+// loadFreshSnapshot 快照与源图数据指纹一致时加载快照，返回是否已加载；调用方持有updateMu
+func (g *GraphIndexingModule) loadFreshSnapshot(path, sourceHash string) bool {
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+	file, payload, err := g.readSnapshot(path)
+	if err != nil {
+		log.Printf("图索引快照不可用，重新构建: %v", err)
+		return false
+	}
+	if file.SourceHash != sourceHash {
+		log.Println("图索引快照已过期（图数据已变化），重新构建")
+		return false
+	}
+	g.applySnapshot(file, payload)
+	log.Printf("图索引快照已加载: %s，%d 个实体，%d 个关系", path, len(payload.Entities), len(payload.Relations))
+	return true
+}
+
+// snapshotInfo 快照元信息
+func snapshotInfo(file *graphIndexSnapshotFile, payload *graphIndexSnapshotPayload) *GraphIndexSnapshotInfo {
+	return &GraphIndexSnapshotInfo{
+		Version:    file.Version,
+		CreatedAt:  file.CreatedAt,
+		SourceHash: file.SourceHash,
+		ConfigHash: file.ConfigHash,
+		Entities:   len(payload.Entities),
+		Relations:  len(payload.Relations),
+	}
+}
+
+// SourceHash 当前索引对应的源图数据指纹
+func (g *GraphIndexingModule) SourceHash() string {
+	g.indexMu.RLock()
+	defer g.indexMu.RUnlock()
+	return g.sourceHash
+}
+
+// configHash 影响索引内容的配置指纹：索引结构声明、是否启用LLM关系键及所用模型、是否去重及同义词表
+//
+// 未开启去重时不写入去重项，已有快照的指纹保持不变。
+func (g *GraphIndexingModule) configHash() string {
+	llmKeys := g.llmRelationKeysEnabled()
+	model := ""
+	if llmKeys && g.config != nil {
+		model = g.config.LLMModel
+	}
+	fields := map[string]interface{}{
+		"schema":            g.schema,
+		"llm_relation_keys": llmKeys,
+		"llm_model":         model,
+	}
+	if g.dedupEnabled() {
+		fields["dedup"] = true
+		if len(g.entitySynonyms) > 0 {
+			fields["dedup_synonyms"] = g.entitySynonyms
+		}
+	}
+	return fingerprintOf(fields)
+}
+
+// graphIndexSourceHash 计算索引输入（实体节点和关系）的指纹，用于判断快照是否过期
+func graphIndexSourceHash(nodes []GraphNode, relationships []*Relationship) string {
+	nodeParts := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		nodeParts = append(nodeParts, node.NodeID+"|"+node.Name+"|"+fingerprintOf(node.Properties))
+	}
+	sort.Strings(nodeParts)
+
+	relationParts := make([]string, 0, len(relationships))
+	for _, relationship := range relationships {
+		relationParts = append(relationParts, relationship.SourceID+"|"+relationship.RelationType+"|"+relationship.TargetID)
+	}
+	sort.Strings(relationParts)
+
+	return fingerprintOf([]interface{}{nodeParts, relationParts})
+}
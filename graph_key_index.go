@@ -0,0 +1,330 @@
+package batch_0001
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+// 键匹配方式
+const (
+	KeyMatchExact         = "exact"          // 与键完全相同（忽略大小写）
+	KeyMatchPrefix        = "prefix"         // 键以关键词开头
+	KeyMatchSubstring     = "substring"      // 键包含关键词
+	KeyMatchPinyin        = "pinyin"         // 关键词与键的全拼相同，如"gongbaojiding"
+	KeyMatchPinyinInitial = "pinyin_initial" // 关键词与键的拼音首字母相同，如"gbjd"
+	KeyMatchPinyinPrefix  = "pinyin_prefix"  // 键的全拼以关键词开头
+	KeyMatchEditDistance  = "edit_distance"  // 与键的编辑距离在容差内，如错别字
+)
+
+// keyMatchScores 各匹配方式的基础得分，编辑距离匹配再按距离扣分
+var keyMatchScores = map[string]float64{
+	KeyMatchExact:         1.0,
+	KeyMatchPrefix:        0.9,
+	KeyMatchPinyin:        0.85,
+	KeyMatchSubstring:     0.8,
+	KeyMatchPinyinInitial: 0.75,
+	KeyMatchPinyinPrefix:  0.7,
+	KeyMatchEditDistance:  0.7,
+}
+
+// KeyMatch 一个索引键的匹配结果
+type KeyMatch struct {
+	Key      string  `json:"key"`      // 匹配到的索引键
+	Kind     string  `json:"kind"`     // 匹配方式
+	Score    float64 `json:"score"`    // 匹配得分，0到1
+	Distance int     `json:"distance"` // 编辑距离，仅编辑距离匹配时非零
+}
+
+// keyIndex 索引键的模糊查找结构
+//
+// 字符n-gram倒排表支持子串、前缀和编辑距离候选召回，拼音按全拼和首字母排序后二分查找，
+// 每次查询只验证少量候选键，不再遍历全部键。索引只读，键集合变化后重新构建。
+type keyIndex struct {
+	keys       []string         // 原始索引键
+	normalized []string         // 小写后的索引键
+	unigrams   map[rune][]int   // 字符 -> 键下标
+	bigrams    map[string][]int // 相邻两字 -> 键下标
+	pinyins    []pinyinEntry    // 按全拼排序
+	initials   []pinyinEntry    // 按首字母排序
+}
+
+// pinyinEntry 键的拼音形式
+type pinyinEntry struct {
+	text string
+	key  int
+}
+
+// newKeyIndex 为一组索引键构建查找结构，不修改传入的切片
+func newKeyIndex(keys []string) *keyIndex {
+	keys = append([]string(nil), keys...)
+	sort.Strings(keys)
+	idx := &keyIndex{
+		keys:       keys,
+		normalized: make([]string, len(keys)),
+		unigrams:   make(map[rune][]int),
+		bigrams:    make(map[string][]int),
+	}
+
+	args := pinyin.NewArgs()
+	args.Fallback = func(r rune, a pinyin.Args) []string {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return []string{string(unicode.ToLower(r))}
+		}
+		return nil
+	}
+
+	for i, key := range keys {
+		normalized := strings.ToLower(key)
+		idx.normalized[i] = normalized
+
+		runes := []rune(normalized)
+		seenUnigrams := make(map[rune]bool, len(runes))
+		seenBigrams := make(map[string]bool, len(runes))
+		for j, r := range runes {
+			if !seenUnigrams[r] {
+				seenUnigrams[r] = true
+				idx.unigrams[r] = append(idx.unigrams[r], i)
+			}
+			if j+1 < len(runes) {
+				bigram := string(runes[j : j+2])
+				if !seenBigrams[bigram] {
+					seenBigrams[bigram] = true
+					idx.bigrams[bigram] = append(idx.bigrams[bigram], i)
+				}
+			}
+		}
+
+		// 只为包含汉字的键建立拼音，纯字母键由子串匹配覆盖
+		syllables := pinyin.LazyPinyin(key, args)
+		if len(syllables) == 0 || !containsHan(key) {
+			continue
+		}
+		var full, initials strings.Builder
+		for _, syllable := range syllables {
+			full.WriteString(syllable)
+			initials.WriteByte(syllable[0])
+		}
+		idx.pinyins = append(idx.pinyins, pinyinEntry{text: full.String(), key: i})
+		idx.initials = append(idx.initials, pinyinEntry{text: initials.String(), key: i})
+	}
+
+	sortPinyinEntries(idx.pinyins)
+	sortPinyinEntries(idx.initials)
+	return idx
+}
+
+// Search 查找与关键词匹配的键，按得分从高到低排序，limit<=0时返回全部匹配
+func (idx *keyIndex) Search(keyword string, limit int) []KeyMatch {
+	query := strings.ToLower(strings.TrimSpace(keyword))
+	if query == "" || len(idx.keys) == 0 {
+		return nil
+	}
+
+	best := make(map[int]KeyMatch)
+	record := func(i int, kind string, distance int) {
+		score := keyMatchScores[kind]
+		if kind == KeyMatchEditDistance {
+			score = (score*10 - float64(distance)) / 10 // 每差一个字扣0.1
+		}
+		if current, ok := best[i]; ok && current.Score >= score {
+			return
+		}
+		best[i] = KeyMatch{Key: idx.keys[i], Kind: kind, Score: score, Distance: distance}
+	}
+
+	idx.searchSubstring(query, record)
+	if len(query) >= 2 && isPinyinQuery(query) {
+		idx.searchPinyin(query, record)
+	}
+	idx.searchEditDistance(query, best, record)
+
+	matches := make([]KeyMatch, 0, len(best))
+	for _, match := range best {
+		matches = append(matches, match)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		// 同分时更短的键与关键词更接近
+		if len(matches[i].Key) != len(matches[j].Key) {
+			return len(matches[i].Key) < len(matches[j].Key)
+		}
+		return matches[i].Key < matches[j].Key
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// searchSubstring 通过n-gram倒排表召回包含关键词的键
+//
+// 取关键词中出现次数最少的n-gram的倒排表作为候选，再逐个验证。
+func (idx *keyIndex) searchSubstring(query string, record func(int, string, int)) {
+	runes := []rune(query)
+	var candidates []int
+	if len(runes) == 1 {
+		candidates = idx.unigrams[runes[0]]
+	} else {
+		for j := 0; j+1 < len(runes); j++ {
+			posting, ok := idx.bigrams[string(runes[j:j+2])]
+			if !ok {
+				return
+			}
+			if candidates == nil || len(posting) < len(candidates) {
+				candidates = posting
+			}
+		}
+	}
+
+	for _, i := range candidates {
+		normalized := idx.normalized[i]
+		switch {
+		case normalized == query:
+			record(i, KeyMatchExact, 0)
+		case strings.HasPrefix(normalized, query):
+			record(i, KeyMatchPrefix, 0)
+		case strings.Contains(normalized, query):
+			record(i, KeyMatchSubstring, 0)
+		}
+	}
+}
+
+// searchPinyin 按全拼、全拼前缀和首字母匹配
+func (idx *keyIndex) searchPinyin(query string, record func(int, string, int)) {
+	query = strings.ReplaceAll(query, " ", "")
+	for _, entry := range prefixRange(idx.pinyins, query) {
+		if entry.text == query {
+			record(entry.key, KeyMatchPinyin, 0)
+		} else {
+			record(entry.key, KeyMatchPinyinPrefix, 0)
+		}
+	}
+	for _, entry := range prefixRange(idx.initials, query) {
+		if entry.text == query {
+			record(entry.key, KeyMatchPinyinInitial, 0)
+		}
+	}
+}
+
+// searchEditDistance 召回与关键词编辑距离在容差内的键，已有更好匹配的键跳过
+//
+// 候选键须与关键词共享足够多的字符且长度相近，只对候选计算编辑距离。
+func (idx *keyIndex) searchEditDistance(query string, best map[int]KeyMatch, record func(int, string, int)) {
+	// 两个字的关键词改一个字几乎就是另一个词（如"川菜"和"素菜"），不做编辑距离匹配
+	runes := []rune(query)
+	if len(runes) < 3 {
+		return
+	}
+	maxDistance := 1
+	if len(runes) > 4 {
+		maxDistance = 2
+	}
+
+	shared := make(map[int]int)
+	seen := make(map[rune]bool, len(runes))
+	for _, r := range runes {
+		if seen[r] {
+			continue
+		}
+		seen[r] = true
+		for _, i := range idx.unigrams[r] {
+			shared[i]++
+		}
+	}
+
+	minShared := max(len(seen)-maxDistance, 1)
+	for i, count := range shared {
+		if count < minShared {
+			continue
+		}
+		if _, matched := best[i]; matched {
+			continue
+		}
+		candidate := []rune(idx.normalized[i])
+		if len(candidate) < 2 || abs(len(candidate)-len(runes)) > maxDistance {
+			continue
+		}
+		if distance := levenshtein(runes, candidate, maxDistance); distance <= maxDistance {
+			record(i, KeyMatchEditDistance, distance)
+		}
+	}
+}
+
+// levenshtein 计算两个字符序列的编辑距离，超过limit时提前返回limit+1
+func levenshtein(a, b []rune, limit int) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		rowMin := current[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(min(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+			rowMin = min(rowMin, current[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// prefixRange 在已排序的拼音表中二分查找以prefix开头的条目
+func prefixRange(entries []pinyinEntry, prefix string) []pinyinEntry {
+	start := sort.Search(len(entries), func(i int) bool { return entries[i].text >= prefix })
+	end := start
+	for end < len(entries) && strings.HasPrefix(entries[end].text, prefix) {
+		end++
+	}
+	return entries[start:end]
+}
+
+// sortPinyinEntries 按拼音排序，拼音相同时按键下标
+func sortPinyinEntries(entries []pinyinEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].text != entries[j].text {
+			return entries[i].text < entries[j].text
+		}
+		return entries[i].key < entries[j].key
+	})
+}
+
+// isPinyinQuery 关键词是否为拼音（仅由字母和空格组成），单个字母会命中大量键，由调用方排除
+func isPinyinQuery(query string) bool {
+	for _, r := range query {
+		if r != ' ' && (r > unicode.MaxASCII || !unicode.IsLetter(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsHan 是否包含汉字
+func containsHan(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// abs 整数绝对值
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,64 @@
+package batch_0001
+
+import (
+	"testing"
+
+	"github.com/cloudwego/eino-ext/components/model/ark"
+)
+
+func TestNewKeyIndexKeepsInputOrder(t *testing.T) {
+	keys := []string{"红烧肉", "宫保鸡丁", "鸡蛋"}
+	idx := newKeyIndex(keys)
+
+	if keys[0] != "红烧肉" || keys[1] != "宫保鸡丁" || keys[2] != "鸡蛋" {
+		t.Errorf("newKeyIndex修改了传入的切片: %v", keys)
+	}
+	if matches := idx.Search("红烧肉", 1); len(matches) != 1 || matches[0].Kind != KeyMatchExact {
+		t.Errorf("精确匹配失败: %v", matches)
+	}
+}
+
+// realEntityKeys 按默认索引结构从 cypher/ 下的CSV数据生成实体索引键
+func realEntityKeys(b *testing.B) []string {
+	graph, err := LoadCSVGraph("cypher/nodes.csv", "cypher/relationships.csv")
+	if err != nil {
+		b.Skipf("缺少CSV数据: %v", err)
+	}
+	g := NewGraphIndexingModule(&Config{}, ark.ChatModel{})
+	for _, label := range g.schema.Labels() {
+		g.createEntityKeyValues(label, graph.NodesByLabel(label))
+	}
+	return g.GetAllEntityKeys()
+}
+
+func BenchmarkKeyIndexMatch(b *testing.B) {
+	keys := realEntityKeys(b)
+	idx := newKeyIndex(keys)
+	b.Logf("%d 个实体索引键", len(keys))
+
+	queries := []struct {
+		name    string
+		keyword string
+	}{
+		{"exact", "宫保鸡丁"},
+		{"substring", "鸡"},
+		{"pinyin", "hongshaorou"},
+		{"pinyin_initial", "gbjd"},
+		{"edit_distance", "西红柿炒鸡旦"},
+	}
+	for _, query := range queries {
+		b.Run(query.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				idx.Search(query.keyword, 10)
+			}
+		})
+	}
+}
+
+func BenchmarkNewKeyIndex(b *testing.B) {
+	keys := realEntityKeys(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		newKeyIndex(keys)
+	}
+}
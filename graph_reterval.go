@@ -12,7 +12,6 @@ import (
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/components/prompt"
 	"github.com/cloudwego/eino/schema"
-	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 )
 
 // QueryType 图查询类型枚举
@@ -74,13 +73,18 @@ type KnowledgeSubgraph struct {
 
 // Config 配置结构
 type Config struct {
-	Neo4jURI      string                 `json:"neo4j_uri"`
-	Neo4jUser     string                 `json:"neo4j_user"`
-	Neo4jPassword string                 `json:"neo4j_password"`
-	LLMModel      string                 `json:"llm_model"`
-	ArkAPIKey     string                 `json:"ark_api_key"`
-	ArkBaseURL    string                 `json:"ark_base_url"`
-	Constraints   map[string]interface{} `json:"constraints"`
+	Neo4jURI             string                 `json:"neo4j_uri"`
+	Neo4jUser            string                 `json:"neo4j_user"`
+	Neo4jPassword        string                 `json:"neo4j_password"`
+	LLMModel             string                 `json:"llm_model"`
+	ArkAPIKey            string                 `json:"ark_api_key"`
+	ArkBaseURL           string                 `json:"ark_base_url"`
+	BM25IndexPath        string                 `json:"bm25_index_path"`         // BM25索引持久化路径，为空时仅在内存中构建
+	GraphIndexPath       string                 `json:"graph_index_path"`        // 图索引快照路径，为空时每次启动重新构建图索引
+	GraphIndexSchemaPath string                 `json:"graph_index_schema_path"` // 图索引结构声明文件，为空时使用DefaultGraphIndexSchema
+	DedupReportPath      string                 `json:"dedup_report_path"`       // 图索引去重审计报告路径，为空时构建索引后不去重
+	DedupSynonymsPath    string                 `json:"dedup_synonyms_path"`     // 去重同义词表（JSON对象：别名 -> 标准名），为空时只按规范化名称合并
+	Constraints          map[string]interface{} `json:"constraints"`
 }
 
 // GraphRAGRetrieval 真正的图RAG检索系统 - 基于图结构的智能检索引擎
@@ -109,7 +113,8 @@ type Config struct {
 type GraphRAGRetrieval struct {
 	config    *Config
 	llmClient *ark.ChatModel
-	driver    neo4j.DriverWithContext
+	store     GraphStore // 图存储
+	ownsStore bool       // 图存储由本模块创建时，Close会关闭它
 
 	// 图结构缓存 - 提高重复查询的性能
 	entityCache   map[string]map[string]interface{} // 实体信息缓存
@@ -139,7 +144,7 @@ func NewGraphRAGRetrieval(config *Config) *GraphRAGRetrieval {
 
 // Initialize 初始化图RAG检索系统
 //
-// 建立Neo4j连接，构建图索引，为后续的图查询做准备。
+// 建立图存储连接，构建图索引，为后续的图查询做准备。
 func (g *GraphRAGRetrieval) Initialize(ctx context.Context) error {
 	log.Println("初始化图RAG检索系统...")
 
@@ -154,24 +159,17 @@ func (g *GraphRAGRetrieval) Initialize(ctx context.Context) error {
 	}
 	g.llmClient = arkClient
 
-	// 连接Neo4j图数据库
-	driver, err := neo4j.NewDriverWithContext(
-		g.config.Neo4jURI,
-		neo4j.BasicAuth(g.config.Neo4jUser, g.config.Neo4jPassword, ""),
-	)
-	if err != nil {
-		return fmt.Errorf("Neo4j连接失败: %w", err)
-	}
-	g.driver = driver
-
-	// 测试连接有效性
-	err = driver.VerifyConnectivity(ctx)
-	if err != nil {
-		return fmt.Errorf("Neo4j连接验证失败: %w", err)
+	// 未设置图存储时连接Neo4j
+	if g.store == nil {
+		store, err := ConnectNeo4jGraphStore(ctx, g.config.Neo4jURI, g.config.Neo4jUser, g.config.Neo4jPassword, "")
+		if err != nil {
+			return err
+		}
+		g.store = store
+		g.ownsStore = true
+		log.Println("Neo4j连接成功")
 	}
 
-	log.Println("Neo4j连接成功")
-
 	// 预热：构建实体和关系索引，加速后续查询
 	if err := g.buildGraphIndex(ctx); err != nil {
 		log.Printf("构建图索引失败: %v", err)
@@ -180,6 +178,28 @@ func (g *GraphRAGRetrieval) Initialize(ctx context.Context) error {
 	return nil
 }
 
+// SetGraphStore 设置图存储
+//
+// 需在Initialize之前调用；未设置时Initialize按配置连接Neo4j。
+func (g *GraphRAGRetrieval) SetGraphStore(store GraphStore) {
+	g.store = store
+	g.ownsStore = false
+}
+
+// Refresh 知识库同步后刷新图结构缓存
+//
+// 清空实体、关系和子图缓存并重新构建图索引，避免返回已变化或已删除节点的旧信息。
+func (g *GraphRAGRetrieval) Refresh(ctx context.Context) error {
+	g.entityCache = make(map[string]map[string]interface{})
+	g.relationCache = make(map[string]int)
+	g.subgraphCache = make(map[string]*KnowledgeSubgraph)
+
+	if err := g.buildGraphIndex(ctx); err != nil {
+		return fmt.Errorf("刷新图索引失败: %w", err)
+	}
+	return nil
+}
+
 // buildGraphIndex 构建图索引以加速查询
 //
 // 预先计算和缓存图中实体和关系的统计信息，
@@ -187,87 +207,24 @@ func (g *GraphRAGRetrieval) Initialize(ctx context.Context) error {
 func (g *GraphRAGRetrieval) buildGraphIndex(ctx context.Context) error {
 	log.Println("构建图结构索引...")
 
-	session := g.driver.NewSession(ctx, neo4j.SessionConfig{})
-	defer session.Close(ctx)
-
-	// 构建实体索引 - 计算每个节点的度数（连接关系数）
-	entityQuery := `
-		MATCH (n)
-		WHERE n.nodeId IS NOT NULL
-		WITH n, COUNT { (n)--() } as degree
-		RETURN labels(n) as node_labels, n.nodeId as node_id, 
-		       n.name as name, n.category as category, degree
-		ORDER BY degree DESC
-		LIMIT 1000
-	`
-
-	result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
-		result, err := tx.Run(ctx, entityQuery, nil)
-		if err != nil {
-			return nil, err
-		}
-
-		records, err := result.Collect(ctx)
-		if err != nil {
-			return nil, err
-		}
-
-		return records, nil
-	})
-
+	stats, err := g.store.DegreeStats(ctx, 1000)
 	if err != nil {
-		return fmt.Errorf("执行实体索引查询失败: %w", err)
+		return err
 	}
 
-	records := result.([]*neo4j.Record)
-	for _, record := range records {
-		nodeID, _ := record.Get("node_id")
-		nodeLabels, _ := record.Get("node_labels")
-		name, _ := record.Get("name")
-		category, _ := record.Get("category")
-		degree, _ := record.Get("degree")
-
-		// 缓存节点信息，包括重要的度数信息
-		g.entityCache[nodeID.(string)] = map[string]interface{}{
-			"labels":   nodeLabels,
-			"name":     name,
-			"category": category,
-			"degree":   degree, // 节点度数，用于重要性评估
+	// 缓存节点信息，包括重要的度数信息
+	for _, entry := range stats.Nodes {
+		g.entityCache[entry.Node.NodeID] = map[string]interface{}{
+			"labels":   entry.Node.Labels,
+			"name":     entry.Node.Name,
+			"category": entry.Node.Properties["category"],
+			"degree":   entry.Degree, // 节点度数，用于重要性评估
 		}
 	}
 
-	// 构建关系类型索引
-	// 统计每种关系类型的频率，用于查询优化
-	relationQuery := `
-		MATCH ()-[r]->()
-		RETURN type(r) as rel_type, count(r) as frequency
-		ORDER BY frequency DESC
-	`
-
-	result, err = session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
-		result, err := tx.Run(ctx, relationQuery, nil)
-		if err != nil {
-			return nil, err
-		}
-
-		records, err := result.Collect(ctx)
-		if err != nil {
-			return nil, err
-		}
-
-		return records, nil
-	})
-
-	if err != nil {
-		return fmt.Errorf("执行关系索引查询失败: %w", err)
-	}
-
-	records = result.([]*neo4j.Record)
-	for _, record := range records {
-		relType, _ := record.Get("rel_type")
-		frequency, _ := record.Get("frequency")
-
-		g.relationCache[relType.(string)] = int(frequency.(int64))
+	// 关系类型频率，用于查询优化
+	for relType, frequency := range stats.RelationCounts {
+		g.relationCache[relType] = frequency
 	}
 
 	log.Printf("索引构建完成: %d个实体, %d个关系类型",
@@ -396,87 +353,26 @@ func (g *GraphRAGRetrieval) MultiHopTraversal(ctx context.Context, graphQuery *G
 
 	var paths []*GraphPath
 
-	if g.driver == nil {
-		return paths, fmt.Errorf("Neo4j连接未建立")
+	if g.store == nil {
+		return paths, fmt.Errorf("图存储未初始化")
 	}
 
-	session := g.driver.NewSession(ctx, neo4j.SessionConfig{})
-	defer session.Close(ctx)
-
 	// 根据查询类型选择不同的遍历策略
 	if graphQuery.QueryType == MultiHop {
-		targetLabelsCondition := ""
-		if len(graphQuery.TargetEntities) > 0 {
-			targetLabelsCondition = "AND ANY(label IN labels(target) WHERE label IN $target_labels)"
-		}
-
-		// 构建多跳遍历查询
-		cypherQuery := fmt.Sprintf(`
-			// 多跳推理查询
-			UNWIND $source_entities as source_name
-			MATCH (source)
-			WHERE source.name CONTAINS source_name OR source.nodeId = source_name
-			
-			// 执行多跳遍历
-			MATCH path = (source)-[*1..%d]-(target)
-			WHERE NOT source = target
-			%s
-			
-			// 计算路径相关性
-			WITH path, source, target,
-			     length(path) as path_len,
-			     relationships(path) as rels,
-			     nodes(path) as path_nodes
-			
-			// 路径评分：短路径 + 高度数节点 + 关系类型匹配
-			WITH path, source, target, path_len, rels, path_nodes,
-			     (1.0 / path_len) + 
-			     (REDUCE(s = 0.0, n IN path_nodes | s + COUNT { (n)--() }) / 10.0 / size(path_nodes)) +
-			     (CASE WHEN ANY(r IN rels WHERE type(r) IN $relation_types) THEN 0.3 ELSE 0.0 END) as relevance
-			
-			ORDER BY relevance DESC
-			LIMIT 20
-			
-			RETURN path, source, target, path_len, rels, path_nodes, relevance
-		`, graphQuery.MaxDepth, targetLabelsCondition)
-
-		relationTypes := graphQuery.RelationTypes
-		if relationTypes == nil {
-			relationTypes = []string{}
-		}
-
-		result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
-			result, err := tx.Run(ctx, cypherQuery, map[string]interface{}{
-				"source_entities": graphQuery.SourceEntities,
-				"target_labels":   graphQuery.TargetEntities,
-				"relation_types":  relationTypes,
-			})
-			if err != nil {
-				return nil, err
-			}
-
-			records, err := result.Collect(ctx)
-			if err != nil {
-				return nil, err
-			}
-
-			return records, nil
+		multiHopPaths, err := g.store.FindPaths(ctx, PathQuery{
+			Sources:       graphQuery.SourceEntities,
+			TargetLabels:  graphQuery.TargetEntities,
+			RelationTypes: graphQuery.RelationTypes,
+			MaxDepth:      graphQuery.MaxDepth,
+			Limit:         20,
 		})
-
 		if err != nil {
-			return nil, fmt.Errorf("多跳遍历查询失败: %w", err)
-		}
-
-		records := result.([]*neo4j.Record)
-		for _, record := range records {
-			pathData := g.parseNeo4jPath(record)
-			if pathData != nil {
-				paths = append(paths, pathData)
-			}
+			return nil, err
 		}
+		paths = append(paths, multiHopPaths...)
 	} else if graphQuery.QueryType == EntityRelation {
 		// 实体间关系查询
-		entityPaths, err := g.findEntityRelations(ctx, graphQuery, session)
+		entityPaths, err := g.findEntityRelations(ctx, graphQuery)
 		if err != nil {
 			log.Printf("查找实体关系失败: %v", err)
 		} else {
@@ -484,7 +380,7 @@ func (g *GraphRAGRetrieval) MultiHopTraversal(ctx context.Context, graphQuery *G
 		}
 	} else if graphQuery.QueryType == PathFinding {
 		// 最短路径查找
-		shortestPaths, err := g.findShortestPaths(ctx, graphQuery, session)
+		shortestPaths, err := g.findShortestPaths(ctx, graphQuery)
 		if err != nil {
 			log.Printf("查找最短路径失败: %v", err)
 		} else {
@@ -501,67 +397,16 @@ func (g *GraphRAGRetrieval) MultiHopTraversal(ctx context.Context, graphQuery *G
 func (g *GraphRAGRetrieval) ExtractKnowledgeSubgraph(ctx context.Context, graphQuery *GraphQuery) (*KnowledgeSubgraph, error) {
 	log.Printf("提取知识子图: %v", graphQuery.SourceEntities)
 
-	if g.driver == nil {
-		return g.fallbackSubgraphExtraction(graphQuery), fmt.Errorf("Neo4j连接未建立")
+	if g.store == nil {
+		return g.fallbackSubgraphExtraction(graphQuery), fmt.Errorf("图存储未初始化")
 	}
 
-	session := g.driver.NewSession(ctx, neo4j.SessionConfig{})
-	defer session.Close(ctx)
-
-	// 简化的子图提取（不依赖APOC）
-	cypherQuery := fmt.Sprintf(`
-		// 找到源实体
-		UNWIND $source_entities as entity_name
-		MATCH (source)
-		WHERE source.name CONTAINS entity_name 
-		   OR source.nodeId = entity_name
-		
-		// 获取指定深度的邻居
-		MATCH (source)-[r*1..%d]-(neighbor)
-		WITH source, collect(DISTINCT neighbor) as neighbors, 
-		     collect(DISTINCT r) as relationships
-		WHERE size(neighbors) <= $max_nodes
-		
-		// 计算图指标
-		WITH source, neighbors, relationships,
-		     size(neighbors) as node_count,
-		     size(relationships) as rel_count
-		
-		RETURN 
-		    source,
-		    neighbors[0..%d] as nodes,
-		    relationships[0..%d] as rels,
-		    {
-		        node_count: node_count,
-		        relationship_count: rel_count,
-		        density: CASE WHEN node_count > 1 THEN toFloat(rel_count) / (node_count * (node_count - 1) / 2) ELSE 0.0 END
-		    } as metrics
-	`, graphQuery.MaxDepth, graphQuery.MaxNodes, graphQuery.MaxNodes)
-
-	result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
-		result, err := tx.Run(ctx, cypherQuery, map[string]interface{}{
-			"source_entities": graphQuery.SourceEntities,
-			"max_nodes":       graphQuery.MaxNodes,
-		})
-		if err != nil {
-			return nil, err
-		}
-
-		record, err := result.Single(ctx)
-		if err != nil {
-			return nil, err
-		}
-
-		return record, nil
-	})
-
+	subgraph, err := g.store.Subgraph(ctx, graphQuery.SourceEntities, graphQuery.MaxDepth, graphQuery.MaxNodes)
 	if err != nil {
 		log.Printf("子图提取失败: %v", err)
 		return g.fallbackSubgraphExtraction(graphQuery), err
 	}
-
-	record := result.(*neo4j.Record)
-	return g.buildKnowledgeSubgraph(record), nil
+	return subgraph, nil
 }
 
 // GraphStructureReasoning 基于图结构的推理：这是图RAG的智能之处
@@ -636,8 +481,8 @@ func (g *GraphRAGRetrieval) AdaptiveQueryPlanning(query string) []*GraphQuery {
 func (g *GraphRAGRetrieval) GraphRAGSearch(ctx context.Context, query string, topK int) ([]*schema.Document, error) {
 	log.Printf("开始图RAG检索: %s", query)
 
-	if g.driver == nil {
-		log.Println("Neo4j连接未建立，返回空结果")
+	if g.store == nil {
+		log.Println("图存储未初始化，返回空结果")
 		return []*schema.Document{}, nil
 	}
 
@@ -694,118 +539,6 @@ func (g *GraphRAGRetrieval) GraphRAGSearch(ctx context.Context, query string, to
 
 // ========== 辅助方法 ==========
 
-// parseNeo4jPath 解析Neo4j路径记录
-func (g *GraphRAGRetrieval) parseNeo4jPath(record *neo4j.Record) *GraphPath {
-	pathNodes, exists := record.Get("path_nodes")
-	if !exists {
-		return nil
-	}
-
-	rels, exists := record.Get("rels")
-	if !exists {
-		return nil
-	}
-
-	pathLen, exists := record.Get("path_len")
-	if !exists {
-		return nil
-	}
-
-	relevance, exists := record.Get("relevance")
-	if !exists {
-		return nil
-	}
-
-	// 转换节点
-	var nodes []map[string]interface{}
-	if nodeList, ok := pathNodes.([]interface{}); ok {
-		for _, node := range nodeList {
-			if n, ok := node.(neo4j.Node); ok {
-				nodeMap := map[string]interface{}{
-					"id":         n.Props["nodeId"],
-					"name":       n.Props["name"],
-					"labels":     n.Labels,
-					"properties": n.Props,
-				}
-				nodes = append(nodes, nodeMap)
-			}
-		}
-	}
-
-	// 转换关系
-	var relationships []map[string]interface{}
-	if relList, ok := rels.([]interface{}); ok {
-		for _, rel := range relList {
-			if r, ok := rel.(neo4j.Relationship); ok {
-				relMap := map[string]interface{}{
-					"type":       r.Type,
-					"properties": r.Props,
-				}
-				relationships = append(relationships, relMap)
-			}
-		}
-	}
-
-	return &GraphPath{
-		Nodes:          nodes,
-		Relationships:  relationships,
-		PathLength:     int(pathLen.(int64)),
-		RelevanceScore: relevance.(float64),
-		PathType:       "multi_hop",
-	}
-}
-
-// buildKnowledgeSubgraph 构建知识子图对象
-func (g *GraphRAGRetrieval) buildKnowledgeSubgraph(record *neo4j.Record) *KnowledgeSubgraph {
-	source, _ := record.Get("source")
-	nodes, _ := record.Get("nodes")
-	rels, _ := record.Get("rels")
-	metrics, _ := record.Get("metrics")
-
-	var centralNodes []map[string]interface{}
-	if sourceNode, ok := source.(neo4j.Node); ok {
-		centralNodes = append(centralNodes, sourceNode.Props)
-	}
-
-	var connectedNodes []map[string]interface{}
-	if nodeList, ok := nodes.([]interface{}); ok {
-		for _, node := range nodeList {
-			if n, ok := node.(neo4j.Node); ok {
-				connectedNodes = append(connectedNodes, n.Props)
-			}
-		}
-	}
-
-	var relationships []map[string]interface{}
-	if relList, ok := rels.([]interface{}); ok {
-		for _, rel := range relList {
-			if r, ok := rel.(neo4j.Relationship); ok {
-				relationships = append(relationships, r.Props)
-			}
-		}
-	}
-
-	var graphMetrics map[string]float64
-	if metricsMap, ok := metrics.(map[string]interface{}); ok {
-		graphMetrics = make(map[string]float64)
-		for k, v := range metricsMap {
-			if f, ok := v.(float64); ok {
-				graphMetrics[k] = f
-			} else if i, ok := v.(int64); ok {
-				graphMetrics[k] = float64(i)
-			}
-		}
-	}
-
-	return &KnowledgeSubgraph{
-		CentralNodes:    centralNodes,
-		ConnectedNodes:  connectedNodes,
-		Relationships:   relationships,
-		GraphMetrics:    graphMetrics,
-		ReasoningChains: [][]string{},
-	}
-}
-
 // pathsToDocuments 将图路径转换为Document对象
 func (g *GraphRAGRetrieval) pathsToDocuments(paths []*GraphPath, query string) []*schema.Document {
 	var documents []*schema.Document
@@ -989,13 +722,13 @@ func (g *GraphRAGRetrieval) validateReasoningChains(chains []string, query strin
 }
 
 // findEntityRelations 查找实体间关系
-func (g *GraphRAGRetrieval) findEntityRelations(ctx context.Context, graphQuery *GraphQuery, session neo4j.SessionWithContext) ([]*GraphPath, error) {
+func (g *GraphRAGRetrieval) findEntityRelations(ctx context.Context, graphQuery *GraphQuery) ([]*GraphPath, error) {
 	// 实现实体间关系查找逻辑
 	return []*GraphPath{}, nil
 }
 
 // findShortestPaths 查找最短路径
-func (g *GraphRAGRetrieval) findShortestPaths(ctx context.Context, graphQuery *GraphQuery, session neo4j.SessionWithContext) ([]*GraphPath, error) {
+func (g *GraphRAGRetrieval) findShortestPaths(ctx context.Context, graphQuery *GraphQuery) ([]*GraphPath, error) {
 	// 实现最短路径查找逻辑
 	return []*GraphPath{}, nil
 }
@@ -1013,10 +746,9 @@ func (g *GraphRAGRetrieval) fallbackSubgraphExtraction(graphQuery *GraphQuery) *
 
 // Close 关闭资源连接
 func (g *GraphRAGRetrieval) Close(ctx context.Context) error {
-	if g.driver != nil {
-		err := g.driver.Close(ctx)
-		if err != nil {
-			return fmt.Errorf("关闭Neo4j连接失败: %w", err)
+	if g.store != nil && g.ownsStore {
+		if err := g.store.Close(ctx); err != nil {
+			return err
 		}
 		log.Println("图RAG检索系统已关闭")
 	}
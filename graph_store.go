@@ -0,0 +1,130 @@
+package batch_0001
+
+import (
+	"context"
+	"fmt"
+)
+
+// GraphStore 图存储接口
+//
+// 抽象检索模块对图数据库的访问：邻居查询、k跳扩展、路径查找、子图提取、属性检索和度数统计。
+// Neo4jGraphStore 通过Cypher查询实现，MemoryGraphStore 基于从CSV文件加载的内存图实现，
+// 后者不依赖数据库，便于单元测试和本地运行。
+//
+// 节点匹配规则在两种实现中一致：源实体按"名称包含关键词或nodeId等于关键词"匹配。
+type GraphStore interface {
+	// Neighbors 获取节点的直接邻居（不区分方向），relationTypes为空时不限制关系类型，limit<=0时不限制数量
+	Neighbors(ctx context.Context, nodeID string, relationTypes []string, limit int) ([]GraphNode, error)
+
+	// BatchNeighbors 一次查询获取多个节点的直接邻居，limit为每个节点的邻居数上限，语义与Neighbors一致
+	BatchNeighbors(ctx context.Context, nodeIDs []string, relationTypes []string, limit int) (map[string][]GraphNode, error)
+
+	// Expand 从指定节点出发进行k跳扩展，返回扩展到的节点（不含起点）和经过的关系
+	//
+	// maxNodes>0时扩展到的节点数达到上限即停止。
+	Expand(ctx context.Context, nodeIDs []string, maxDepth, maxNodes int) ([]GraphNode, []GraphRelation, error)
+
+	// FindPaths 查找从源实体出发的多跳路径，按相关性得分降序返回
+	FindPaths(ctx context.Context, query PathQuery) ([]*GraphPath, error)
+
+	// Subgraph 提取以源实体为中心、指定深度内的知识子图
+	//
+	// maxNodes>0时邻居数超过maxNodes的源实体被跳过，maxNodes<=0时不限制；
+	// 没有满足条件的源实体时返回错误。
+	Subgraph(ctx context.Context, sources []string, maxDepth, maxNodes int) (*KnowledgeSubgraph, error)
+
+	// SearchNodes 按属性包含关键词检索节点，每个(节点, 关键词)匹配返回一条结果
+	SearchNodes(ctx context.Context, query NodeSearchQuery) ([]NodeMatch, error)
+
+	// DegreeStats 统计度数最高的节点和各关系类型的数量
+	DegreeStats(ctx context.Context, limit int) (*GraphDegreeStats, error)
+
+	// Close 释放图存储持有的资源
+	Close(ctx context.Context) error
+}
+
+// PathQuery 多跳路径查询参数
+type PathQuery struct {
+	Sources       []string // 源实体（名称关键词或nodeId）
+	TargetLabels  []string // 目标节点标签，为空时不限制
+	RelationTypes []string // 关注的关系类型，路径包含其中之一时加分
+	MaxDepth      int      // 最大跳数
+	Limit         int      // 返回的路径数
+}
+
+// NodeSearchQuery 节点属性检索参数
+type NodeSearchQuery struct {
+	Keywords    []string          // 关键词，满足其一即可
+	Fields      []string          // 参与匹配的属性名，如name、description、category
+	Labels      []string          // 节点标签，为空时不限制
+	Constraints *QueryConstraints // 菜谱约束，为nil时不过滤
+	// SortByDifficulty 按难度升序、名称升序排序（默认只按名称排序）
+	SortByDifficulty bool
+	Limit            int
+}
+
+// NodeMatch 属性检索的一条匹配结果
+type NodeMatch struct {
+	Node    GraphNode // 匹配的节点
+	Keyword string    // 命中的关键词
+}
+
+// NodeDegree 节点及其度数（关联的关系数）
+type NodeDegree struct {
+	Node   GraphNode
+	Degree int
+}
+
+// GraphDegreeStats 图的度数统计
+type GraphDegreeStats struct {
+	Nodes          []NodeDegree   // 度数最高的节点，按度数降序
+	RelationCounts map[string]int // 关系类型 -> 数量
+}
+
+// 路径查询的默认参数
+const (
+	defaultPathDepth = 2
+	defaultPathLimit = 20
+)
+
+// normalized 填充路径查询的默认参数
+func (q PathQuery) normalized() PathQuery {
+	if q.MaxDepth <= 0 {
+		q.MaxDepth = defaultPathDepth
+	}
+	if q.Limit <= 0 {
+		q.Limit = defaultPathLimit
+	}
+	return q
+}
+
+// pathNodeMap 转换为GraphPath中的节点表示
+func pathNodeMap(node GraphNode) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         node.Properties["nodeId"],
+		"name":       node.Name,
+		"labels":     node.Labels,
+		"properties": node.Properties,
+	}
+}
+
+// pathRelationMap 转换为GraphPath中的关系表示
+func pathRelationMap(relation GraphRelation) map[string]interface{} {
+	return map[string]interface{}{
+		"type":       relation.RelationType,
+		"properties": relation.Properties,
+	}
+}
+
+// subgraphDensity 子图密度：关系数 / 完全图的边数
+func subgraphDensity(nodeCount, relationCount int) float64 {
+	if nodeCount <= 1 {
+		return 0
+	}
+	return float64(relationCount) / float64(nodeCount*(nodeCount-1)/2)
+}
+
+// errNoSubgraph 没有满足条件的源实体
+func errNoSubgraph(sources []string) error {
+	return fmt.Errorf("未找到可提取子图的实体: %v", sources)
+}
@@ -0,0 +1,438 @@
+package batch_0001
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxPathCandidates 内存路径查找时枚举的候选路径上限
+//
+// 分类、概念类型等枢纽节点连接了大量节点，多跳枚举的路径数会迅速膨胀；
+// 按路径长度逐层枚举，达到上限后不再扩展，短路径得分更高，截断对结果影响很小。
+const maxPathCandidates = 5000
+
+// MemoryGraphStore 基于内存图的图存储
+//
+// 由 CSVGraph 构建，查询语义与 Neo4jGraphStore 的Cypher查询保持一致，不需要数据库连接。
+// 并发安全，可通过 Load 替换为重新加载的图。
+type MemoryGraphStore struct {
+	mu       sync.RWMutex
+	graph    *CSVGraph
+	incoming map[string][]int // 终止nodeId -> Relations下标
+}
+
+// NewMemoryGraphStore 由内存图创建图存储
+func NewMemoryGraphStore(graph *CSVGraph) *MemoryGraphStore {
+	store := &MemoryGraphStore{}
+	store.Load(graph)
+	return store
+}
+
+// LoadMemoryGraphStore 从节点和关系CSV文件创建图存储
+func LoadMemoryGraphStore(nodesPath, relationshipsPath string) (*MemoryGraphStore, error) {
+	graph, err := LoadCSVGraph(nodesPath, relationshipsPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewMemoryGraphStore(graph), nil
+}
+
+// Load 替换为新的内存图（如增量同步重新读取CSV后）
+func (s *MemoryGraphStore) Load(graph *CSVGraph) {
+	incoming := make(map[string][]int)
+	for i, relation := range graph.Relations {
+		incoming[relation.EndNodeID] = append(incoming[relation.EndNodeID], i)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.graph = graph
+	s.incoming = incoming
+}
+
+// incident 节点关联的全部关系下标（先出边后入边）
+func (s *MemoryGraphStore) incident(nodeID string) []int {
+	out := s.graph.outgoing[nodeID]
+	in := s.incoming[nodeID]
+	indexes := make([]int, 0, len(out)+len(in))
+	indexes = append(indexes, out...)
+	return append(indexes, in...)
+}
+
+// degree 节点的度数
+func (s *MemoryGraphStore) degree(nodeID string) int {
+	return len(s.graph.outgoing[nodeID]) + len(s.incoming[nodeID])
+}
+
+// otherEnd 关系另一端的节点ID
+func otherEnd(relation GraphRelation, nodeID string) string {
+	if relation.StartNodeID == nodeID {
+		return relation.EndNodeID
+	}
+	return relation.StartNodeID
+}
+
+// matchSources 按"名称包含关键词或nodeId等于关键词"匹配源节点，保持关键词顺序并去重
+func (s *MemoryGraphStore) matchSources(sources []string) []string {
+	var matched []string
+	seen := make(map[string]bool)
+	for _, source := range sources {
+		for _, node := range s.graph.Nodes {
+			if seen[node.NodeID] {
+				continue
+			}
+			if strings.Contains(node.Name, source) || getStringFromMap(node.Properties, "nodeId", "") == source {
+				seen[node.NodeID] = true
+				matched = append(matched, node.NodeID)
+			}
+		}
+	}
+	return matched
+}
+
+// Neighbors 获取节点的直接邻居
+func (s *MemoryGraphStore) Neighbors(ctx context.Context, nodeID string, relationTypes []string, limit int) ([]GraphNode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.neighbors(nodeID, relationTypes, limit), nil
+}
+
+// BatchNeighbors 批量获取多个节点的直接邻居
+func (s *MemoryGraphStore) BatchNeighbors(ctx context.Context, nodeIDs []string, relationTypes []string, limit int) (map[string][]GraphNode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string][]GraphNode, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		if neighbors := s.neighbors(nodeID, relationTypes, limit); len(neighbors) > 0 {
+			result[nodeID] = neighbors
+		}
+	}
+	return result, nil
+}
+
+// neighbors 节点的直接邻居，按关联关系的顺序去重
+func (s *MemoryGraphStore) neighbors(nodeID string, relationTypes []string, limit int) []GraphNode {
+	var neighbors []GraphNode
+	seen := make(map[string]bool)
+	for _, index := range s.incident(nodeID) {
+		relation := s.graph.Relations[index]
+		if len(relationTypes) > 0 && !containsString(relationTypes, relation.RelationType) {
+			continue
+		}
+		neighborID := otherEnd(relation, nodeID)
+		if seen[neighborID] {
+			continue
+		}
+		seen[neighborID] = true
+		if node, ok := s.graph.Node(neighborID); ok {
+			neighbors = append(neighbors, *node)
+		}
+		if limit > 0 && len(neighbors) >= limit {
+			break
+		}
+	}
+	return neighbors
+}
+
+// Expand 从指定节点出发按广度优先进行k跳扩展
+func (s *MemoryGraphStore) Expand(ctx context.Context, nodeIDs []string, maxDepth, maxNodes int) ([]GraphNode, []GraphRelation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	nodes, relations := s.expand(nodeIDs, max(maxDepth, 1), maxNodes)
+	return nodes, relations, nil
+}
+
+func (s *MemoryGraphStore) expand(nodeIDs []string, maxDepth, maxNodes int) ([]GraphNode, []GraphRelation) {
+	visited := make(map[string]bool, len(nodeIDs))
+	for _, id := range nodeIDs {
+		visited[id] = true
+	}
+	usedRelations := make(map[int]bool)
+
+	var nodes []GraphNode
+	var relations []GraphRelation
+	frontier := nodeIDs
+	for depth := 0; depth < maxDepth && len(frontier) > 0; depth++ {
+		var next []string
+		for _, nodeID := range frontier {
+			for _, index := range s.incident(nodeID) {
+				if !usedRelations[index] {
+					usedRelations[index] = true
+					relations = append(relations, s.graph.Relations[index])
+				}
+
+				neighborID := otherEnd(s.graph.Relations[index], nodeID)
+				if visited[neighborID] {
+					continue
+				}
+				visited[neighborID] = true
+				if node, ok := s.graph.Node(neighborID); ok {
+					nodes = append(nodes, *node)
+					next = append(next, neighborID)
+				}
+				if maxNodes > 0 && len(nodes) >= maxNodes {
+					return nodes, relations
+				}
+			}
+		}
+		frontier = next
+	}
+	return nodes, relations
+}
+
+// FindPaths 查找从源实体出发的多跳路径
+//
+// 评分与Neo4j实现一致：1/路径长度 + 路径节点平均度数/10 + 包含关注关系类型时加0.3。
+func (s *MemoryGraphStore) FindPaths(ctx context.Context, query PathQuery) ([]*GraphPath, error) {
+	query = query.normalized()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type partialPath struct {
+		nodes     []string
+		relations []int
+	}
+
+	var paths []*GraphPath
+	candidates := 0
+	for _, sourceID := range s.matchSources(query.Sources) {
+		frontier := []partialPath{{nodes: []string{sourceID}}}
+		for depth := 1; depth <= query.MaxDepth && len(frontier) > 0 && candidates < maxPathCandidates; depth++ {
+			var next []partialPath
+			for _, partial := range frontier {
+				last := partial.nodes[len(partial.nodes)-1]
+				for _, index := range s.incident(last) {
+					neighborID := otherEnd(s.graph.Relations[index], last)
+					if containsString(partial.nodes, neighborID) {
+						continue
+					}
+
+					extended := partialPath{
+						nodes:     append(append([]string{}, partial.nodes...), neighborID),
+						relations: append(append([]int{}, partial.relations...), index),
+					}
+					next = append(next, extended)
+
+					if path := s.scorePath(extended.nodes, extended.relations, query); path != nil {
+						paths = append(paths, path)
+					}
+					candidates++
+					if candidates >= maxPathCandidates {
+						break
+					}
+				}
+				if candidates >= maxPathCandidates {
+					break
+				}
+			}
+			frontier = next
+		}
+	}
+
+	sort.SliceStable(paths, func(i, j int) bool {
+		return paths[i].RelevanceScore > paths[j].RelevanceScore
+	})
+	if len(paths) > query.Limit {
+		paths = paths[:query.Limit]
+	}
+	return paths, nil
+}
+
+// scorePath 为路径评分并转换为GraphPath，目标节点不满足标签要求时返回nil
+func (s *MemoryGraphStore) scorePath(nodeIDs []string, relationIndexes []int, query PathQuery) *GraphPath {
+	target, ok := s.graph.Node(nodeIDs[len(nodeIDs)-1])
+	if !ok {
+		return nil
+	}
+	if len(query.TargetLabels) > 0 && !hasAnyLabel(*target, query.TargetLabels) {
+		return nil
+	}
+
+	path := &GraphPath{
+		PathLength: len(relationIndexes),
+		PathType:   "multi_hop",
+	}
+
+	degreeSum := 0
+	for _, nodeID := range nodeIDs {
+		node, _ := s.graph.Node(nodeID)
+		path.Nodes = append(path.Nodes, pathNodeMap(*node))
+		degreeSum += s.degree(nodeID)
+	}
+
+	relationBonus := 0.0
+	for _, index := range relationIndexes {
+		relation := s.graph.Relations[index]
+		path.Relationships = append(path.Relationships, pathRelationMap(relation))
+		if containsString(query.RelationTypes, relation.RelationType) {
+			relationBonus = 0.3
+		}
+	}
+
+	path.RelevanceScore = 1.0/float64(path.PathLength) +
+		float64(degreeSum)/10.0/float64(len(nodeIDs)) +
+		relationBonus
+	return path
+}
+
+// Subgraph 提取以源实体为中心的知识子图
+func (s *MemoryGraphStore) Subgraph(ctx context.Context, sources []string, maxDepth, maxNodes int) (*KnowledgeSubgraph, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, sourceID := range s.matchSources(sources) {
+		// 多取一个节点用于判断是否超过上限
+		limit := 0
+		if maxNodes > 0 {
+			limit = maxNodes + 1
+		}
+		neighbors, relations := s.expand([]string{sourceID}, max(maxDepth, 1), limit)
+		if len(neighbors) == 0 || (maxNodes > 0 && len(neighbors) > maxNodes) {
+			continue
+		}
+
+		source, _ := s.graph.Node(sourceID)
+		subgraph := &KnowledgeSubgraph{
+			CentralNodes: []map[string]interface{}{source.Properties},
+			GraphMetrics: map[string]float64{
+				"node_count":         float64(len(neighbors)),
+				"relationship_count": float64(len(relations)),
+				"density":            subgraphDensity(len(neighbors), len(relations)),
+			},
+			ReasoningChains: [][]string{},
+		}
+		for _, node := range neighbors {
+			subgraph.ConnectedNodes = append(subgraph.ConnectedNodes, node.Properties)
+		}
+		for i, relation := range relations {
+			if maxNodes > 0 && i >= maxNodes {
+				break
+			}
+			subgraph.Relationships = append(subgraph.Relationships, relation.Properties)
+		}
+		return subgraph, nil
+	}
+
+	return nil, errNoSubgraph(sources)
+}
+
+// SearchNodes 按属性包含关键词检索节点
+func (s *MemoryGraphStore) SearchNodes(ctx context.Context, query NodeSearchQuery) ([]NodeMatch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matches []NodeMatch
+	for _, keyword := range query.Keywords {
+		for _, node := range s.graph.Nodes {
+			if len(query.Labels) > 0 && !hasAnyLabel(node, query.Labels) {
+				continue
+			}
+			if !nodeFieldsContain(node, query.Fields, keyword) {
+				continue
+			}
+			if !query.Constraints.IsEmpty() && !query.Constraints.MatchGraphNode(node, s.requiredIngredientNames(node.NodeID)) {
+				continue
+			}
+			matches = append(matches, NodeMatch{Node: node, Keyword: keyword})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i].Node, matches[j].Node
+		if query.SortByDifficulty {
+			da, okA := a.Properties["difficulty"].(float64)
+			db, okB := b.Properties["difficulty"].(float64)
+			// 与Cypher一致：升序排序时缺失值排在最后
+			if okA != okB {
+				return okA
+			}
+			if okA && da != db {
+				return da < db
+			}
+		}
+		return a.Name < b.Name
+	})
+
+	if query.Limit > 0 && len(matches) > query.Limit {
+		matches = matches[:query.Limit]
+	}
+	return matches, nil
+}
+
+// requiredIngredientNames 菜谱需要的食材名称
+func (s *MemoryGraphStore) requiredIngredientNames(recipeID string) []string {
+	var names []string
+	for _, relation := range s.graph.Outgoing(recipeID, "REQUIRES") {
+		if node, ok := s.graph.Node(relation.EndNodeID); ok && hasLabel(*node, "Ingredient") {
+			names = append(names, node.Name)
+		}
+	}
+	return names
+}
+
+// DegreeStats 统计度数最高的节点和各关系类型的数量
+func (s *MemoryGraphStore) DegreeStats(ctx context.Context, limit int) (*GraphDegreeStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := &GraphDegreeStats{RelationCounts: make(map[string]int)}
+	for _, node := range s.graph.Nodes {
+		// 与Neo4j实现一致，只统计带nodeId属性的节点（不含派生的分类、概念类型节点）
+		if node.Properties["nodeId"] == nil {
+			continue
+		}
+		stats.Nodes = append(stats.Nodes, NodeDegree{Node: node, Degree: s.degree(node.NodeID)})
+	}
+	sort.SliceStable(stats.Nodes, func(i, j int) bool {
+		return stats.Nodes[i].Degree > stats.Nodes[j].Degree
+	})
+	if limit > 0 && len(stats.Nodes) > limit {
+		stats.Nodes = stats.Nodes[:limit]
+	}
+
+	for _, relation := range s.graph.Relations {
+		stats.RelationCounts[relation.RelationType]++
+	}
+	return stats, nil
+}
+
+// Close 内存图存储没有需要释放的资源
+func (s *MemoryGraphStore) Close(ctx context.Context) error {
+	return nil
+}
+
+// nodeFieldsContain 节点的任一字符串属性是否包含关键词
+func nodeFieldsContain(node GraphNode, fields []string, keyword string) bool {
+	for _, field := range fields {
+		if value, ok := node.Properties[field].(string); ok && strings.Contains(value, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAnyLabel 节点是否带有任一指定标签
+func hasAnyLabel(node GraphNode, labels []string) bool {
+	for _, label := range labels {
+		if hasLabel(node, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsString 切片中是否包含指定字符串
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+package batch_0001
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testGraphStore 两道菜谱的小图：红烧肉需要四种食材、两个步骤，麻婆豆腐需要一种食材
+func testGraphStore() *MemoryGraphStore {
+	nodeRows := []map[string]string{
+		{"nodeId": "201000001", "labels": "Recipe", "name": "红烧肉", "category": "荤菜", "cuisineType": "湘菜", "difficulty": "2", "tags": "家常"},
+		{"nodeId": "201000002", "labels": "Ingredient", "name": "五花肉", "category": "蛋白质"},
+		{"nodeId": "201000003", "labels": "Ingredient", "name": "冰糖", "category": "调料"},
+		{"nodeId": "201000004", "labels": "Ingredient", "name": "生抽", "category": "调料"},
+		{"nodeId": "201000005", "labels": "Ingredient", "name": "八角", "category": "调料"},
+		{"nodeId": "201000006", "labels": "CookingStep", "name": "步骤1", "stepNumber": "1.0", "description": "五花肉切块焯水"},
+		{"nodeId": "201000007", "labels": "CookingStep", "name": "步骤2", "stepNumber": "2.0", "description": "炒糖色后炖煮"},
+		{"nodeId": "202000001", "labels": "Recipe", "name": "麻婆豆腐", "category": "荤菜", "cuisineType": "川菜", "difficulty": "1", "tags": "下饭"},
+		{"nodeId": "202000002", "labels": "Ingredient", "name": "豆腐", "category": "豆制品"},
+	}
+	relationRows := []map[string]string{
+		{"startNodeId": "201000001", "endNodeId": "201000002", "relationshipType": relationCodeRequires},
+		{"startNodeId": "201000001", "endNodeId": "201000003", "relationshipType": relationCodeRequires},
+		{"startNodeId": "201000001", "endNodeId": "201000004", "relationshipType": relationCodeRequires},
+		{"startNodeId": "201000001", "endNodeId": "201000005", "relationshipType": relationCodeRequires},
+		{"startNodeId": "201000001", "endNodeId": "201000006", "relationshipType": relationCodeContainsStep},
+		{"startNodeId": "201000001", "endNodeId": "201000007", "relationshipType": relationCodeContainsStep},
+		{"startNodeId": "202000001", "endNodeId": "202000002", "relationshipType": relationCodeRequires},
+	}
+	return NewMemoryGraphStore(NewCSVGraphFromRows(nodeRows, relationRows))
+}
+
+func nodeNames(nodes []GraphNode) []string {
+	names := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		names = append(names, node.Name)
+	}
+	return names
+}
+
+func TestMemoryGraphStoreNeighbors(t *testing.T) {
+	store := testGraphStore()
+	ctx := context.Background()
+
+	all, err := store.Neighbors(ctx, "201000001", []string{"REQUIRES"}, 0)
+	if err != nil {
+		t.Fatalf("Neighbors: %v", err)
+	}
+	if got := strings.Join(nodeNames(all), ","); got != "五花肉,冰糖,生抽,八角" {
+		t.Errorf("limit=0 应返回全部食材，得到 %s", got)
+	}
+
+	limited, _ := store.Neighbors(ctx, "201000001", []string{"REQUIRES"}, 3)
+	if len(limited) != 3 {
+		t.Errorf("limit=3 应返回3个邻居，得到 %d", len(limited))
+	}
+
+	steps, _ := store.Neighbors(ctx, "201000001", []string{"CONTAINS_STEP"}, 0)
+	if got := strings.Join(nodeNames(steps), ","); got != "步骤1,步骤2" {
+		t.Errorf("按关系类型过滤失败，得到 %s", got)
+	}
+}
+
+func TestMemoryGraphStoreBatchNeighbors(t *testing.T) {
+	store := testGraphStore()
+
+	result, err := store.BatchNeighbors(context.Background(), []string{"201000001", "202000001", "999999999"}, []string{"REQUIRES"}, 3)
+	if err != nil {
+		t.Fatalf("BatchNeighbors: %v", err)
+	}
+	if len(result["201000001"]) != 3 {
+		t.Errorf("红烧肉应返回3个食材，得到 %v", nodeNames(result["201000001"]))
+	}
+	if got := strings.Join(nodeNames(result["202000001"]), ","); got != "豆腐" {
+		t.Errorf("麻婆豆腐应返回豆腐，得到 %s", got)
+	}
+	if _, ok := result["999999999"]; ok {
+		t.Error("没有邻居的节点不应出现在结果中")
+	}
+
+	for _, nodeID := range []string{"201000001", "202000001"} {
+		single, _ := store.Neighbors(context.Background(), nodeID, []string{"REQUIRES"}, 3)
+		if strings.Join(nodeNames(single), ",") != strings.Join(nodeNames(result[nodeID]), ",") {
+			t.Errorf("%s 的批量结果与单个查询不一致", nodeID)
+		}
+	}
+}
+
+func TestMemoryGraphStoreExpand(t *testing.T) {
+	store := testGraphStore()
+	ctx := context.Background()
+
+	nodes, relations, err := store.Expand(ctx, []string{"202000001"}, 1, 0)
+	if err != nil {
+		t.Fatalf("Expand: %v", err)
+	}
+	names := nodeNames(nodes)
+	sort.Strings(names)
+	if got := strings.Join(names, ","); got != "荤菜,豆腐" {
+		t.Errorf("一跳扩展应得到豆腐和分类节点，得到 %s", got)
+	}
+	if len(relations) != 2 {
+		t.Errorf("一跳扩展应得到2条关系，得到 %d", len(relations))
+	}
+
+	limited, _, _ := store.Expand(ctx, []string{"201000001"}, 2, 2)
+	if len(limited) != 2 {
+		t.Errorf("maxNodes=2 应截断到2个节点，得到 %d", len(limited))
+	}
+}
+
+func TestMemoryGraphStoreSubgraph(t *testing.T) {
+	store := testGraphStore()
+	ctx := context.Background()
+
+	unlimited, err := store.Subgraph(ctx, []string{"红烧肉"}, 1, 0)
+	if err != nil {
+		t.Fatalf("maxNodes=0 应不限制节点数: %v", err)
+	}
+	// 四种食材、两个步骤和荤菜分类
+	if got := unlimited.GraphMetrics["node_count"]; got != 7 {
+		t.Errorf("node_count = %v，期望 7", got)
+	}
+	if len(unlimited.ConnectedNodes) != 7 || len(unlimited.Relationships) != 7 {
+		t.Errorf("子图应包含7个节点和7条关系，得到 %d 和 %d", len(unlimited.ConnectedNodes), len(unlimited.Relationships))
+	}
+
+	if _, err := store.Subgraph(ctx, []string{"红烧肉"}, 1, 3); err == nil {
+		t.Error("邻居超过maxNodes时应跳过该源实体并返回错误")
+	}
+
+	small, err := store.Subgraph(ctx, []string{"麻婆豆腐"}, 1, 3)
+	if err != nil {
+		t.Fatalf("Subgraph: %v", err)
+	}
+	if got := small.CentralNodes[0]["name"]; got != "麻婆豆腐" {
+		t.Errorf("中心节点应为麻婆豆腐，得到 %v", got)
+	}
+}
+
+func TestMemoryGraphStoreSearchNodes(t *testing.T) {
+	store := testGraphStore()
+	ctx := context.Background()
+
+	matches, err := store.SearchNodes(ctx, NodeSearchQuery{
+		Keywords:         []string{"菜"},
+		Fields:           []string{"cuisineType"},
+		Labels:           []string{"Recipe"},
+		SortByDifficulty: true,
+	})
+	if err != nil {
+		t.Fatalf("SearchNodes: %v", err)
+	}
+	var names []string
+	for _, match := range matches {
+		names = append(names, match.Node.Name)
+	}
+	if got := strings.Join(names, ","); got != "麻婆豆腐,红烧肉" {
+		t.Errorf("应按难度升序返回，得到 %s", got)
+	}
+
+	constrained, _ := store.SearchNodes(ctx, NodeSearchQuery{
+		Keywords:    []string{"荤菜"},
+		Fields:      []string{"category"},
+		Labels:      []string{"Recipe"},
+		Constraints: &QueryConstraints{ExcludedIngredients: []string{"五花肉"}},
+	})
+	if len(constrained) != 1 || constrained[0].Node.Name != "麻婆豆腐" {
+		t.Errorf("排除五花肉后应只剩麻婆豆腐，得到 %d 个结果", len(constrained))
+	}
+
+	limited, _ := store.SearchNodes(ctx, NodeSearchQuery{Keywords: []string{"调料"}, Fields: []string{"category"}, Limit: 2})
+	if len(limited) != 2 {
+		t.Errorf("Limit=2 应返回2个结果，得到 %d", len(limited))
+	}
+}
+
+func TestMemoryGraphStoreFindPaths(t *testing.T) {
+	store := testGraphStore()
+
+	paths, err := store.FindPaths(context.Background(), PathQuery{
+		Sources:       []string{"红烧肉"},
+		TargetLabels:  []string{"Ingredient"},
+		RelationTypes: []string{"REQUIRES"},
+		MaxDepth:      1,
+	})
+	if err != nil {
+		t.Fatalf("FindPaths: %v", err)
+	}
+	if len(paths) != 4 {
+		t.Fatalf("应找到4条到食材的路径，得到 %d", len(paths))
+	}
+	for _, path := range paths {
+		if path.PathLength != 1 {
+			t.Errorf("路径长度应为1，得到 %d", path.PathLength)
+		}
+		labels, _ := path.Nodes[len(path.Nodes)-1]["labels"].([]string)
+		if !containsString(labels, "Ingredient") {
+			t.Errorf("路径终点应为食材，得到 %v", labels)
+		}
+	}
+}
@@ -0,0 +1,497 @@
+package batch_0001
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Neo4jGraphStore 基于Neo4j的图存储
+type Neo4jGraphStore struct {
+	driver   neo4j.DriverWithContext
+	database string
+	owned    bool // 驱动由本存储创建时，Close会关闭驱动
+}
+
+// NewNeo4jGraphStore 使用已有的Neo4j驱动创建图存储
+//
+// 驱动由调用方管理，Close不会关闭它，可与数据准备模块共用同一个连接。
+func NewNeo4jGraphStore(driver neo4j.DriverWithContext, database string) *Neo4jGraphStore {
+	return &Neo4jGraphStore{driver: driver, database: database}
+}
+
+// ConnectNeo4jGraphStore 连接Neo4j并创建图存储，Close时关闭连接
+func ConnectNeo4jGraphStore(ctx context.Context, uri, user, password, database string) (*Neo4jGraphStore, error) {
+	driver, err := neo4j.NewDriverWithContext(uri, neo4j.BasicAuth(user, password, ""))
+	if err != nil {
+		return nil, fmt.Errorf("Neo4j连接失败: %w", err)
+	}
+	if err := driver.VerifyConnectivity(ctx); err != nil {
+		driver.Close(ctx)
+		return nil, fmt.Errorf("Neo4j连接验证失败: %w", err)
+	}
+
+	return &Neo4jGraphStore{driver: driver, database: database, owned: true}, nil
+}
+
+// readRecords 在读事务中执行查询并返回全部记录
+func (s *Neo4jGraphStore) readRecords(ctx context.Context, query string, params map[string]interface{}) ([]*neo4j.Record, error) {
+	session := s.driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: s.database})
+	defer session.Close(ctx)
+
+	result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+		return result.Collect(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]*neo4j.Record), nil
+}
+
+// Neighbors 获取节点的直接邻居
+func (s *Neo4jGraphStore) Neighbors(ctx context.Context, nodeID string, relationTypes []string, limit int) ([]GraphNode, error) {
+	neighbors, err := s.BatchNeighbors(ctx, []string{nodeID}, relationTypes, limit)
+	if err != nil {
+		return nil, err
+	}
+	return neighbors[nodeID], nil
+}
+
+// BatchNeighbors 批量获取多个节点的直接邻居，所有节点在一次查询中完成
+func (s *Neo4jGraphStore) BatchNeighbors(ctx context.Context, nodeIDs []string, relationTypes []string, limit int) (map[string][]GraphNode, error) {
+	result := make(map[string][]GraphNode, len(nodeIDs))
+	if len(nodeIDs) == 0 {
+		return result, nil
+	}
+
+	query := `
+		UNWIND $node_ids AS node_id
+		MATCH (n {nodeId: node_id})-[r]-(neighbor)
+		WHERE size($relation_types) = 0 OR type(r) IN $relation_types
+		WITH node_id, collect(DISTINCT neighbor) AS neighbors
+		RETURN node_id, CASE WHEN $limit > 0 THEN neighbors[0..$limit] ELSE neighbors END AS neighbors
+	`
+
+	records, err := s.readRecords(ctx, query, map[string]interface{}{
+		"node_ids":       nodeIDs,
+		"relation_types": nonNilStrings(relationTypes),
+		"limit":          limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("查询邻居节点失败: %w", err)
+	}
+
+	for _, record := range records {
+		nodeID, _ := record.Get("node_id")
+		value, _ := record.Get("neighbors")
+		list, _ := value.([]interface{})
+		neighbors := make([]GraphNode, 0, len(list))
+		for _, item := range list {
+			if node, ok := item.(neo4j.Node); ok {
+				neighbors = append(neighbors, graphNodeFromNeo4j(node))
+			}
+		}
+		result[fmt.Sprintf("%v", nodeID)] = neighbors
+	}
+	return result, nil
+}
+
+// Expand 从指定节点出发进行k跳扩展
+func (s *Neo4jGraphStore) Expand(ctx context.Context, nodeIDs []string, maxDepth, maxNodes int) ([]GraphNode, []GraphRelation, error) {
+	query := fmt.Sprintf(`
+		MATCH (source)
+		WHERE source.nodeId IN $node_ids
+		MATCH path = (source)-[*1..%d]-(neighbor)
+		WHERE NOT neighbor.nodeId IN $node_ids OR neighbor.nodeId IS NULL
+		UNWIND relationships(path) as r
+		WITH collect(DISTINCT neighbor) as neighbors, collect(DISTINCT r) as rels
+		RETURN CASE WHEN $max_nodes > 0 THEN neighbors[0..$max_nodes] ELSE neighbors END as neighbors,
+		       [r IN rels | {start: startNode(r).nodeId, end: endNode(r).nodeId, type: type(r), props: properties(r)}] as rels
+	`, max(maxDepth, 1))
+
+	records, err := s.readRecords(ctx, query, map[string]interface{}{
+		"node_ids":  nodeIDs,
+		"max_nodes": maxNodes,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("k跳扩展查询失败: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil, nil
+	}
+
+	var nodes []GraphNode
+	neighbors, _ := records[0].Get("neighbors")
+	if list, ok := neighbors.([]interface{}); ok {
+		for _, item := range list {
+			if node, ok := item.(neo4j.Node); ok {
+				nodes = append(nodes, graphNodeFromNeo4j(node))
+			}
+		}
+	}
+
+	var relations []GraphRelation
+	rels, _ := records[0].Get("rels")
+	for _, entry := range recordMaps(rels) {
+		properties, _ := entry["props"].(map[string]interface{})
+		relations = append(relations, GraphRelation{
+			StartNodeID:  getStringFromMap(entry, "start", ""),
+			EndNodeID:    getStringFromMap(entry, "end", ""),
+			RelationType: getStringFromMap(entry, "type", ""),
+			Properties:   properties,
+		})
+	}
+	return nodes, relations, nil
+}
+
+// FindPaths 查找从源实体出发的多跳路径
+//
+// 路径评分：短路径 + 路径节点的平均度数 + 关系类型匹配。
+func (s *Neo4jGraphStore) FindPaths(ctx context.Context, query PathQuery) ([]*GraphPath, error) {
+	query = query.normalized()
+
+	targetLabelsCondition := ""
+	if len(query.TargetLabels) > 0 {
+		targetLabelsCondition = "AND ANY(label IN labels(target) WHERE label IN $target_labels)"
+	}
+
+	cypherQuery := fmt.Sprintf(`
+		// 多跳推理查询
+		UNWIND $source_entities as source_name
+		MATCH (source)
+		WHERE source.name CONTAINS source_name OR source.nodeId = source_name
+
+		// 执行多跳遍历
+		MATCH path = (source)-[*1..%d]-(target)
+		WHERE NOT source = target
+		%s
+
+		// 计算路径相关性
+		WITH path, source, target,
+		     length(path) as path_len,
+		     relationships(path) as rels,
+		     nodes(path) as path_nodes
+
+		// 路径评分：短路径 + 高度数节点 + 关系类型匹配
+		WITH path, source, target, path_len, rels, path_nodes,
+		     (1.0 / path_len) +
+		     (REDUCE(s = 0.0, n IN path_nodes | s + COUNT { (n)--() }) / 10.0 / size(path_nodes)) +
+		     (CASE WHEN ANY(r IN rels WHERE type(r) IN $relation_types) THEN 0.3 ELSE 0.0 END) as relevance
+
+		ORDER BY relevance DESC
+		LIMIT $limit
+
+		RETURN path, source, target, path_len, rels, path_nodes, relevance
+	`, query.MaxDepth, targetLabelsCondition)
+
+	records, err := s.readRecords(ctx, cypherQuery, map[string]interface{}{
+		"source_entities": query.Sources,
+		"target_labels":   query.TargetLabels,
+		"relation_types":  nonNilStrings(query.RelationTypes),
+		"limit":           query.Limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("多跳遍历查询失败: %w", err)
+	}
+
+	var paths []*GraphPath
+	for _, record := range records {
+		if path := graphPathFromRecord(record); path != nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// Subgraph 提取以源实体为中心的知识子图（不依赖APOC）
+func (s *Neo4jGraphStore) Subgraph(ctx context.Context, sources []string, maxDepth, maxNodes int) (*KnowledgeSubgraph, error) {
+	cypherQuery := fmt.Sprintf(`
+		// 找到源实体
+		UNWIND $source_entities as entity_name
+		MATCH (source)
+		WHERE source.name CONTAINS entity_name
+		   OR source.nodeId = entity_name
+
+		// 获取指定深度的邻居，max_nodes<=0时不限制邻居数
+		MATCH (source)-[r*1..%d]-(neighbor)
+		WITH source, collect(DISTINCT neighbor) as neighbors,
+		     collect(DISTINCT r) as relationships
+		WHERE $max_nodes <= 0 OR size(neighbors) <= $max_nodes
+
+		// 计算图指标
+		WITH source, neighbors, relationships,
+		     size(neighbors) as node_count,
+		     size(relationships) as rel_count
+
+		RETURN
+		    source,
+		    CASE WHEN $max_nodes > 0 THEN neighbors[0..$max_nodes] ELSE neighbors END as nodes,
+		    CASE WHEN $max_nodes > 0 THEN relationships[0..$max_nodes] ELSE relationships END as rels,
+		    {
+		        node_count: node_count,
+		        relationship_count: rel_count,
+		        density: CASE WHEN node_count > 1 THEN toFloat(rel_count) / (node_count * (node_count - 1) / 2) ELSE 0.0 END
+		    } as metrics
+		LIMIT 1
+	`, max(maxDepth, 1))
+
+	records, err := s.readRecords(ctx, cypherQuery, map[string]interface{}{
+		"source_entities": sources,
+		"max_nodes":       maxNodes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("子图提取查询失败: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, errNoSubgraph(sources)
+	}
+	return knowledgeSubgraphFromRecord(records[0]), nil
+}
+
+// SearchNodes 按属性包含关键词检索节点
+func (s *Neo4jGraphStore) SearchNodes(ctx context.Context, query NodeSearchQuery) ([]NodeMatch, error) {
+	params := map[string]interface{}{
+		"keywords": query.Keywords,
+		"fields":   query.Fields,
+		"labels":   nonNilStrings(query.Labels),
+		"limit":    query.Limit,
+	}
+
+	// 查询约束下推到Cypher
+	constraintClause := ""
+	if condition, constraintParams := query.Constraints.CypherCondition("node"); condition != "" {
+		constraintClause = "AND " + condition
+		for k, v := range constraintParams {
+			params[k] = v
+		}
+	}
+
+	orderBy := "node.name"
+	if query.SortByDifficulty {
+		orderBy = "node.difficulty ASC, node.name"
+	}
+
+	cypherQuery := `
+		UNWIND $keywords as keyword
+		MATCH (node)
+		WHERE (size($labels) = 0 OR any(label IN labels(node) WHERE label IN $labels))
+		  AND any(field IN $fields WHERE node[field] CONTAINS keyword)
+		  ` + constraintClause + `
+		RETURN node, keyword as matched_keyword
+		ORDER BY ` + orderBy + `
+		LIMIT $limit
+	`
+
+	records, err := s.readRecords(ctx, cypherQuery, params)
+	if err != nil {
+		return nil, fmt.Errorf("节点属性检索失败: %w", err)
+	}
+
+	matches := make([]NodeMatch, 0, len(records))
+	for _, record := range records {
+		value, _ := record.Get("node")
+		keyword, _ := record.Get("matched_keyword")
+		if node, ok := value.(neo4j.Node); ok {
+			matches = append(matches, NodeMatch{
+				Node:    graphNodeFromNeo4j(node),
+				Keyword: fmt.Sprintf("%v", keyword),
+			})
+		}
+	}
+	return matches, nil
+}
+
+// DegreeStats 统计度数最高的节点和各关系类型的数量
+func (s *Neo4jGraphStore) DegreeStats(ctx context.Context, limit int) (*GraphDegreeStats, error) {
+	// 实体索引 - 计算每个节点的度数（连接关系数）
+	entityQuery := `
+		MATCH (n)
+		WHERE n.nodeId IS NOT NULL
+		WITH n, COUNT { (n)--() } as degree
+		RETURN n as node, degree
+		ORDER BY degree DESC
+		LIMIT $limit
+	`
+
+	records, err := s.readRecords(ctx, entityQuery, map[string]interface{}{"limit": limit})
+	if err != nil {
+		return nil, fmt.Errorf("执行实体索引查询失败: %w", err)
+	}
+
+	stats := &GraphDegreeStats{RelationCounts: make(map[string]int)}
+	for _, record := range records {
+		value, _ := record.Get("node")
+		degree, _ := record.Get("degree")
+		node, ok := value.(neo4j.Node)
+		if !ok {
+			continue
+		}
+		count, _ := degree.(int64)
+		stats.Nodes = append(stats.Nodes, NodeDegree{Node: graphNodeFromNeo4j(node), Degree: int(count)})
+	}
+
+	// 关系类型索引 - 统计每种关系类型的频率
+	relationQuery := `
+		MATCH ()-[r]->()
+		RETURN type(r) as rel_type, count(r) as frequency
+		ORDER BY frequency DESC
+	`
+
+	records, err = s.readRecords(ctx, relationQuery, nil)
+	if err != nil {
+		return nil, fmt.Errorf("执行关系索引查询失败: %w", err)
+	}
+	for _, record := range records {
+		relType, _ := record.Get("rel_type")
+		frequency, _ := record.Get("frequency")
+		count, _ := frequency.(int64)
+		stats.RelationCounts[fmt.Sprintf("%v", relType)] = int(count)
+	}
+
+	return stats, nil
+}
+
+// Close 关闭由本存储创建的Neo4j连接
+func (s *Neo4jGraphStore) Close(ctx context.Context) error {
+	if !s.owned || s.driver == nil {
+		return nil
+	}
+	if err := s.driver.Close(ctx); err != nil {
+		return fmt.Errorf("关闭Neo4j连接失败: %w", err)
+	}
+	log.Println("Neo4j连接已关闭")
+	return nil
+}
+
+// graphNodeFromNeo4j 将Neo4j节点转换为GraphNode
+func graphNodeFromNeo4j(node neo4j.Node) GraphNode {
+	return GraphNode{
+		NodeID:     getStringFromMap(node.Props, "nodeId", ""),
+		Labels:     node.Labels,
+		Name:       getStringFromMap(node.Props, "name", ""),
+		Properties: node.Props,
+	}
+}
+
+// graphPathFromRecord 解析路径查询返回的记录
+func graphPathFromRecord(record *neo4j.Record) *GraphPath {
+	pathNodes, exists := record.Get("path_nodes")
+	if !exists {
+		return nil
+	}
+	rels, exists := record.Get("rels")
+	if !exists {
+		return nil
+	}
+	pathLen, exists := record.Get("path_len")
+	if !exists {
+		return nil
+	}
+	relevance, exists := record.Get("relevance")
+	if !exists {
+		return nil
+	}
+
+	// 转换节点
+	var nodes []map[string]interface{}
+	if nodeList, ok := pathNodes.([]interface{}); ok {
+		for _, node := range nodeList {
+			if n, ok := node.(neo4j.Node); ok {
+				nodes = append(nodes, pathNodeMap(graphNodeFromNeo4j(n)))
+			}
+		}
+	}
+
+	// 转换关系
+	var relationships []map[string]interface{}
+	if relList, ok := rels.([]interface{}); ok {
+		for _, rel := range relList {
+			if r, ok := rel.(neo4j.Relationship); ok {
+				relationships = append(relationships, pathRelationMap(GraphRelation{
+					RelationType: r.Type,
+					Properties:   r.Props,
+				}))
+			}
+		}
+	}
+
+	length, _ := pathLen.(int64)
+	score, _ := relevance.(float64)
+	return &GraphPath{
+		Nodes:          nodes,
+		Relationships:  relationships,
+		PathLength:     int(length),
+		RelevanceScore: score,
+		PathType:       "multi_hop",
+	}
+}
+
+// knowledgeSubgraphFromRecord 解析子图查询返回的记录
+func knowledgeSubgraphFromRecord(record *neo4j.Record) *KnowledgeSubgraph {
+	source, _ := record.Get("source")
+	nodes, _ := record.Get("nodes")
+	rels, _ := record.Get("rels")
+	metrics, _ := record.Get("metrics")
+
+	var centralNodes []map[string]interface{}
+	if sourceNode, ok := source.(neo4j.Node); ok {
+		centralNodes = append(centralNodes, sourceNode.Props)
+	}
+
+	var connectedNodes []map[string]interface{}
+	if nodeList, ok := nodes.([]interface{}); ok {
+		for _, node := range nodeList {
+			if n, ok := node.(neo4j.Node); ok {
+				connectedNodes = append(connectedNodes, n.Props)
+			}
+		}
+	}
+
+	// 变长匹配的关系变量是关系列表，逐个展开
+	var relationships []map[string]interface{}
+	var collectRelations func(value interface{})
+	collectRelations = func(value interface{}) {
+		switch v := value.(type) {
+		case neo4j.Relationship:
+			relationships = append(relationships, v.Props)
+		case []interface{}:
+			for _, item := range v {
+				collectRelations(item)
+			}
+		}
+	}
+	collectRelations(rels)
+
+	graphMetrics := make(map[string]float64)
+	if metricsMap, ok := metrics.(map[string]interface{}); ok {
+		for k, v := range metricsMap {
+			if f, ok := v.(float64); ok {
+				graphMetrics[k] = f
+			} else if i, ok := v.(int64); ok {
+				graphMetrics[k] = float64(i)
+			}
+		}
+	}
+
+	return &KnowledgeSubgraph{
+		CentralNodes:    centralNodes,
+		ConnectedNodes:  connectedNodes,
+		Relationships:   relationships,
+		GraphMetrics:    graphMetrics,
+		ReasoningChains: [][]string{},
+	}
+}
+
+// nonNilStrings 作为查询参数时将nil切片转换为空切片
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,378 @@
+package batch_0001
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+
+	"github.com/cloudwego/eino-ext/components/model/ark"
+	"github.com/cloudwego/eino/schema"
+	arkModel "github.com/volcengine/volcengine-go-sdk/service/arkruntime/model"
+)
+
+// GroundingMode 忠实度检查模式
+type GroundingMode string
+
+const (
+	// GroundingOff 不做忠实度检查（默认行为）
+	GroundingOff GroundingMode = "off"
+	// GroundingMark 标注无依据的句子/步骤
+	GroundingMark GroundingMode = "mark"
+	// GroundingStrip 删除无依据的句子/步骤
+	GroundingStrip GroundingMode = "strip"
+)
+
+// 默认参数
+const (
+	DefaultGroundingMinOverlap = 0.5            // 词面重叠率阈值
+	DefaultUnsupportedMarker   = "【未在知识库中找到依据】" // 标注模式下追加的提示
+	groundingMinCheckRunes     = 4              // 少于该字数的片段不参与检查
+	groundingVerifierMaxRunes  = 6000           // LLM核验时上下文的最大字数
+	groundingVerifierLowerRate = 0.2            // 低于该重叠率的片段直接判为无依据，不再交给LLM
+)
+
+// GroundingConfig 忠实度检查配置
+type GroundingConfig struct {
+	Mode           GroundingMode `json:"mode"`             // 检查模式：off / mark / strip
+	MinOverlap     float64       `json:"min_overlap"`      // 判为有依据的最小词面重叠率 (0-1)
+	UseLLMVerifier bool          `json:"use_llm_verifier"` // 是否使用LLM复核词面检查未通过的片段
+	Marker         string        `json:"marker"`           // 标注模式下追加在无依据片段后的文字
+}
+
+// GroundedSegment 答案片段的检查结果
+type GroundedSegment struct {
+	Text       string   `json:"text"`        // 片段原文
+	Overlap    float64  `json:"overlap"`     // 与检索文档的最大词面重叠率
+	Supported  bool     `json:"supported"`   // 是否有知识库依据
+	Checked    bool     `json:"checked"`     // 是否参与检查（标题、过短片段不参与）
+	VerifiedBy string   `json:"verified_by"` // 判定来源：lexical / llm
+	SourceIDs  []string `json:"source_ids"`  // 支撑该片段的文档ID
+}
+
+// GroundedAnswer 带忠实度信息的答案
+type GroundedAnswer struct {
+	Answer       string             `json:"answer"`       // 处理后的答案（已标注或删除无依据内容）
+	RawAnswer    string             `json:"raw_answer"`   // 模型原始输出
+	Groundedness float64            `json:"groundedness"` // 有依据内容占比 (0-1)，按字数加权
+	Mode         GroundingMode      `json:"mode"`         // 使用的检查模式
+	Segments     []*GroundedSegment `json:"segments"`     // 逐句检查明细
+}
+
+// UnsupportedSegments 返回所有无依据的片段
+func (a *GroundedAnswer) UnsupportedSegments() []*GroundedSegment {
+	var result []*GroundedSegment
+	for _, seg := range a.Segments {
+		if seg.Checked && !seg.Supported {
+			result = append(result, seg)
+		}
+	}
+	return result
+}
+
+// GroundingChecker 答案忠实度检查器
+//
+// 将生成的答案切分为句子/步骤，逐一与检索到的文档比对，
+// 找出知识库中没有依据的内容，并按配置进行标注或删除。
+//
+// 检查流程：
+// 1. 切分：按行和中文句末标点切分答案，标题和过短片段不参与检查
+// 2. 词面比对：计算片段与每个文档的字符二元组重叠率，取最大值
+// 3. LLM复核（可选）：对重叠率处于灰色区间的片段，由LLM判断是否被文档支持
+// 4. 后处理：标注或删除无依据片段，计算整体的忠实度得分
+type GroundingChecker struct {
+	config    *GroundingConfig
+	chatModel *ark.ChatModel
+}
+
+// NewGroundingChecker 创建忠实度检查器
+//
+// Args:
+//   - config: 检查配置，为nil时使用标注模式
+//   - chatModel: 用于LLM复核的模型，可为nil（此时仅做词面检查）
+func NewGroundingChecker(config *GroundingConfig, chatModel *ark.ChatModel) *GroundingChecker {
+	if config == nil {
+		config = &GroundingConfig{Mode: GroundingMark}
+	}
+	if config.Mode == "" {
+		config.Mode = GroundingMark
+	}
+	if config.MinOverlap <= 0 || config.MinOverlap > 1 {
+		config.MinOverlap = DefaultGroundingMinOverlap
+	}
+	if config.Marker == "" {
+		config.Marker = DefaultUnsupportedMarker
+	}
+	return &GroundingChecker{
+		config:    config,
+		chatModel: chatModel,
+	}
+}
+
+// Check 检查答案中的每个句子/步骤是否能在检索文档中找到依据
+func (c *GroundingChecker) Check(ctx context.Context, answer string, documents []*schema.Document) *GroundedAnswer {
+	result := &GroundedAnswer{
+		RawAnswer: answer,
+		Mode:      c.config.Mode,
+	}
+
+	// 预先计算每个文档的二元组集合
+	docGrams := make([]map[string]bool, 0, len(documents))
+	docIDs := make([]string, 0, len(documents))
+	for i, doc := range documents {
+		if doc == nil || doc.Content == "" {
+			continue
+		}
+		docGrams = append(docGrams, toGramSet(doc.Content))
+		docID := doc.ID
+		if docID == "" {
+			if nodeID, ok := doc.MetaData["node_id"]; ok {
+				docID = fmt.Sprintf("%v", nodeID)
+			} else {
+				docID = fmt.Sprintf("doc_%d", i)
+			}
+		}
+		docIDs = append(docIDs, docID)
+	}
+
+	// 1. 切分并做词面检查
+	lines := strings.Split(answer, "\n")
+	lineSegments := make([][]*GroundedSegment, len(lines))
+	var pending []*GroundedSegment // 需要LLM复核的片段
+
+	for i, line := range lines {
+		for _, sentence := range splitSentences(line) {
+			seg := &GroundedSegment{Text: sentence, Supported: true}
+			lineSegments[i] = append(lineSegments[i], seg)
+
+			if !isCheckableSegment(sentence) {
+				continue
+			}
+
+			seg.Checked = true
+			seg.VerifiedBy = "lexical"
+			grams := toGramSet(sentence)
+			for j, dg := range docGrams {
+				overlap := gramOverlap(grams, dg)
+				if overlap > seg.Overlap {
+					seg.Overlap = overlap
+				}
+				if overlap >= c.config.MinOverlap {
+					seg.SourceIDs = append(seg.SourceIDs, docIDs[j])
+				}
+			}
+			seg.Supported = seg.Overlap >= c.config.MinOverlap
+
+			if !seg.Supported && seg.Overlap >= groundingVerifierLowerRate {
+				pending = append(pending, seg)
+			}
+		}
+	}
+
+	// 2. LLM复核灰色区间的片段
+	if c.config.UseLLMVerifier && c.chatModel != nil && len(pending) > 0 {
+		if err := c.verifyWithLLM(ctx, pending, documents); err != nil {
+			log.Printf("LLM忠实度复核失败，仅使用词面检查结果: %v", err)
+		}
+	}
+
+	// 3. 标注/删除无依据片段，并计算得分
+	var totalRunes, supportedRunes int
+	var outLines []string
+	for i, segments := range lineSegments {
+		var parts []string
+		removed := false
+		for _, seg := range segments {
+			result.Segments = append(result.Segments, seg)
+			if !seg.Checked {
+				parts = append(parts, seg.Text)
+				continue
+			}
+
+			runes := countCheckableRunes(seg.Text)
+			totalRunes += runes
+			if seg.Supported {
+				supportedRunes += runes
+				parts = append(parts, seg.Text)
+				continue
+			}
+
+			switch c.config.Mode {
+			case GroundingStrip:
+				removed = true
+			case GroundingMark:
+				parts = append(parts, seg.Text+c.config.Marker)
+			default:
+				parts = append(parts, seg.Text)
+			}
+		}
+
+		line := strings.Join(parts, "")
+		// 整行内容都被删除时不保留空行，原本的空行保持不变
+		if removed && strings.TrimSpace(line) == "" {
+			continue
+		}
+		if len(segments) == 0 {
+			line = lines[i]
+		}
+		outLines = append(outLines, line)
+	}
+
+	result.Answer = strings.TrimSpace(strings.Join(outLines, "\n"))
+	if totalRunes > 0 {
+		result.Groundedness = float64(supportedRunes) / float64(totalRunes)
+	} else {
+		result.Groundedness = 1.0
+	}
+
+	log.Printf("忠实度检查完成，得分: %.2f，无依据片段: %d", result.Groundedness, len(result.UnsupportedSegments()))
+	return result
+}
+
+// groundingVerifyResult LLM复核结果
+type groundingVerifyResult struct {
+	Supported []int `json:"supported"`
+}
+
+// verifyWithLLM 使用LLM判断片段是否被检索文档支持
+func (c *GroundingChecker) verifyWithLLM(ctx context.Context, segments []*GroundedSegment, documents []*schema.Document) error {
+	var contextParts []string
+	for _, doc := range documents {
+		if doc != nil && doc.Content != "" {
+			contextParts = append(contextParts, doc.Content)
+		}
+	}
+	docContext := []rune(strings.Join(contextParts, "\n\n"))
+	if len(docContext) > groundingVerifierMaxRunes {
+		docContext = docContext[:groundingVerifierMaxRunes]
+	}
+
+	var claims []string
+	for i, seg := range segments {
+		claims = append(claims, fmt.Sprintf("%d. %s", i+1, strings.TrimSpace(seg.Text)))
+	}
+
+	userContent := fmt.Sprintf(`请判断下列陈述是否能由参考资料直接支持或合理推出（同义改写视为支持，资料中没有的新步骤、新用量、新食材视为不支持）。
+
+参考资料：
+%s
+
+待判断的陈述：
+%s
+
+请严格按照JSON格式返回被支持的陈述编号，不要包含多余的文字：
+{"supported": [1, 3]}`, string(docContext), strings.Join(claims, "\n"))
+
+	messages := []*schema.Message{
+		schema.SystemMessage("你是严谨的事实核查助手，只依据给定资料做判断。"),
+		schema.UserMessage(userContent),
+	}
+
+	thinking := &arkModel.Thinking{
+		Type: arkModel.ThinkingTypeDisabled,
+	}
+
+	response, err := c.chatModel.Generate(ctx, messages, ark.WithThinking(thinking))
+	if err != nil {
+		return fmt.Errorf("LLM生成失败: %w", err)
+	}
+
+	cleanContent := cleanJSONResponse(response.Content)
+
+	var result groundingVerifyResult
+	if err := json.Unmarshal([]byte(cleanContent), &result); err != nil {
+		return fmt.Errorf("JSON解析失败: %w, 响应内容: %s", err, response.Content)
+	}
+
+	for _, idx := range result.Supported {
+		if idx >= 1 && idx <= len(segments) {
+			segments[idx-1].Supported = true
+			segments[idx-1].VerifiedBy = "llm"
+		}
+	}
+	return nil
+}
+
+// ========== 辅助函数 ==========
+
+// splitSentences 按中文/英文句末标点切分一行文本，保留标点和原有空白
+func splitSentences(line string) []string {
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+
+	var sentences []string
+	var current []rune
+	for _, r := range line {
+		current = append(current, r)
+		switch r {
+		case '。', '！', '？', '；', '!', '?', ';':
+			sentences = append(sentences, string(current))
+			current = current[:0]
+		}
+	}
+	if len(current) > 0 {
+		sentences = append(sentences, string(current))
+	}
+	return sentences
+}
+
+// isCheckableSegment 判断片段是否需要做忠实度检查
+//
+// Markdown标题、以冒号结尾的小标题和过短的片段不包含可核查的事实，直接跳过。
+func isCheckableSegment(text string) bool {
+	trimmed := strings.TrimSpace(text)
+	if strings.HasPrefix(trimmed, "#") {
+		return false
+	}
+	if strings.HasSuffix(trimmed, "：") || strings.HasSuffix(trimmed, ":") {
+		return false
+	}
+	return countCheckableRunes(trimmed) >= groundingMinCheckRunes
+}
+
+// countCheckableRunes 统计文字和数字的个数（忽略标点、空白和Markdown符号）
+func countCheckableRunes(text string) int {
+	count := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			count++
+		}
+	}
+	return count
+}
+
+// toGramSet 将文本转换为字符二元组集合，用于中文词面重叠计算
+func toGramSet(text string) map[string]bool {
+	var runes []rune
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			runes = append(runes, r)
+		}
+	}
+
+	grams := make(map[string]bool)
+	if len(runes) == 1 {
+		grams[string(runes)] = true
+	}
+	for i := 0; i+1 < len(runes); i++ {
+		grams[string(runes[i:i+2])] = true
+	}
+	return grams
+}
+
+// gramOverlap 计算片段二元组在文档中出现的比例
+func gramOverlap(segment, document map[string]bool) float64 {
+	if len(segment) == 0 {
+		return 0
+	}
+	hit := 0
+	for gram := range segment {
+		if document[gram] {
+			hit++
+		}
+	}
+	return float64(hit) / float64(len(segment))
+}
@@ -10,7 +10,6 @@ import (
 
 	"github.com/cloudwego/eino-ext/components/model/ark"
 	"github.com/cloudwego/eino/schema"
-	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 )
 
 // RetrievalResult 检索结果数据结构
@@ -26,6 +25,12 @@ type RetrievalResult struct {
 	Metadata       map[string]interface{} `json:"metadata"`        // 丰富的元数据信息
 }
 
+// 约束检索相关参数
+const (
+	minConstrainedResults = 3 // 约束检索结果少于该数量时放宽约束
+	constraintOverFetch   = 3 // 内存过滤的通道多取候选的倍数
+)
+
 // HybridRetrievalModule 混合检索模块 - RAG系统的多策略检索引擎
 //
 // 实现了创新的双层检索范式，结合图结构的精确匹配和向量语义的模糊匹配，
@@ -33,15 +38,15 @@ type RetrievalResult struct {
 //
 // 核心特点：
 // 1. 双层检索架构：实体级 + 主题级的分层检索策略
-// 2. 多数据源融合：Neo4j图数据库 + Milvus向量数据库 + BM25文本检索
+// 2. 多数据源融合：图存储（Neo4j或内存图） + Milvus向量数据库 + BM25文本检索
 // 3. 智能查询理解：LLM驱动的查询意图分析和关键词提取
 // 4. 图结构增强：利用知识图谱的关系信息丰富检索结果
 // 5. 公平结果合并：Round-robin轮询策略避免单一方法偏差
 //
 // 检索流程：
 // 1. 查询预处理：LLM分析提取实体级和主题级关键词
-// 2. 实体级检索：基于图索引的精确实体和关系匹配
-// 3. 主题级检索：基于图关系的主题概念检索
+// 2. 实体级检索：基于图索引（GraphIndexingModule）实体键的实体匹配
+// 3. 主题级检索：基于图索引关系键/主题键的主题概念检索
 // 4. 向量增强检索：Milvus语义相似度搜索
 // 5. 结果融合排序：Round-robin合并 + 相关性排序
 //
@@ -55,12 +60,15 @@ type HybridRetrievalModule struct {
 	milvusModule *MilvusIndexConstructionModule // Milvus向量索引模块
 	dataModule   *GraphDataPreparationModule    // 图数据准备模块
 	llmClient    *ark.ChatModel                 // 大语言模型客户端
-	driver       neo4j.DriverWithContext        // Neo4j数据库连接
+	store        GraphStore                     // 图存储
+	ownsStore    bool                           // 图存储由本模块创建时，Close会关闭它
+	bm25         *BM25Retriever                 // BM25文本检索器
+	reranker     Reranker                       // 融合结果重排序器，为nil时不重排序
+	constraints  *QueryConstraintExtractor      // 查询约束抽取器
 
 	// 图索引相关
-	entityCache   map[string]*RetrievalResult // 实体信息缓存
-	relationCache map[string]int              // 关系类型缓存
-	graphIndexed  bool                        // 图索引构建状态
+	index        *GraphIndexingModule // 实体/关系键值对索引
+	graphIndexed bool                 // 图索引构建状态
 }
 
 // NewHybridRetrievalModule 创建新的混合检索模块
@@ -73,13 +81,12 @@ type HybridRetrievalModule struct {
 //	llmClient: 大语言模型客户端，用于查询分析
 func NewHybridRetrievalModule(config *Config, milvusModule *MilvusIndexConstructionModule, dataModule *GraphDataPreparationModule, llmClient *ark.ChatModel) *HybridRetrievalModule {
 	return &HybridRetrievalModule{
-		config:        config,
-		milvusModule:  milvusModule,
-		dataModule:    dataModule,
-		llmClient:     llmClient,
-		entityCache:   make(map[string]*RetrievalResult),
-		relationCache: make(map[string]int),
-		graphIndexed:  false,
+		config:       config,
+		milvusModule: milvusModule,
+		dataModule:   dataModule,
+		llmClient:    llmClient,
+		reranker:     NewLexicalReranker(nil),
+		graphIndexed: false,
 	}
 }
 
@@ -95,151 +102,214 @@ func NewHybridRetrievalModule(config *Config, milvusModule *MilvusIndexConstruct
 func (h *HybridRetrievalModule) Initialize(ctx context.Context, chunks []*schema.Document) error {
 	log.Println("初始化混合检索模块...")
 
-	// 连接Neo4j图数据库
-	driver, err := neo4j.NewDriverWithContext(
-		h.config.Neo4jURI,
-		neo4j.BasicAuth(h.config.Neo4jUser, h.config.Neo4jPassword, ""),
-	)
-	if err != nil {
-		return fmt.Errorf("Neo4j连接失败: %w", err)
-	}
-	h.driver = driver
-
-	// 测试连接
-	err = driver.VerifyConnectivity(ctx)
-	if err != nil {
-		return fmt.Errorf("Neo4j连接验证失败: %w", err)
+	// 未设置图存储时连接Neo4j
+	if h.store == nil {
+		store, err := ConnectNeo4jGraphStore(ctx, h.config.Neo4jURI, h.config.Neo4jUser, h.config.Neo4jPassword, "")
+		if err != nil {
+			return err
+		}
+		h.store = store
+		h.ownsStore = true
 	}
 
-	log.Printf("BM25检索器初始化完成，文档数量: %d", len(chunks))
-
 	// 构建图索引 - 核心的图结构检索能力
 	if err := h.buildGraphIndex(ctx); err != nil {
 		return fmt.Errorf("构建图索引失败: %w", err)
 	}
 
+	// 初始化BM25检索器（依赖图索引中的实体名称作为分词词典）
+	h.initializeBM25(chunks)
+
+	// 初始化查询约束抽取器（使用食材名称识别排除食材）
+	h.constraints = NewQueryConstraintExtractor(h.ingredientNames())
+
 	return nil
 }
 
-// buildGraphIndex 构建图索引系统
+// initializeBM25 初始化BM25检索器
 //
-// 从图数据模块获取实体和关系数据，构建高效的键值对索引结构。
-// 支持实体级和主题级的快速检索。
-func (h *HybridRetrievalModule) buildGraphIndex(ctx context.Context) error {
-	if h.graphIndexed {
-		return nil
+// 有文档块时：若磁盘索引与当前文档块一致则直接复用，否则重新构建并保存。
+// 无文档块时（如从已有Milvus集合加载知识库）：尝试从磁盘加载索引。
+// BM25只是检索通道之一，失败时记录日志并跳过，不影响其他检索通道。
+func (h *HybridRetrievalModule) initializeBM25(chunks []*schema.Document) {
+	indexPath := h.config.BM25IndexPath
+	retriever := NewBM25Retriever(NewChineseTokenizer(h.dictionaryWords()), DefaultBM25K1, DefaultBM25B)
+
+	if len(chunks) == 0 {
+		if indexPath == "" {
+			log.Println("没有文档块且未配置BM25索引路径，跳过BM25检索器")
+			return
+		}
+		if err := retriever.LoadFromFile(indexPath); err != nil {
+			log.Printf("BM25索引加载失败，跳过BM25检索器: %v", err)
+			return
+		}
+		h.bm25 = retriever
+		log.Printf("BM25检索器初始化完成，文档数量: %d", retriever.Size())
+		return
 	}
 
-	log.Println("开始构建图索引...")
-
-	session := h.driver.NewSession(ctx, neo4j.SessionConfig{})
-	defer session.Close(ctx)
-
-	// 构建实体索引
-	entityQuery := `
-		MATCH (n)
-		WHERE n.nodeId IS NOT NULL
-		WITH n, COUNT { (n)--() } as degree
-		RETURN labels(n) as node_labels, n.nodeId as node_id, 
-		       n.name as name, n.category as category, 
-		       n.description as description, degree
-		ORDER BY degree DESC
-		LIMIT 1000
-	`
-
-	result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
-		result, err := tx.Run(ctx, entityQuery, nil)
-		if err != nil {
-			return nil, err
+	if indexPath != "" {
+		if err := retriever.LoadFromFile(indexPath); err == nil && retriever.MatchesCorpus(chunks) {
+			h.bm25 = retriever
+			log.Printf("BM25检索器初始化完成（复用磁盘索引），文档数量: %d", retriever.Size())
+			return
 		}
-		records, err := result.Collect(ctx)
-		if err != nil {
-			return nil, err
+	}
+
+	retriever.Build(chunks)
+	if indexPath != "" {
+		if err := retriever.SaveToFile(indexPath); err != nil {
+			log.Printf("BM25索引保存失败: %v", err)
 		}
-		return records, nil
-	})
+	}
+	h.bm25 = retriever
+	log.Printf("BM25检索器初始化完成，文档数量: %d", retriever.Size())
+}
 
-	if err != nil {
-		return fmt.Errorf("执行实体索引查询失败: %w", err)
+// Refresh 知识库同步后刷新内存缓存
+//
+// 重新构建图索引和查询约束抽取器；BM25索引在全量同步时重建，
+// 增量同步时只替换受影响菜谱的文档块，并保存到磁盘。
+func (h *HybridRetrievalModule) Refresh(ctx context.Context, report *KnowledgeBaseSyncReport) error {
+	h.graphIndexed = false
+	if err := h.buildGraphIndex(ctx); err != nil {
+		return fmt.Errorf("刷新图索引失败: %w", err)
 	}
+	h.constraints = NewQueryConstraintExtractor(h.ingredientNames())
 
-	records := result.([]*neo4j.Record)
-	for _, record := range records {
-		nodeID, _ := record.Get("node_id")
-		nodeLabels, _ := record.Get("node_labels")
-		name, _ := record.Get("name")
-		category, _ := record.Get("category")
-		description, _ := record.Get("description")
-		degree, _ := record.Get("degree")
+	if report == nil || !report.HasChanges() {
+		return nil
+	}
+	if report.Full {
+		h.initializeBM25(h.dataModule.Chunks)
+		return nil
+	}
+	if h.bm25 == nil {
+		// 增量同步时内存中只有受影响菜谱的文档块，无法据此构建完整索引
+		log.Println("BM25检索器未初始化，跳过增量更新，请重建知识库以生成BM25索引")
+		return nil
+	}
 
-		// 构建内容
-		var contentParts []string
-		if nameStr, ok := name.(string); ok && nameStr != "" {
-			contentParts = append(contentParts, fmt.Sprintf("名称: %s", nameStr))
+	// 新增菜谱和食材的名称加入分词词典，使新文档块中的名称能被整词匹配
+	h.bm25.tokenizer.AddWords(h.dictionaryWords())
+	h.bm25.ReplaceDocuments(report.ReplacedNodeIDs(), report.Chunks)
+	if h.config.BM25IndexPath != "" {
+		if err := h.bm25.SaveToFile(h.config.BM25IndexPath); err != nil {
+			log.Printf("BM25索引保存失败: %v", err)
 		}
-		if categoryStr, ok := category.(string); ok && categoryStr != "" {
-			contentParts = append(contentParts, fmt.Sprintf("分类: %s", categoryStr))
+	}
+	return nil
+}
+
+// dictionaryWords 收集菜谱和食材名称作为分词词典
+func (h *HybridRetrievalModule) dictionaryWords() []string {
+	var words []string
+	if h.dataModule != nil {
+		for _, recipe := range h.dataModule.Recipes {
+			words = append(words, recipe.Name)
 		}
-		if descStr, ok := description.(string); ok && descStr != "" {
-			contentParts = append(contentParts, fmt.Sprintf("描述: %s", descStr))
+		for _, ingredient := range h.dataModule.Ingredients {
+			words = append(words, ingredient.Name)
 		}
-
-		// 确定节点类型
-		nodeType := "Unknown"
-		if labels, ok := nodeLabels.([]interface{}); ok && len(labels) > 0 {
-			if labelStr, ok := labels[0].(string); ok {
-				nodeType = labelStr
-			}
+	}
+	if h.index != nil {
+		for _, entity := range h.index.EntitiesByType("Category") {
+			words = append(words, entity.EntityName)
 		}
+	}
+	return words
+}
 
-		// 缓存实体信息
-		h.entityCache[nodeID.(string)] = &RetrievalResult{
-			Content:        strings.Join(contentParts, "\n"),
-			NodeID:         nodeID.(string),
-			NodeType:       nodeType,
-			RelevanceScore: 0.8, // 基础相关性得分
-			RetrievalLevel: "entity",
-			Metadata: map[string]interface{}{
-				"name":     name,
-				"category": category,
-				"degree":   degree,
-				"labels":   nodeLabels,
-			},
+// ingredientNames 收集食材名称
+func (h *HybridRetrievalModule) ingredientNames() []string {
+	var names []string
+	if h.dataModule != nil {
+		for _, ingredient := range h.dataModule.Ingredients {
+			names = append(names, ingredient.Name)
 		}
 	}
+	if h.index != nil {
+		for _, entity := range h.index.EntitiesByType("Ingredient") {
+			names = append(names, entity.EntityName)
+		}
+	}
+	return names
+}
 
-	// 构建关系类型索引
-	relationQuery := `
-		MATCH ()-[r]->()
-		RETURN type(r) as rel_type, count(r) as frequency
-		ORDER BY frequency DESC
-	`
+// ExtractConstraints 从查询中抽取结构化约束（菜系、难度、总耗时、分类、排除食材）
+func (h *HybridRetrievalModule) ExtractConstraints(query string) *QueryConstraints {
+	if h.constraints == nil {
+		h.constraints = NewQueryConstraintExtractor(h.ingredientNames())
+	}
+	constraints := h.constraints.Extract(query)
+	if !constraints.IsEmpty() {
+		log.Printf("查询约束: %s", constraints)
+	}
+	return constraints
+}
 
-	result, err = session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
-		result, err := tx.Run(ctx, relationQuery, nil)
-		if err != nil {
-			return nil, err
-		}
-		records, err := result.Collect(ctx)
-		if err != nil {
-			return nil, err
-		}
-		return records, nil
-	})
+// SetReranker 设置融合结果重排序器，传入nil时关闭重排序
+func (h *HybridRetrievalModule) SetReranker(reranker Reranker) {
+	h.reranker = reranker
+}
 
-	if err != nil {
-		return fmt.Errorf("执行关系索引查询失败: %w", err)
+// SetGraphStore 设置图存储
+//
+// 需在Initialize之前调用；未设置时Initialize按配置连接Neo4j。
+func (h *HybridRetrievalModule) SetGraphStore(store GraphStore) {
+	h.store = store
+	h.ownsStore = false
+}
+
+// BM25Search BM25关键词检索
+//
+// 检索器未初始化时返回空结果。
+func (h *HybridRetrievalModule) BM25Search(query string, topK int) []*schema.Document {
+	if h.bm25 == nil {
+		return []*schema.Document{}
+	}
+	return h.bm25.Search(query, topK)
+}
+
+// buildGraphIndex 构建图索引系统
+//
+// 从图数据模块的输出构建实体和关系键值对索引（GraphIndexingModule）：
+// 实体级检索按实体键（实体名称）匹配，主题级检索按关系键和主题键匹配。
+func (h *HybridRetrievalModule) buildGraphIndex(ctx context.Context) error {
+	if h.graphIndexed {
+		return nil
 	}
 
-	records = result.([]*neo4j.Record)
-	for _, record := range records {
-		relType, _ := record.Get("rel_type")
-		frequency, _ := record.Get("frequency")
-		h.relationCache[relType.(string)] = int(frequency.(int64))
+	log.Println("开始构建图索引...")
+
+	if h.dataModule == nil {
+		return fmt.Errorf("未设置图数据准备模块")
+	}
+	// 从已有Milvus集合加载知识库时图数据尚未加载
+	if len(h.dataModule.Recipes) == 0 {
+		if _, err := h.dataModule.LoadGraphData(); err != nil {
+			return fmt.Errorf("加载图数据失败: %w", err)
+		}
+	}
+
+	if h.index == nil {
+		var llmClient ark.ChatModel
+		if h.llmClient != nil {
+			llmClient = *h.llmClient
+		}
+		h.index = NewGraphIndexingModule(h.config, llmClient)
+		if err := h.index.LoadConfigFiles(); err != nil {
+			return err
+		}
+	}
+	if err := h.index.BuildFromDataModule(ctx, h.dataModule); err != nil {
+		return err
 	}
 
 	h.graphIndexed = true
-	log.Printf("索引构建完成: %d个实体, %d个关系类型", len(h.entityCache), len(h.relationCache))
+	stats := h.index.GetStatistics()
+	log.Printf("索引构建完成: %v个实体, %v个关系", stats["total_entities"], stats["total_relations"])
 
 	return nil
 }
@@ -353,60 +423,73 @@ func (h *HybridRetrievalModule) extractWithLLM(ctx context.Context, query string
 }
 
 // EntityLevelRetrieval 实体级检索：专注于具体实体和关系
-// 使用图索引的键值对结构进行检索
+// 使用图索引的实体键（实体名称）进行检索，精确匹配实体键的结果得分更高
 func (h *HybridRetrievalModule) EntityLevelRetrieval(ctx context.Context, entityKeywords []string, topK int) ([]*RetrievalResult, error) {
 	var results []*RetrievalResult
 
 	// 1. 使用图索引进行实体检索
-	for _, keyword := range entityKeywords {
-		for nodeID, entity := range h.entityCache {
-			// 简单的关键词匹配
-			if strings.Contains(strings.ToLower(entity.Content), strings.ToLower(keyword)) ||
-				strings.Contains(strings.ToLower(entity.NodeID), strings.ToLower(keyword)) {
-
-				// 获取邻居信息
-				neighbors, _ := h.getNodeNeighbors(ctx, nodeID, 2)
-
-				// 构建增强内容
-				enhancedContent := entity.Content
-				if len(neighbors) > 0 {
-					enhancedContent += fmt.Sprintf("\n相关信息: %s", strings.Join(neighbors, ", "))
-				}
-
-				result := &RetrievalResult{
-					Content:        enhancedContent,
-					NodeID:         entity.NodeID,
-					NodeType:       entity.NodeType,
-					RelevanceScore: 0.9, // 精确匹配得分较高
-					RetrievalLevel: "entity",
-					Metadata: map[string]interface{}{
-						"matched_keyword": keyword,
-						"source":          "graph_index",
-					},
-				}
-
-				// 复制原有元数据
-				for k, v := range entity.Metadata {
-					result.Metadata[k] = v
+	if h.index != nil {
+		seen := make(map[string]bool)
+		for _, keyword := range entityKeywords {
+			for _, match := range h.index.MatchEntityKeys(keyword, 0) {
+				for _, entity := range h.index.GetEntitiesByKey(match.Key) {
+					nodeID := getStringFromMap(entity.Metadata, "node_id", "")
+					if nodeID == "" || seen[nodeID] {
+						continue
+					}
+					seen[nodeID] = true
+
+					// 包含关键词的实体得0.8，精确匹配更高，拼音和错别字匹配按键的匹配得分折算
+					score := 0.8
+					switch {
+					case entity.EntityName == keyword:
+						score = 0.9
+					case match.Kind != KeyMatchPrefix && match.Kind != KeyMatchSubstring && match.Kind != KeyMatchExact:
+						score = 0.8 * match.Score
+					}
+					metadata := entityResultMetadata(entity, keyword, "graph_index")
+					metadata["key_match"] = match.Kind
+					results = append(results, &RetrievalResult{
+						Content:        entity.ValueContent,
+						NodeID:         nodeID,
+						NodeType:       entity.EntityType,
+						RelevanceScore: score,
+						RetrievalLevel: "entity",
+						Metadata:       metadata,
+					})
 				}
+			}
+		}
 
-				results = append(results, result)
+		// 名称越接近关键词越靠前，邻居信息只为保留的结果查询
+		sortRetrievalResults(results)
+		if len(results) > topK {
+			results = results[:topK]
+		}
+		nodeIDs := make([]string, 0, len(results))
+		for _, result := range results {
+			nodeIDs = append(nodeIDs, result.NodeID)
+		}
+		neighborNames := h.batchNeighborNames(ctx, nodeIDs, nil, 2)
+		for _, result := range results {
+			if neighbors := neighborNames[result.NodeID]; len(neighbors) > 0 {
+				result.Content += fmt.Sprintf("\n相关信息: %s", strings.Join(neighbors, ", "))
 			}
 		}
 	}
 
-	// 2. 如果图索引结果不足，使用Neo4j进行补充检索
+	// 2. 如果图索引结果不足，使用图存储进行补充检索
 	if len(results) < topK {
-		neo4jResults, err := h.neo4jEntityLevelSearch(ctx, entityKeywords, topK-len(results))
+		graphResults, err := h.graphEntityLevelSearch(ctx, entityKeywords, topK-len(results))
 		if err != nil {
-			log.Printf("Neo4j补充检索失败: %v", err)
+			log.Printf("图存储补充检索失败: %v", err)
 		} else {
-			results = append(results, neo4jResults...)
+			results = append(results, graphResults...)
 		}
 	}
 
 	// 3. 按相关性排序并返回
-	sort.Slice(results, func(i, j int) bool {
+	sort.SliceStable(results, func(i, j int) bool {
 		return results[i].RelevanceScore > results[j].RelevanceScore
 	})
 
@@ -418,87 +501,84 @@ func (h *HybridRetrievalModule) EntityLevelRetrieval(ctx context.Context, entity
 	return results, nil
 }
 
-// neo4jEntityLevelSearch Neo4j补充检索
-func (h *HybridRetrievalModule) neo4jEntityLevelSearch(ctx context.Context, keywords []string, limit int) ([]*RetrievalResult, error) {
-	var results []*RetrievalResult
+// entityResultMetadata 由索引实体构建检索结果元数据
+func entityResultMetadata(entity *EntityKeyValue, keyword, source string) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"name":            entity.EntityName,
+		"labels":          []string{entity.EntityType},
+		"matched_keyword": keyword,
+		"source":          source,
+	}
+	if properties, ok := entity.Metadata["properties"].(map[string]interface{}); ok {
+		metadata["category"] = properties["category"]
+		if cuisineType, exists := properties["cuisineType"]; exists {
+			metadata["cuisine_type"] = cuisineType
+		}
+		if difficulty, exists := properties["difficulty"]; exists {
+			metadata["difficulty"] = difficulty
+		}
+	}
+	return metadata
+}
 
-	session := h.driver.NewSession(ctx, neo4j.SessionConfig{})
-	defer session.Close(ctx)
-
-	cypherQuery := `
-		UNWIND $keywords as keyword
-		MATCH (node)
-		WHERE node.name CONTAINS keyword 
-		   OR node.description CONTAINS keyword
-		   OR node.category CONTAINS keyword
-		RETURN 
-		    node.nodeId as node_id,
-		    node.name as name,
-		    node.description as description,
-		    node.category as category,
-		    labels(node) as labels,
-		    keyword as matched_keyword
-		ORDER BY node.name
-		LIMIT $limit
-	`
-
-	result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
-		result, err := tx.Run(ctx, cypherQuery, map[string]interface{}{
-			"keywords": keywords,
-			"limit":    limit,
-		})
-		if err != nil {
-			return nil, err
+// sortRetrievalResults 按得分降序排序，得分相同时名称较短（更接近关键词）的在前
+func sortRetrievalResults(results []*RetrievalResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].RelevanceScore != results[j].RelevanceScore {
+			return results[i].RelevanceScore > results[j].RelevanceScore
 		}
-		records, err := result.Collect(ctx)
-		if err != nil {
-			return nil, err
+		nameI := getStringFromMap(results[i].Metadata, "name", "")
+		nameJ := getStringFromMap(results[j].Metadata, "name", "")
+		if len(nameI) != len(nameJ) {
+			return len(nameI) < len(nameJ)
 		}
-		return records, nil
+		return results[i].NodeID < results[j].NodeID
 	})
+}
 
+// graphEntityLevelSearch 图存储补充检索：按名称、描述、分类匹配节点
+func (h *HybridRetrievalModule) graphEntityLevelSearch(ctx context.Context, keywords []string, limit int) ([]*RetrievalResult, error) {
+	matches, err := h.store.SearchNodes(ctx, NodeSearchQuery{
+		Keywords: keywords,
+		Fields:   []string{"name", "description", "category"},
+		Limit:    limit,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	records := result.([]*neo4j.Record)
-	for _, record := range records {
-		var contentParts []string
+	var results []*RetrievalResult
+	for _, match := range matches {
+		node := match.Node
 
-		if name, exists := record.Get("name"); exists && name != nil {
-			contentParts = append(contentParts, fmt.Sprintf("菜品: %v", name))
+		var contentParts []string
+		if node.Name != "" {
+			contentParts = append(contentParts, fmt.Sprintf("菜品: %s", node.Name))
 		}
-		if description, exists := record.Get("description"); exists && description != nil {
+		if description, exists := node.Properties["description"]; exists && description != nil {
 			contentParts = append(contentParts, fmt.Sprintf("描述: %v", description))
 		}
-		if category, exists := record.Get("category"); exists && category != nil {
+		if category, exists := node.Properties["category"]; exists && category != nil {
 			contentParts = append(contentParts, fmt.Sprintf("分类: %v", category))
 		}
 
-		nodeID, _ := record.Get("node_id")
-		name, _ := record.Get("name")
-		labels, _ := record.Get("labels")
-		matchedKeyword, _ := record.Get("matched_keyword")
-
 		// 确定节点类型
 		nodeType := "Unknown"
-		if labelSlice, ok := labels.([]interface{}); ok && len(labelSlice) > 0 {
-			if labelStr, ok := labelSlice[0].(string); ok {
-				nodeType = labelStr
-			}
+		if len(node.Labels) > 0 {
+			nodeType = node.Labels[0]
 		}
 
 		results = append(results, &RetrievalResult{
 			Content:        strings.Join(contentParts, "\n"),
-			NodeID:         nodeID.(string),
+			NodeID:         node.NodeID,
 			NodeType:       nodeType,
 			RelevanceScore: 0.7, // 补充检索得分较低
 			RetrievalLevel: "entity",
 			Metadata: map[string]interface{}{
-				"name":            name,
-				"labels":          labels,
-				"matched_keyword": matchedKeyword,
-				"source":          "neo4j_fallback",
+				"name":            node.Name,
+				"labels":          node.Labels,
+				"matched_keyword": match.Keyword,
+				"source":          "graph_fallback",
 			},
 		})
 	}
@@ -507,51 +587,101 @@ func (h *HybridRetrievalModule) neo4jEntityLevelSearch(ctx context.Context, keyw
 }
 
 // TopicLevelRetrieval 主题级检索：专注于广泛主题和概念
-// 使用图索引的关系键值对结构进行主题检索
+// 使用图索引的关系键和主题键进行检索，命中的关系归并到源实体（菜谱），
+// 命中关键词越多、命中关系越多的菜谱越靠前
 func (h *HybridRetrievalModule) TopicLevelRetrieval(ctx context.Context, topicKeywords []string, topK int) ([]*RetrievalResult, error) {
 	var results []*RetrievalResult
 
-	// 1. 使用实体的分类信息进行主题检索
-	for _, keyword := range topicKeywords {
-		for nodeID, entity := range h.entityCache {
-			// 检查分类匹配
-			if category, exists := entity.Metadata["category"]; exists {
-				if categoryStr, ok := category.(string); ok {
-					if strings.Contains(strings.ToLower(categoryStr), strings.ToLower(keyword)) {
-						contentParts := []string{
-							fmt.Sprintf("主题分类: %s", keyword),
-							entity.Content,
-						}
+	// 1. 使用图索引的关系键进行主题检索
+	if h.index != nil {
+		type topicHit struct {
+			result   *RetrievalResult
+			keywords []string
+			matches  int
+			related  []string
+		}
+		hits := make(map[string]*topicHit)
+		var order []string
+
+		for _, keyword := range topicKeywords {
+			_, relations := h.index.SearchByKeyword(keyword)
+			for _, relation := range relations {
+				source, exists := h.index.GetEntity(relation.SourceEntity)
+				if !exists {
+					continue
+				}
 
-						results = append(results, &RetrievalResult{
-							Content:        strings.Join(contentParts, "\n"),
-							NodeID:         nodeID,
-							NodeType:       entity.NodeType,
-							RelevanceScore: 0.85, // 分类匹配得分
-							RetrievalLevel: "topic",
-							Metadata: map[string]interface{}{
-								"matched_keyword": keyword,
-								"source":          "category_match",
-							},
-						})
-					}
+				score := 0.8
+				if containsString(relation.IndexKeys, keyword) {
+					score = 0.85 // 精确匹配主题键
+				}
+
+				hit, exists := hits[relation.SourceEntity]
+				if !exists {
+					hit = &topicHit{result: &RetrievalResult{
+						Content:        fmt.Sprintf("主题: %s\n%s", keyword, source.ValueContent),
+						NodeID:         relation.SourceEntity,
+						NodeType:       source.EntityType,
+						RelevanceScore: score,
+						RetrievalLevel: "topic",
+						Metadata:       entityResultMetadata(source, keyword, "relation_index"),
+					}}
+					hits[relation.SourceEntity] = hit
+					order = append(order, relation.SourceEntity)
+				}
+				hit.result.RelevanceScore = max(hit.result.RelevanceScore, score)
+				hit.matches++
+				if !containsString(hit.keywords, keyword) {
+					hit.keywords = append(hit.keywords, keyword)
+				}
+
+				// 步骤实体的名称只是编号，不作为相关信息
+				target, exists := h.index.GetEntity(relation.TargetEntity)
+				if exists && target.EntityType != "CookingStep" && len(hit.related) < 5 && !containsString(hit.related, target.EntityName) {
+					hit.related = append(hit.related, target.EntityName)
 				}
 			}
 		}
+
+		sort.SliceStable(order, func(i, j int) bool {
+			a, b := hits[order[i]], hits[order[j]]
+			if a.result.RelevanceScore != b.result.RelevanceScore {
+				return a.result.RelevanceScore > b.result.RelevanceScore
+			}
+			if len(a.keywords) != len(b.keywords) {
+				return len(a.keywords) > len(b.keywords)
+			}
+			if a.matches != b.matches {
+				return a.matches > b.matches
+			}
+			return order[i] < order[j]
+		})
+		for _, nodeID := range order {
+			if len(results) >= topK {
+				break
+			}
+			hit := hits[nodeID]
+			if len(hit.related) > 0 {
+				hit.result.Content += fmt.Sprintf("\n相关: %s", strings.Join(hit.related, ", "))
+			}
+			hit.result.Metadata["matched_keywords"] = hit.keywords
+			hit.result.Metadata["matched_relations"] = hit.matches
+			results = append(results, hit.result)
+		}
 	}
 
-	// 2. 如果结果不足，使用Neo4j进行补充检索
+	// 2. 如果结果不足，使用图存储进行补充检索
 	if len(results) < topK {
-		neo4jResults, err := h.neo4jTopicLevelSearch(ctx, topicKeywords, topK-len(results))
+		graphResults, err := h.graphTopicLevelSearch(ctx, topicKeywords, topK-len(results))
 		if err != nil {
-			log.Printf("Neo4j主题级检索失败: %v", err)
+			log.Printf("图存储主题级检索失败: %v", err)
 		} else {
-			results = append(results, neo4jResults...)
+			results = append(results, graphResults...)
 		}
 	}
 
 	// 3. 按相关性排序并返回
-	sort.Slice(results, func(i, j int) bool {
+	sort.SliceStable(results, func(i, j int) bool {
 		return results[i].RelevanceScore > results[j].RelevanceScore
 	})
 
@@ -563,104 +693,70 @@ func (h *HybridRetrievalModule) TopicLevelRetrieval(ctx context.Context, topicKe
 	return results, nil
 }
 
-// neo4jTopicLevelSearch Neo4j主题级检索补充
-func (h *HybridRetrievalModule) neo4jTopicLevelSearch(ctx context.Context, keywords []string, limit int) ([]*RetrievalResult, error) {
-	var results []*RetrievalResult
-
-	session := h.driver.NewSession(ctx, neo4j.SessionConfig{})
-	defer session.Close(ctx)
-
-	cypherQuery := `
-		UNWIND $keywords as keyword
-		MATCH (r:Recipe)
-		WHERE r.category CONTAINS keyword 
-		   OR r.cuisineType CONTAINS keyword
-		   OR r.tags CONTAINS keyword
-		WITH r, keyword
-		OPTIONAL MATCH (r)-[:REQUIRES]->(i:Ingredient)
-		WITH r, keyword, collect(i.name)[0..3] as ingredients
-		RETURN 
-		    r.nodeId as node_id,
-		    r.name as name,
-		    r.category as category,
-		    r.cuisineType as cuisine_type,
-		    r.difficulty as difficulty,
-		    ingredients,
-		    keyword as matched_keyword
-		ORDER BY r.difficulty ASC, r.name
-		LIMIT $limit
-	`
-
-	result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
-		result, err := tx.Run(ctx, cypherQuery, map[string]interface{}{
-			"keywords": keywords,
-			"limit":    limit,
-		})
-		if err != nil {
-			return nil, err
-		}
-		records, err := result.Collect(ctx)
-		if err != nil {
-			return nil, err
-		}
-		return records, nil
-	})
+// graphTopicLevelSearch 图存储主题级检索补充：按分类、菜系、标签匹配菜谱
+func (h *HybridRetrievalModule) graphTopicLevelSearch(ctx context.Context, keywords []string, limit int) ([]*RetrievalResult, error) {
+	// 查询约束下推到图存储
+	query := NodeSearchQuery{
+		Keywords:         keywords,
+		Fields:           []string{"category", "cuisineType", "tags"},
+		Labels:           []string{"Recipe"},
+		SortByDifficulty: true,
+		Limit:            limit,
+	}
+	if constraints, ok := QueryConstraintsFromContext(ctx); ok {
+		query.Constraints = constraints
+	}
 
+	matches, err := h.store.SearchNodes(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 
-	records := result.([]*neo4j.Record)
-	for _, record := range records {
-		var contentParts []string
+	// 主要食材（前3个）一次查询取回
+	recipeIDs := make([]string, 0, len(matches))
+	for _, match := range matches {
+		recipeIDs = append(recipeIDs, match.Node.NodeID)
+	}
+	ingredientNames := h.batchNeighborNames(ctx, recipeIDs, []string{"REQUIRES"}, 3)
+
+	var results []*RetrievalResult
+	for _, match := range matches {
+		node := match.Node
+		category := node.Properties["category"]
+		cuisineType := node.Properties["cuisineType"]
+		difficulty := node.Properties["difficulty"]
 
-		if name, exists := record.Get("name"); exists && name != nil {
-			contentParts = append(contentParts, fmt.Sprintf("菜品: %v", name))
+		var contentParts []string
+		if node.Name != "" {
+			contentParts = append(contentParts, fmt.Sprintf("菜品: %s", node.Name))
 		}
-		if category, exists := record.Get("category"); exists && category != nil {
+		if category != nil {
 			contentParts = append(contentParts, fmt.Sprintf("分类: %v", category))
 		}
-		if cuisineType, exists := record.Get("cuisine_type"); exists && cuisineType != nil {
+		if cuisineType != nil {
 			contentParts = append(contentParts, fmt.Sprintf("菜系: %v", cuisineType))
 		}
-		if difficulty, exists := record.Get("difficulty"); exists && difficulty != nil {
+		if difficulty != nil {
 			contentParts = append(contentParts, fmt.Sprintf("难度: %v", difficulty))
 		}
 
-		if ingredients, exists := record.Get("ingredients"); exists {
-			if ingredientSlice, ok := ingredients.([]interface{}); ok && len(ingredientSlice) > 0 {
-				var ingredientNames []string
-				for _, ing := range ingredientSlice {
-					if ingStr, ok := ing.(string); ok {
-						ingredientNames = append(ingredientNames, ingStr)
-					}
-				}
-				if len(ingredientNames) > 0 {
-					contentParts = append(contentParts, fmt.Sprintf("主要食材: %s", strings.Join(ingredientNames, ", ")))
-				}
-			}
+		if names := ingredientNames[node.NodeID]; len(names) > 0 {
+			contentParts = append(contentParts, fmt.Sprintf("主要食材: %s", strings.Join(names, ", ")))
 		}
 
-		nodeID, _ := record.Get("node_id")
-		name, _ := record.Get("name")
-		category, _ := record.Get("category")
-		cuisineType, _ := record.Get("cuisine_type")
-		difficulty, _ := record.Get("difficulty")
-		matchedKeyword, _ := record.Get("matched_keyword")
-
 		results = append(results, &RetrievalResult{
 			Content:        strings.Join(contentParts, "\n"),
-			NodeID:         nodeID.(string),
+			NodeID:         node.NodeID,
 			NodeType:       "Recipe",
 			RelevanceScore: 0.75, // 补充检索得分
 			RetrievalLevel: "topic",
 			Metadata: map[string]interface{}{
-				"name":            name,
+				"name":            node.Name,
 				"category":        category,
 				"cuisine_type":    cuisineType,
 				"difficulty":      difficulty,
-				"matched_keyword": matchedKeyword,
-				"source":          "neo4j_fallback",
+				"matched_keyword": match.Keyword,
+				"source":          "graph_fallback",
 			},
 		})
 	}
@@ -751,35 +847,49 @@ func (h *HybridRetrievalModule) DualLevelRetrieval(ctx context.Context, query st
 }
 
 // VectorSearchEnhanced 增强的向量检索：结合图信息
+//
+// 优先使用Milvus相似度搜索，查询约束转换为Milvus标量过滤条件；
+// Milvus不可用时降级为基于图索引缓存的文本匹配。
 func (h *HybridRetrievalModule) VectorSearchEnhanced(ctx context.Context, query string, topK int) ([]*schema.Document, error) {
-	// 由于milvusModule是interface{}类型，这里提供一个基础实现框架
-	// 实际使用时需要根据具体的Milvus模块接口进行调整
+	log.Printf("执行增强向量检索: %s", query)
 
-	var documents []*schema.Document
+	constraints, _ := QueryConstraintsFromContext(ctx)
 
-	// 模拟向量检索结果
-	// 在实际实现中，这里应该调用Milvus模块的相似度搜索方法
-	log.Printf("执行增强向量检索: %s", query)
+	if h.milvusModule != nil {
+		results, err := h.milvusModule.SimilaritySearchWithFilter(ctx, query, topK, constraints.ToFilterExpr())
+		if err == nil {
+			return searchResultsToDocuments(results), nil
+		}
+		log.Printf("Milvus向量检索失败，降级到图索引匹配: %v", err)
+	}
+
+	var documents []*schema.Document
 
 	// 从图索引中获取一些相关结果作为模拟
 	var mockResults []*RetrievalResult
-	for nodeID, entity := range h.entityCache {
-		if strings.Contains(strings.ToLower(entity.Content), strings.ToLower(query)) {
+	var entities []*EntityKeyValue
+	if h.index != nil {
+		entities = h.index.EntitiesByType("")
+	}
+	for _, entity := range entities {
+		if strings.Contains(strings.ToLower(entity.ValueContent), strings.ToLower(query)) {
+			nodeID := getStringFromMap(entity.Metadata, "node_id", "")
+
 			// 获取邻居信息增强
 			neighbors, _ := h.getNodeNeighbors(ctx, nodeID, 3)
 
-			content := entity.Content
+			content := entity.ValueContent
 			if len(neighbors) > 0 {
-				content += fmt.Sprintf("\n相关信息: %s", strings.Join(neighbors[:3], ", "))
+				content += fmt.Sprintf("\n相关信息: %s", strings.Join(neighbors, ", "))
 			}
 
 			mockResults = append(mockResults, &RetrievalResult{
 				Content:        content,
 				NodeID:         nodeID,
-				NodeType:       entity.NodeType,
+				NodeType:       entity.EntityType,
 				RelevanceScore: 0.8,
 				RetrievalLevel: "vector",
-				Metadata:       entity.Metadata,
+				Metadata:       entityResultMetadata(entity, query, "graph_index"),
 			})
 
 			if len(mockResults) >= topK {
@@ -812,63 +922,136 @@ func (h *HybridRetrievalModule) VectorSearchEnhanced(ctx context.Context, query
 		documents = append(documents, doc)
 	}
 
-	return documents, nil
+	return filterByConstraints(documents, constraints), nil
 }
 
-// getNodeNeighbors 获取节点的邻居信息
-func (h *HybridRetrievalModule) getNodeNeighbors(ctx context.Context, nodeID string, maxNeighbors int) ([]string, error) {
-	session := h.driver.NewSession(ctx, neo4j.SessionConfig{})
-	defer session.Close(ctx)
-
-	query := `
-		MATCH (n {nodeId: $node_id})-[r]-(neighbor)
-		RETURN neighbor.name as name
-		LIMIT $limit
-	`
-
-	result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
-		result, err := tx.Run(ctx, query, map[string]interface{}{
-			"node_id": nodeID,
-			"limit":   maxNeighbors,
-		})
-		if err != nil {
-			return nil, err
+// searchResultsToDocuments 将Milvus搜索结果转换为Document格式
+func searchResultsToDocuments(results []SearchResult) []*schema.Document {
+	documents := make([]*schema.Document, 0, len(results))
+	for _, result := range results {
+		metadata := make(map[string]interface{})
+		for k, v := range result.Metadata {
+			metadata[k] = v
 		}
-		records, err := result.Collect(ctx)
-		if err != nil {
-			return nil, err
+		metadata["score"] = float64(result.Score)
+		metadata["search_type"] = "vector_enhanced"
+
+		documents = append(documents, &schema.Document{
+			ID:       result.ID,
+			Content:  result.Text,
+			MetaData: metadata,
+		})
+	}
+	return documents
+}
+
+// filterByConstraints 在内存中按查询约束过滤文档
+func filterByConstraints(documents []*schema.Document, constraints *QueryConstraints) []*schema.Document {
+	if constraints.IsEmpty() {
+		return documents
+	}
+	filtered := make([]*schema.Document, 0, len(documents))
+	for _, doc := range documents {
+		if constraints.MatchDocument(doc) {
+			filtered = append(filtered, doc)
 		}
-		return records, nil
-	})
+	}
+	return filtered
+}
 
+// getNodeNeighbors 获取节点的邻居信息
+func (h *HybridRetrievalModule) getNodeNeighbors(ctx context.Context, nodeID string, maxNeighbors int) ([]string, error) {
+	nodes, err := h.store.Neighbors(ctx, nodeID, nil, maxNeighbors)
 	if err != nil {
 		return nil, err
 	}
 
 	var neighbors []string
-	records := result.([]*neo4j.Record)
-	for _, record := range records {
-		if name, exists := record.Get("name"); exists && name != nil {
-			if nameStr, ok := name.(string); ok && nameStr != "" {
-				neighbors = append(neighbors, nameStr)
-			}
+	for _, node := range nodes {
+		if node.Name != "" {
+			neighbors = append(neighbors, node.Name)
 		}
 	}
-
 	return neighbors, nil
 }
 
+// batchNeighborNames 一次查询获取多个节点的邻居名称，查询失败时记录日志并返回空结果
+func (h *HybridRetrievalModule) batchNeighborNames(ctx context.Context, nodeIDs []string, relationTypes []string, limit int) map[string][]string {
+	names := make(map[string][]string, len(nodeIDs))
+	if len(nodeIDs) == 0 {
+		return names
+	}
+
+	neighbors, err := h.store.BatchNeighbors(ctx, nodeIDs, relationTypes, limit)
+	if err != nil {
+		log.Printf("批量获取邻居节点失败: %v", err)
+		return names
+	}
+	for nodeID, nodes := range neighbors {
+		for _, node := range nodes {
+			if node.Name != "" {
+				names[nodeID] = append(names[nodeID], node.Name)
+			}
+		}
+	}
+	return names
+}
+
 // HybridSearch 混合检索：使用Round-robin轮询合并策略
-// 公平轮询合并不同检索结果，不使用权重配置
+// 公平轮询合并双层检索、向量检索和BM25检索三个通道的结果，不使用权重配置，
+// 合并后由重排序器统一打分并截取前topK个结果
 func (h *HybridRetrievalModule) HybridSearch(ctx context.Context, query string, topK int) ([]*schema.Document, error) {
+	mergedDocs := h.HybridCandidates(ctx, query, topK)
+	return h.finishHybridSearch(ctx, query, mergedDocs, topK)
+}
+
+// HybridCandidates 混合检索候选：轮询合并三个通道的结果，不重排序也不截断
+//
+// 供需要与其他来源合并后统一重排序的调用方使用（如路由器的组合检索），避免重复重排序。
+func (h *HybridRetrievalModule) HybridCandidates(ctx context.Context, query string, topK int) []*schema.Document {
 	log.Printf("开始混合检索: %s", query)
 
+	// 查询约束：优先使用上游（如路由器）放入上下文的约束
+	constraints, ok := QueryConstraintsFromContext(ctx)
+	if !ok {
+		constraints = h.ExtractConstraints(query)
+	}
+
+	// 约束过严导致结果过少时逐步放宽
+	minResults := min(topK, minConstrainedResults)
+	mergedDocs := h.mergeChannels(WithQueryConstraints(ctx, constraints), query, topK)
+	for len(mergedDocs) < minResults {
+		relaxed, ok := constraints.Relax()
+		if !ok {
+			break
+		}
+		log.Printf("约束检索结果不足(%d<%d)，放宽约束: %s → %s", len(mergedDocs), minResults, constraints, relaxed)
+		constraints = relaxed
+		mergedDocs = h.mergeChannels(WithQueryConstraints(ctx, constraints), query, topK)
+	}
+
+	if !constraints.IsEmpty() {
+		for _, doc := range mergedDocs {
+			doc.MetaData["query_constraints"] = constraints.String()
+		}
+	}
+	return mergedDocs
+}
+
+// mergeChannels 执行各检索通道并轮询合并结果
+//
+// 上下文中的查询约束会下推到Milvus过滤条件和Cypher查询中；
+// 无法下推的通道（图索引缓存、BM25）在内存中按约束过滤。
+func (h *HybridRetrievalModule) mergeChannels(ctx context.Context, query string, topK int) []*schema.Document {
+	constraints, _ := QueryConstraintsFromContext(ctx)
+
 	// 1. 双层检索（实体+主题检索）
 	dualDocs, err := h.DualLevelRetrieval(ctx, query, topK)
 	if err != nil {
 		log.Printf("双层检索失败: %v", err)
 		dualDocs = []*schema.Document{}
 	}
+	dualDocs = filterByConstraints(dualDocs, constraints)
 
 	// 2. 增强向量检索
 	vectorDocs, err := h.VectorSearchEnhanced(ctx, query, topK)
@@ -877,14 +1060,27 @@ func (h *HybridRetrievalModule) HybridSearch(ctx context.Context, query string,
 		vectorDocs = []*schema.Document{}
 	}
 
-	// 3. Round-robin轮询合并
+	// 3. BM25关键词检索（有约束时多取候选再过滤）
+	bm25K := topK
+	if !constraints.IsEmpty() {
+		bm25K = topK * constraintOverFetch
+	}
+	bm25Docs := filterByConstraints(h.BM25Search(query, bm25K), constraints)
+	if len(bm25Docs) > topK {
+		bm25Docs = bm25Docs[:topK]
+	}
+
+	// 4. Round-robin轮询合并
 	var mergedDocs []*schema.Document
 	seenDocIDs := make(map[string]bool)
 	maxLen := len(dualDocs)
 	if len(vectorDocs) > maxLen {
 		maxLen = len(vectorDocs)
 	}
-	originLen := len(dualDocs) + len(vectorDocs)
+	if len(bm25Docs) > maxLen {
+		maxLen = len(bm25Docs)
+	}
+	originLen := len(dualDocs) + len(vectorDocs) + len(bm25Docs)
 
 	for i := 0; i < maxLen; i++ {
 		// 先添加双层检索结果
@@ -946,27 +1142,58 @@ func (h *HybridRetrievalModule) HybridSearch(ctx context.Context, query string,
 				mergedDocs = append(mergedDocs, doc)
 			}
 		}
+
+		// 最后添加BM25检索结果
+		if i < len(bm25Docs) {
+			doc := bm25Docs[i]
+			docID := doc.ID
+			if nodeID, exists := doc.MetaData["node_id"]; exists {
+				docID = fmt.Sprintf("%v", nodeID)
+			}
+
+			if !seenDocIDs[docID] {
+				seenDocIDs[docID] = true
+				doc.MetaData["search_method"] = "bm25"
+				doc.MetaData["round_robin_order"] = len(mergedDocs)
+				// BM25原始得分无上界，使用按最高分归一化后的得分作为final_score
+				if score, ok := doc.MetaData["normalized_score"].(float64); ok {
+					doc.MetaData["final_score"] = score
+				} else {
+					doc.MetaData["final_score"] = 0.0
+				}
+				mergedDocs = append(mergedDocs, doc)
+			}
+		}
 	}
 
-	// 取前topK个结果
+	log.Printf("Round-robin合并：从总共%d个结果合并为%d个文档", originLen, len(mergedDocs))
+	return mergedDocs
+}
+
+// finishHybridSearch 对合并结果重排序并截取前topK个
+func (h *HybridRetrievalModule) finishHybridSearch(ctx context.Context, query string, mergedDocs []*schema.Document, topK int) ([]*schema.Document, error) {
+	// 重排序：统一各通道得分口径后取前topK个结果
 	finalDocs := mergedDocs
+	if h.reranker != nil {
+		rerankedDocs, err := h.reranker.Rerank(ctx, query, mergedDocs, topK)
+		if err != nil {
+			log.Printf("重排序失败，保留轮询顺序: %v", err)
+		} else {
+			finalDocs = rerankedDocs
+		}
+	}
 	if len(finalDocs) > topK {
 		finalDocs = finalDocs[:topK]
 	}
 
-	log.Printf("Round-robin合并：从总共%d个结果合并为%d个文档", originLen, len(finalDocs))
 	log.Printf("混合检索完成，返回 %d 个文档", len(finalDocs))
 	return finalDocs, nil
 }
 
 // Close 关闭资源连接
 func (h *HybridRetrievalModule) Close(ctx context.Context) error {
-	if h.driver != nil {
-		err := h.driver.Close(ctx)
-		if err != nil {
-			return fmt.Errorf("关闭Neo4j连接失败: %w", err)
-		}
-		log.Println("Neo4j连接已关闭")
+	if h.store != nil && h.ownsStore {
+		return h.store.Close(ctx)
 	}
 	return nil
 }
@@ -0,0 +1,91 @@
+package batch_0001
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestGraphTopicLevelSearchOnMemoryStore(t *testing.T) {
+	h := NewHybridRetrievalModule(&Config{}, nil, nil, nil)
+	h.SetGraphStore(testGraphStore())
+
+	results, err := h.graphTopicLevelSearch(context.Background(), []string{"荤菜"}, 5)
+	if err != nil {
+		t.Fatalf("graphTopicLevelSearch: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("应返回2道荤菜，得到 %d", len(results))
+	}
+	// 按难度升序，麻婆豆腐在前
+	if results[0].NodeID != "202000001" || results[1].NodeID != "201000001" {
+		t.Errorf("结果顺序错误: %s, %s", results[0].NodeID, results[1].NodeID)
+	}
+	if !strings.Contains(results[0].Content, "主要食材: 豆腐") {
+		t.Errorf("麻婆豆腐缺少主要食材: %s", results[0].Content)
+	}
+	if !strings.Contains(results[1].Content, "主要食材: 五花肉, 冰糖, 生抽") || strings.Contains(results[1].Content, "八角") {
+		t.Errorf("红烧肉的主要食材应只取前3个: %s", results[1].Content)
+	}
+
+	ctx := WithQueryConstraints(context.Background(), &QueryConstraints{CuisineTypes: []string{"湘菜"}})
+	constrained, err := h.graphTopicLevelSearch(ctx, []string{"荤菜"}, 5)
+	if err != nil {
+		t.Fatalf("graphTopicLevelSearch: %v", err)
+	}
+	if len(constrained) != 1 || constrained[0].NodeID != "201000001" {
+		t.Errorf("湘菜约束下应只返回红烧肉，得到 %d 个结果", len(constrained))
+	}
+}
+
+func TestEntityLevelRetrievalOnMemoryStore(t *testing.T) {
+	h := NewHybridRetrievalModule(&Config{}, nil, nil, nil)
+	h.SetGraphStore(testGraphStore())
+
+	results, err := h.EntityLevelRetrieval(context.Background(), []string{"豆腐"}, 5)
+	if err != nil {
+		t.Fatalf("EntityLevelRetrieval: %v", err)
+	}
+	var names []string
+	for _, result := range results {
+		names = append(names, getStringFromMap(result.Metadata, "name", ""))
+	}
+	if got := strings.Join(names, ","); got != "豆腐,麻婆豆腐" {
+		t.Errorf("应按名称检索到豆腐和麻婆豆腐，得到 %s", got)
+	}
+}
+
+func TestGraphRAGRetrievalOnMemoryStore(t *testing.T) {
+	g := NewGraphRAGRetrieval(&Config{})
+	g.SetGraphStore(testGraphStore())
+	ctx := context.Background()
+
+	subgraph, err := g.ExtractKnowledgeSubgraph(ctx, &GraphQuery{
+		QueryType:      Subgraph,
+		SourceEntities: []string{"红烧肉"},
+		MaxDepth:       1,
+	})
+	if err != nil {
+		t.Fatalf("ExtractKnowledgeSubgraph: %v", err)
+	}
+	if len(subgraph.ConnectedNodes) != 7 {
+		t.Errorf("MaxNodes未设置时应返回完整子图，得到 %d 个节点", len(subgraph.ConnectedNodes))
+	}
+
+	paths, err := g.MultiHopTraversal(ctx, &GraphQuery{
+		QueryType:      MultiHop,
+		SourceEntities: []string{"麻婆豆腐"},
+		TargetEntities: []string{"Ingredient"},
+		MaxDepth:       2,
+	})
+	if err != nil {
+		t.Fatalf("MultiHopTraversal: %v", err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("应找到从麻婆豆腐到食材的路径")
+	}
+	last := paths[0].Nodes[len(paths[0].Nodes)-1]
+	if last["name"] != "豆腐" {
+		t.Errorf("最相关的路径应以豆腐结尾，得到 %v", last["name"])
+	}
+}
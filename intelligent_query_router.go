@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 
 	"github.com/cloudwego/eino/schema"
 )
@@ -30,6 +31,8 @@ type QueryAnalysis struct {
 	RecommendedStrategy   SearchStrategy `json:"recommended_strategy"`   // 推荐的检索策略
 	Confidence            float64        `json:"confidence"`             // 推荐置信度 (0-1)，表示对推荐策略的信心程度
 	Reasoning             string         `json:"reasoning"`              // 推荐理由，解释为什么选择该策略的原因
+
+	Constraints *QueryConstraints `json:"constraints,omitempty"` // 从查询中抽取的结构化约束
 }
 
 // RouteStatistics 路由统计信息
@@ -74,7 +77,11 @@ type IntelligentQueryRouter struct {
 	graphRAGRetrieval    *GraphRAGRetrieval     // 图RAG检索模块
 	llmClient            interface{}            // 大语言模型客户端
 	config               *Config                // 系统配置
+	reranker             Reranker               // 组合检索结果重排序器，为nil时不重排序
+	diversity            *DiversityConfig       // 结果多样性配置
+	parentResolver       ParentDocumentResolver // 父子分块时将子块展开为完整菜谱，为nil时不展开
 
+	statsMu    sync.Mutex       // 保护routeStats，HTTP服务下查询并发执行
 	routeStats *RouteStatistics // 路由统计信息
 }
 
@@ -92,6 +99,8 @@ func NewIntelligentQueryRouter(traditionalRetrieval *HybridRetrievalModule, grap
 		graphRAGRetrieval:    graphRAGRetrieval,
 		llmClient:            llmClient,
 		config:               config,
+		reranker:             NewLexicalReranker(nil),
+		diversity:            DefaultDiversityConfig(),
 		routeStats: &RouteStatistics{
 			TraditionalCount: 0,
 			GraphRAGCount:    0,
@@ -101,6 +110,21 @@ func NewIntelligentQueryRouter(traditionalRetrieval *HybridRetrievalModule, grap
 	}
 }
 
+// SetReranker 设置组合检索结果重排序器，传入nil时关闭重排序
+func (r *IntelligentQueryRouter) SetReranker(reranker Reranker) {
+	r.reranker = reranker
+}
+
+// SetDiversity 设置结果多样性配置，传入nil时关闭多样性处理
+func (r *IntelligentQueryRouter) SetDiversity(config *DiversityConfig) {
+	r.diversity = config
+}
+
+// SetParentResolver 设置父文档获取函数，使用父子分块时检索到的子块会展开为完整菜谱
+func (r *IntelligentQueryRouter) SetParentResolver(resolve ParentDocumentResolver) {
+	r.parentResolver = resolve
+}
+
 // AnalyzeQuery 分析查询特征
 //
 // 使用LLM深度分析查询的各种特征，为路由决策提供数据支持。
@@ -219,38 +243,56 @@ func (r *IntelligentQueryRouter) RouteQuery(ctx context.Context, query string, t
 	// 更新路由统计
 	r.updateRouteStats(analysis.RecommendedStrategy)
 
+	// 抽取查询约束并放入上下文，各检索通道共用同一份约束
+	if analysis.Constraints == nil {
+		analysis.Constraints = r.traditionalRetrieval.ExtractConstraints(query)
+	}
+	ctx = WithQueryConstraints(ctx, analysis.Constraints)
+
 	var documents []*schema.Document
 
+	// 多取候选，为多样性筛选留出空间
+	fetchK := r.diversity.FetchK(topK)
+
 	// 根据推荐策略执行检索
 	switch analysis.RecommendedStrategy {
 	case HybridTraditional:
 		log.Println("使用传统混合检索")
 		// documents, err = r.executeTraditionalRetrieval(ctx, query, topK)
-		documents, err = r.traditionalRetrieval.HybridSearch(ctx, query, topK)
+		documents, err = r.traditionalRetrieval.HybridSearch(ctx, query, fetchK)
 
 	case GraphRAG:
 		log.Println("🕸️ 使用图RAG检索")
 		// documents, err = r.executeGraphRAGRetrieval(ctx, query, topK)
-		documents, err = r.graphRAGRetrieval.GraphRAGSearch(ctx, query, topK)
+		documents, err = r.graphRAGRetrieval.GraphRAGSearch(ctx, query, fetchK)
 
 	case Combined:
 		log.Println("🔄 使用组合检索策略")
-		documents, err = r.executeCombinedSearch(ctx, query, topK)
+		documents, err = r.executeCombinedSearch(ctx, query, fetchK)
 
 	default:
 		log.Printf("未知策略: %s，使用传统检索", analysis.RecommendedStrategy)
 		// documents, err = r.executeTraditionalRetrieval(ctx, query, topK)
-		documents, err = r.traditionalRetrieval.HybridSearch(ctx, query, topK)
+		documents, err = r.traditionalRetrieval.HybridSearch(ctx, query, fetchK)
 	}
 
 	if err != nil {
 		log.Printf("查询路由失败: %v", err)
 		// 降级到传统检索
-		documents, _ = r.traditionalRetrieval.HybridSearch(ctx, query, topK)
+		documents, _ = r.traditionalRetrieval.HybridSearch(ctx, query, fetchK)
+	}
+
+	// 图RAG等通道无法下推约束，统一在内存中按约束过滤，结果不足时放宽
+	if !analysis.Constraints.IsEmpty() {
+		var applied *QueryConstraints
+		documents, applied = FilterDocuments(documents, analysis.Constraints, min(topK, minConstrainedResults))
+		if applied != analysis.Constraints {
+			log.Printf("约束过滤结果不足，已放宽约束: %s", applied)
+		}
 	}
 
 	// 后处理结果
-	documents = r.postProcessResults(documents, analysis)
+	documents = r.postProcessResults(documents, analysis, topK)
 
 	log.Printf("路由完成，返回 %d 个结果", len(documents))
 	return documents, analysis, nil
@@ -267,15 +309,20 @@ func (r *IntelligentQueryRouter) executeCombinedSearch(ctx context.Context, quer
 	}
 	graphK := topK - traditionalK
 
-	// 并行执行两种检索
-	// traditionalDocs, err1 := r.executeTraditionalRetrieval(ctx, query, traditionalK)
-	traditionalDocs, err1 := r.traditionalRetrieval.HybridSearch(ctx, query, traditionalK)
-	if err1 != nil {
-		log.Printf("传统检索失败: %v", err1)
-		traditionalDocs = []*schema.Document{}
+	// 有重排序器时两个通道取相同数量的候选（传统检索取未重排序、未截断的候选），合并后只重排序一次
+	var traditionalDocs []*schema.Document
+	if r.reranker != nil {
+		graphK = topK
+		traditionalDocs = r.traditionalRetrieval.HybridCandidates(ctx, query, topK)
+	} else {
+		var err1 error
+		traditionalDocs, err1 = r.traditionalRetrieval.HybridSearch(ctx, query, traditionalK)
+		if err1 != nil {
+			log.Printf("传统检索失败: %v", err1)
+			traditionalDocs = []*schema.Document{}
+		}
 	}
 
-	// graphDocs, err2 := r.executeGraphRAGRetrieval(ctx, query, graphK)
 	graphDocs, err2 := r.graphRAGRetrieval.GraphRAGSearch(ctx, query, graphK)
 	if err2 != nil {
 		log.Printf("图RAG检索失败: %v", err2)
@@ -322,6 +369,16 @@ func (r *IntelligentQueryRouter) executeCombinedSearch(ctx context.Context, quer
 		}
 	}
 
+	// 重排序：图RAG和传统检索的得分口径不同，需要统一打分
+	if r.reranker != nil {
+		rerankedDocs, err := r.reranker.Rerank(ctx, query, combinedDocs, topK)
+		if err != nil {
+			log.Printf("重排序失败，保留轮询顺序: %v", err)
+		} else {
+			combinedDocs = rerankedDocs
+		}
+	}
+
 	// 限制结果数量
 	if len(combinedDocs) > topK {
 		combinedDocs = combinedDocs[:topK]
@@ -331,7 +388,12 @@ func (r *IntelligentQueryRouter) executeCombinedSearch(ctx context.Context, quer
 }
 
 // postProcessResults 后处理结果
-func (r *IntelligentQueryRouter) postProcessResults(documents []*schema.Document, analysis *QueryAnalysis) []*schema.Document {
+//
+// 先做多样性筛选（避免结果集中在同一菜谱），再将父子分块的子块展开为完整菜谱，最后补充路由信息。
+func (r *IntelligentQueryRouter) postProcessResults(documents []*schema.Document, analysis *QueryAnalysis, topK int) []*schema.Document {
+	documents = DiversifyResults(documents, r.diversity, topK)
+	documents = ExpandParentDocuments(documents, r.parentResolver)
+
 	for _, doc := range documents {
 		if doc.MetaData == nil {
 			doc.MetaData = make(map[string]interface{})
@@ -348,6 +410,9 @@ func (r *IntelligentQueryRouter) postProcessResults(documents []*schema.Document
 
 // updateRouteStats 更新路由统计信息
 func (r *IntelligentQueryRouter) updateRouteStats(strategy SearchStrategy) {
+	r.statsMu.Lock()
+	defer r.statsMu.Unlock()
+
 	r.routeStats.TotalQueries++
 
 	switch strategy {
@@ -370,6 +435,9 @@ func (r *IntelligentQueryRouter) updateRouteStats(strategy SearchStrategy) {
 
 // GetRouteStatistics 获取路由统计信息
 func (r *IntelligentQueryRouter) GetRouteStatistics() *RouteStatistics {
+	r.statsMu.Lock()
+	defer r.statsMu.Unlock()
+
 	return &RouteStatistics{
 		TraditionalCount: r.routeStats.TraditionalCount,
 		GraphRAGCount:    r.routeStats.GraphRAGCount,
@@ -435,6 +503,8 @@ func (r *IntelligentQueryRouter) ExplainRoutingDecision(ctx context.Context, que
 推荐策略：%s
 置信度：%.2f
 
+查询约束：%s
+
 决策理由：%s`,
 		query,
 		analysis.QueryComplexity, complexityDesc,
@@ -443,6 +513,7 @@ func (r *IntelligentQueryRouter) ExplainRoutingDecision(ctx context.Context, que
 		analysis.EntityCount,
 		analysis.RecommendedStrategy,
 		analysis.Confidence,
+		r.traditionalRetrieval.ExtractConstraints(query),
 		analysis.Reasoning)
 
 	return explanation
@@ -0,0 +1,548 @@
+package batch_0001
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// kbSyncStateVersion 同步状态文件格式版本，格式变化时递增以强制全量同步
+const kbSyncStateVersion = 1
+
+// syncedNodeLabels 参与变化检测的节点类型
+var syncedNodeLabels = []string{"Recipe", "Ingredient", "CookingStep"}
+
+// GraphFingerprints 图数据指纹
+//
+// 菜谱指纹覆盖菜谱自身属性、所属分类、关联的食材和步骤节点属性以及关系属性，
+// 任何会影响菜谱文档内容的变化都会改变菜谱指纹。节点属性中若维护了updatedAt，
+// 也会随属性一起参与指纹计算。
+type GraphFingerprints struct {
+	Recipes map[string]string            `json:"recipes"` // 菜谱ID -> 菜谱文档指纹
+	Nodes   map[string]map[string]string `json:"nodes"`   // 节点类型 -> 节点ID -> 节点属性指纹
+}
+
+// KnowledgeBaseState 知识库同步状态，记录上次同步时的图数据指纹
+type KnowledgeBaseState struct {
+	Version      int                `json:"version"`
+	SyncedAt     time.Time          `json:"synced_at"`
+	ChunkSize    int                `json:"chunk_size"`
+	ChunkOverlap int                `json:"chunk_overlap"`
+	ChunkUnit    ChunkUnit          `json:"chunk_unit"`
+	ChunkerName  string             `json:"chunker"`
+	Fingerprints *GraphFingerprints `json:"fingerprints"`
+}
+
+// NodeChangeCount 某类节点的变化数量
+type NodeChangeCount struct {
+	Added   int `json:"added"`
+	Changed int `json:"changed"`
+	Deleted int `json:"deleted"`
+}
+
+// KnowledgeBaseSyncReport 知识库同步报告
+type KnowledgeBaseSyncReport struct {
+	Full           bool                        `json:"full"`            // 是否重新生成了全部文档（无同步状态、分块参数变化或集合需重建）
+	NodeChanges    map[string]*NodeChangeCount `json:"node_changes"`    // 按节点类型统计的变化
+	UpdatedRecipes []string                    `json:"updated_recipes"` // 新增或变化、已重新生成文档的菜谱ID
+	DeletedRecipes []string                    `json:"deleted_recipes"` // 已删除的菜谱ID
+	FailedRecipes  []string                    `json:"failed_recipes"`  // 文档生成失败、下次同步重试的菜谱ID
+	Vector         *VectorSyncReport           `json:"vector"`          // 向量索引同步结果
+	Duration       time.Duration               `json:"duration"`
+
+	// Chunks 重新生成的文档块；全量同步时为全部文档块
+	Chunks []*schema.Document `json:"-"`
+}
+
+// ReplacedNodeIDs 文档块被替换或删除的菜谱ID（重新生成的 + 已删除的）
+func (r *KnowledgeBaseSyncReport) ReplacedNodeIDs() []string {
+	ids := make([]string, 0, len(r.UpdatedRecipes)+len(r.DeletedRecipes))
+	ids = append(ids, r.UpdatedRecipes...)
+	return append(ids, r.DeletedRecipes...)
+}
+
+// HasChanges 本次同步是否改动了文档
+func (r *KnowledgeBaseSyncReport) HasChanges() bool {
+	return r.Full || len(r.UpdatedRecipes) > 0 || len(r.DeletedRecipes) > 0
+}
+
+// String 同步报告的可读描述
+func (r *KnowledgeBaseSyncReport) String() string {
+	var parts []string
+	if r.Full {
+		parts = append(parts, "全量同步")
+	}
+	for _, label := range syncedNodeLabels {
+		if count, ok := r.NodeChanges[label]; ok {
+			parts = append(parts, fmt.Sprintf("%s(新增%d/变化%d/删除%d)", label, count.Added, count.Changed, count.Deleted))
+		}
+	}
+	parts = append(parts, fmt.Sprintf("重新生成菜谱文档 %d 个，删除菜谱 %d 个", len(r.UpdatedRecipes), len(r.DeletedRecipes)))
+	if len(r.FailedRecipes) > 0 {
+		parts = append(parts, fmt.Sprintf("生成失败 %d 个", len(r.FailedRecipes)))
+	}
+	if r.Vector != nil {
+		parts = append(parts, "向量索引: "+r.Vector.String())
+	}
+	parts = append(parts, fmt.Sprintf("耗时 %v", r.Duration.Round(time.Millisecond)))
+	return strings.Join(parts, "，")
+}
+
+// KnowledgeBaseSyncer 知识库增量同步器
+//
+// 通过比对图数据指纹与上次同步时保存的状态，找出新增、变化、删除的菜谱（以及影响菜谱的
+// 食材、步骤节点），只重新生成受影响菜谱的文档和文档块，并增量更新Milvus向量索引：
+// - 无同步状态或分块参数变化：重新生成全部文档，Milvus按内容指纹比对，只写入变化的文档块
+// - 集合不存在或模式不兼容：完整重建向量索引
+// - 其他情况：只处理受影响的菜谱
+type KnowledgeBaseSyncer struct {
+	dataModule   *GraphDataPreparationModule
+	indexModule  *MilvusIndexConstructionModule
+	statePath    string
+	chunkSize    int
+	chunkOverlap int
+}
+
+// NewKnowledgeBaseSyncer 创建知识库同步器
+//
+// Args:
+//   - dataModule: 图数据准备模块
+//   - indexModule: Milvus向量索引模块
+//   - statePath: 同步状态文件路径
+//   - chunkSize, chunkOverlap: 文档分块参数，需与构建知识库时一致（计量单位和分块策略取数据模块的配置）
+func NewKnowledgeBaseSyncer(dataModule *GraphDataPreparationModule, indexModule *MilvusIndexConstructionModule, statePath string, chunkSize, chunkOverlap int) *KnowledgeBaseSyncer {
+	return &KnowledgeBaseSyncer{
+		dataModule:   dataModule,
+		indexModule:  indexModule,
+		statePath:    statePath,
+		chunkSize:    chunkSize,
+		chunkOverlap: chunkOverlap,
+	}
+}
+
+// Sync 增量同步知识库
+//
+// 同步成功后保存新的同步状态；文档生成失败的菜谱不记录新指纹，下次同步时重试。
+func (s *KnowledgeBaseSyncer) Sync(ctx context.Context) (*KnowledgeBaseSyncReport, error) {
+	start := time.Now()
+	log.Println("开始增量同步知识库...")
+
+	if _, err := s.dataModule.LoadGraphData(); err != nil {
+		return nil, fmt.Errorf("加载图数据失败: %w", err)
+	}
+	current, err := s.dataModule.GraphFingerprints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := s.loadState()
+	if err != nil {
+		log.Printf("同步状态不可用，执行全量同步: %v", err)
+		state = nil
+	}
+
+	if err := s.indexModule.setupClient(ctx); err != nil {
+		return nil, err
+	}
+	compatible, err := s.indexModule.collectionSchemaCompatible(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous *GraphFingerprints
+	if state != nil {
+		previous = state.Fingerprints
+	}
+	report := &KnowledgeBaseSyncReport{NodeChanges: diffNodeFingerprints(previous, current)}
+
+	switch {
+	case !compatible:
+		log.Println("集合不存在或模式不兼容，完整重建向量索引")
+		if err := s.syncAll(ctx, current, report, true); err != nil {
+			return nil, err
+		}
+	case state == nil || !s.sameChunking(state):
+		if err := s.syncAll(ctx, current, report, false); err != nil {
+			return nil, err
+		}
+	default:
+		if err := s.syncChanged(ctx, previous, current, report); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.saveState(current); err != nil {
+		return nil, err
+	}
+
+	report.Duration = time.Since(start)
+	log.Printf("知识库同步完成: %s", report)
+	return report, nil
+}
+
+// SaveBaseline 记录当前图数据指纹作为同步基线
+//
+// 在完整构建知识库后调用，之后的同步只处理此后发生的变化。
+func (s *KnowledgeBaseSyncer) SaveBaseline(ctx context.Context) error {
+	current, err := s.dataModule.GraphFingerprints(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 构建失败、没有文档的菜谱不记录指纹，下次同步时重试
+	built := make(map[string]bool, len(s.dataModule.Documents))
+	for _, doc := range s.dataModule.Documents {
+		built[doc.ID] = true
+	}
+	for id := range current.Recipes {
+		if !built[id] {
+			delete(current.Recipes, id)
+		}
+	}
+	return s.saveState(current)
+}
+
+// syncAll 重新生成全部文档并同步向量索引
+func (s *KnowledgeBaseSyncer) syncAll(ctx context.Context, current *GraphFingerprints, report *KnowledgeBaseSyncReport, rebuild bool) error {
+	report.Full = true
+
+	// 部分菜谱生成失败时继续同步其余菜谱，失败的菜谱记入报告、下次同步重试
+	documents, err := s.dataModule.BuildRecipeDocuments()
+	var buildErr *RecipeDocumentsError
+	if err != nil && !errors.As(err, &buildErr) {
+		return fmt.Errorf("构建菜谱文档失败: %w", err)
+	}
+	chunks, err := s.dataModule.ChunkDocuments(s.chunkSize, s.chunkOverlap)
+	if err != nil {
+		return fmt.Errorf("文档分块失败: %w", err)
+	}
+
+	built := make(map[string]bool, len(documents))
+	for _, doc := range documents {
+		built[doc.ID] = true
+		report.UpdatedRecipes = append(report.UpdatedRecipes, doc.ID)
+	}
+	for id := range current.Recipes {
+		if !built[id] {
+			report.FailedRecipes = append(report.FailedRecipes, id)
+			delete(current.Recipes, id)
+		}
+	}
+	sort.Strings(report.FailedRecipes)
+	report.Chunks = chunks
+
+	if rebuild {
+		if err := s.indexModule.BuildVectorIndex(ctx, chunks); err != nil {
+			return fmt.Errorf("构建向量索引失败: %w", err)
+		}
+		report.Vector = &VectorSyncReport{Added: len(chunks)}
+		return nil
+	}
+
+	if err := s.indexModule.LoadCollection(ctx); err != nil {
+		return err
+	}
+	report.Vector, err = s.indexModule.SyncDocuments(ctx, chunks)
+	return err
+}
+
+// syncChanged 只重新生成受影响菜谱的文档并同步对应的文档块
+func (s *KnowledgeBaseSyncer) syncChanged(ctx context.Context, previous, current *GraphFingerprints, report *KnowledgeBaseSyncReport) error {
+	updated, deleted := diffRecipeFingerprints(previous.Recipes, current.Recipes)
+	if len(updated) == 0 && len(deleted) == 0 {
+		log.Println("菜谱文档没有变化，无需同步")
+		return nil
+	}
+
+	var documents []*schema.Document
+	if len(updated) > 0 {
+		var err error
+		documents, err = s.dataModule.BuildRecipeDocuments(updated...)
+		var buildErr *RecipeDocumentsError
+		if err != nil && !errors.As(err, &buildErr) {
+			return fmt.Errorf("重新生成菜谱文档失败: %w", err)
+		}
+	} else {
+		// 只有删除时不需要查询图数据库，直接从文档列表中移除
+		s.dataModule.mergeRecipeDocuments(nil)
+	}
+
+	// 生成失败的菜谱保留旧文档块和旧指纹，下次同步时重试
+	built := make(map[string]bool, len(documents))
+	var chunks []*schema.Document
+	chunker := NewChunker(s.dataModule.ChunkStrategy, s.splitter())
+	for _, doc := range documents {
+		built[doc.ID] = true
+		chunks = append(chunks, chunker.Chunk(doc)...)
+	}
+	for _, id := range updated {
+		if built[id] {
+			report.UpdatedRecipes = append(report.UpdatedRecipes, id)
+			continue
+		}
+		report.FailedRecipes = append(report.FailedRecipes, id)
+		if oldHash, ok := previous.Recipes[id]; ok {
+			current.Recipes[id] = oldHash
+		} else {
+			delete(current.Recipes, id)
+		}
+	}
+	report.DeletedRecipes = deleted
+	report.Chunks = chunks
+
+	replaced := report.ReplacedNodeIDs()
+	s.dataModule.ReplaceChunks(replaced, chunks)
+
+	if err := s.indexModule.LoadCollection(ctx); err != nil {
+		return err
+	}
+	vector, err := s.indexModule.SyncNodeDocuments(ctx, replaced, chunks)
+	if err != nil {
+		return err
+	}
+	report.Vector = vector
+	return nil
+}
+
+// splitter 按当前分块参数创建文本切分器
+func (s *KnowledgeBaseSyncer) splitter() *TextSplitter {
+	return NewTextSplitter(s.chunkSize, s.chunkOverlap, s.dataModule.ChunkUnit)
+}
+
+// sameChunking 分块参数是否与上次同步时一致，不一致时所有文档块都需要重新生成
+func (s *KnowledgeBaseSyncer) sameChunking(state *KnowledgeBaseState) bool {
+	splitter := s.splitter()
+	return state.ChunkSize == splitter.ChunkSize &&
+		state.ChunkOverlap == splitter.ChunkOverlap &&
+		state.ChunkUnit == splitter.Unit &&
+		state.ChunkerName == NewChunker(s.dataModule.ChunkStrategy, splitter).Name()
+}
+
+// loadState 读取同步状态，文件不存在时返回nil
+func (s *KnowledgeBaseSyncer) loadState() (*KnowledgeBaseState, error) {
+	if s.statePath == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(s.statePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取同步状态失败: %w", err)
+	}
+
+	var state KnowledgeBaseState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("解析同步状态失败: %w", err)
+	}
+	if state.Version != kbSyncStateVersion || state.Fingerprints == nil {
+		return nil, fmt.Errorf("同步状态版本不匹配: %d != %d", state.Version, kbSyncStateVersion)
+	}
+	return &state, nil
+}
+
+// saveState 保存同步状态
+func (s *KnowledgeBaseSyncer) saveState(fingerprints *GraphFingerprints) error {
+	if s.statePath == "" {
+		return nil
+	}
+	if dir := filepath.Dir(s.statePath); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("创建同步状态目录失败: %w", err)
+		}
+	}
+
+	splitter := s.splitter()
+	data, err := json.MarshalIndent(&KnowledgeBaseState{
+		Version:      kbSyncStateVersion,
+		SyncedAt:     time.Now(),
+		ChunkSize:    splitter.ChunkSize,
+		ChunkOverlap: splitter.ChunkOverlap,
+		ChunkUnit:    splitter.Unit,
+		ChunkerName:  NewChunker(s.dataModule.ChunkStrategy, splitter).Name(),
+		Fingerprints: fingerprints,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化同步状态失败: %w", err)
+	}
+
+	// 先写临时文件再重命名，避免中断时留下不完整的状态
+	tmpPath := s.statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("写入同步状态失败: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.statePath); err != nil {
+		return fmt.Errorf("保存同步状态失败: %w", err)
+	}
+
+	log.Printf("同步状态已保存: %s", s.statePath)
+	return nil
+}
+
+// GraphFingerprints 计算当前图数据的指纹
+//
+// 只统计与菜谱关联的食材和步骤节点：未被任何菜谱引用的节点不影响菜谱文档。
+func (g *GraphDataPreparationModule) GraphFingerprints(ctx context.Context) (*GraphFingerprints, error) {
+	if g.csvGraph != nil {
+		return g.csvGraph.fingerprints(), nil
+	}
+
+	session := g.Driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: g.Database})
+	defer session.Close(ctx)
+
+	query := `
+		MATCH (r:Recipe)
+		WHERE r.nodeId >= '200000000'
+		OPTIONAL MATCH (r)-[:BELONGS_TO_CATEGORY]->(c:Category)
+		WITH r, collect(DISTINCT c.name) as categories
+		OPTIONAL MATCH (r)-[req:REQUIRES]->(i:Ingredient)
+		WITH r, categories,
+		     collect({nodeId: i.nodeId, node: properties(i), rel: properties(req)}) as ingredients
+		OPTIONAL MATCH (r)-[cs:CONTAINS_STEP]->(s:CookingStep)
+		RETURN r.nodeId as nodeId, properties(r) as properties, categories, ingredients,
+		       collect({nodeId: s.nodeId, node: properties(s), rel: properties(cs)}) as steps
+	`
+
+	result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
+		result, err := tx.Run(ctx, query, nil)
+		if err != nil {
+			return nil, err
+		}
+		return result.Collect(ctx)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("计算图数据指纹失败: %w", err)
+	}
+
+	fingerprints := newGraphFingerprints()
+	for _, record := range result.([]*neo4j.Record) {
+		nodeID, _ := record.Get("nodeId")
+		properties, _ := record.Get("properties")
+		categories, _ := record.Get("categories")
+		ingredients, _ := record.Get("ingredients")
+		steps, _ := record.Get("steps")
+
+		fingerprints.addRecipe(fmt.Sprintf("%v", nodeID), properties, categories, ingredients, steps)
+	}
+
+	log.Printf("图数据指纹计算完成: %d 个菜谱", len(fingerprints.Recipes))
+	return fingerprints, nil
+}
+
+// newGraphFingerprints 创建空的图数据指纹
+func newGraphFingerprints() *GraphFingerprints {
+	fingerprints := &GraphFingerprints{
+		Recipes: make(map[string]string),
+		Nodes:   make(map[string]map[string]string),
+	}
+	for _, label := range syncedNodeLabels {
+		fingerprints.Nodes[label] = make(map[string]string)
+	}
+	return fingerprints
+}
+
+// addRecipe 记录一个菜谱及其关联食材、步骤的指纹
+//
+// ingredients和steps为{nodeId, node, rel}条目列表，node和rel分别是节点和关系的属性。
+func (f *GraphFingerprints) addRecipe(recipeID string, properties, categories, ingredients, steps interface{}) {
+	recipeHash := fingerprintOf(properties)
+	f.Nodes["Recipe"][recipeID] = recipeHash
+
+	ingredientParts := collectLinkedNodes(ingredients, f.Nodes["Ingredient"])
+	stepParts := collectLinkedNodes(steps, f.Nodes["CookingStep"])
+
+	f.Recipes[recipeID] = fingerprintOf([]interface{}{
+		recipeHash, categories, ingredientParts, stepParts,
+	})
+}
+
+// collectLinkedNodes 记录关联节点的属性指纹，返回排序后的"节点指纹+关系指纹"列表
+func collectLinkedNodes(value interface{}, nodes map[string]string) []string {
+	items, _ := value.([]interface{})
+	parts := make([]string, 0, len(items))
+	for _, item := range items {
+		entry, ok := item.(map[string]interface{})
+		if !ok || entry["nodeId"] == nil {
+			// OPTIONAL MATCH没有匹配时会产生空条目
+			continue
+		}
+		nodeID := fmt.Sprintf("%v", entry["nodeId"])
+		nodeHash := fingerprintOf(entry["node"])
+		nodes[nodeID] = nodeHash
+		parts = append(parts, nodeID+":"+nodeHash+":"+fingerprintOf(entry["rel"]))
+	}
+	sort.Strings(parts)
+	return parts
+}
+
+// fingerprintOf 计算任意属性值的指纹
+//
+// JSON序列化时映射按键排序，保证同样的属性得到同样的指纹。
+func fingerprintOf(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		data = []byte(fmt.Sprintf("%v", value))
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// diffRecipeFingerprints 比对菜谱指纹，返回新增或变化的菜谱ID和已删除的菜谱ID
+func diffRecipeFingerprints(previous, current map[string]string) (updated, deleted []string) {
+	for id, hash := range current {
+		if previous[id] != hash {
+			updated = append(updated, id)
+		}
+	}
+	for id := range previous {
+		if _, ok := current[id]; !ok {
+			deleted = append(deleted, id)
+		}
+	}
+	sort.Strings(updated)
+	sort.Strings(deleted)
+	return updated, deleted
+}
+
+// diffNodeFingerprints 按节点类型统计新增、变化、删除的节点数量
+func diffNodeFingerprints(previous, current *GraphFingerprints) map[string]*NodeChangeCount {
+	changes := make(map[string]*NodeChangeCount)
+	for _, label := range syncedNodeLabels {
+		var before map[string]string
+		if previous != nil {
+			before = previous.Nodes[label]
+		}
+		after := current.Nodes[label]
+
+		count := &NodeChangeCount{}
+		for id, hash := range after {
+			oldHash, ok := before[id]
+			switch {
+			case !ok:
+				count.Added++
+			case oldHash != hash:
+				count.Changed++
+			}
+		}
+		for id := range before {
+			if _, ok := after[id]; !ok {
+				count.Deleted++
+			}
+		}
+		changes[label] = count
+	}
+	return changes
+}
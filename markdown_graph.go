@@ -0,0 +1,481 @@
+package batch_0001
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// nodes.csv / relationships.csv 的列，与现有数据文件一致
+var (
+	nodeCSVColumns = []string{
+		"nodeId", "labels", "name", "preferredTerm", "fsn", "conceptType", "synonyms", "category",
+		"difficulty", "cuisineType", "prepTime", "cookTime", "servings", "tags", "filePath",
+		"amount", "unit", "isMain", "description", "stepNumber", "methods", "tools", "timeEstimate",
+	}
+	relationCSVColumns = []string{
+		"startNodeId", "endNodeId", "relationshipType", "relationshipId", "amount", "unit", "step_order",
+	}
+)
+
+// 菜谱实例节点ID的起点：菜谱、食材、步骤共用一个递增序列
+const firstInstanceNodeID = 201000000
+
+// hierarchyRow 层次结构节点
+type hierarchyRow struct {
+	nodeID string
+	label  string
+	name   string
+	fsn    string
+}
+
+// recipeHierarchyRows 层次结构节点，与现有 nodes.csv 开头的节点一致
+var recipeHierarchyRows = []hierarchyRow{
+	{"100000000", "Root", "烹饪概念", "烹饪概念 (Culinary Concept)"},
+	{"200000000", "Recipe", "菜谱", "菜谱 (Recipe)"},
+	{"300000000", "Ingredient", "食材", "食材 (Ingredient)"},
+	{"400000000", "CookingMethod", "烹饪方法", "烹饪方法 (Cooking Method)"},
+	{"500000000", "CookingTool", "烹饪工具", "烹饪工具 (Cooking Tool)"},
+	{"610000000", "DifficultyLevel", "一星", "一星 (One Star)"},
+	{"620000000", "DifficultyLevel", "二星", "二星 (Two Star)"},
+	{"630000000", "DifficultyLevel", "三星", "三星 (Three Star)"},
+	{"640000000", "DifficultyLevel", "四星", "四星 (Four Star)"},
+	{"650000000", "DifficultyLevel", "五星", "五星 (Five Star)"},
+	{"710000000", "RecipeCategory", "素菜", "素菜 (Vegetarian Dish)"},
+	{"720000000", "RecipeCategory", "荤菜", "荤菜 (Meat Dish)"},
+	{"730000000", "RecipeCategory", "水产", "水产 (Aquatic Product)"},
+	{"740000000", "RecipeCategory", "早餐", "早餐 (Breakfast)"},
+	{"750000000", "RecipeCategory", "主食", "主食 (Staple Food)"},
+	{"760000000", "RecipeCategory", "汤类", "汤类 (Soup)"},
+	{"770000000", "RecipeCategory", "甜品", "甜品 (Dessert)"},
+	{"780000000", "RecipeCategory", "饮料", "饮料 (Beverage)"},
+	{"790000000", "RecipeCategory", "调料", "调料 (Condiment)"},
+}
+
+// GraphCSVRows nodes.csv 和 relationships.csv 的行数据
+type GraphCSVRows struct {
+	Nodes     []map[string]string
+	Relations []map[string]string
+
+	KeptRecipes    []string // 已有图中没有Markdown文件、原样保留的菜谱
+	DroppedRecipes []string // 已有图中没有Markdown文件、被丢弃的菜谱
+}
+
+// Graph 按CSV导入规则构建内存图，可用于 MemoryGraphStore 或 Neo4jGraphImporter
+func (r *GraphCSVRows) Graph() *CSVGraph {
+	return NewCSVGraphFromRows(r.Nodes, r.Relations)
+}
+
+// WriteCSV 写出 nodes.csv 和 relationships.csv
+func (r *GraphCSVRows) WriteCSV(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("创建输出目录失败: %w", err)
+	}
+	if err := writeCSVRows(filepath.Join(dir, "nodes.csv"), nodeCSVColumns, r.Nodes); err != nil {
+		return fmt.Errorf("写入节点文件失败: %w", err)
+	}
+	if err := writeCSVRows(filepath.Join(dir, "relationships.csv"), relationCSVColumns, r.Relations); err != nil {
+		return fmt.Errorf("写入关系文件失败: %w", err)
+	}
+	return nil
+}
+
+// writeCSVRows 写出带表头的CSV文件，先写临时文件再替换，避免中途失败留下不完整的文件
+func writeCSVRows(path string, columns []string, rows []map[string]string) error {
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(file)
+	writer.Write(columns)
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for i, column := range columns {
+			record[i] = row[column]
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// RecipeGraphBuilder 把Markdown菜谱转换为图的CSV行
+//
+// 节点ID和关系ID在重新生成时保持稳定：传入已有的图时，菜谱按文件路径（其次按菜名）、
+// 食材按菜谱内的名称、步骤按菜谱内的序号复用原有nodeId，关系按两端节点和类型复用原有relationshipId；
+// 新出现的实体在已有最大ID之后依次分配。
+//
+// Markdown中没有的属性（菜系、准备和烹饪时间、标签、同义词、fsn，食材的分类和是否主料，步骤的方法和工具）
+// 从已有图中对应的节点沿用，新菜谱的这些属性为空。已有图中没有对应Markdown文件的菜谱默认连同食材、
+// 步骤和关系原样保留，SetDropMissingRecipes(true)时丢弃；两种情况都记录在 GraphCSVRows 中。
+type RecipeGraphBuilder struct {
+	existing    *CSVGraph
+	dropMissing bool
+
+	nextNodeID     int
+	nextRelationID int
+	usedNodeIDs    map[string]bool
+	relationIDs    map[string]string // 起点|终点|编码 -> relationshipId
+}
+
+// NewRecipeGraphBuilder 创建构建器，existing为nil时从头分配ID
+func NewRecipeGraphBuilder(existing *CSVGraph) *RecipeGraphBuilder {
+	b := &RecipeGraphBuilder{
+		existing:       existing,
+		nextNodeID:     firstInstanceNodeID + 1,
+		nextRelationID: 1,
+		usedNodeIDs:    make(map[string]bool),
+		relationIDs:    make(map[string]string),
+	}
+	if existing == nil {
+		return b
+	}
+
+	for _, node := range existing.Nodes {
+		if id, err := strconv.Atoi(node.NodeID); err == nil && id > firstInstanceNodeID && id < firstInstanceNodeID+1000000 {
+			b.nextNodeID = max(b.nextNodeID, id+1)
+		}
+	}
+	for _, relation := range existing.Relations {
+		relationID, _ := relation.Properties["relationshipId"].(string)
+		code, _ := relation.Properties["originalType"].(string)
+		if relationID == "" || code == "" {
+			continue
+		}
+		b.relationIDs[relationKey(relation.StartNodeID, relation.EndNodeID, code)] = relationID
+		if number, err := strconv.Atoi(strings.TrimPrefix(relationID, "R_")); err == nil {
+			b.nextRelationID = max(b.nextRelationID, number+1)
+		}
+	}
+	return b
+}
+
+// SetDropMissingRecipes 设置是否丢弃已有图中没有对应Markdown文件的菜谱
+func (b *RecipeGraphBuilder) SetDropMissingRecipes(drop bool) {
+	b.dropMissing = drop
+}
+
+// Build 生成层次结构节点和全部菜谱的节点、关系行
+func (b *RecipeGraphBuilder) Build(recipes []*MarkdownRecipe) *GraphCSVRows {
+	rows := &GraphCSVRows{}
+	categoryIDs := make(map[string]string)
+	difficultyIDs := make(map[int]string)
+	for _, hierarchy := range recipeHierarchyRows {
+		rows.Nodes = append(rows.Nodes, map[string]string{
+			"nodeId":        hierarchy.nodeID,
+			"labels":        hierarchy.label,
+			"name":          hierarchy.name,
+			"preferredTerm": hierarchy.name,
+			"fsn":           hierarchy.fsn,
+			"conceptType":   hierarchy.label,
+		})
+		b.usedNodeIDs[hierarchy.nodeID] = true
+		switch hierarchy.label {
+		case "RecipeCategory":
+			categoryIDs[hierarchy.name] = hierarchy.nodeID
+		case "DifficultyLevel":
+			difficultyIDs[difficultyLevels[hierarchy.name]] = hierarchy.nodeID
+		}
+	}
+
+	for _, recipe := range recipes {
+		b.addRecipe(rows, recipe, categoryIDs, difficultyIDs)
+	}
+	b.addMissingRecipes(rows)
+	return rows
+}
+
+// addRecipe 生成一个菜谱及其食材、步骤的节点和关系行
+func (b *RecipeGraphBuilder) addRecipe(rows *GraphCSVRows, recipe *MarkdownRecipe, categoryIDs map[string]string, difficultyIDs map[int]string) {
+	existingRecipe := b.findExistingRecipe(recipe)
+
+	filePath := recipe.FilePath
+	recipeID := ""
+	if existingRecipe != nil {
+		recipeID = existingRecipe.NodeID
+		// 沿用原有的路径写法，避免仅因分隔符不同产生差异
+		if original := getStringFromMap(existingRecipe.Properties, "filePath", ""); original != "" {
+			filePath = original
+		}
+	}
+	recipeID = b.allocateNodeID(recipeID)
+
+	recipeRow := map[string]string{
+		"nodeId":        recipeID,
+		"labels":        "Recipe",
+		"name":          recipe.Name,
+		"preferredTerm": recipe.Name,
+		"conceptType":   "Recipe",
+		"category":      recipe.Category,
+		"servings":      recipe.Servings,
+		"filePath":      filePath,
+		"description":   recipe.Description,
+	}
+	if recipe.Difficulty > 0 {
+		recipeRow["difficulty"] = fmt.Sprintf("%.1f", float64(recipe.Difficulty))
+	}
+	if existingRecipe != nil {
+		carryOverProperties(recipeRow, existingRecipe,
+			"fsn", "cuisineType", "prepTime", "cookTime", "tags", "synonyms", "servings", "description")
+	}
+	rows.Nodes = append(rows.Nodes, recipeRow)
+
+	existingIngredients := make(map[string]*GraphNode)
+	existingSteps := make(map[string]*GraphNode)
+	if existingRecipe != nil {
+		for _, relation := range b.existing.Outgoing(recipeID, "REQUIRES") {
+			if node, ok := b.existing.Node(relation.EndNodeID); ok {
+				existingIngredients[node.Name] = node
+			}
+		}
+		for _, relation := range b.existing.Outgoing(recipeID, "CONTAINS_STEP") {
+			if node, ok := b.existing.Node(relation.EndNodeID); ok {
+				if stepNumber, ok := node.Properties["stepNumber"].(float64); ok {
+					existingSteps[fmt.Sprintf("%.1f", stepNumber)] = node
+				}
+			}
+		}
+	}
+
+	for _, ingredient := range recipe.Ingredients {
+		existingIngredient := existingIngredients[ingredient.Name]
+		ingredientID := b.allocateNodeID(nodeIDOf(existingIngredient))
+		ingredientRow := map[string]string{
+			"nodeId":        ingredientID,
+			"labels":        "Ingredient",
+			"name":          ingredient.Name,
+			"preferredTerm": ingredient.Name,
+			"conceptType":   "Ingredient",
+			"amount":        ingredient.Amount,
+			"unit":          ingredient.Unit,
+		}
+		if existingIngredient != nil && existingIngredient.NodeID == ingredientID {
+			carryOverProperties(ingredientRow, existingIngredient, "fsn", "synonyms", "category", "isMain", "description")
+		}
+		rows.Nodes = append(rows.Nodes, ingredientRow)
+		rows.Relations = append(rows.Relations, map[string]string{
+			"startNodeId":      recipeID,
+			"endNodeId":        ingredientID,
+			"relationshipType": relationCodeRequires,
+			"relationshipId":   b.relationID(recipeID, ingredientID, relationCodeRequires),
+			"amount":           ingredient.Amount,
+			"unit":             ingredient.Unit,
+		})
+	}
+
+	for i, step := range recipe.Steps {
+		stepNumber := fmt.Sprintf("%.1f", float64(i+1))
+		existingStep := existingSteps[stepNumber]
+		stepID := b.allocateNodeID(nodeIDOf(existingStep))
+		stepRow := map[string]string{
+			"nodeId":        stepID,
+			"labels":        "CookingStep",
+			"name":          fmt.Sprintf("步骤%d", i+1),
+			"preferredTerm": fmt.Sprintf("步骤%d", i+1),
+			"conceptType":   "CookingStep",
+			"description":   step,
+			"stepNumber":    stepNumber,
+			"timeEstimate":  stepTimeEstimate(step),
+		}
+		if existingStep != nil && existingStep.NodeID == stepID {
+			carryOverProperties(stepRow, existingStep, "fsn", "synonyms", "methods", "tools", "timeEstimate")
+		}
+		rows.Nodes = append(rows.Nodes, stepRow)
+		rows.Relations = append(rows.Relations, map[string]string{
+			"startNodeId":      recipeID,
+			"endNodeId":        stepID,
+			"relationshipType": relationCodeContainsStep,
+			"relationshipId":   b.relationID(recipeID, stepID, relationCodeContainsStep),
+			"step_order":       stepNumber,
+		})
+	}
+
+	if categoryID, ok := categoryIDs[recipe.Category]; ok {
+		rows.Relations = append(rows.Relations, map[string]string{
+			"startNodeId":      recipeID,
+			"endNodeId":        categoryID,
+			"relationshipType": relationCodeBelongsTo,
+			"relationshipId":   b.relationID(recipeID, categoryID, relationCodeBelongsTo),
+		})
+	}
+	if difficultyID, ok := difficultyIDs[recipe.Difficulty]; ok {
+		rows.Relations = append(rows.Relations, map[string]string{
+			"startNodeId":      recipeID,
+			"endNodeId":        difficultyID,
+			"relationshipType": relationCodeDifficultyLevel,
+			"relationshipId":   b.relationID(recipeID, difficultyID, relationCodeDifficultyLevel),
+		})
+	}
+}
+
+// addMissingRecipes 处理已有图中没有对应Markdown文件的菜谱
+//
+// 保留时原样写出菜谱节点、它的CSV关系（需要食材、包含步骤、分类、难度）以及关系另一端尚未写出的节点。
+func (b *RecipeGraphBuilder) addMissingRecipes(rows *GraphCSVRows) {
+	if b.existing == nil {
+		return
+	}
+	for _, recipe := range b.existing.NodesByLabel("Recipe") {
+		if b.usedNodeIDs[recipe.NodeID] {
+			continue
+		}
+		if b.dropMissing {
+			rows.DroppedRecipes = append(rows.DroppedRecipes, recipe.Name)
+			continue
+		}
+
+		b.usedNodeIDs[recipe.NodeID] = true
+		rows.Nodes = append(rows.Nodes, nodeCSVRow(recipe))
+		rows.KeptRecipes = append(rows.KeptRecipes, recipe.Name)
+		for _, relation := range b.existing.Outgoing(recipe.NodeID, "") {
+			code, _ := relation.Properties["originalType"].(string)
+			if code == "" {
+				continue // 派生关系由导入时重新构建
+			}
+			if end, ok := b.existing.Node(relation.EndNodeID); ok && !b.usedNodeIDs[end.NodeID] {
+				b.usedNodeIDs[end.NodeID] = true
+				rows.Nodes = append(rows.Nodes, nodeCSVRow(*end))
+			}
+			rows.Relations = append(rows.Relations, map[string]string{
+				"startNodeId":      relation.StartNodeID,
+				"endNodeId":        relation.EndNodeID,
+				"relationshipType": code,
+				"relationshipId":   b.relationID(relation.StartNodeID, relation.EndNodeID, code),
+				"amount":           csvPropertyValue(relation.Properties["amount"]),
+				"unit":             csvPropertyValue(relation.Properties["unit"]),
+				"step_order":       csvPropertyValue(relation.Properties["stepOrder"]),
+			})
+		}
+	}
+}
+
+// nodeCSVRow 把已有图中的节点还原为 nodes.csv 的一行
+func nodeCSVRow(node GraphNode) map[string]string {
+	row := map[string]string{
+		"nodeId": node.NodeID,
+		"labels": getStringFromMap(node.Properties, "originalLabels", ""),
+		"name":   node.Name,
+	}
+	for _, column := range nodeCSVColumns {
+		if _, set := row[column]; !set {
+			row[column] = csvPropertyValue(node.Properties[column])
+		}
+	}
+	return row
+}
+
+// carryOverProperties 行中为空的列沿用已有节点的属性值
+func carryOverProperties(row map[string]string, node *GraphNode, columns ...string) {
+	for _, column := range columns {
+		if row[column] != "" {
+			continue
+		}
+		if value := csvPropertyValue(node.Properties[column]); value != "" {
+			row[column] = value
+		}
+	}
+}
+
+// csvPropertyValue 把节点或关系属性格式化为CSV中的写法：数值保留一位小数，布尔值写作True/False
+func csvPropertyValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', 1, 64)
+	case bool:
+		if v {
+			return "True"
+		}
+		return "False"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// nodeIDOf 节点的ID，node为nil时返回空
+func nodeIDOf(node *GraphNode) string {
+	if node == nil {
+		return ""
+	}
+	return node.NodeID
+}
+
+// findExistingRecipe 在已有图中查找同一菜谱：先按文件路径，再按菜名
+func (b *RecipeGraphBuilder) findExistingRecipe(recipe *MarkdownRecipe) *GraphNode {
+	if b.existing == nil {
+		return nil
+	}
+	recipes := b.existing.NodesByLabel("Recipe")
+
+	path := normalizeRecipePath(recipe.FilePath)
+	for i := range recipes {
+		existingPath := normalizeRecipePath(getStringFromMap(recipes[i].Properties, "filePath", ""))
+		if existingPath == "" || b.usedNodeIDs[recipes[i].NodeID] {
+			continue
+		}
+		if existingPath == path || strings.HasSuffix(existingPath, "/"+path) || strings.HasSuffix(path, "/"+existingPath) {
+			return &recipes[i]
+		}
+	}
+	for i := range recipes {
+		if recipes[i].Name == recipe.Name && !b.usedNodeIDs[recipes[i].NodeID] {
+			return &recipes[i]
+		}
+	}
+	return nil
+}
+
+// allocateNodeID 复用已有ID（未被占用时），否则分配新ID
+func (b *RecipeGraphBuilder) allocateNodeID(existingID string) string {
+	if existingID != "" && !b.usedNodeIDs[existingID] {
+		b.usedNodeIDs[existingID] = true
+		return existingID
+	}
+	for {
+		id := strconv.Itoa(b.nextNodeID)
+		b.nextNodeID++
+		if !b.usedNodeIDs[id] {
+			b.usedNodeIDs[id] = true
+			return id
+		}
+	}
+}
+
+// relationID 复用已有关系ID，否则分配新ID
+func (b *RecipeGraphBuilder) relationID(startID, endID, code string) string {
+	key := relationKey(startID, endID, code)
+	if id, ok := b.relationIDs[key]; ok {
+		return id
+	}
+	id := fmt.Sprintf("R_%06d", b.nextRelationID)
+	b.nextRelationID++
+	b.relationIDs[key] = id
+	return id
+}
+
+// relationKey 关系的去重键
+func relationKey(startID, endID, code string) string {
+	return startID + "|" + endID + "|" + code
+}
+
+// normalizeRecipePath 统一路径分隔符，兼容Windows风格的 filePath
+func normalizeRecipePath(path string) string {
+	return strings.Trim(strings.ReplaceAll(path, "\\", "/"), "/")
+}
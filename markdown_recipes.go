@@ -0,0 +1,328 @@
+package batch_0001
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MarkdownIngredient Markdown菜谱中的一种食材
+type MarkdownIngredient struct {
+	Name   string // 食材名称
+	Amount string // 用量，如"350"、"2-3"、"适量"
+	Unit   string // 单位，如"g"、"个"
+}
+
+// MarkdownRecipe 从HowToCook风格Markdown文件解析出的菜谱
+//
+// 文件结构：一级标题"# XX的做法"、简介段落、"预估烹饪难度：★★★"，
+// 以及"## 必备原料和工具"、"## 计算"、"## 操作"等二级标题小节。
+type MarkdownRecipe struct {
+	Name        string               // 菜名，去掉标题中的"的做法"
+	Description string               // 标题与第一个小节之间的简介
+	Difficulty  int                  // 难度星级，0表示未标注
+	Category    string               // 菜谱分类，由所在目录推断
+	Servings    string               // 份数，如"2人"
+	FilePath    string               // 相对于菜谱根目录的路径
+	Ingredients []MarkdownIngredient // 食材及用量
+	Tools       []string             // 必备工具
+	Steps       []string             // 操作步骤
+}
+
+// recipeDirCategories HowToCook目录名对应的菜谱分类
+var recipeDirCategories = map[string]string{
+	"vegetable_dish": "素菜",
+	"meat_dish":      "荤菜",
+	"aquatic":        "水产",
+	"breakfast":      "早餐",
+	"staple":         "主食",
+	"soup":           "汤类",
+	"dessert":        "甜品",
+	"drink":          "饮料",
+	"condiment":      "调料",
+	"semi-finished":  "半成品",
+}
+
+// cookingToolSuffixes 必备原料和工具中按后缀识别为工具的名称
+var cookingToolSuffixes = []string{
+	"锅", "刀", "铲", "碗", "盘", "盆", "板", "筷", "勺子", "漏勺", "夹子", "剪刀", "盖",
+	"微波炉", "烤箱", "电饭煲", "破壁机", "料理机", "搅拌机", "打蛋器", "保鲜膜", "锡纸", "油纸", "冰箱",
+}
+
+var (
+	markdownListItemPattern = regexp.MustCompile(`^(\s*)(?:[-*+]|\d+[.、)）])\s+(.+)$`)
+	ingredientNameSeparator = regexp.MustCompile(`[\s=:：（(，,*×]`)
+	ingredientAmountPattern = regexp.MustCompile(`(\d+(?:\.\d+)?(?:\s*[-~～]\s*\d+(?:\.\d+)?)?)\s*([A-Za-z]+|[^\s\d（()*×，,。;；=+]{1,2})?`)
+	vagueAmountPattern      = regexp.MustCompile(`适量|少许|少量|若干|一点`)
+	servingsPattern         = regexp.MustCompile(`(\d+(?:\s*[-~～]\s*\d+)?)\s*(?:人份|人)`)
+	stepTimePattern         = regexp.MustCompile(`\d+(?:\.\d+)?(?:\s*[-~～]\s*\d+(?:\.\d+)?)?\s*(?:分钟|小时|秒)`)
+)
+
+// ErrNotMarkdownRecipe 文件没有"## 操作"小节，不是菜谱（如README、模板）
+var ErrNotMarkdownRecipe = errors.New("不是菜谱文件")
+
+// MarkdownRecipesError 部分Markdown文件读取或解析失败
+type MarkdownRecipesError struct {
+	FailedFiles []string // 失败的文件，相对于菜谱根目录
+	Err         error    // 各文件的错误
+}
+
+// Error 错误描述
+func (e *MarkdownRecipesError) Error() string {
+	return fmt.Sprintf("%d 个菜谱文件解析失败: %v", len(e.FailedFiles), e.Err)
+}
+
+// Unwrap 返回各文件的错误
+func (e *MarkdownRecipesError) Unwrap() error {
+	return e.Err
+}
+
+// ParseMarkdownRecipeDir 递归解析目录下的全部Markdown菜谱，按文件路径排序返回
+//
+// README和模板等不是菜谱的文件被跳过；有"## 操作"小节但无法解析的文件不跳过，
+// 返回已解析的菜谱和 *MarkdownRecipesError，由调用方决定是否继续。
+func ParseMarkdownRecipeDir(root string) ([]*MarkdownRecipe, error) {
+	var paths []string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && strings.EqualFold(filepath.Ext(path), ".md") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("遍历菜谱目录失败: %w", err)
+	}
+	sort.Strings(paths)
+
+	var recipes []*MarkdownRecipe
+	var failedFiles []string
+	var errs []error
+	for _, path := range paths {
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			relPath = path
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			failedFiles = append(failedFiles, relPath)
+			errs = append(errs, fmt.Errorf("读取菜谱文件失败: %w", err))
+			continue
+		}
+		recipe, err := ParseMarkdownRecipe(string(content), relPath)
+		if errors.Is(err, ErrNotMarkdownRecipe) {
+			continue
+		}
+		if err != nil {
+			failedFiles = append(failedFiles, relPath)
+			errs = append(errs, err)
+			continue
+		}
+		recipes = append(recipes, recipe)
+	}
+
+	if len(failedFiles) > 0 {
+		return recipes, &MarkdownRecipesError{FailedFiles: failedFiles, Err: errors.Join(errs...)}
+	}
+	return recipes, nil
+}
+
+// ParseMarkdownRecipe 解析一个Markdown菜谱文件，filePath用于推断分类并记录来源
+func ParseMarkdownRecipe(content, filePath string) (*MarkdownRecipe, error) {
+	recipe := &MarkdownRecipe{
+		FilePath: filePath,
+		Category: categoryForRecipePath(filePath),
+	}
+
+	var section string
+	var description []string
+	var requiredItems, calculationItems []string
+	var hasSteps bool
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, "# "):
+			if recipe.Name == "" {
+				recipe.Name = strings.TrimSuffix(strings.TrimSpace(trimmed[2:]), "的做法")
+			}
+			continue
+		case strings.HasPrefix(trimmed, "## "):
+			section = strings.TrimSpace(trimmed[3:])
+			if strings.Contains(section, "操作") {
+				hasSteps = true
+			}
+			continue
+		case trimmed == "":
+			continue
+		}
+
+		if strings.Contains(trimmed, "预估烹饪难度") {
+			recipe.Difficulty = strings.Count(trimmed, "★")
+			continue
+		}
+
+		item, indented := markdownListItem(line)
+		switch {
+		case section == "":
+			if !strings.HasPrefix(trimmed, "!") {
+				description = append(description, trimmed)
+			}
+		case strings.Contains(section, "必备原料和工具"):
+			if item != "" {
+				requiredItems = append(requiredItems, item)
+			}
+		case strings.Contains(section, "计算"):
+			if item != "" {
+				calculationItems = append(calculationItems, item)
+			} else if match := servingsPattern.FindStringSubmatch(trimmed); match != nil && recipe.Servings == "" {
+				recipe.Servings = match[1] + "人"
+			}
+		case strings.Contains(section, "操作"):
+			if item == "" {
+				continue
+			}
+			// 缩进的子列表项并入上一步
+			if indented && len(recipe.Steps) > 0 {
+				recipe.Steps[len(recipe.Steps)-1] += "；" + item
+			} else {
+				recipe.Steps = append(recipe.Steps, item)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取菜谱内容失败: %w", err)
+	}
+
+	if !hasSteps {
+		return nil, fmt.Errorf("%w: %s", ErrNotMarkdownRecipe, filePath)
+	}
+	if recipe.Name == "" {
+		return nil, fmt.Errorf("菜谱缺少一级标题: %s", filePath)
+	}
+	if len(recipe.Steps) == 0 {
+		return nil, fmt.Errorf("菜谱的操作小节没有步骤: %s", filePath)
+	}
+	recipe.Description = strings.Join(description, "")
+	recipe.Ingredients, recipe.Tools = mergeMarkdownIngredients(requiredItems, calculationItems)
+	return recipe, nil
+}
+
+// markdownListItem 提取列表项文本，indented表示缩进的子列表项
+func markdownListItem(line string) (item string, indented bool) {
+	match := markdownListItemPattern.FindStringSubmatch(line)
+	if match == nil {
+		return "", false
+	}
+	return strings.TrimSpace(match[2]), len(match[1]) >= 2
+}
+
+// categoryForRecipePath 由目录名推断菜谱分类
+func categoryForRecipePath(filePath string) string {
+	for _, part := range strings.Split(filepath.ToSlash(filePath), "/") {
+		if category, ok := recipeDirCategories[part]; ok {
+			return category
+		}
+	}
+	return ""
+}
+
+// mergeMarkdownIngredients 合并"必备原料和工具"和"计算"小节
+//
+// 食材以必备原料中的顺序为准，用量取自计算小节；只出现在计算小节中的食材追加在后面。
+func mergeMarkdownIngredients(requiredItems, calculationItems []string) ([]MarkdownIngredient, []string) {
+	amounts := make(map[string]MarkdownIngredient)
+	var calculationOrder []string
+	for _, item := range calculationItems {
+		ingredient := parseIngredientAmount(item)
+		if ingredient.Name == "" {
+			continue
+		}
+		if _, exists := amounts[ingredient.Name]; !exists {
+			calculationOrder = append(calculationOrder, ingredient.Name)
+		}
+		amounts[ingredient.Name] = ingredient
+	}
+
+	var ingredients []MarkdownIngredient
+	var tools []string
+	seen := make(map[string]bool)
+	for _, item := range requiredItems {
+		name := ingredientName(item)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if _, calculated := amounts[name]; !calculated && isCookingToolName(name) {
+			tools = append(tools, name)
+			continue
+		}
+		ingredient, ok := amounts[name]
+		if !ok {
+			ingredient = parseIngredientAmount(item)
+		}
+		ingredients = append(ingredients, ingredient)
+	}
+	for _, name := range calculationOrder {
+		if !seen[name] {
+			seen[name] = true
+			ingredients = append(ingredients, amounts[name])
+		}
+	}
+	return ingredients, tools
+}
+
+// ingredientName 取列表项开头的食材名称
+func ingredientName(item string) string {
+	item = strings.Trim(item, "*_` ")
+	if loc := ingredientNameSeparator.FindStringIndex(item); loc != nil {
+		item = item[:loc[0]]
+	}
+	// 去掉紧跟在名称后的数字用量，如"鸡胸肉350g"
+	if loc := ingredientAmountPattern.FindStringIndex(item); loc != nil && loc[0] > 0 {
+		item = item[:loc[0]]
+	}
+	return strings.TrimSpace(item)
+}
+
+// parseIngredientAmount 解析"鸡胸肉 350g"、"盐 2-3 g"、"食用油 适量"形式的用量
+func parseIngredientAmount(item string) MarkdownIngredient {
+	ingredient := MarkdownIngredient{Name: ingredientName(item)}
+	rest := strings.TrimPrefix(strings.Trim(item, "*_` "), ingredient.Name)
+	if match := ingredientAmountPattern.FindStringSubmatch(rest); match != nil {
+		ingredient.Amount = strings.Join(strings.Fields(match[1]), "")
+		ingredient.Unit = match[2]
+	} else if vague := vagueAmountPattern.FindString(rest); vague != "" {
+		ingredient.Amount = vague
+	}
+	return ingredient
+}
+
+// isCookingToolName 按后缀判断名称是否为烹饪工具
+func isCookingToolName(name string) bool {
+	for _, suffix := range cookingToolSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// stepTimeEstimate 从步骤文本中提取第一个时间描述
+func stepTimeEstimate(step string) string {
+	return strings.Join(strings.Fields(stepTimePattern.FindString(step)), "")
+}
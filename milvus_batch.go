@@ -33,6 +33,7 @@ package batch_0001
 import (
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"log"
 	"math"
@@ -53,11 +54,13 @@ const (
 	DefaultCollectionName = "cooking_knowledge"
 	DefaultDimension      = 81920 // 豆包embedding模型的向量维度
 	DefaultModelName      = "doubao-embedding-text-240715"
-	DefaultAPIKey         = "be0d94ab-5e0f-48ca-87bf-5522007ba28e"
 	BatchSize             = 100
 	MaxRetries            = 3
 )
 
+// ErrCollectionSchemaOutdated 已有集合由旧版本创建，缺少当前版本的字段，需要重建
+var ErrCollectionSchemaOutdated = errors.New("向量集合模式已过期")
+
 // 文档实体结构
 type DocumentEntity struct {
 	ID          string                 `json:"id"`           // 主键，唯一标识
@@ -93,6 +96,9 @@ type CollectionStats struct {
 }
 
 // 过滤条件
+//
+// 字符串和数值为等值匹配，切片为in匹配，也可以直接放入FilterExpr；
+// 更复杂的条件请使用FilterExpr（见milvus_filter.go）。
 type SearchFilters map[string]interface{}
 
 // MilvusIndexConstructionModule Milvus向量索引构建模块
@@ -103,10 +109,13 @@ type MilvusIndexConstructionModule struct {
 	dimension         int64
 	modelName         string
 	apiKey            string
+	username          string // Milvus认证用户名，为空时不认证
+	password          string
 	client            *milvusclient.Client
 	embedder          *ark.Embedder
 	collectionCreated bool
 	indexCreated      bool
+	liveSchema        *entity.Schema // 服务端集合的实际模式，过滤条件按它校验，集合重建后清空
 }
 
 // NewMilvusIndexConstructionModule 创建新的Milvus索引构建模块
@@ -119,7 +128,7 @@ type MilvusIndexConstructionModule struct {
 //   - collectionName: 向量集合名称，用于组织数据
 //   - dimension: 向量维度，需与embedding模型匹配
 //   - modelName: Ark embedding模型名称
-//   - apiKey: API密钥
+//   - apiKey: API密钥，不内置默认值，需在运行时通过密钥来源提供
 //
 // Note:
 //
@@ -140,10 +149,6 @@ func NewMilvusIndexConstructionModule(host, port, collectionName string, dimensi
 	if modelName == "" {
 		modelName = DefaultModelName
 	}
-	if apiKey == "" {
-		apiKey = DefaultAPIKey
-	}
-
 	return &MilvusIndexConstructionModule{
 		host:           host,
 		port:           port,
@@ -154,6 +159,12 @@ func NewMilvusIndexConstructionModule(host, port, collectionName string, dimensi
 	}
 }
 
+// SetCredentials 设置Milvus认证用户名和密码，未设置时以无认证方式连接
+func (m *MilvusIndexConstructionModule) SetCredentials(username, password string) {
+	m.username = username
+	m.password = password
+}
+
 // safeTruncate 安全截取字符串，处理空值和长度限制
 //
 // Milvus对字符串字段有长度限制，此方法确保数据符合schema要求。
@@ -190,8 +201,8 @@ func (m *MilvusIndexConstructionModule) setupClient(ctx context.Context) error {
 	addr := fmt.Sprintf("%s:%s", m.host, m.port)
 	client, err := milvusclient.New(ctx, &milvusclient.ClientConfig{
 		Address:  addr,
-		Username: "root",
-		Password: "rootroot",
+		Username: m.username,
+		Password: m.password,
 		DBName:   "default",
 	})
 	if err != nil {
@@ -227,6 +238,10 @@ func (m *MilvusIndexConstructionModule) setupEmbeddings(ctx context.Context) err
 		return nil // 已经初始化
 	}
 
+	if m.apiKey == "" {
+		return fmt.Errorf("初始化embedding模型失败: 未配置API Key")
+	}
+
 	log.Printf("正在初始化嵌入模型: %s", m.modelName)
 
 	embedder, err := ark.NewEmbedder(ctx, &ark.EmbeddingConfig{
@@ -272,10 +287,13 @@ func (m *MilvusIndexConstructionModule) createCollectionSchema() *entity.Schema
 		WithField(entity.NewField().WithName("category").WithDataType(entity.FieldTypeVarChar).WithMaxLength(100)).     // 菜品分类
 		WithField(entity.NewField().WithName("cuisine_type").WithDataType(entity.FieldTypeVarChar).WithMaxLength(200)). // 菜系类型
 		WithField(entity.NewField().WithName("difficulty").WithDataType(entity.FieldTypeInt64)).                        // 难度等级
+		WithField(entity.NewField().WithName("total_time").WithDataType(entity.FieldTypeInt64)).                        // 总耗时（分钟）
 		// 文档处理相关字段
-		WithField(entity.NewField().WithName("doc_type").WithDataType(entity.FieldTypeVarChar).WithMaxLength(50)).  // 文档类型
-		WithField(entity.NewField().WithName("chunk_id").WithDataType(entity.FieldTypeVarChar).WithMaxLength(150)). // 分块ID
-		WithField(entity.NewField().WithName("parent_id").WithDataType(entity.FieldTypeVarChar).WithMaxLength(100)) // 父文档ID
+		WithField(entity.NewField().WithName("doc_type").WithDataType(entity.FieldTypeVarChar).WithMaxLength(50)).   // 文档类型
+		WithField(entity.NewField().WithName("chunk_id").WithDataType(entity.FieldTypeVarChar).WithMaxLength(150)).  // 分块ID
+		WithField(entity.NewField().WithName("parent_id").WithDataType(entity.FieldTypeVarChar).WithMaxLength(100)). // 父文档ID
+		// 内容指纹：用于增量同步时判断文档块是否变化
+		WithField(entity.NewField().WithName("content_hash").WithDataType(entity.FieldTypeVarChar).WithMaxLength(64))
 
 	return schema
 }
@@ -308,10 +326,14 @@ func (m *MilvusIndexConstructionModule) CreateCollection(ctx context.Context, fo
 			}
 		} else {
 			log.Printf("集合 %s 已存在", m.collectionName)
+			if err := m.checkCollectionSchema(ctx); err != nil {
+				return false, err
+			}
 			m.collectionCreated = true
 			return true, nil
 		}
 	}
+	m.liveSchema = nil
 
 	// 创建集合
 	schema := m.createCollectionSchema()
@@ -423,6 +445,15 @@ func (m *MilvusIndexConstructionModule) insertDocumentsBatch(ctx context.Context
 
 // insertSingleBatch 插入单个批次
 func (m *MilvusIndexConstructionModule) insertSingleBatch(ctx context.Context, batch []*schema.Document) error {
+	return m.writeSingleBatch(ctx, batch, false)
+}
+
+// writeSingleBatch 向量化并写入单个批次
+//
+// Args:
+//   - batch: 文档块批次
+//   - upsert: true时按主键（文档块ID）覆盖写入，false时直接插入
+func (m *MilvusIndexConstructionModule) writeSingleBatch(ctx context.Context, batch []*schema.Document, upsert bool) error {
 	// 准备数据切片
 	ids := make([]string, 0, len(batch))
 	texts := make([]string, 0, len(batch))
@@ -432,9 +463,11 @@ func (m *MilvusIndexConstructionModule) insertSingleBatch(ctx context.Context, b
 	categories := make([]string, 0, len(batch))
 	cuisineTypes := make([]string, 0, len(batch))
 	difficulties := make([]int64, 0, len(batch))
+	totalTimes := make([]int64, 0, len(batch))
 	docTypes := make([]string, 0, len(batch))
 	chunkIDs := make([]string, 0, len(batch))
 	parentIDs := make([]string, 0, len(batch))
+	contentHashes := make([]string, 0, len(batch))
 
 	// 提取文本用于向量化
 	batchTexts := make([]string, len(batch))
@@ -484,16 +517,17 @@ func (m *MilvusIndexConstructionModule) insertSingleBatch(ctx context.Context, b
 		categories = append(categories, m.safeTruncate(getStringMeta("category"), 100))
 		cuisineTypes = append(cuisineTypes, m.safeTruncate(getStringMeta("cuisine_type"), 200))
 		difficulties = append(difficulties, getInt64Meta("difficulty"))
+		totalTimes = append(totalTimes, getInt64Meta("total_time"))
 		docTypes = append(docTypes, m.safeTruncate(getStringMeta("doc_type"), 50))
 		chunkIDs = append(chunkIDs, m.safeTruncate(getStringMeta("chunk_id"), 150))
 		parentIDs = append(parentIDs, m.safeTruncate(getStringMeta("parent_id"), 100))
+		contentHashes = append(contentHashes, DocumentContentHash(doc))
 
 		// 转换向量为字节
 		vectorBytes = append(vectorBytes, m.vector2Bytes(vectors[i]))
 	}
 
-	// 执行插入
-	_, err = m.client.Insert(ctx, milvusclient.NewColumnBasedInsertOption(m.collectionName).
+	option := milvusclient.NewColumnBasedInsertOption(m.collectionName).
 		WithVarcharColumn("id", ids).
 		WithBinaryVectorColumn("vector", int(m.dimension), vectorBytes).
 		WithVarcharColumn("text", texts).
@@ -503,10 +537,18 @@ func (m *MilvusIndexConstructionModule) insertSingleBatch(ctx context.Context, b
 		WithVarcharColumn("category", categories).
 		WithVarcharColumn("cuisine_type", cuisineTypes).
 		WithInt64Column("difficulty", difficulties).
+		WithInt64Column("total_time", totalTimes).
 		WithVarcharColumn("doc_type", docTypes).
 		WithVarcharColumn("chunk_id", chunkIDs).
-		WithVarcharColumn("parent_id", parentIDs))
+		WithVarcharColumn("parent_id", parentIDs).
+		WithVarcharColumn("content_hash", contentHashes)
 
+	// 执行写入
+	if upsert {
+		_, err = m.client.Upsert(ctx, option)
+	} else {
+		_, err = m.client.Insert(ctx, option)
+	}
 	return err
 }
 
@@ -542,10 +584,34 @@ func (m *MilvusIndexConstructionModule) AddDocuments(ctx context.Context, newChu
 //   - []SearchResult: 搜索结果列表
 //   - error: 搜索失败时返回错误信息
 func (m *MilvusIndexConstructionModule) SimilaritySearch(ctx context.Context, query string, topK int, filters SearchFilters) ([]SearchResult, error) {
+	return m.SimilaritySearchWithFilter(ctx, query, topK, filters.ToFilterExpr())
+}
+
+// SimilaritySearchWithFilter 使用类型化过滤表达式的相似度搜索
+//
+// Args:
+//   - query: 查询文本
+//   - topK: 返回结果数量
+//   - filter: 过滤表达式，为nil时不过滤
+//
+// Returns:
+//   - []SearchResult: 搜索结果列表
+//   - error: 过滤表达式不合法或搜索失败时返回错误信息
+func (m *MilvusIndexConstructionModule) SimilaritySearchWithFilter(ctx context.Context, query string, topK int, filter FilterExpr) ([]SearchResult, error) {
 	if !m.collectionCreated {
 		return nil, fmt.Errorf("请先构建或加载向量索引")
 	}
 
+	// 先按服务端集合的实际模式编译过滤表达式，字段或取值不合法时无需生成查询向量
+	collectionSchema, err := m.collectionSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	filterExpr, filterParams, err := compileFilter(collectionSchema, filter)
+	if err != nil {
+		return nil, fmt.Errorf("过滤条件不合法: %w", err)
+	}
+
 	if err := m.setupEmbeddings(ctx); err != nil {
 		return nil, err
 	}
@@ -558,20 +624,20 @@ func (m *MilvusIndexConstructionModule) SimilaritySearch(ctx context.Context, qu
 
 	queryBytes := m.vector2Bytes(queryVectors[0])
 
-	// 构建过滤表达式
-	filterExpr := m.buildFilterExpression(filters)
-
 	// 创建搜索参数
 	annParam := index.NewHNSWAnnParam(64)
 	searchOption := milvusclient.NewSearchOption(m.collectionName, topK, []entity.Vector{entity.BinaryVector(queryBytes)}).
 		WithANNSField("vector").
-		WithOutputFields("text", "node_id", "recipe_name", "node_type", "category", "cuisine_type", "difficulty", "doc_type", "chunk_id", "parent_id").
+		WithOutputFields("text", "node_id", "recipe_name", "node_type", "category", "cuisine_type", "difficulty", "total_time", "doc_type", "chunk_id", "parent_id").
 		WithSearchParam("metric_type", "COSINE").
 		WithAnnParam(annParam)
 
 	// 添加过滤条件
 	if filterExpr != "" {
 		searchOption.WithFilter(filterExpr)
+		for key, value := range filterParams {
+			searchOption.WithTemplateParam(key, value)
+		}
 	}
 
 	// 执行搜索
@@ -592,6 +658,7 @@ func (m *MilvusIndexConstructionModule) SimilaritySearch(ctx context.Context, qu
 		cateCol := res.GetColumn("category")
 		cuisineCol := res.GetColumn("cuisine_type")
 		diffCol := res.GetColumn("difficulty")
+		timeCol := res.GetColumn("total_time")
 		docCol := res.GetColumn("doc_type")
 		chunkCol := res.GetColumn("chunk_id")
 		parentCol := res.GetColumn("parent_id")
@@ -604,6 +671,7 @@ func (m *MilvusIndexConstructionModule) SimilaritySearch(ctx context.Context, qu
 		cates := cateCol.FieldData().GetScalars().GetStringData().GetData()
 		cuisines := cuisineCol.FieldData().GetScalars().GetStringData().GetData()
 		diffs := diffCol.FieldData().GetScalars().GetLongData().GetData()
+		times := timeCol.FieldData().GetScalars().GetLongData().GetData()
 		docs := docCol.FieldData().GetScalars().GetStringData().GetData()
 		chunks := chunkCol.FieldData().GetScalars().GetStringData().GetData()
 		parents := parentCol.FieldData().GetScalars().GetStringData().GetData()
@@ -622,6 +690,7 @@ func (m *MilvusIndexConstructionModule) SimilaritySearch(ctx context.Context, qu
 					"category":     cates[i],
 					"cuisine_type": cuisines[i],
 					"difficulty":   diffs[i],
+					"total_time":   times[i],
 					"doc_type":     docs[i],
 					"chunk_id":     chunks[i],
 					"parent_id":    parents[i],
@@ -634,41 +703,6 @@ func (m *MilvusIndexConstructionModule) SimilaritySearch(ctx context.Context, qu
 	return results, nil
 }
 
-// buildFilterExpression 构建过滤表达式
-func (m *MilvusIndexConstructionModule) buildFilterExpression(filters SearchFilters) string {
-	if len(filters) == 0 {
-		return ""
-	}
-
-	var conditions []string
-	for key, value := range filters {
-		switch v := value.(type) {
-		case string:
-			conditions = append(conditions, fmt.Sprintf(`%s == "%s"`, key, v))
-		case int, int64, float64:
-			conditions = append(conditions, fmt.Sprintf(`%s == %v`, key, v))
-		case []string:
-			quotedValues := make([]string, len(v))
-			for i, val := range v {
-				quotedValues[i] = fmt.Sprintf(`"%s"`, val)
-			}
-			conditions = append(conditions, fmt.Sprintf(`%s in [%s]`, key, strings.Join(quotedValues, ", ")))
-		case []interface{}:
-			var quotedValues []string
-			for _, val := range v {
-				if str, ok := val.(string); ok {
-					quotedValues = append(quotedValues, fmt.Sprintf(`"%s"`, str))
-				} else {
-					quotedValues = append(quotedValues, fmt.Sprintf(`%v`, val))
-				}
-			}
-			conditions = append(conditions, fmt.Sprintf(`%s in [%s]`, key, strings.Join(quotedValues, ", ")))
-		}
-	}
-
-	return strings.Join(conditions, " and ")
-}
-
 // GetCollectionStats 获取集合统计信息
 //
 // Returns:
@@ -721,6 +755,7 @@ func (m *MilvusIndexConstructionModule) DeleteCollection(ctx context.Context) er
 		log.Printf("集合 %s 已删除", m.collectionName)
 		m.collectionCreated = false
 		m.indexCreated = false
+		m.liveSchema = nil
 	} else {
 		log.Printf("集合 %s 不存在", m.collectionName)
 	}
@@ -728,6 +763,62 @@ func (m *MilvusIndexConstructionModule) DeleteCollection(ctx context.Context) er
 	return nil
 }
 
+// missingCollectionFields 返回已有集合缺少的当前版本字段
+func (m *MilvusIndexConstructionModule) missingCollectionFields(ctx context.Context) ([]string, error) {
+	collectionSchema, err := m.collectionSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	actual := make(map[string]bool)
+	for _, field := range collectionSchema.Fields {
+		actual[field.Name] = true
+	}
+	var missing []string
+	for _, field := range m.createCollectionSchema().Fields {
+		if !actual[field.Name] {
+			missing = append(missing, field.Name)
+		}
+	}
+	return missing, nil
+}
+
+// checkCollectionSchema 已有集合缺少当前版本字段时返回ErrCollectionSchemaOutdated
+//
+// 旧版本创建的集合没有total_time、content_hash等字段，写入和带这些字段的过滤都会在服务端失败，
+// 加载时提前报错并提示重建。
+func (m *MilvusIndexConstructionModule) checkCollectionSchema(ctx context.Context) error {
+	missing, err := m.missingCollectionFields(ctx)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: 集合 %s 缺少字段 %s，请执行 rebuild 重建向量索引",
+			ErrCollectionSchemaOutdated, m.collectionName, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// collectionSchema 获取服务端集合的实际模式
+//
+// 旧版本创建的集合可能缺少当前版本的字段，过滤条件需要按实际模式校验，
+// 否则校验通过的表达式会在服务端执行时失败。结果缓存到集合重建为止。
+func (m *MilvusIndexConstructionModule) collectionSchema(ctx context.Context) (*entity.Schema, error) {
+	if m.liveSchema != nil {
+		return m.liveSchema, nil
+	}
+	if err := m.setupClient(ctx); err != nil {
+		return nil, err
+	}
+
+	collection, err := m.client.DescribeCollection(ctx, milvusclient.NewDescribeCollectionOption(m.collectionName))
+	if err != nil {
+		return nil, fmt.Errorf("获取集合信息失败: %w", err)
+	}
+	m.liveSchema = collection.Schema
+	return m.liveSchema, nil
+}
+
 // HasCollection 检查集合是否存在
 //
 // Returns:
@@ -758,6 +849,9 @@ func (m *MilvusIndexConstructionModule) LoadCollection(ctx context.Context) erro
 	if !exists {
 		return fmt.Errorf("集合 %s 不存在", m.collectionName)
 	}
+	if err := m.checkCollectionSchema(ctx); err != nil {
+		return err
+	}
 
 	_, err = m.client.LoadCollection(ctx, milvusclient.NewLoadCollectionOption(m.collectionName))
 	if err != nil {
@@ -0,0 +1,399 @@
+package batch_0001
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/milvus-io/milvus/client/v2/entity"
+)
+
+// FilterExpr Milvus标量过滤表达式
+//
+// 通过Eq、In、Range、Like、And、Or、Not等构造函数组合出过滤条件，
+// 编译时会根据集合模式校验字段名和值类型：
+// - 比较、in、范围条件的值通过Milvus模板参数传递，不拼接进表达式
+// - like条件的模式串做转义后内联（模式串需要保留通配符语义）
+//
+// 示例：
+//
+//	And(In("cuisine_type", "川菜", "湘菜"), Range("difficulty", nil, 2), Not(Contains("text", "香菜")))
+type FilterExpr interface {
+	compile(c *filterCompiler) (string, error)
+}
+
+// ========== 构造函数 ==========
+
+// Eq 等于
+func Eq(field string, value interface{}) FilterExpr {
+	return &compareFilter{field: field, op: "==", value: value}
+}
+
+// Ne 不等于
+func Ne(field string, value interface{}) FilterExpr {
+	return &compareFilter{field: field, op: "!=", value: value}
+}
+
+// Gt 大于
+func Gt(field string, value interface{}) FilterExpr {
+	return &compareFilter{field: field, op: ">", value: value}
+}
+
+// Gte 大于等于
+func Gte(field string, value interface{}) FilterExpr {
+	return &compareFilter{field: field, op: ">=", value: value}
+}
+
+// Lt 小于
+func Lt(field string, value interface{}) FilterExpr {
+	return &compareFilter{field: field, op: "<", value: value}
+}
+
+// Lte 小于等于
+func Lte(field string, value interface{}) FilterExpr {
+	return &compareFilter{field: field, op: "<=", value: value}
+}
+
+// In 字段值在给定列表中
+func In(field string, values ...interface{}) FilterExpr {
+	return &inFilter{field: field, values: values}
+}
+
+// NotIn 字段值不在给定列表中
+func NotIn(field string, values ...interface{}) FilterExpr {
+	return &inFilter{field: field, values: values, negate: true}
+}
+
+// Range 闭区间范围，min/max为nil表示该侧不限制
+func Range(field string, min, max interface{}) FilterExpr {
+	var parts []FilterExpr
+	if min != nil {
+		parts = append(parts, Gte(field, min))
+	}
+	if max != nil {
+		parts = append(parts, Lte(field, max))
+	}
+	return And(parts...)
+}
+
+// Like 模式匹配，pattern中的%和_为通配符，需要按字面匹配的部分先用EscapeLikePattern转义
+func Like(field string, pattern string) FilterExpr {
+	return &likeFilter{field: field, pattern: pattern}
+}
+
+// Contains 字段包含给定子串，子串中的通配符按字面匹配
+func Contains(field string, substr string) FilterExpr {
+	return &likeFilter{field: field, pattern: "%" + EscapeLikePattern(substr) + "%"}
+}
+
+// EscapeLikePattern 转义like模式中的通配符%和_，使其按字面匹配
+func EscapeLikePattern(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "%", `\%`)
+	return strings.ReplaceAll(value, "_", `\_`)
+}
+
+// And 逻辑与，忽略nil和空条件
+func And(exprs ...FilterExpr) FilterExpr {
+	return &logicalFilter{op: "and", exprs: exprs}
+}
+
+// Or 逻辑或，忽略nil和空条件
+func Or(exprs ...FilterExpr) FilterExpr {
+	return &logicalFilter{op: "or", exprs: exprs}
+}
+
+// Not 逻辑非
+func Not(expr FilterExpr) FilterExpr {
+	return &notFilter{expr: expr}
+}
+
+// ToFilterExpr 将SearchFilters转换为过滤表达式
+//
+// 字符串和数值转换为等值条件，切片转换为in条件；多个字段之间为逻辑与。
+func (f SearchFilters) ToFilterExpr() FilterExpr {
+	if len(f) == 0 {
+		return nil
+	}
+
+	// 按字段名排序，保证生成的表达式稳定
+	keys := make([]string, 0, len(f))
+	for key := range f {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var exprs []FilterExpr
+	for _, key := range keys {
+		switch v := f[key].(type) {
+		case FilterExpr:
+			exprs = append(exprs, v)
+		case []string:
+			values := make([]interface{}, len(v))
+			for i, val := range v {
+				values[i] = val
+			}
+			exprs = append(exprs, In(key, values...))
+		case []interface{}:
+			exprs = append(exprs, In(key, v...))
+		default:
+			exprs = append(exprs, Eq(key, v))
+		}
+	}
+	return And(exprs...)
+}
+
+// ========== 编译 ==========
+
+// filterCompiler 过滤表达式编译器
+type filterCompiler struct {
+	fields map[string]entity.FieldType // 可过滤的标量字段及其类型
+	params map[string]interface{}      // 模板参数
+}
+
+// compileFilter 根据集合模式编译过滤表达式
+//
+// Returns:
+//   - string: Milvus过滤表达式，expr为nil或空条件时为空字符串
+//   - map[string]interface{}: 表达式引用的模板参数
+//   - error: 字段不存在、值类型不匹配等错误
+func compileFilter(collectionSchema *entity.Schema, expr FilterExpr) (string, map[string]interface{}, error) {
+	c := &filterCompiler{
+		fields: make(map[string]entity.FieldType),
+		params: make(map[string]interface{}),
+	}
+	for _, field := range collectionSchema.Fields {
+		switch field.DataType {
+		case entity.FieldTypeBinaryVector, entity.FieldTypeFloatVector, entity.FieldTypeFloat16Vector,
+			entity.FieldTypeBFloat16Vector, entity.FieldTypeSparseVector, entity.FieldTypeInt8Vector:
+			continue
+		}
+		c.fields[field.Name] = field.DataType
+	}
+
+	if expr == nil {
+		return "", c.params, nil
+	}
+	exprStr, err := expr.compile(c)
+	if err != nil {
+		return "", nil, err
+	}
+	return exprStr, c.params, nil
+}
+
+// addParam 注册模板参数，返回表达式中的占位符
+func (c *filterCompiler) addParam(value interface{}) string {
+	name := fmt.Sprintf("p%d", len(c.params))
+	c.params[name] = value
+	return "{" + name + "}"
+}
+
+// fieldType 校验字段名并返回字段类型
+func (c *filterCompiler) fieldType(field string) (entity.FieldType, error) {
+	dataType, ok := c.fields[field]
+	if !ok {
+		return 0, fmt.Errorf("过滤字段不存在或不可过滤: %s", field)
+	}
+	return dataType, nil
+}
+
+// normalizeValue 按字段类型校验并规整过滤值
+func normalizeValue(field string, dataType entity.FieldType, value interface{}) (interface{}, error) {
+	switch dataType {
+	case entity.FieldTypeVarChar, entity.FieldTypeString:
+		if str, ok := value.(string); ok {
+			return str, nil
+		}
+	case entity.FieldTypeInt8, entity.FieldTypeInt16, entity.FieldTypeInt32, entity.FieldTypeInt64:
+		switch v := value.(type) {
+		case int:
+			return int64(v), nil
+		case int32:
+			return int64(v), nil
+		case int64:
+			return v, nil
+		case float64:
+			if v == math.Trunc(v) {
+				return int64(v), nil
+			}
+		}
+	case entity.FieldTypeFloat, entity.FieldTypeDouble:
+		switch v := value.(type) {
+		case int:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
+		case float32:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		}
+	case entity.FieldTypeBool:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("字段 %s 的过滤值类型不匹配: %T", field, value)
+}
+
+// compareFilter 比较条件
+type compareFilter struct {
+	field string
+	op    string
+	value interface{}
+}
+
+func (f *compareFilter) compile(c *filterCompiler) (string, error) {
+	dataType, err := c.fieldType(f.field)
+	if err != nil {
+		return "", err
+	}
+	value, err := normalizeValue(f.field, dataType, f.value)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s %s", f.field, f.op, c.addParam(value)), nil
+}
+
+// inFilter 列表条件
+type inFilter struct {
+	field  string
+	values []interface{}
+	negate bool
+}
+
+func (f *inFilter) compile(c *filterCompiler) (string, error) {
+	dataType, err := c.fieldType(f.field)
+	if err != nil {
+		return "", err
+	}
+	if len(f.values) == 0 {
+		return "", nil
+	}
+
+	// 模板参数要求同类型切片
+	var strValues []string
+	var intValues []int64
+	var floatValues []float64
+	for _, raw := range f.values {
+		value, err := normalizeValue(f.field, dataType, raw)
+		if err != nil {
+			return "", err
+		}
+		switch v := value.(type) {
+		case string:
+			strValues = append(strValues, v)
+		case int64:
+			intValues = append(intValues, v)
+		case float64:
+			floatValues = append(floatValues, v)
+		default:
+			return "", fmt.Errorf("字段 %s 不支持in条件", f.field)
+		}
+	}
+
+	var placeholder string
+	switch {
+	case strValues != nil:
+		placeholder = c.addParam(strValues)
+	case intValues != nil:
+		placeholder = c.addParam(intValues)
+	default:
+		placeholder = c.addParam(floatValues)
+	}
+
+	op := "in"
+	if f.negate {
+		op = "not in"
+	}
+	return fmt.Sprintf("%s %s %s", f.field, op, placeholder), nil
+}
+
+// likeFilter 模式匹配条件
+type likeFilter struct {
+	field   string
+	pattern string
+}
+
+func (f *likeFilter) compile(c *filterCompiler) (string, error) {
+	dataType, err := c.fieldType(f.field)
+	if err != nil {
+		return "", err
+	}
+	if dataType != entity.FieldTypeVarChar && dataType != entity.FieldTypeString {
+		return "", fmt.Errorf("字段 %s 不是字符串类型，不支持like条件", f.field)
+	}
+	return fmt.Sprintf(`%s like "%s"`, f.field, escapeFilterLiteral(f.pattern)), nil
+}
+
+// logicalFilter 逻辑与/或条件
+type logicalFilter struct {
+	op    string
+	exprs []FilterExpr
+}
+
+func (f *logicalFilter) compile(c *filterCompiler) (string, error) {
+	var parts []string
+	for _, expr := range f.exprs {
+		if expr == nil {
+			continue
+		}
+		part, err := expr.compile(c)
+		if err != nil {
+			return "", err
+		}
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	switch len(parts) {
+	case 0:
+		return "", nil
+	case 1:
+		return parts[0], nil
+	default:
+		return "(" + strings.Join(parts, " "+f.op+" ") + ")", nil
+	}
+}
+
+// notFilter 逻辑非条件
+type notFilter struct {
+	expr FilterExpr
+}
+
+func (f *notFilter) compile(c *filterCompiler) (string, error) {
+	if f.expr == nil {
+		return "", nil
+	}
+	part, err := f.expr.compile(c)
+	if err != nil || part == "" {
+		return part, err
+	}
+	return "not (" + part + ")", nil
+}
+
+// escapeFilterLiteral 转义内联到表达式中的字符串字面量
+//
+// like模式中的\%、\_、\\是EscapeLikePattern产生的转义，原样保留；其余反斜杠和引号转义。
+func escapeFilterLiteral(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\':
+			if i+1 < len(value) && strings.IndexByte(`%_\`, value[i+1]) >= 0 {
+				b.WriteByte(c)
+				b.WriteByte(value[i+1])
+				i++
+				continue
+			}
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,162 @@
+package batch_0001
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/milvus-io/milvus/client/v2/entity"
+)
+
+// testFilterSchema 与菜谱集合结构一致的标量字段，外加一个不可过滤的向量字段
+func testFilterSchema() *entity.Schema {
+	return entity.NewSchema().
+		WithField(entity.NewField().WithName("id").WithDataType(entity.FieldTypeVarChar).WithIsPrimaryKey(true)).
+		WithField(entity.NewField().WithName("vector").WithDataType(entity.FieldTypeBinaryVector).WithDim(512)).
+		WithField(entity.NewField().WithName("text").WithDataType(entity.FieldTypeVarChar)).
+		WithField(entity.NewField().WithName("recipe_name").WithDataType(entity.FieldTypeVarChar)).
+		WithField(entity.NewField().WithName("category").WithDataType(entity.FieldTypeVarChar)).
+		WithField(entity.NewField().WithName("cuisine_type").WithDataType(entity.FieldTypeVarChar)).
+		WithField(entity.NewField().WithName("difficulty").WithDataType(entity.FieldTypeInt64)).
+		WithField(entity.NewField().WithName("total_time").WithDataType(entity.FieldTypeInt64))
+}
+
+func TestEscapeLikePattern(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"红烧肉", "红烧肉"},
+		{"50%", `50\%`},
+		{"a_b", `a\_b`},
+		{`a\b`, `a\\b`},
+		{`100%_\`, `100\%\_\\`},
+		{`\%`, `\\\%`},
+	}
+	for _, tt := range tests {
+		if got := EscapeLikePattern(tt.value); got != tt.want {
+			t.Errorf("EscapeLikePattern(%q) = %q，期望 %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeFilterLiteral(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"红烧肉", "红烧肉"},
+		{`他说"好"`, `他说\"好\"`},
+		{`a\b`, `a\\b`},
+		{`结尾\`, `结尾\\`},
+		// EscapeLikePattern产生的转义原样保留
+		{`\%\_\\`, `\%\_\\`},
+		{`"%\"`, `\"%\\\"`},
+	}
+	for _, tt := range tests {
+		if got := escapeFilterLiteral(tt.value); got != tt.want {
+			t.Errorf("escapeFilterLiteral(%q) = %q，期望 %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCompileFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		expr       FilterExpr
+		wantExpr   string
+		wantParams map[string]interface{}
+		wantErr    bool
+	}{
+		{
+			name:       "空条件",
+			expr:       nil,
+			wantParams: map[string]interface{}{},
+		},
+		{
+			name:       "引号通过模板参数传递",
+			expr:       Eq("cuisine_type", `川"菜`),
+			wantExpr:   "cuisine_type == {p0}",
+			wantParams: map[string]interface{}{"p0": `川"菜`},
+		},
+		{
+			name:       "整数字段接受整数值的浮点数",
+			expr:       Eq("difficulty", 2.0),
+			wantExpr:   "difficulty == {p0}",
+			wantParams: map[string]interface{}{"p0": int64(2)},
+		},
+		{
+			name:       "Like保留通配符",
+			expr:       Like("recipe_name", "红烧%"),
+			wantExpr:   `recipe_name like "红烧%"`,
+			wantParams: map[string]interface{}{},
+		},
+		{
+			name:       "Like转义引号和反斜杠",
+			expr:       Like("text", `a\b"`),
+			wantExpr:   `text like "a\\b\""`,
+			wantParams: map[string]interface{}{},
+		},
+		{
+			name:       "Contains中的通配符按字面匹配",
+			expr:       Contains("text", `50%_"辣"\`),
+			wantExpr:   `text like "%50\%\_\"辣\"\\%"`,
+			wantParams: map[string]interface{}{},
+		},
+		{
+			name: "嵌套与或非",
+			expr: And(
+				In("cuisine_type", "川菜", "湘菜"),
+				Or(Range("difficulty", nil, 2), Not(Contains("text", "香菜"))),
+				nil,
+			),
+			wantExpr: `(cuisine_type in {p0} and (difficulty <= {p1} or not (text like "%香菜%")))`,
+			wantParams: map[string]interface{}{
+				"p0": []string{"川菜", "湘菜"},
+				"p1": int64(2),
+			},
+		},
+		{
+			name:     "非包裹与条件",
+			expr:     Not(And(Eq("category", "荤菜"), NotIn("total_time", 30, 60))),
+			wantExpr: "not ((category == {p0} and total_time not in {p1}))",
+			wantParams: map[string]interface{}{
+				"p0": "荤菜",
+				"p1": []int64{30, 60},
+			},
+		},
+		{
+			name:       "空的与或非条件",
+			expr:       Or(And(), Not(nil), In("category")),
+			wantParams: map[string]interface{}{},
+		},
+		{name: "字段不存在", expr: Eq("cuisine", "川菜"), wantErr: true},
+		{name: "向量字段不可过滤", expr: Eq("vector", "x"), wantErr: true},
+		{name: "整数字段传入字符串", expr: Eq("difficulty", "2"), wantErr: true},
+		{name: "整数字段传入小数", expr: Lte("total_time", 2.5), wantErr: true},
+		{name: "字符串字段传入整数", expr: Eq("category", 1), wantErr: true},
+		{name: "in列表类型不一致", expr: In("cuisine_type", "川菜", 1), wantErr: true},
+		{name: "非字符串字段不支持like", expr: Like("difficulty", "1%"), wantErr: true},
+		{name: "嵌套条件中的错误字段", expr: Or(Eq("category", "荤菜"), Not(Eq("unknown", 1))), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, params, err := compileFilter(testFilterSchema(), tt.expr)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("期望编译失败，得到 %q", expr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("compileFilter: %v", err)
+			}
+			if expr != tt.wantExpr {
+				t.Errorf("表达式 = %q，期望 %q", expr, tt.wantExpr)
+			}
+			if !reflect.DeepEqual(params, tt.wantParams) {
+				t.Errorf("模板参数 = %#v，期望 %#v", params, tt.wantParams)
+			}
+		})
+	}
+}
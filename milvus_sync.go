@@ -0,0 +1,305 @@
+package batch_0001
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/milvus-io/milvus/client/v2/milvusclient"
+)
+
+// 增量同步相关参数
+const (
+	syncQueryPageSize = 1000 // 分页读取已有文档块时的每页数量
+	deleteBatchSize   = 500  // 按主键删除时的每批数量
+)
+
+// hashedMetadataFields 参与内容指纹计算的元数据字段（即写入Milvus的标量字段）
+var hashedMetadataFields = []string{
+	"node_id", "recipe_name", "node_type", "category", "cuisine_type",
+	"difficulty", "total_time", "doc_type", "chunk_id", "parent_id",
+}
+
+// VectorSyncReport 向量索引增量同步报告
+type VectorSyncReport struct {
+	Added     int `json:"added"`     // 新增的文档块数
+	Updated   int `json:"updated"`   // 内容变化、重新向量化的文档块数
+	Unchanged int `json:"unchanged"` // 未变化、跳过的文档块数
+	Deleted   int `json:"deleted"`   // 已不存在、被删除的文档块数
+}
+
+// String 同步报告的可读描述
+func (r *VectorSyncReport) String() string {
+	return fmt.Sprintf("新增 %d，更新 %d，未变化 %d，删除 %d", r.Added, r.Updated, r.Unchanged, r.Deleted)
+}
+
+// DocumentContentHash 计算文档块的内容指纹
+//
+// 指纹覆盖文本内容和写入Milvus的标量元数据，任一变化都会触发重新写入。
+func DocumentContentHash(doc *schema.Document) string {
+	h := sha256.New()
+	h.Write([]byte(doc.Content))
+	for _, key := range hashedMetadataFields {
+		h.Write([]byte{0})
+		h.Write([]byte(key))
+		h.Write([]byte{'='})
+		if value, ok := doc.MetaData[key]; ok && value != nil {
+			h.Write([]byte(fmt.Sprintf("%v", value)))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// UpsertDocuments 按文档块ID覆盖写入文档
+//
+// 已存在的文档块被替换，不存在的被新增；只对传入的文档块生成向量。
+func (m *MilvusIndexConstructionModule) UpsertDocuments(ctx context.Context, chunks []*schema.Document) error {
+	if !m.collectionCreated {
+		return fmt.Errorf("请先构建或加载向量索引")
+	}
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	if err := m.setupEmbeddings(ctx); err != nil {
+		return err
+	}
+
+	for i := 0; i < len(chunks); i += BatchSize {
+		end := min(i+BatchSize, len(chunks))
+		if err := m.writeSingleBatch(ctx, chunks[i:end], true); err != nil {
+			return fmt.Errorf("批量更新失败 [%d-%d]: %w", i, end-1, err)
+		}
+		log.Printf("已更新 %d/%d 条数据", end, len(chunks))
+	}
+	return nil
+}
+
+// DeleteByIDs 按文档块ID删除
+//
+// Returns:
+//   - int64: 删除的记录数
+//   - error: 删除失败时返回错误信息
+func (m *MilvusIndexConstructionModule) DeleteByIDs(ctx context.Context, ids []string) (int64, error) {
+	if !m.collectionCreated {
+		return 0, fmt.Errorf("请先构建或加载向量索引")
+	}
+
+	var deleted int64
+	for i := 0; i < len(ids); i += deleteBatchSize {
+		end := min(i+deleteBatchSize, len(ids))
+		result, err := m.client.Delete(ctx, milvusclient.NewDeleteOption(m.collectionName).WithStringIDs("id", ids[i:end]))
+		if err != nil {
+			return deleted, fmt.Errorf("删除文档块失败: %w", err)
+		}
+		deleted += result.DeleteCount
+	}
+	return deleted, nil
+}
+
+// DeleteByNodeIDs 删除属于指定图节点的全部文档块（按node_id或parent_id匹配）
+//
+// 删除接口不支持模板参数，这里先用过滤表达式查出主键再按主键删除，避免拼接原始值。
+//
+// Returns:
+//   - int64: 删除的记录数
+//   - error: 删除失败时返回错误信息
+func (m *MilvusIndexConstructionModule) DeleteByNodeIDs(ctx context.Context, nodeIDs []string) (int64, error) {
+	if len(nodeIDs) == 0 {
+		return 0, nil
+	}
+
+	values := stringsToValues(nodeIDs)
+	entries, err := m.queryChunkHashes(ctx, Or(In("node_id", values...), In("parent_id", values...)))
+	if err != nil {
+		return 0, err
+	}
+
+	ids := make([]string, 0, len(entries))
+	for id := range entries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	deleted, err := m.DeleteByIDs(ctx, ids)
+	if err != nil {
+		return deleted, err
+	}
+	log.Printf("已删除 %d 个节点的 %d 个文档块", len(nodeIDs), deleted)
+	return deleted, nil
+}
+
+// SyncDocuments 基于内容指纹的增量同步
+//
+// 将集合内容同步为给定的文档块集合：
+// - 新增或内容指纹变化的文档块：重新向量化并upsert
+// - 指纹未变化的文档块：跳过，不调用embedding
+// - 集合中存在但不在给定集合中的文档块：删除
+func (m *MilvusIndexConstructionModule) SyncDocuments(ctx context.Context, chunks []*schema.Document) (*VectorSyncReport, error) {
+	if !m.collectionCreated {
+		return nil, fmt.Errorf("请先构建或加载向量索引")
+	}
+
+	existing, err := m.queryChunkHashes(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := m.applyChunkDiff(ctx, existing, chunks)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("向量索引增量同步完成: %s", report)
+	return report, nil
+}
+
+// SyncNodeDocuments 只同步属于指定图节点的文档块
+//
+// 与SyncDocuments相同的比对逻辑，但范围限定在node_id或parent_id属于nodeIDs的文档块：
+// chunks为这些节点重新生成的全部文档块；节点已删除时不传对应文档块，其旧文档块会被删除。
+func (m *MilvusIndexConstructionModule) SyncNodeDocuments(ctx context.Context, nodeIDs []string, chunks []*schema.Document) (*VectorSyncReport, error) {
+	if !m.collectionCreated {
+		return nil, fmt.Errorf("请先构建或加载向量索引")
+	}
+	if len(nodeIDs) == 0 {
+		return &VectorSyncReport{}, nil
+	}
+
+	values := stringsToValues(nodeIDs)
+	existing, err := m.queryChunkHashes(ctx, Or(In("node_id", values...), In("parent_id", values...)))
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := m.applyChunkDiff(ctx, existing, chunks)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("%d 个节点的向量索引同步完成: %s", len(nodeIDs), report)
+	return report, nil
+}
+
+// applyChunkDiff 比对已有文档块指纹与目标文档块，写入变化的文档块并删除多余的文档块
+func (m *MilvusIndexConstructionModule) applyChunkDiff(ctx context.Context, existing map[string]string, chunks []*schema.Document) (*VectorSyncReport, error) {
+	report := &VectorSyncReport{}
+	var changed []*schema.Document
+	seen := make(map[string]bool)
+	for _, chunk := range chunks {
+		id := m.safeTruncate(chunk.ID, 150)
+		seen[id] = true
+
+		oldHash, exists := existing[id]
+		switch {
+		case !exists:
+			report.Added++
+			changed = append(changed, chunk)
+		case oldHash != DocumentContentHash(chunk):
+			report.Updated++
+			changed = append(changed, chunk)
+		default:
+			report.Unchanged++
+		}
+	}
+
+	var staleIDs []string
+	for id := range existing {
+		if !seen[id] {
+			staleIDs = append(staleIDs, id)
+		}
+	}
+	sort.Strings(staleIDs)
+
+	if err := m.UpsertDocuments(ctx, changed); err != nil {
+		return nil, err
+	}
+	if len(staleIDs) > 0 {
+		if _, err := m.DeleteByIDs(ctx, staleIDs); err != nil {
+			return nil, err
+		}
+	}
+	report.Deleted = len(staleIDs)
+	return report, nil
+}
+
+// collectionSchemaCompatible 检查已有集合是否包含当前模式的全部字段
+func (m *MilvusIndexConstructionModule) collectionSchemaCompatible(ctx context.Context) (bool, error) {
+	exists, err := m.HasCollection(ctx)
+	if err != nil || !exists {
+		return false, err
+	}
+
+	missing, err := m.missingCollectionFields(ctx)
+	if err != nil {
+		return false, err
+	}
+	if len(missing) > 0 {
+		log.Printf("集合 %s 缺少字段: %s", m.collectionName, strings.Join(missing, ", "))
+		return false, nil
+	}
+	return true, nil
+}
+
+// queryChunkHashes 分页读取满足过滤条件的文档块ID及其内容指纹
+//
+// Milvus限制offset+limit不超过16384，这里按主键游标翻页：每页取 id > 上一页最大ID 的记录，
+// Query结果按主键升序返回，因此不会遗漏或重复。
+func (m *MilvusIndexConstructionModule) queryChunkHashes(ctx context.Context, filter FilterExpr) (map[string]string, error) {
+	collectionSchema, err := m.collectionSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make(map[string]string)
+	cursor := ""
+	for {
+		// 首页的 id > "" 同时满足Query必须带过滤条件的要求
+		pageFilter := Gt("id", cursor)
+		if filter != nil {
+			pageFilter = And(filter, pageFilter)
+		}
+		filterExpr, filterParams, err := compileFilter(collectionSchema, pageFilter)
+		if err != nil {
+			return nil, fmt.Errorf("过滤条件不合法: %w", err)
+		}
+
+		option := milvusclient.NewQueryOption(m.collectionName).
+			WithFilter(filterExpr).
+			WithOutputFields("id", "content_hash").
+			WithLimit(syncQueryPageSize)
+		for key, value := range filterParams {
+			option.WithTemplateParam(key, value)
+		}
+
+		resultSet, err := m.client.Query(ctx, option)
+		if err != nil {
+			return nil, fmt.Errorf("查询已有文档块失败: %w", err)
+		}
+
+		idCol := resultSet.GetColumn("id")
+		hashCol := resultSet.GetColumn("content_hash")
+		if idCol == nil || hashCol == nil {
+			break
+		}
+		for i := 0; i < idCol.Len(); i++ {
+			id, err := idCol.GetAsString(i)
+			if err != nil {
+				return nil, fmt.Errorf("读取文档块ID失败: %w", err)
+			}
+			hash, _ := hashCol.GetAsString(i)
+			hashes[id] = hash
+			if id > cursor {
+				cursor = id
+			}
+		}
+
+		if idCol.Len() < syncQueryPageSize {
+			break
+		}
+	}
+	return hashes, nil
+}
@@ -2,10 +2,14 @@ package batch_0001
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/cloudwego/eino/schema"
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
@@ -35,12 +39,25 @@ type GraphDataPreparationModule struct {
 	// 数据库驱动
 	Driver neo4j.DriverWithContext `json:"-"`
 
+	// CSV模式：不连接Neo4j，从节点和关系CSV文件加载图数据
+	csvNodesPath         string
+	csvRelationshipsPath string
+	csvGraph             *CSVGraph
+
+	// 按需生成的父文档缓存，查询路径只写这里，不修改Documents
+	parentMu    sync.Mutex
+	parentCache map[string]*schema.Document
+
 	// 数据存储容器 - 使用 Eino Schema.Document
 	Documents    []*schema.Document `json:"documents"`     // 完整文档列表
 	Chunks       []*schema.Document `json:"chunks"`        // 分块文档列表
 	Recipes      []GraphNode        `json:"recipes"`       // 菜谱实体列表
 	Ingredients  []GraphNode        `json:"ingredients"`   // 食材实体列表
 	CookingSteps []GraphNode        `json:"cooking_steps"` // 烹饪步骤实体列表
+
+	// 分块配置
+	ChunkUnit     ChunkUnit `json:"chunk_unit"`     // 块大小的计量单位，默认按字符
+	ChunkStrategy string    `json:"chunk_strategy"` // 分块策略，默认按章节分块
 }
 
 func NewGraphDataPreparationModule(uri, user, password, database string) (*GraphDataPreparationModule, error) {
@@ -50,15 +67,17 @@ func NewGraphDataPreparationModule(uri, user, password, database string) (*Graph
 	}
 
 	module := &GraphDataPreparationModule{
-		URI:          uri,
-		User:         user,
-		Password:     password,
-		Database:     database,
-		Documents:    make([]*schema.Document, 0),
-		Chunks:       make([]*schema.Document, 0),
-		Recipes:      make([]GraphNode, 0),
-		Ingredients:  make([]GraphNode, 0),
-		CookingSteps: make([]GraphNode, 0),
+		URI:           uri,
+		User:          user,
+		Password:      password,
+		Database:      database,
+		Documents:     make([]*schema.Document, 0),
+		Chunks:        make([]*schema.Document, 0),
+		Recipes:       make([]GraphNode, 0),
+		Ingredients:   make([]GraphNode, 0),
+		CookingSteps:  make([]GraphNode, 0),
+		ChunkUnit:     ChunkUnitRune,
+		ChunkStrategy: ChunkerSection,
 	}
 
 	// 建立数据库连接
@@ -114,6 +133,10 @@ func (g *GraphDataPreparationModule) Close() error {
 }
 
 func (g *GraphDataPreparationModule) LoadGraphData() (map[string]interface{}, error) {
+	if g.csvNodesPath != "" {
+		return g.loadGraphDataFromCSV()
+	}
+
 	log.Println("正在从Neo4j加载图数据...")
 
 	ctx := context.Background()
@@ -321,285 +344,613 @@ func (g *GraphDataPreparationModule) LoadGraphData() (map[string]interface{}, er
 	}, nil
 }
 
-func (g *GraphDataPreparationModule) BuildRecipeDocuments() ([]*schema.Document, error) {
-	log.Println("正在构建菜谱文档...")
+// 菜谱文档批量构建参数
+const (
+	recipeDocumentBatchSize  = 50                     // 每次查询的菜谱数量
+	recipeDocumentWorkers    = 4                      // 并发查询的批次数
+	recipeDocumentRetries    = 3                      // 批次查询失败时的最多尝试次数
+	recipeDocumentRetryDelay = 500 * time.Millisecond // 重试间隔，按尝试次数递增
+)
 
-	ctx := context.Background()
-	session := g.Driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: g.Database})
-	defer session.Close(ctx)
+// RecipeDocumentsError 部分菜谱批次重试后仍查询失败，这些菜谱没有生成文档
+type RecipeDocumentsError struct {
+	FailedIDs []string // 未生成文档的菜谱ID
+	Err       error    // 各失败批次的错误
+}
 
-	var documents []*schema.Document
+// Error 错误描述
+func (e *RecipeDocumentsError) Error() string {
+	return fmt.Sprintf("%d 个菜谱文档生成失败: %v", len(e.FailedIDs), e.Err)
+}
 
-	// 遍历所有已加载的菜谱实体，为每个菜谱构建完整文档
-	for _, recipe := range g.Recipes {
-		recipeID := recipe.NodeID
-		recipeName := recipe.Name
-
-		// 第一步：获取菜谱的相关食材信息
-		// 通过REQUIRES关系查询菜谱所需的所有食材，包括用量信息
-		ingredientsQuery := `
-			MATCH (r:Recipe {nodeId: $recipe_id})-[req:REQUIRES]->(i:Ingredient)
-			RETURN i.name as name, i.category as category, 
-			       req.amount as amount, req.unit as unit,
-			       i.description as description
-			ORDER BY i.name
-		`
-
-		ingredientsResult, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
-			result, err := tx.Run(ctx, ingredientsQuery, map[string]interface{}{
-				"recipe_id": recipeID,
-			})
-			if err != nil {
-				return nil, err
-			}
+// Unwrap 返回各失败批次的错误
+func (e *RecipeDocumentsError) Unwrap() error {
+	return e.Err
+}
 
-			var ingredientsInfo []string
-			for result.Next(ctx) {
-				record := result.Record()
+// recipeDetailsQuery 按菜谱ID批量查询食材（按名称排序）和步骤（按顺序排序）
+const recipeDetailsQuery = `
+	UNWIND $recipe_ids AS recipeId
+	MATCH (r:Recipe {nodeId: recipeId})
+	CALL {
+		WITH r
+		OPTIONAL MATCH (r)-[req:REQUIRES]->(i:Ingredient)
+		WITH i, req
+		ORDER BY i.name
+		RETURN collect(CASE WHEN i IS NULL THEN NULL ELSE {
+			name: i.name, amount: req.amount, unit: req.unit, description: i.description
+		} END) as ingredients
+	}
+	CALL {
+		WITH r
+		OPTIONAL MATCH (r)-[c:CONTAINS_STEP]->(s:CookingStep)
+		WITH s, c
+		ORDER BY COALESCE(c.stepOrder, s.stepNumber, 999)
+		RETURN collect(CASE WHEN s IS NULL THEN NULL ELSE {
+			name: s.name, description: s.description, methods: s.methods,
+			tools: s.tools, timeEstimate: s.timeEstimate
+		} END) as steps
+	}
+	RETURN r.nodeId as nodeId, ingredients, steps
+`
 
-				name, _ := record.Get("name")
-				amount, _ := record.Get("amount")
-				unit, _ := record.Get("unit")
-				description, _ := record.Get("description")
+// recipeDetails 菜谱的食材和步骤描述文本
+type recipeDetails struct {
+	ingredients []string
+	steps       []string
+}
 
-				// 构建食材描述文本：名称 + 用量 + 描述
-				ingredientText := fmt.Sprintf("%v", name)
+// BuildRecipeDocuments 构建菜谱文档
+//
+// 不传菜谱ID时为全部已加载的菜谱构建文档，并替换文档列表；
+// 传入菜谱ID时只重新生成这些菜谱的文档（用于增量同步），合并到已有文档列表中，
+// 同时移除已不在菜谱列表中的菜谱文档。
+//
+// 菜谱按批次用一次UNWIND查询取回食材和步骤，多个批次由有限数量的协程并发查询，
+// 避免逐个菜谱查询带来的大量往返。
+//
+// Returns:
+//   - []*schema.Document: 本次构建的文档，部分批次失败时为其余菜谱的文档
+//   - error: 有批次重试后仍失败时返回*RecipeDocumentsError，列出未生成文档的菜谱ID
+func (g *GraphDataPreparationModule) BuildRecipeDocuments(recipeIDs ...string) ([]*schema.Document, error) {
+	log.Println("正在构建菜谱文档...")
 
-				// 添加用量信息（如果有）
-				if amount != nil && unit != nil {
-					amountStr := fmt.Sprintf("%v", amount)
-					unitStr := fmt.Sprintf("%v", unit)
-					if amountStr != "" && unitStr != "" {
-						ingredientText += fmt.Sprintf("(%s%s)", amountStr, unitStr)
-					}
-				}
+	recipes := g.Recipes
+	if len(recipeIDs) > 0 {
+		wanted := make(map[string]bool, len(recipeIDs))
+		for _, id := range recipeIDs {
+			wanted[id] = true
+		}
+		recipes = nil
+		for _, recipe := range g.Recipes {
+			if wanted[recipe.NodeID] {
+				recipes = append(recipes, recipe)
+			}
+		}
+	}
 
-				// 添加食材描述（如果有）
-				if description != nil {
-					descStr := fmt.Sprintf("%v", description)
-					if descStr != "" {
-						ingredientText += fmt.Sprintf(" - %s", descStr)
-					}
-				}
+	documents, err := g.buildDocuments(recipes)
 
-				ingredientsInfo = append(ingredientsInfo, ingredientText)
-			}
+	// 文档列表重新生成后，按需生成的父文档缓存可能过期
+	g.parentMu.Lock()
+	g.parentCache = nil
+	g.parentMu.Unlock()
 
-			return ingredientsInfo, result.Err()
-		})
+	if len(recipeIDs) == 0 {
+		g.Documents = documents
+		log.Printf("成功构建 %d 个菜谱文档", len(documents))
+	} else {
+		g.mergeRecipeDocuments(documents)
+		log.Printf("成功重新生成 %d 个菜谱文档", len(documents))
+	}
+	if err != nil {
+		log.Printf("菜谱文档构建不完整: %v", err)
+	}
+	return documents, err
+}
 
-		if err != nil {
-			log.Printf("获取菜谱食材失败 %s (ID: %s): %v", recipeName, recipeID, err)
+// buildDocuments 按菜谱列表顺序为每个菜谱构建完整文档，不修改模块状态
+func (g *GraphDataPreparationModule) buildDocuments(recipes []GraphNode) ([]*schema.Document, error) {
+	details, err := g.loadRecipeDetails(context.Background(), recipes)
+
+	var documents []*schema.Document
+	for _, recipe := range recipes {
+		detail, ok := details[recipe.NodeID]
+		if !ok {
 			continue
 		}
+		documents = append(documents, buildRecipeDocument(recipe, detail.ingredients, detail.steps))
+	}
+	return documents, err
+}
 
-		ingredientsInfo := ingredientsResult.([]string)
+// loadRecipeDetails 分批并发查询菜谱的食材和步骤
+//
+// 查询失败的批次按递增间隔重试，重试后仍失败的批次不出现在返回结果中，
+// 其菜谱ID汇总到*RecipeDocumentsError。CSV模式下直接从内存图生成。
+func (g *GraphDataPreparationModule) loadRecipeDetails(ctx context.Context, recipes []GraphNode) (map[string]*recipeDetails, error) {
+	if g.csvGraph != nil {
+		return g.csvGraph.recipeDetails(recipes), nil
+	}
+
+	details := make(map[string]*recipeDetails, len(recipes))
+	var failedIDs []string
+	var errs []error
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, recipeDocumentWorkers)
+
+	for start := 0; start < len(recipes); start += recipeDocumentBatchSize {
+		end := min(start+recipeDocumentBatchSize, len(recipes))
+		ids := make([]string, 0, end-start)
+		for _, recipe := range recipes[start:end] {
+			ids = append(ids, recipe.NodeID)
+		}
 
-		// 第二步：获取菜谱的烹饪步骤信息
-		// 通过CONTAINS_STEP关系查询菜谱的所有制作步骤，按顺序排列
-		stepsQuery := `
-			MATCH (r:Recipe {nodeId: $recipe_id})-[c:CONTAINS_STEP]->(s:CookingStep)
-			RETURN s.name as name, s.description as description,
-			       s.stepNumber as stepNumber, s.methods as methods,
-			       s.tools as tools, s.timeEstimate as timeEstimate,
-			       c.stepOrder as stepOrder
-			ORDER BY COALESCE(c.stepOrder, s.stepNumber, 999)
-		`
+		wg.Add(1)
+		go func(ids []string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
 
-		stepsResult, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
-			result, err := tx.Run(ctx, stepsQuery, map[string]interface{}{
-				"recipe_id": recipeID,
-			})
+			batch, err := g.queryRecipeDetailsWithRetry(ctx, ids)
+
+			mu.Lock()
+			defer mu.Unlock()
 			if err != nil {
-				return nil, err
+				failedIDs = append(failedIDs, ids...)
+				errs = append(errs, fmt.Errorf("菜谱 %s 等 %d 个: %w", ids[0], len(ids), err))
+				return
 			}
+			for id, detail := range batch {
+				details[id] = detail
+			}
+		}(ids)
+	}
 
-			var stepsInfo []string
-			for result.Next(ctx) {
-				record := result.Record()
-
-				name, _ := record.Get("name")
-				description, _ := record.Get("description")
-				methods, _ := record.Get("methods")
-				tools, _ := record.Get("tools")
-				timeEstimate, _ := record.Get("timeEstimate")
+	wg.Wait()
+	if len(failedIDs) > 0 {
+		sort.Strings(failedIDs)
+		return details, &RecipeDocumentsError{FailedIDs: failedIDs, Err: errors.Join(errs...)}
+	}
+	return details, nil
+}
 
-				// 构建步骤描述文本：包含步骤名称、详细描述、方法、工具、时间等
-				stepText := fmt.Sprintf("步骤: %v", name)
+// queryRecipeDetailsWithRetry 查询一批菜谱的食材和步骤，失败时按递增间隔重试
+func (g *GraphDataPreparationModule) queryRecipeDetailsWithRetry(ctx context.Context, recipeIDs []string) (map[string]*recipeDetails, error) {
+	var err error
+	for attempt := 1; attempt <= recipeDocumentRetries; attempt++ {
+		var batch map[string]*recipeDetails
+		batch, err = g.queryRecipeDetails(ctx, recipeIDs)
+		if err == nil {
+			return batch, nil
+		}
+		log.Printf("获取菜谱食材和步骤失败 (%s 等 %d 个菜谱，第%d次): %v", recipeIDs[0], len(recipeIDs), attempt, err)
+		if attempt == recipeDocumentRetries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Duration(attempt) * recipeDocumentRetryDelay):
+		}
+	}
+	return nil, err
+}
 
-				// 添加详细描述（如果有）
-				if description != nil {
-					descStr := fmt.Sprintf("%v", description)
-					if descStr != "" {
-						stepText += fmt.Sprintf("\n描述: %s", descStr)
-					}
-				}
+// queryRecipeDetails 查询一批菜谱的食材和步骤描述文本
+func (g *GraphDataPreparationModule) queryRecipeDetails(ctx context.Context, recipeIDs []string) (map[string]*recipeDetails, error) {
+	// 会话不是并发安全的，每个批次使用独立会话
+	session := g.Driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: g.Database})
+	defer session.Close(ctx)
 
-				// 添加烹饪方法（如果有）
-				if methods != nil {
-					methodsStr := fmt.Sprintf("%v", methods)
-					if methodsStr != "" {
-						stepText += fmt.Sprintf("\n方法: %s", methodsStr)
-					}
-				}
+	result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
+		result, err := tx.Run(ctx, recipeDetailsQuery, map[string]interface{}{
+			"recipe_ids": recipeIDs,
+		})
+		if err != nil {
+			return nil, err
+		}
 
-				// 添加所需工具（如果有）
-				if tools != nil {
-					toolsStr := fmt.Sprintf("%v", tools)
-					if toolsStr != "" {
-						stepText += fmt.Sprintf("\n工具: %s", toolsStr)
-					}
-				}
+		details := make(map[string]*recipeDetails, len(recipeIDs))
+		for result.Next(ctx) {
+			record := result.Record()
 
-				// 添加时间估计（如果有）
-				if timeEstimate != nil {
-					timeStr := fmt.Sprintf("%v", timeEstimate)
-					if timeStr != "" {
-						stepText += fmt.Sprintf("\n时间: %s", timeStr)
-					}
-				}
+			nodeID, _ := record.Get("nodeId")
+			ingredients, _ := record.Get("ingredients")
+			steps, _ := record.Get("steps")
 
-				stepsInfo = append(stepsInfo, stepText)
+			detail := &recipeDetails{}
+			for _, entry := range recordMaps(ingredients) {
+				detail.ingredients = append(detail.ingredients, formatIngredientText(entry))
+			}
+			for _, entry := range recordMaps(steps) {
+				detail.steps = append(detail.steps, formatStepText(entry))
 			}
+			details[fmt.Sprintf("%v", nodeID)] = detail
+		}
 
-			return stepsInfo, result.Err()
-		})
+		return details, result.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(map[string]*recipeDetails), nil
+}
 
+// nodesByLabelQuery 查询指定标签的实例节点，派生节点（分类等）没有nodeId，按"标签:名称"标识
+const nodesByLabelQuery = `
+	MATCH (n)
+	WHERE $label IN labels(n) AND (n.nodeId IS NULL OR n.nodeId >= '200000000')
+	RETURN n
+	ORDER BY coalesce(n.nodeId, n.name)
+`
+
+// relationshipsByTypeQuery 查询指定类型的关系，端点标识与nodesByLabelQuery一致
+const relationshipsByTypeQuery = `
+	MATCH (a)-[rel]->(b)
+	WHERE type(rel) IN $types
+	RETURN coalesce(a.nodeId, labels(a)[0] + ':' + a.name) AS source,
+	       type(rel) AS type,
+	       coalesce(b.nodeId, labels(b)[0] + ':' + b.name) AS target
+	ORDER BY source, type, target
+`
+
+// NodesByLabel 获取指定标签的节点（不含层次结构节点）
+//
+// 菜谱、食材、烹饪步骤返回LoadGraphData已加载的节点，其余标签按需查询。
+func (g *GraphDataPreparationModule) NodesByLabel(ctx context.Context, label string) ([]GraphNode, error) {
+	switch label {
+	case "Recipe":
+		return g.Recipes, nil
+	case "Ingredient":
+		return g.Ingredients, nil
+	case "CookingStep":
+		return g.CookingSteps, nil
+	}
+	if g.csvGraph != nil {
+		return g.csvGraph.NodesByLabel(label), nil
+	}
+
+	session := g.Driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: g.Database})
+	defer session.Close(ctx)
+
+	result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
+		result, err := tx.Run(ctx, nodesByLabelQuery, map[string]interface{}{"label": label})
 		if err != nil {
-			log.Printf("获取菜谱步骤失败 %s (ID: %s): %v", recipeName, recipeID, err)
+			return nil, err
+		}
+		return result.Collect(ctx)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("查询%s节点失败: %w", label, err)
+	}
+
+	var nodes []GraphNode
+	for _, record := range result.([]*neo4j.Record) {
+		value, _ := record.Get("n")
+		neo4jNode, ok := value.(neo4j.Node)
+		if !ok {
 			continue
 		}
+		node := graphNodeFromNeo4j(neo4jNode)
+		if node.NodeID == "" {
+			node.NodeID = derivedNodeID(label, node.Name)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
 
-		stepsInfo := stepsResult.([]string)
+// RelationshipsByType 获取指定类型的全部关系
+func (g *GraphDataPreparationModule) RelationshipsByType(ctx context.Context, relationTypes []string) ([]*Relationship, error) {
+	var relationships []*Relationship
+	if g.csvGraph != nil {
+		for _, relation := range g.csvGraph.Relations {
+			if containsString(relationTypes, relation.RelationType) {
+				relationships = append(relationships, &Relationship{
+					SourceID:     relation.StartNodeID,
+					RelationType: relation.RelationType,
+					TargetID:     relation.EndNodeID,
+				})
+			}
+		}
+		return relationships, nil
+	}
 
-		// 第三步：构建完整的菜谱文档内容
-		// 使用Markdown格式，便于阅读和后续处理
-		var contentParts []string
-		contentParts = append(contentParts, fmt.Sprintf("# %s", recipeName))
+	session := g.Driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: g.Database})
+	defer session.Close(ctx)
 
-		// 添加菜谱基本信息部分
-		if description, ok := recipe.Properties["description"]; ok && description != nil {
-			descStr := fmt.Sprintf("%v", description)
-			if descStr != "" {
-				contentParts = append(contentParts, fmt.Sprintf("\n## 菜品描述\n%s", descStr))
-			}
+	result, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
+		result, err := tx.Run(ctx, relationshipsByTypeQuery, map[string]interface{}{"types": relationTypes})
+		if err != nil {
+			return nil, err
 		}
+		return result.Collect(ctx)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("查询关系失败: %w", err)
+	}
 
-		// 添加菜系信息
-		if cuisineType, ok := recipe.Properties["cuisineType"]; ok && cuisineType != nil {
-			cuisineStr := fmt.Sprintf("%v", cuisineType)
-			if cuisineStr != "" {
-				contentParts = append(contentParts, fmt.Sprintf("\n菜系: %s", cuisineStr))
-			}
+	for _, record := range result.([]*neo4j.Record) {
+		source, _ := record.Get("source")
+		relationType, _ := record.Get("type")
+		target, _ := record.Get("target")
+		relationships = append(relationships, &Relationship{
+			SourceID:     fmt.Sprintf("%v", source),
+			RelationType: fmt.Sprintf("%v", relationType),
+			TargetID:     fmt.Sprintf("%v", target),
+		})
+	}
+	return relationships, nil
+}
+
+// recordMaps 将查询返回的映射列表转换为[]map[string]interface{}
+func recordMaps(value interface{}) []map[string]interface{} {
+	items, _ := value.([]interface{})
+	maps := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			maps = append(maps, m)
 		}
+	}
+	return maps
+}
 
-		// 添加难度信息
-		if difficulty, ok := recipe.Properties["difficulty"]; ok && difficulty != nil {
-			difficultyStr := fmt.Sprintf("%v", difficulty)
-			if difficultyStr != "" {
-				contentParts = append(contentParts, fmt.Sprintf("难度: %s星", difficultyStr))
-			}
+// formatIngredientText 构建食材描述文本：名称 + 用量 + 描述
+func formatIngredientText(entry map[string]interface{}) string {
+	ingredientText := fmt.Sprintf("%v", entry["name"])
+
+	// 添加用量信息（如果有）
+	amount, unit := entry["amount"], entry["unit"]
+	if amount != nil && unit != nil {
+		amountStr := fmt.Sprintf("%v", amount)
+		unitStr := fmt.Sprintf("%v", unit)
+		if amountStr != "" && unitStr != "" {
+			ingredientText += fmt.Sprintf("(%s%s)", amountStr, unitStr)
 		}
+	}
 
-		// 添加时间信息（准备时间和烹饪时间）
-		var timeInfo []string
-		if prepTime, ok := recipe.Properties["prepTime"]; ok && prepTime != nil {
-			prepTimeStr := fmt.Sprintf("%v", prepTime)
-			if prepTimeStr != "" {
-				timeInfo = append(timeInfo, fmt.Sprintf("准备时间: %s", prepTimeStr))
-			}
+	// 添加食材描述（如果有）
+	if description := entry["description"]; description != nil {
+		descStr := fmt.Sprintf("%v", description)
+		if descStr != "" {
+			ingredientText += fmt.Sprintf(" - %s", descStr)
 		}
-		if cookTime, ok := recipe.Properties["cookTime"]; ok && cookTime != nil {
-			cookTimeStr := fmt.Sprintf("%v", cookTime)
-			if cookTimeStr != "" {
-				timeInfo = append(timeInfo, fmt.Sprintf("烹饪时间: %s", cookTimeStr))
-			}
+	}
+
+	return ingredientText
+}
+
+// formatStepText 构建步骤描述文本：包含步骤名称、详细描述、方法、工具、时间等
+func formatStepText(entry map[string]interface{}) string {
+	stepText := fmt.Sprintf("步骤: %v", entry["name"])
+
+	fields := []struct {
+		key   string
+		label string
+	}{
+		{"description", "描述"},  // 详细描述
+		{"methods", "方法"},      // 烹饪方法
+		{"tools", "工具"},        // 所需工具
+		{"timeEstimate", "时间"}, // 时间估计
+	}
+	for _, field := range fields {
+		value := entry[field.key]
+		if value == nil {
+			continue
 		}
-		if len(timeInfo) > 0 {
-			contentParts = append(contentParts, fmt.Sprintf("\n时间信息: %s", strings.Join(timeInfo, ", ")))
+		if valueStr := fmt.Sprintf("%v", value); valueStr != "" {
+			stepText += fmt.Sprintf("\n%s: %s", field.label, valueStr)
 		}
+	}
 
-		// 添加份量信息
-		if servings, ok := recipe.Properties["servings"]; ok && servings != nil {
-			servingsStr := fmt.Sprintf("%v", servings)
-			if servingsStr != "" {
-				contentParts = append(contentParts, fmt.Sprintf("份量: %s", servingsStr))
-			}
-		}
+	return stepText
+}
 
-		// 添加食材清单部分
-		if len(ingredientsInfo) > 0 {
-			contentParts = append(contentParts, "\n## 所需食材")
-			for i, ingredient := range ingredientsInfo {
-				contentParts = append(contentParts, fmt.Sprintf("%d. %s", i+1, ingredient))
-			}
+// buildRecipeDocument 由菜谱属性、食材和步骤描述构建完整的菜谱文档
+func buildRecipeDocument(recipe GraphNode, ingredientsInfo, stepsInfo []string) *schema.Document {
+	recipeID := recipe.NodeID
+	recipeName := recipe.Name
+
+	// 构建完整的菜谱文档内容
+	// 使用Markdown格式，便于阅读和后续处理
+	var contentParts []string
+	contentParts = append(contentParts, fmt.Sprintf("# %s", recipeName))
+
+	// 添加菜谱基本信息部分
+	if description, ok := recipe.Properties["description"]; ok && description != nil {
+		descStr := fmt.Sprintf("%v", description)
+		if descStr != "" {
+			contentParts = append(contentParts, fmt.Sprintf("\n## 菜品描述\n%s", descStr))
 		}
+	}
 
-		// 添加制作步骤部分
-		if len(stepsInfo) > 0 {
-			contentParts = append(contentParts, "\n## 制作步骤")
-			for i, step := range stepsInfo {
-				contentParts = append(contentParts, fmt.Sprintf("\n### 第%d步\n%s", i+1, step))
-			}
+	// 添加菜系信息
+	if cuisineType, ok := recipe.Properties["cuisineType"]; ok && cuisineType != nil {
+		cuisineStr := fmt.Sprintf("%v", cuisineType)
+		if cuisineStr != "" {
+			contentParts = append(contentParts, fmt.Sprintf("\n菜系: %s", cuisineStr))
 		}
+	}
 
-		// 添加标签信息部分
-		if tags, ok := recipe.Properties["tags"]; ok && tags != nil {
-			tagsStr := fmt.Sprintf("%v", tags)
-			if tagsStr != "" {
-				contentParts = append(contentParts, fmt.Sprintf("\n## 标签\n%s", tagsStr))
-			}
+	// 添加难度信息
+	if difficulty, ok := recipe.Properties["difficulty"]; ok && difficulty != nil {
+		difficultyStr := fmt.Sprintf("%v", difficulty)
+		if difficultyStr != "" {
+			contentParts = append(contentParts, fmt.Sprintf("难度: %s星", difficultyStr))
 		}
+	}
 
-		// 组合成最终的完整文档内容
-		fullContent := strings.Join(contentParts, "\n")
+	// 添加时间信息（准备时间和烹饪时间）
+	var timeInfo []string
+	if prepTime, ok := recipe.Properties["prepTime"]; ok && prepTime != nil {
+		prepTimeStr := fmt.Sprintf("%v", prepTime)
+		if prepTimeStr != "" {
+			timeInfo = append(timeInfo, fmt.Sprintf("准备时间: %s", prepTimeStr))
+		}
+	}
+	if cookTime, ok := recipe.Properties["cookTime"]; ok && cookTime != nil {
+		cookTimeStr := fmt.Sprintf("%v", cookTime)
+		if cookTimeStr != "" {
+			timeInfo = append(timeInfo, fmt.Sprintf("烹饪时间: %s", cookTimeStr))
+		}
+	}
+	if len(timeInfo) > 0 {
+		contentParts = append(contentParts, fmt.Sprintf("\n时间信息: %s", strings.Join(timeInfo, ", ")))
+	}
 
-		// 第四步：创建Document对象
-		// 包含完整的文档内容和丰富的元数据，支持后续的检索和处理
-		metadata := map[string]interface{}{
-			// 图数据库相关信息
-			"node_id":     recipeID,   // 原始节点ID
-			"recipe_name": recipeName, // 菜谱名称
-			"node_type":   "Recipe",   // 节点类型
+	// 添加份量信息
+	if servings, ok := recipe.Properties["servings"]; ok && servings != nil {
+		servingsStr := fmt.Sprintf("%v", servings)
+		if servingsStr != "" {
+			contentParts = append(contentParts, fmt.Sprintf("份量: %s", servingsStr))
+		}
+	}
 
-			// 菜谱属性信息
-			"category":     getStringFromMap(recipe.Properties, "category", "未知"),    // 菜品分类
-			"cuisine_type": getStringFromMap(recipe.Properties, "cuisineType", "未知"), // 菜系类型
-			"difficulty":   getIntFromMap(recipe.Properties, "difficulty", 0),        // 难度等级
-			"prep_time":    getStringFromMap(recipe.Properties, "prepTime", ""),      // 准备时间
-			"cook_time":    getStringFromMap(recipe.Properties, "cookTime", ""),      // 烹饪时间
-			"servings":     getStringFromMap(recipe.Properties, "servings", ""),      // 服务份量
+	// 添加食材清单部分
+	if len(ingredientsInfo) > 0 {
+		contentParts = append(contentParts, "\n## 所需食材")
+		for i, ingredient := range ingredientsInfo {
+			contentParts = append(contentParts, fmt.Sprintf("%d. %s", i+1, ingredient))
+		}
+	}
 
-			// 文档统计信息
-			"ingredients_count": len(ingredientsInfo), // 食材数量
-			"steps_count":       len(stepsInfo),       // 步骤数量
-			"doc_type":          "recipe",             // 文档类型
-			"content_length":    len(fullContent),     // 内容长度
+	// 添加制作步骤部分
+	if len(stepsInfo) > 0 {
+		contentParts = append(contentParts, "\n## 制作步骤")
+		for i, step := range stepsInfo {
+			contentParts = append(contentParts, fmt.Sprintf("\n### 第%d步\n%s", i+1, step))
 		}
+	}
 
-		doc := &schema.Document{
-			ID:       recipeID,
-			Content:  fullContent,
-			MetaData: metadata,
+	// 添加标签信息部分
+	if tags, ok := recipe.Properties["tags"]; ok && tags != nil {
+		tagsStr := fmt.Sprintf("%v", tags)
+		if tagsStr != "" {
+			contentParts = append(contentParts, fmt.Sprintf("\n## 标签\n%s", tagsStr))
 		}
+	}
 
-		documents = append(documents, doc)
+	// 组合成最终的完整文档内容
+	fullContent := strings.Join(contentParts, "\n")
+
+	// 创建Document对象
+	// 包含完整的文档内容和丰富的元数据，支持后续的检索和处理
+	metadata := map[string]interface{}{
+		// 图数据库相关信息
+		"node_id":     recipeID,   // 原始节点ID
+		"recipe_name": recipeName, // 菜谱名称
+		"node_type":   "Recipe",   // 节点类型
+
+		// 菜谱属性信息
+		"category":     getStringFromMap(recipe.Properties, "category", "未知"),    // 菜品分类
+		"cuisine_type": getStringFromMap(recipe.Properties, "cuisineType", "未知"), // 菜系类型
+		"difficulty":   getIntFromMap(recipe.Properties, "difficulty", 0),        // 难度等级
+		"prep_time":    getStringFromMap(recipe.Properties, "prepTime", ""),      // 准备时间
+		"cook_time":    getStringFromMap(recipe.Properties, "cookTime", ""),      // 烹饪时间
+		"total_time":   recipeTotalTime(recipe.Properties),                       // 总耗时（分钟）
+		"servings":     getStringFromMap(recipe.Properties, "servings", ""),      // 服务份量
+
+		// 文档统计信息
+		"ingredients_count": len(ingredientsInfo), // 食材数量
+		"steps_count":       len(stepsInfo),       // 步骤数量
+		"doc_type":          "recipe",             // 文档类型
+		"content_length":    len(fullContent),     // 内容长度
 	}
 
-	g.Documents = documents
-	log.Printf("成功构建 %d 个菜谱文档", len(documents))
-	return documents, nil
+	return &schema.Document{
+		ID:       recipeID,
+		Content:  fullContent,
+		MetaData: metadata,
+	}
 }
 
-func (g *GraphDataPreparationModule) ChunkDocuments(chunkSize, chunkOverlap int) ([]*schema.Document, error) {
-	if chunkSize <= 0 {
-		chunkSize = 500
+// mergeRecipeDocuments 将重新生成的菜谱文档合并到文档列表
+//
+// 文档按菜谱列表的顺序排列，已不在菜谱列表中的菜谱文档被移除。
+func (g *GraphDataPreparationModule) mergeRecipeDocuments(documents []*schema.Document) {
+	byID := make(map[string]*schema.Document, len(g.Documents)+len(documents))
+	for _, doc := range g.Documents {
+		byID[doc.ID] = doc
+	}
+	for _, doc := range documents {
+		byID[doc.ID] = doc
+	}
+
+	merged := make([]*schema.Document, 0, len(g.Recipes))
+	for _, recipe := range g.Recipes {
+		if doc, ok := byID[recipe.NodeID]; ok {
+			merged = append(merged, doc)
+		}
 	}
-	if chunkOverlap < 0 {
-		chunkOverlap = 50
+	g.Documents = merged
+}
+
+// ParentDocuments 按菜谱ID获取完整菜谱文档，用于父子分块的检索结果展开
+//
+// 优先使用内存中的文档；缺失的（如从已有集合加载知识库、未构建文档时）按需从图数据库生成，
+// 缓存在独立的父文档缓存中。查询路径上并发调用，只读Documents，不修改文档列表。
+func (g *GraphDataPreparationModule) ParentDocuments(recipeIDs []string) map[string]*schema.Document {
+	g.parentMu.Lock()
+	defer g.parentMu.Unlock()
+
+	byID := make(map[string]*schema.Document, len(g.Documents))
+	for _, doc := range g.Documents {
+		byID[doc.ID] = doc
 	}
 
-	log.Printf("正在进行文档分块，块大小: %d, 重叠: %d", chunkSize, chunkOverlap)
+	parents := make(map[string]*schema.Document, len(recipeIDs))
+	wanted := make(map[string]bool)
+	for _, id := range recipeIDs {
+		if doc, ok := byID[id]; ok {
+			parents[id] = doc
+		} else if doc, ok := g.parentCache[id]; ok {
+			parents[id] = doc
+		} else {
+			wanted[id] = true
+		}
+	}
+	if len(wanted) == 0 {
+		return parents
+	}
+
+	if len(g.Recipes) == 0 {
+		if _, err := g.LoadGraphData(); err != nil {
+			log.Printf("加载图数据失败，无法展开父文档: %v", err)
+			return parents
+		}
+	}
+	var recipes []GraphNode
+	for _, recipe := range g.Recipes {
+		if wanted[recipe.NodeID] {
+			recipes = append(recipes, recipe)
+		}
+	}
+
+	// 部分菜谱生成失败时仍使用其余生成成功的文档
+	documents, err := g.buildDocuments(recipes)
+	if err != nil {
+		log.Printf("生成父文档失败: %v", err)
+	}
+	if g.parentCache == nil {
+		g.parentCache = make(map[string]*schema.Document)
+	}
+	for _, doc := range documents {
+		g.parentCache[doc.ID] = doc
+		parents[doc.ID] = doc
+	}
+	return parents
+}
+
+// ChunkDocuments 对全部文档分块
+//
+// 分块策略由ChunkStrategy选择，块大小和重叠按ChunkUnit计量（默认按字符）。
+func (g *GraphDataPreparationModule) ChunkDocuments(chunkSize, chunkOverlap int) ([]*schema.Document, error) {
+	splitter := NewTextSplitter(chunkSize, chunkOverlap, g.ChunkUnit)
+	chunker := NewChunker(g.ChunkStrategy, splitter)
+
+	log.Printf("正在进行文档分块，策略: %s，块大小: %d, 重叠: %d（单位: %s）", chunker.Name(), splitter.ChunkSize, splitter.ChunkOverlap, splitter.Unit)
 
 	// 检查是否有可分块的文档
 	if len(g.Documents) == 0 {
@@ -607,118 +958,10 @@ func (g *GraphDataPreparationModule) ChunkDocuments(chunkSize, chunkOverlap int)
 	}
 
 	var chunks []*schema.Document
-	chunkID := 0
 
 	// 遍历所有文档进行分块处理
 	for _, doc := range g.Documents {
-		content := doc.Content
-
-		// 分块决策：根据文档长度选择分块策略
-		if len(content) <= chunkSize {
-			// 情况1：文档较短，无需分块
-			// 直接将整个文档作为一个块，保持完整性
-			metadata := make(map[string]interface{})
-			for k, v := range doc.MetaData {
-				metadata[k] = v
-			}
-			metadata["chunk_id"] = fmt.Sprintf("%v_chunk_%d", doc.MetaData["node_id"], chunkID)
-			metadata["parent_id"] = doc.MetaData["node_id"]
-			metadata["chunk_index"] = 0
-			metadata["total_chunks"] = 1
-			metadata["chunk_size"] = len(content)
-			metadata["doc_type"] = "chunk"
-
-			chunk := &schema.Document{
-				ID:       fmt.Sprintf("%v_chunk_%d", doc.MetaData["node_id"], chunkID),
-				Content:  content,
-				MetaData: metadata,
-			}
-			chunks = append(chunks, chunk)
-			chunkID++
-		} else {
-			// 情况2：文档较长，需要分块处理
-			// 优先按Markdown章节（## 标题）进行语义分块
-			sections := strings.Split(content, "\n## ")
-			if len(sections) <= 1 {
-				// 情况2a：没有二级标题，按长度强制分块
-				// 使用滑动窗口方式，保持重叠以维持上下文
-				totalChunks := (len(content)-1)/(chunkSize-chunkOverlap) + 1
-
-				for i := 0; i < totalChunks; i++ {
-					start := i * (chunkSize - chunkOverlap)
-					end := start + chunkSize
-					if end > len(content) {
-						end = len(content)
-					}
-
-					chunkContent := content[start:end]
-
-					metadata := make(map[string]interface{})
-					for k, v := range doc.MetaData {
-						metadata[k] = v
-					}
-					metadata["chunk_id"] = fmt.Sprintf("%v_chunk_%d", doc.MetaData["node_id"], chunkID)
-					metadata["parent_id"] = doc.MetaData["node_id"]
-					metadata["chunk_index"] = i
-					metadata["total_chunks"] = totalChunks
-					metadata["chunk_size"] = len(chunkContent)
-					metadata["doc_type"] = "chunk"
-					metadata["chunking_method"] = "length_based"
-
-					chunk := &schema.Document{
-						ID:       fmt.Sprintf("%v_chunk_%d", doc.MetaData["node_id"], chunkID),
-						Content:  chunkContent,
-						MetaData: metadata,
-					}
-					chunks = append(chunks, chunk)
-					chunkID++
-				}
-			} else {
-				// 情况2b：有二级标题，按章节语义分块
-				// 这种方式能更好地保持内容的语义完整性
-				totalChunks := len(sections)
-				for i, section := range sections {
-					var chunkContent string
-					var sectionTitle string
-
-					if i == 0 {
-						// 第一个部分包含文档标题和开头内容
-						chunkContent = section
-						sectionTitle = "主标题"
-					} else {
-						// 其他部分添加章节标题，保持Markdown格式
-						chunkContent = fmt.Sprintf("## %s", section)
-						lines := strings.Split(section, "\n")
-						if len(lines) > 0 {
-							sectionTitle = lines[0]
-						} else {
-							sectionTitle = "未知章节"
-						}
-					}
-
-					metadata := make(map[string]interface{})
-					for k, v := range doc.MetaData {
-						metadata[k] = v
-					}
-					metadata["chunk_id"] = fmt.Sprintf("%v_chunk_%d", doc.MetaData["node_id"], chunkID)
-					metadata["parent_id"] = doc.MetaData["node_id"]
-					metadata["chunk_index"] = i
-					metadata["total_chunks"] = totalChunks
-					metadata["chunk_size"] = len(chunkContent)
-					metadata["doc_type"] = "chunk"
-					metadata["chunking_method"] = "section_based"
-					metadata["section_title"] = sectionTitle
-
-					chunk := &schema.Document{
-						ID:       fmt.Sprintf("%v_chunk_%d", doc.MetaData["node_id"], chunkID),
-						Content:  chunkContent,
-						MetaData: metadata,
-					}
-					chunks = append(chunks, chunk)
-					chunkID++
-				}
-			}
-		}
+		chunks = append(chunks, chunker.Chunk(doc)...)
 	}
 
 	g.Chunks = chunks
@@ -726,6 +969,24 @@ func (g *GraphDataPreparationModule) ChunkDocuments(chunkSize, chunkOverlap int)
 	return chunks, nil
 }
 
+// ReplaceChunks 用重新生成的文档块替换指定菜谱的旧文档块
+//
+// 用于增量同步：parentIDs对应菜谱的旧文档块全部移除，再追加新的文档块。
+func (g *GraphDataPreparationModule) ReplaceChunks(parentIDs []string, chunks []*schema.Document) {
+	replaced := make(map[string]bool, len(parentIDs))
+	for _, id := range parentIDs {
+		replaced[id] = true
+	}
+
+	kept := make([]*schema.Document, 0, len(g.Chunks)+len(chunks))
+	for _, chunk := range g.Chunks {
+		if !replaced[metadataString(chunk, "parent_id")] {
+			kept = append(kept, chunk)
+		}
+	}
+	g.Chunks = append(kept, chunks...)
+}
+
 // GetStatistics 获取完整的数据处理统计信息
 //
 // 提供数据准备过程的详细统计，包括实体数量、文档数量、
@@ -809,6 +1070,15 @@ func getStringFromMap(m map[string]interface{}, key, defaultValue string) string
 	return defaultValue
 }
 
+// recipeTotalTime 计算菜谱总耗时（分钟）：优先使用图中的totalTime，否则由准备时间和烹饪时间解析
+func recipeTotalTime(properties map[string]interface{}) int {
+	if totalTime := getIntFromMap(properties, "totalTime", 0); totalTime > 0 {
+		return totalTime
+	}
+	return ParseMinutes(getStringFromMap(properties, "prepTime", "")) +
+		ParseMinutes(getStringFromMap(properties, "cookTime", ""))
+}
+
 // 辅助函数：从映射中安全获取整数值
 func getIntFromMap(m map[string]interface{}, key string, defaultValue int) int {
 	if value, ok := m[key]; ok && value != nil {
@@ -818,6 +1088,9 @@ func getIntFromMap(m map[string]interface{}, key string, defaultValue int) int {
 		if intValue, ok := value.(int); ok {
 			return intValue
 		}
+		if floatValue, ok := value.(float64); ok {
+			return int(floatValue)
+		}
 		if strValue, ok := value.(string); ok {
 			if intValue, err := strconv.Atoi(strValue); err == nil {
 				return intValue
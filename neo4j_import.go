@@ -0,0 +1,380 @@
+package batch_0001
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// defaultImportBatchSize 每个UNWIND批次写入的行数
+const defaultImportBatchSize = 500
+
+// importSchemaStatements 导入前创建的约束和索引，与 neo4j_import.cypher 保持一致
+var importSchemaStatements = []string{
+	"CREATE CONSTRAINT recipe_id_unique IF NOT EXISTS FOR (r:Recipe) REQUIRE r.nodeId IS UNIQUE",
+	"CREATE CONSTRAINT ingredient_id_unique IF NOT EXISTS FOR (i:Ingredient) REQUIRE i.nodeId IS UNIQUE",
+	"CREATE CONSTRAINT cookingstep_id_unique IF NOT EXISTS FOR (s:CookingStep) REQUIRE s.nodeId IS UNIQUE",
+	"CREATE CONSTRAINT category_name_unique IF NOT EXISTS FOR (c:Category) REQUIRE c.name IS UNIQUE",
+	"CREATE CONSTRAINT concepttype_name_unique IF NOT EXISTS FOR (c:ConceptType) REQUIRE c.name IS UNIQUE",
+	"CREATE INDEX recipe_name_index IF NOT EXISTS FOR (r:Recipe) ON (r.name)",
+	"CREATE INDEX recipe_difficulty_index IF NOT EXISTS FOR (r:Recipe) ON (r.difficulty)",
+	"CREATE INDEX recipe_cuisine_index IF NOT EXISTS FOR (r:Recipe) ON (r.cuisineType)",
+	"CREATE INDEX ingredient_name_index IF NOT EXISTS FOR (i:Ingredient) ON (i.name)",
+	"CREATE INDEX ingredient_category_index IF NOT EXISTS FOR (i:Ingredient) ON (i.category)",
+	"CREATE INDEX cookingstep_number_index IF NOT EXISTS FOR (s:CookingStep) ON (s.stepNumber)",
+	"CREATE FULLTEXT INDEX recipe_fulltext_index IF NOT EXISTS FOR (r:Recipe) ON EACH [r.name, r.description, r.preferredTerm, r.fsn, r.tags]",
+	"CREATE FULLTEXT INDEX ingredient_fulltext_index IF NOT EXISTS FOR (i:Ingredient) ON EACH [i.name, i.description, i.preferredTerm, i.fsn]",
+	"CREATE FULLTEXT INDEX cookingstep_fulltext_index IF NOT EXISTS FOR (s:CookingStep) ON EACH [s.name, s.description, s.methods, s.tools]",
+}
+
+// cypherIdentifierPattern 可以直接拼接进Cypher语句的标签/关系类型
+var cypherIdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// quoteCypherName 转义标签或关系类型名称
+//
+// 标签和关系类型不能参数化，只能拼接进语句；非标识符名称（如未知关系编码）用反引号包裹。
+func quoteCypherName(name string) string {
+	if cypherIdentifierPattern.MatchString(name) {
+		return name
+	}
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// GraphImportReport 导入结果统计
+type GraphImportReport struct {
+	NodesWritten         map[string]int `json:"nodes_written"`         // 标签 -> 写入（MERGE）的节点数
+	RelationsWritten     map[string]int `json:"relations_written"`     // 关系类型 -> 写入（MERGE）的关系数
+	NodesCreated         int            `json:"nodes_created"`         // 新建的节点数，重复导入时为0
+	RelationshipsCreated int            `json:"relationships_created"` // 新建的关系数，重复导入时为0
+	PropertiesSet        int            `json:"properties_set"`        // 设置的属性数
+	NodesDeleted         int            `json:"nodes_deleted"`         // Prune删除的节点数
+	RelationshipsDeleted int            `json:"relationships_deleted"` // Prune删除的关系数
+	Validation           CSVValidation  `json:"validation"`            // CSV数据校验结果
+	Duration             time.Duration  `json:"duration"`
+}
+
+// TotalNodes 写入的节点总数
+func (r *GraphImportReport) TotalNodes() int {
+	total := 0
+	for _, count := range r.NodesWritten {
+		total += count
+	}
+	return total
+}
+
+// TotalRelations 写入的关系总数
+func (r *GraphImportReport) TotalRelations() int {
+	total := 0
+	for _, count := range r.RelationsWritten {
+		total += count
+	}
+	return total
+}
+
+// Neo4jGraphImporter 通过Go驱动把CSV图写入Neo4j，替代 neo4j_import.cypher
+//
+// 节点按nodeId MERGE（派生的Category/ConceptType节点按名称MERGE），关系按端点和类型MERGE，
+// 因此重复导入是幂等的：第二次导入只会更新属性，不会产生重复节点或关系。
+type Neo4jGraphImporter struct {
+	driver    neo4j.DriverWithContext
+	database  string
+	batchSize int
+}
+
+// NewNeo4jGraphImporter 创建导入器，batchSize<=0时使用默认批大小
+func NewNeo4jGraphImporter(driver neo4j.DriverWithContext, database string, batchSize int) *Neo4jGraphImporter {
+	if database == "" {
+		database = "neo4j"
+	}
+	if batchSize <= 0 {
+		batchSize = defaultImportBatchSize
+	}
+	return &Neo4jGraphImporter{driver: driver, database: database, batchSize: batchSize}
+}
+
+// CreateSchema 创建约束和索引
+//
+// 除导入脚本中的约束和索引外，为图中出现的其他标签补充nodeId唯一约束，保证MERGE走索引。
+func (im *Neo4jGraphImporter) CreateSchema(ctx context.Context, graph *CSVGraph) error {
+	statements := append([]string{}, importSchemaStatements...)
+	for _, label := range importNodeLabels(graph) {
+		switch label {
+		case "Recipe", "Ingredient", "CookingStep", "Category", "ConceptType":
+			continue
+		}
+		statements = append(statements, fmt.Sprintf(
+			"CREATE CONSTRAINT %s IF NOT EXISTS FOR (n:%s) REQUIRE n.nodeId IS UNIQUE",
+			quoteCypherName(strings.ToLower(label)+"_id_unique"), quoteCypherName(label)))
+	}
+
+	session := im.driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: im.database, AccessMode: neo4j.AccessModeWrite})
+	defer session.Close(ctx)
+
+	for _, statement := range statements {
+		// 约束和索引语句不能与数据写入放在同一事务中，逐条以自动提交事务执行
+		result, err := session.Run(ctx, statement, nil)
+		if err != nil {
+			return fmt.Errorf("创建约束或索引失败: %w", err)
+		}
+		if _, err := result.Consume(ctx); err != nil {
+			return fmt.Errorf("创建约束或索引失败: %w", err)
+		}
+	}
+	log.Printf("已创建 %d 个约束和索引", len(statements))
+	return nil
+}
+
+// Import 批量写入图中的全部节点和关系
+func (im *Neo4jGraphImporter) Import(ctx context.Context, graph *CSVGraph) (*GraphImportReport, error) {
+	start := time.Now()
+	report := &GraphImportReport{
+		NodesWritten:     make(map[string]int),
+		RelationsWritten: make(map[string]int),
+		Validation:       graph.Validation,
+	}
+
+	session := im.driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: im.database, AccessMode: neo4j.AccessModeWrite})
+	defer session.Close(ctx)
+
+	// 先写节点，关系写入时需要MATCH两端节点
+	for _, group := range importNodeGroups(graph) {
+		query := fmt.Sprintf(`
+			UNWIND $rows AS row
+			MERGE (n:%s {%s: row.key})
+			SET n += row.props
+		`, quoteCypherName(group.label), group.keyProperty)
+		if err := im.writeBatches(ctx, session, query, group.rows, report); err != nil {
+			return nil, fmt.Errorf("写入%s节点失败: %w", group.label, err)
+		}
+		report.NodesWritten[group.label] += len(group.rows)
+	}
+
+	for _, group := range importRelationGroups(graph) {
+		query := fmt.Sprintf(`
+			UNWIND $rows AS row
+			MATCH (source:%s {%s: row.start})
+			MATCH (target:%s {%s: row.end})
+			MERGE (source)-[r:%s]->(target)
+			SET r += row.props
+		`, quoteCypherName(group.startLabel), group.startKey,
+			quoteCypherName(group.endLabel), group.endKey,
+			quoteCypherName(group.relationType))
+		if err := im.writeBatches(ctx, session, query, group.rows, report); err != nil {
+			return nil, fmt.Errorf("写入%s关系失败: %w", group.relationType, err)
+		}
+		report.RelationsWritten[group.relationType] += len(group.rows)
+	}
+
+	report.Duration = time.Since(start)
+	log.Printf("导入完成: %d 个节点, %d 条关系, 新建节点 %d, 新建关系 %d, 耗时 %v",
+		report.TotalNodes(), report.TotalRelations(), report.NodesCreated, report.RelationshipsCreated, report.Duration)
+	return report, nil
+}
+
+// Prune 删除Neo4j中已不在图里的节点和关系，用于全量重新生成CSV后的导入
+//
+// 只处理图中出现的标签和关系类型：同一标签下合并键（nodeId，派生节点为名称）不在图中的节点连同其关系一起删除；
+// 同一类型、同一端点标签的关系中两端不在图中对应关系里的被删除。图中完全没有的标签和关系类型不受影响。
+// 应在Import之后调用，删除计数累加到report中。
+func (im *Neo4jGraphImporter) Prune(ctx context.Context, graph *CSVGraph, report *GraphImportReport) error {
+	session := im.driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: im.database, AccessMode: neo4j.AccessModeWrite})
+	defer session.Close(ctx)
+
+	for _, group := range importNodeGroups(graph) {
+		keys := make([]interface{}, 0, len(group.rows))
+		for _, row := range group.rows {
+			keys = append(keys, row["key"])
+		}
+		query := fmt.Sprintf(`
+			MATCH (n:%s)
+			WHERE n.%s IS NOT NULL AND NOT n.%s IN $keys
+			DETACH DELETE n
+		`, quoteCypherName(group.label), group.keyProperty, group.keyProperty)
+		if err := im.writeDeletion(ctx, session, query, map[string]interface{}{"keys": keys}, report); err != nil {
+			return fmt.Errorf("删除已移除的%s节点失败: %w", group.label, err)
+		}
+	}
+
+	for _, group := range importRelationGroups(graph) {
+		pairs := make([]interface{}, 0, len(group.rows))
+		for _, row := range group.rows {
+			pairs = append(pairs, []interface{}{row["start"], row["end"]})
+		}
+		query := fmt.Sprintf(`
+			MATCH (source:%s)-[r:%s]->(target:%s)
+			WHERE NOT [source.%s, target.%s] IN $pairs
+			DELETE r
+		`, quoteCypherName(group.startLabel), quoteCypherName(group.relationType), quoteCypherName(group.endLabel),
+			group.startKey, group.endKey)
+		if err := im.writeDeletion(ctx, session, query, map[string]interface{}{"pairs": pairs}, report); err != nil {
+			return fmt.Errorf("删除已移除的%s关系失败: %w", group.relationType, err)
+		}
+	}
+
+	log.Printf("清理完成: 删除节点 %d, 删除关系 %d", report.NodesDeleted, report.RelationshipsDeleted)
+	return nil
+}
+
+// writeDeletion 在写事务中执行删除语句并累加删除计数
+func (im *Neo4jGraphImporter) writeDeletion(ctx context.Context, session neo4j.SessionWithContext, query string, params map[string]interface{}, report *GraphImportReport) error {
+	result, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
+		result, err := tx.Run(ctx, query, params)
+		if err != nil {
+			return nil, err
+		}
+		return result.Consume(ctx)
+	})
+	if err != nil {
+		return err
+	}
+	counters := result.(neo4j.ResultSummary).Counters()
+	report.NodesDeleted += counters.NodesDeleted()
+	report.RelationshipsDeleted += counters.RelationshipsDeleted()
+	return nil
+}
+
+// writeBatches 按批大小切分行，每批在独立的写事务中执行
+func (im *Neo4jGraphImporter) writeBatches(ctx context.Context, session neo4j.SessionWithContext, query string, rows []map[string]interface{}, report *GraphImportReport) error {
+	for begin := 0; begin < len(rows); begin += im.batchSize {
+		batch := rows[begin:min(begin+im.batchSize, len(rows))]
+		result, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
+			result, err := tx.Run(ctx, query, map[string]interface{}{"rows": batch})
+			if err != nil {
+				return nil, err
+			}
+			return result.Consume(ctx)
+		})
+		if err != nil {
+			return err
+		}
+		counters := result.(neo4j.ResultSummary).Counters()
+		report.NodesCreated += counters.NodesCreated()
+		report.RelationshipsCreated += counters.RelationshipsCreated()
+		report.PropertiesSet += counters.PropertiesSet()
+	}
+	return nil
+}
+
+// importNodeGroup 同一标签、同一合并键的节点行
+type importNodeGroup struct {
+	label       string
+	keyProperty string
+	rows        []map[string]interface{}
+}
+
+// importRelationGroup 同一关系类型、同一端点标签和合并键的关系行
+type importRelationGroup struct {
+	relationType string
+	startLabel   string
+	startKey     string
+	endLabel     string
+	endKey       string
+	rows         []map[string]interface{}
+}
+
+// importNodeKey 节点的标签和合并键
+//
+// CSV中的节点按nodeId合并，派生节点（没有nodeId属性）按名称合并。
+func importNodeKey(node GraphNode) (label, keyProperty string, keyValue interface{}) {
+	if len(node.Labels) > 0 {
+		label = node.Labels[0]
+	}
+	if nodeID, ok := node.Properties["nodeId"]; ok {
+		return label, "nodeId", nodeID
+	}
+	return label, "name", node.Name
+}
+
+// importNodeLabels 图中出现的全部标签
+func importNodeLabels(graph *CSVGraph) []string {
+	seen := make(map[string]bool)
+	for _, node := range graph.Nodes {
+		if label, _, _ := importNodeKey(node); label != "" {
+			seen[label] = true
+		}
+	}
+	labels := make([]string, 0, len(seen))
+	for label := range seen {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// importNodeGroups 按标签和合并键分组节点
+func importNodeGroups(graph *CSVGraph) []*importNodeGroup {
+	groups := make(map[string]*importNodeGroup)
+	var order []string
+	for _, node := range graph.Nodes {
+		label, keyProperty, keyValue := importNodeKey(node)
+		if label == "" {
+			continue
+		}
+		groupKey := label + "|" + keyProperty
+		group, ok := groups[groupKey]
+		if !ok {
+			group = &importNodeGroup{label: label, keyProperty: keyProperty}
+			groups[groupKey] = group
+			order = append(order, groupKey)
+		}
+		group.rows = append(group.rows, map[string]interface{}{"key": keyValue, "props": node.Properties})
+	}
+
+	result := make([]*importNodeGroup, 0, len(order))
+	for _, groupKey := range order {
+		result = append(result, groups[groupKey])
+	}
+	return result
+}
+
+// importRelationGroups 按关系类型和端点分组关系
+func importRelationGroups(graph *CSVGraph) []*importRelationGroup {
+	groups := make(map[string]*importRelationGroup)
+	var order []string
+	for _, relation := range graph.Relations {
+		start, ok := graph.Node(relation.StartNodeID)
+		if !ok {
+			continue
+		}
+		end, ok := graph.Node(relation.EndNodeID)
+		if !ok {
+			continue
+		}
+		startLabel, startKey, startValue := importNodeKey(*start)
+		endLabel, endKey, endValue := importNodeKey(*end)
+		if startLabel == "" || endLabel == "" {
+			continue
+		}
+
+		groupKey := strings.Join([]string{relation.RelationType, startLabel, startKey, endLabel, endKey}, "|")
+		group, ok := groups[groupKey]
+		if !ok {
+			group = &importRelationGroup{
+				relationType: relation.RelationType,
+				startLabel:   startLabel,
+				startKey:     startKey,
+				endLabel:     endLabel,
+				endKey:       endKey,
+			}
+			groups[groupKey] = group
+			order = append(order, groupKey)
+		}
+		properties := relation.Properties
+		if properties == nil {
+			properties = map[string]interface{}{}
+		}
+		group.rows = append(group.rows, map[string]interface{}{"start": startValue, "end": endValue, "props": properties})
+	}
+
+	result := make([]*importRelationGroup, 0, len(order))
+	for _, groupKey := range order {
+		result = append(result, groups[groupKey])
+	}
+	return result
+}
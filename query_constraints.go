@@ -0,0 +1,809 @@
+package batch_0001
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// QueryConstraints 从查询中抽取的结构化约束
+//
+// 同一份约束可以转换为三种形式，保证不同检索通道的过滤口径一致：
+// - Milvus过滤表达式（ToFilterExpr）
+// - Neo4j Cypher WHERE片段及参数（CypherCondition）
+// - 内存检索结果的匹配函数（MatchDocument）
+//
+// 菜系和分类字段可能是逗号分隔的多个值（如"荤菜,凉菜,下酒菜"），三种形式都按
+// "拆分后任一元素与约束值（含数据中的同义写法）完全相等"匹配。
+type QueryConstraints struct {
+	CuisineTypes        []string `json:"cuisine_types,omitempty"`        // 菜系（规范名），满足其一即可
+	Categories          []string `json:"categories,omitempty"`           // 菜品分类（规范名），满足其一即可
+	MinDifficulty       int      `json:"min_difficulty,omitempty"`       // 最低难度（星级），0表示不限制
+	MaxDifficulty       int      `json:"max_difficulty,omitempty"`       // 最高难度（星级），0表示不限制
+	MaxTotalTime        int      `json:"max_total_time,omitempty"`       // 最长总耗时（分钟），0表示不限制
+	ExcludedIngredients []string `json:"excluded_ingredients,omitempty"` // 需要排除的食材
+}
+
+// IsEmpty 是否没有任何约束
+func (c *QueryConstraints) IsEmpty() bool {
+	return c == nil || (len(c.CuisineTypes) == 0 && len(c.Categories) == 0 &&
+		c.MinDifficulty == 0 && c.MaxDifficulty == 0 && c.MaxTotalTime == 0 &&
+		len(c.ExcludedIngredients) == 0)
+}
+
+// String 约束的可读描述
+func (c *QueryConstraints) String() string {
+	if c.IsEmpty() {
+		return "无"
+	}
+
+	var parts []string
+	if len(c.CuisineTypes) > 0 {
+		parts = append(parts, "菜系="+strings.Join(c.CuisineTypes, "/"))
+	}
+	if len(c.Categories) > 0 {
+		parts = append(parts, "分类="+strings.Join(c.Categories, "/"))
+	}
+	if c.MinDifficulty > 0 || c.MaxDifficulty > 0 {
+		parts = append(parts, fmt.Sprintf("难度=%s-%s星", boundString(c.MinDifficulty), boundString(c.MaxDifficulty)))
+	}
+	if c.MaxTotalTime > 0 {
+		parts = append(parts, fmt.Sprintf("总耗时<=%d分钟", c.MaxTotalTime))
+	}
+	if len(c.ExcludedIngredients) > 0 {
+		parts = append(parts, "排除食材="+strings.Join(c.ExcludedIngredients, "/"))
+	}
+	return strings.Join(parts, "，")
+}
+
+// Relax 放宽一项约束，返回放宽后的新约束
+//
+// 放宽顺序：总耗时 → 难度 → 分类 → 菜系。排除食材通常与忌口、过敏有关，不做放宽。
+//
+// Returns:
+//   - *QueryConstraints: 放宽后的约束
+//   - bool: 是否还有可以放宽的约束
+func (c *QueryConstraints) Relax() (*QueryConstraints, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	relaxed := *c
+	switch {
+	case relaxed.MaxTotalTime > 0:
+		relaxed.MaxTotalTime = 0
+	case relaxed.MinDifficulty > 0 || relaxed.MaxDifficulty > 0:
+		relaxed.MinDifficulty = 0
+		relaxed.MaxDifficulty = 0
+	case len(relaxed.Categories) > 0:
+		relaxed.Categories = nil
+	case len(relaxed.CuisineTypes) > 0:
+		relaxed.CuisineTypes = nil
+	default:
+		return c, false
+	}
+	return &relaxed, true
+}
+
+// ToFilterExpr 转换为Milvus过滤表达式，无约束时返回nil
+func (c *QueryConstraints) ToFilterExpr() FilterExpr {
+	if c.IsEmpty() {
+		return nil
+	}
+
+	var exprs []FilterExpr
+	if len(c.CuisineTypes) > 0 {
+		exprs = append(exprs, multiValueFilter("cuisine_type", expandCuisines(c.CuisineTypes)))
+	}
+	if len(c.Categories) > 0 {
+		exprs = append(exprs, multiValueFilter("category", c.Categories))
+	}
+	if c.MinDifficulty > 0 || c.MaxDifficulty > 0 {
+		exprs = append(exprs, Range("difficulty", boundValue(c.MinDifficulty), boundValue(c.MaxDifficulty)))
+	}
+	if c.MaxTotalTime > 0 {
+		// 未解析出耗时的文档total_time为0，需要排除
+		exprs = append(exprs, Range("total_time", 1, c.MaxTotalTime))
+	}
+	for _, ingredient := range c.ExcludedIngredients {
+		exprs = append(exprs, Not(Contains("text", ingredient)))
+	}
+	return And(exprs...)
+}
+
+// CypherCondition 转换为Cypher WHERE条件片段
+//
+// Args:
+//   - alias: 菜谱节点在查询中的变量名，如"r"
+//
+// Returns:
+//   - string: 条件片段（不含WHERE关键字），无约束时为空字符串
+//   - map[string]interface{}: 条件中引用的参数，参数名以constraint_为前缀避免冲突
+func (c *QueryConstraints) CypherCondition(alias string) (string, map[string]interface{}) {
+	params := make(map[string]interface{})
+	if c.IsEmpty() {
+		return "", params
+	}
+
+	var conditions []string
+	if len(c.CuisineTypes) > 0 {
+		conditions = append(conditions, cypherMultiValueCondition(alias+".cuisineType", "constraint_cuisines"))
+		params["constraint_cuisines"] = expandCuisines(c.CuisineTypes)
+	}
+	if len(c.Categories) > 0 {
+		conditions = append(conditions, cypherMultiValueCondition(alias+".category", "constraint_categories"))
+		params["constraint_categories"] = c.Categories
+	}
+	if c.MinDifficulty > 0 {
+		conditions = append(conditions, fmt.Sprintf("%s.difficulty >= $constraint_min_difficulty", alias))
+		params["constraint_min_difficulty"] = c.MinDifficulty
+	}
+	if c.MaxDifficulty > 0 {
+		conditions = append(conditions, fmt.Sprintf("%s.difficulty <= $constraint_max_difficulty", alias))
+		params["constraint_max_difficulty"] = c.MaxDifficulty
+	}
+	if c.MaxTotalTime > 0 {
+		// 与文档的total_time一致：没有totalTime时由准备时间和烹饪时间解析，未解析出耗时的菜谱排除
+		totalTime := cypherTotalTimeExpr(alias)
+		conditions = append(conditions, fmt.Sprintf("%s > 0 AND %s <= $constraint_max_time", totalTime, totalTime))
+		params["constraint_max_time"] = c.MaxTotalTime
+	}
+	if len(c.ExcludedIngredients) > 0 {
+		conditions = append(conditions, fmt.Sprintf(
+			"NOT EXISTS { MATCH (%s)-[:REQUIRES]->(excluded:Ingredient) WHERE any(x IN $constraint_excluded WHERE excluded.name CONTAINS x) }", alias))
+		params["constraint_excluded"] = c.ExcludedIngredients
+	}
+
+	return strings.Join(conditions, " AND "), params
+}
+
+// MatchDocument 判断内存中的文档是否满足约束
+//
+// 约束涉及的字段在文档元数据中缺失时视为不满足，由放宽机制兜底。
+// 排除食材按文档内容做子串匹配。
+func (c *QueryConstraints) MatchDocument(doc *schema.Document) bool {
+	if c.IsEmpty() {
+		return true
+	}
+	if doc == nil {
+		return false
+	}
+
+	if len(c.CuisineTypes) > 0 && !matchMultiValue(metadataString(doc, "cuisine_type"), expandCuisines(c.CuisineTypes)) {
+		return false
+	}
+	if len(c.Categories) > 0 && !matchMultiValue(metadataString(doc, "category"), c.Categories) {
+		return false
+	}
+	if c.MinDifficulty > 0 || c.MaxDifficulty > 0 {
+		difficulty, ok := metadataNumber(doc, "difficulty")
+		if !ok || difficulty <= 0 {
+			return false
+		}
+		if c.MinDifficulty > 0 && difficulty < float64(c.MinDifficulty) {
+			return false
+		}
+		if c.MaxDifficulty > 0 && difficulty > float64(c.MaxDifficulty) {
+			return false
+		}
+	}
+	if c.MaxTotalTime > 0 {
+		totalTime, ok := metadataNumber(doc, "total_time")
+		if !ok || totalTime <= 0 || totalTime > float64(c.MaxTotalTime) {
+			return false
+		}
+	}
+	if len(c.ExcludedIngredients) > 0 && containsAny(doc.Content, c.ExcludedIngredients) {
+		return false
+	}
+
+	return true
+}
+
+// MatchGraphNode 判断图中的菜谱节点是否满足约束，语义与CypherCondition一致
+//
+// Args:
+//   - node: 菜谱节点，使用cuisineType、category、difficulty属性，耗时与文档一样由recipeTotalTime计算
+//   - ingredients: 菜谱需要的食材名称，用于排除食材
+func (c *QueryConstraints) MatchGraphNode(node GraphNode, ingredients []string) bool {
+	if c.IsEmpty() {
+		return true
+	}
+
+	if len(c.CuisineTypes) > 0 && !matchMultiValue(getStringFromMap(node.Properties, "cuisineType", ""), expandCuisines(c.CuisineTypes)) {
+		return false
+	}
+	if len(c.Categories) > 0 && !matchMultiValue(getStringFromMap(node.Properties, "category", ""), c.Categories) {
+		return false
+	}
+	if c.MinDifficulty > 0 || c.MaxDifficulty > 0 {
+		difficulty, ok := node.Properties["difficulty"].(float64)
+		if !ok {
+			return false
+		}
+		if c.MinDifficulty > 0 && difficulty < float64(c.MinDifficulty) {
+			return false
+		}
+		if c.MaxDifficulty > 0 && difficulty > float64(c.MaxDifficulty) {
+			return false
+		}
+	}
+	if c.MaxTotalTime > 0 {
+		totalTime := recipeTotalTime(node.Properties)
+		if totalTime <= 0 || totalTime > c.MaxTotalTime {
+			return false
+		}
+	}
+	for _, ingredient := range ingredients {
+		if containsAny(ingredient, c.ExcludedIngredients) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FilterDocuments 按约束过滤文档，结果不足minResults时逐步放宽约束
+//
+// Returns:
+//   - []*schema.Document: 过滤后的文档
+//   - *QueryConstraints: 实际生效的约束
+func FilterDocuments(documents []*schema.Document, constraints *QueryConstraints, minResults int) ([]*schema.Document, *QueryConstraints) {
+	current := constraints
+	for {
+		var filtered []*schema.Document
+		for _, doc := range documents {
+			if current.MatchDocument(doc) {
+				filtered = append(filtered, doc)
+			}
+		}
+
+		if len(filtered) >= minResults || len(filtered) == len(documents) {
+			return filtered, current
+		}
+
+		relaxed, ok := current.Relax()
+		if !ok {
+			return filtered, current
+		}
+		current = relaxed
+	}
+}
+
+// ========== 约束抽取 ==========
+
+// 菜系别称 → 规范名，规范名取cypher/nodes.csv中实际出现的写法
+var cuisineAliases = map[string]string{
+	"川菜": "川菜", "川味": "川菜", "四川菜": "川菜",
+	"粤菜": "粤菜", "广东菜": "粤菜", "广式": "粤菜",
+	"湘菜": "湘菜", "湖南菜": "湘菜",
+	"鲁菜": "鲁菜", "山东菜": "鲁菜",
+	"苏菜": "苏菜", "江苏菜": "苏菜", "淮扬菜": "苏菜",
+	"浙菜": "浙菜", "浙江菜": "浙菜",
+	"闽菜": "闽菜", "福建菜": "闽菜",
+	"徽菜": "徽菜", "安徽菜": "徽菜",
+	"豫菜": "豫菜", "河南菜": "豫菜",
+	"沪菜": "沪菜", "上海菜": "沪菜", "本帮菜": "沪菜",
+	"黔菜": "黔菜", "贵州菜": "黔菜",
+	"东北菜": "东北菜",
+	"西北菜": "西北菜", "陕菜": "西北菜", "陕西菜": "西北菜",
+	"日式": "日式", "日料": "日式", "日本料理": "日式", "日本菜": "日式",
+	"韩餐": "韩餐", "韩式": "韩餐", "韩国料理": "韩餐", "韩国菜": "韩餐",
+	"意大利": "意大利", "意式": "意大利",
+	"泰国菜": "泰国菜", "泰式": "泰国菜", "泰餐": "泰国菜",
+	"西餐": "西餐", "西式": "西餐",
+}
+
+// cuisineVariants 规范名在数据中的全部写法，匹配时展开；未列出的规范名只匹配自身
+var cuisineVariants = map[string][]string{
+	"苏菜":  {"苏菜", "淮扬菜"},
+	"豫菜":  {"豫菜", "豫东菜"},
+	"沪菜":  {"沪菜", "本帮菜"},
+	"西北菜": {"西北菜", "陕菜"},
+	"日式":  {"日式", "日料"},
+	"意大利": {"意大利", "意大利菜"},
+	"泰国菜": {"泰国菜", "泰国"},
+	"西餐":  {"西餐", "美式", "意大利", "意大利菜"},
+}
+
+// 菜品分类别称 → 规范名，规范名取cypher/nodes.csv中实际出现的写法
+var categoryAliases = map[string]string{
+	"荤菜": "荤菜", "素菜": "素菜", "素食": "素菜",
+	"主食": "主食", "水产": "水产", "海鲜": "水产",
+	"早餐": "早餐", "早饭": "早餐",
+	"饮料": "饮料", "饮品": "饮料",
+	"汤类": "汤类", "汤品": "汤类",
+	"甜品": "甜品", "甜点": "甜品",
+	"凉菜": "凉菜", "下酒菜": "下酒菜", "减肥餐": "减肥餐",
+	"半成品": "半成品", "调料": "调料",
+}
+
+// multiValueSeparator 菜系、分类等多值字段的分隔符，与nodes.csv一致
+const multiValueSeparator = ","
+
+// 难度描述词
+//
+// "简单"、"容易"常用在与难度无关的场合（如"简单介绍一下"），必须修饰菜或做法时才算难度约束，
+// 见easyDifficultyPattern；其余词本身就指难度。
+var (
+	easyDifficultyWords   = []string{"新手", "入门", "零基础", "不难", "厨房小白"}
+	mediumDifficultyWords = []string{"中等难度", "难度适中", "有点难度"}
+	hardDifficultyWords   = []string{"有挑战", "高难度", "难度高", "难一点", "难度大"}
+)
+
+// 排除食材的触发词，"除了"语义含糊（"除了鸡蛋还要什么"），单独由exceptPattern处理
+var exclusionTriggers = []string{"不要", "不含", "不放", "不加", "不吃", "不能吃", "忌口", "去掉", "别放", "不用"}
+
+var (
+	// 简单难度：如"简单的菜"、"简单易做"、"做法简单"、"做起来容易"
+	easyDifficultyPattern = regexp.MustCompile(`(简单|容易)(的|点|一点|些|一些|易|好做|快手|家常)|(做法|做起来|操作|步骤|上手)(很|比较|特别|非常|超)?(简单|容易)`)
+	// 总耗时：如"半小时内"、"20分钟以内"、"不超过一个小时"；单独的时长（如"炖2小时"）不是约束
+	timePattern = regexp.MustCompile(`(不超过|不到|少于|最多|只有)?\s*(半|[0-9]+|[一二两三四五六七八九十]+)\s*(个)?\s*(小时|分钟|min)\s*(以内|之内|内|以下|左右|就能|能|可以|搞定|做好|做完|出锅)?`)
+	// "除了X都/其他…"才是排除，"除了X还要…"是补充
+	exceptPattern = regexp.MustCompile(`除了([\p{Han}、]{1,12}?)(以外|之外)?[，,\s]*(都|其他|其它|别的|其余)`)
+	// 星级难度：如"3星"、"两星以下"
+	starPattern = regexp.MustCompile(`([1-5一二两三四五])\s*星\s*(以下|以内|及以下|以上|及以上)?`)
+	// 过敏食材：如"对花生过敏"
+	allergyPattern = regexp.MustCompile(`([\p{Han}]{1,6})过敏`)
+)
+
+// QueryConstraintExtractor 查询约束抽取器
+//
+// 基于规则从自然语言查询中抽取菜系、难度、总耗时、分类和排除食材等约束。
+// 排除食材使用食材词典做最长匹配；没有词典时截取触发词后的短词。
+type QueryConstraintExtractor struct {
+	ingredients []string // 食材词典，按长度降序排列
+}
+
+// NewQueryConstraintExtractor 创建查询约束抽取器
+//
+// Args:
+//   - ingredients: 食材名称词典，用于识别排除食材，可为空
+func NewQueryConstraintExtractor(ingredients []string) *QueryConstraintExtractor {
+	seen := make(map[string]bool)
+	var dictionary []string
+	for _, name := range ingredients {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		dictionary = append(dictionary, name)
+	}
+	sort.Slice(dictionary, func(i, j int) bool {
+		return len([]rune(dictionary[i])) > len([]rune(dictionary[j]))
+	})
+	return &QueryConstraintExtractor{ingredients: dictionary}
+}
+
+// Extract 从查询中抽取约束
+func (e *QueryConstraintExtractor) Extract(query string) *QueryConstraints {
+	constraints := &QueryConstraints{}
+
+	constraints.ExcludedIngredients = e.extractExcludedIngredients(query)
+
+	// 排除食材的描述中可能出现菜系/分类词（如"不要海鲜"），先从查询中去掉
+	remaining := query
+	for _, ingredient := range constraints.ExcludedIngredients {
+		remaining = strings.ReplaceAll(remaining, ingredient, " ")
+	}
+
+	constraints.CuisineTypes = matchAliases(remaining, cuisineAliases)
+	constraints.Categories = matchAliases(remaining, categoryAliases)
+	constraints.MinDifficulty, constraints.MaxDifficulty = extractDifficulty(remaining)
+	constraints.MaxTotalTime = extractMaxTotalTime(remaining)
+
+	return constraints
+}
+
+// extractExcludedIngredients 抽取需要排除的食材
+func (e *QueryConstraintExtractor) extractExcludedIngredients(query string) []string {
+	var excluded []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			excluded = append(excluded, name)
+		}
+	}
+
+	for _, trigger := range exclusionTriggers {
+		offset := 0
+		for {
+			idx := strings.Index(query[offset:], trigger)
+			if idx < 0 {
+				break
+			}
+			start := offset + idx
+			offset = start + len(trigger)
+
+			// 排除"要不要"、"有没有"一类的疑问用法
+			if start > 0 && strings.HasPrefix(trigger, "不") {
+				prev := []rune(query[:start])
+				if last := prev[len(prev)-1]; strings.ContainsRune("要放加吃用能", last) {
+					continue
+				}
+			}
+
+			for _, item := range splitIngredientList(query[offset:]) {
+				add(e.matchIngredient(item, true))
+			}
+		}
+	}
+
+	for _, match := range exceptPattern.FindAllStringSubmatch(query, -1) {
+		for _, item := range splitIngredientList(match[1]) {
+			add(e.matchIngredient(item, true))
+		}
+	}
+
+	for _, match := range allergyPattern.FindAllStringSubmatch(query, -1) {
+		phrase := strings.TrimPrefix(match[1], "对")
+		add(e.matchIngredient(phrase, false))
+	}
+
+	return excluded
+}
+
+// matchIngredient 在短语中识别食材名称
+//
+// Args:
+//   - phrase: 候选短语
+//   - fromStart: true表示从短语开头匹配，false表示从短语末尾匹配
+func (e *QueryConstraintExtractor) matchIngredient(phrase string, fromStart bool) string {
+	phrase = strings.TrimSpace(phrase)
+	if phrase == "" {
+		return ""
+	}
+
+	for _, name := range e.ingredients {
+		if (fromStart && strings.HasPrefix(phrase, name)) || (!fromStart && strings.HasSuffix(phrase, name)) {
+			return name
+		}
+	}
+
+	// 有词典时只认可词典中的食材，避免把"不要太辣"之类的描述误当作食材
+	if len(e.ingredients) > 0 {
+		return ""
+	}
+
+	// 没有词典时截取最多4个字
+	runes := []rune(phrase)
+	if len(runes) > 4 {
+		if fromStart {
+			runes = runes[:4]
+		} else {
+			runes = runes[len(runes)-4:]
+		}
+	}
+	return string(runes)
+}
+
+// splitIngredientList 截取触发词之后的食材列表，如"香菜和葱的菜" → ["香菜", "葱"]
+func splitIngredientList(text string) []string {
+	var items []string
+	var current []rune
+	flush := func() {
+		if len(current) > 0 {
+			items = append(items, string(current))
+			current = current[:0]
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case r == '和' || r == '或' || r == '、' || r == '跟' || r == '与':
+			flush()
+		case unicode.Is(unicode.Han, r) && r != '的' && r != '也':
+			current = append(current, r)
+		default:
+			flush()
+			return items
+		}
+	}
+	flush()
+	return items
+}
+
+// matchAliases 按别称表匹配查询中出现的规范名称，结果去重并保持稳定顺序
+func matchAliases(query string, aliases map[string]string) []string {
+	seen := make(map[string]bool)
+	var matched []string
+	for alias, canonical := range aliases {
+		if strings.Contains(query, alias) && !seen[canonical] {
+			seen[canonical] = true
+			matched = append(matched, canonical)
+		}
+	}
+	sort.Strings(matched)
+	return matched
+}
+
+// extractDifficulty 抽取难度范围
+func extractDifficulty(query string) (int, int) {
+	if match := starPattern.FindStringSubmatch(query); match != nil {
+		stars := parseChineseNumber(match[1])
+		switch match[2] {
+		case "以下", "以内", "及以下":
+			return 0, stars
+		case "以上", "及以上":
+			return stars, 0
+		default:
+			return stars, stars
+		}
+	}
+
+	if easyDifficultyPattern.MatchString(query) {
+		return 0, 2
+	}
+	for _, word := range easyDifficultyWords {
+		if strings.Contains(query, word) {
+			return 0, 2
+		}
+	}
+	for _, word := range mediumDifficultyWords {
+		if strings.Contains(query, word) {
+			return 3, 3
+		}
+	}
+	for _, word := range hardDifficultyWords {
+		if strings.Contains(query, word) {
+			return 4, 0
+		}
+	}
+	return 0, 0
+}
+
+// extractMaxTotalTime 抽取最长总耗时（分钟）
+//
+// 时长前后必须有限定词（"不超过"、"以内"、"搞定"等）才作为约束。
+func extractMaxTotalTime(query string) int {
+	for _, match := range timePattern.FindAllStringSubmatch(query, -1) {
+		if match[1] == "" && match[5] == "" {
+			continue
+		}
+
+		if match[2] == "半" {
+			if match[4] != "小时" {
+				continue
+			}
+			return 30
+		}
+		value := parseChineseNumber(match[2])
+		if match[4] == "小时" {
+			value *= 60
+		}
+		return value
+	}
+	return 0
+}
+
+// parseChineseNumber 解析阿拉伯数字或简单中文数字（不超过九十九）
+func parseChineseNumber(text string) int {
+	if value, err := strconv.Atoi(text); err == nil {
+		return value
+	}
+
+	digits := map[rune]int{'一': 1, '二': 2, '两': 2, '三': 3, '四': 4, '五': 5, '六': 6, '七': 7, '八': 8, '九': 9}
+	runes := []rune(text)
+	value := 0
+	for i, r := range runes {
+		if r == '十' {
+			if i == 0 {
+				value = 10
+			} else {
+				value *= 10
+			}
+			continue
+		}
+		if i > 0 && runes[i-1] == '十' {
+			value += digits[r]
+		} else {
+			value = digits[r]
+		}
+	}
+	return value
+}
+
+// ParseMinutes 解析菜谱中的时间描述，如"约10分钟"、"15-20分钟"、"1小时30分钟"
+//
+// 区间取上限；无法解析时返回0。
+func ParseMinutes(text string) int {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0
+	}
+
+	total := 0
+	if idx := strings.Index(text, "小时"); idx >= 0 {
+		hourPart := text[:idx]
+		if strings.HasSuffix(hourPart, "半") {
+			total += 30
+			hourPart = strings.TrimSuffix(hourPart, "半")
+		}
+		total += lastNumber(hourPart) * 60
+		if strings.Contains(text[idx:], "半") {
+			total += 30
+		}
+		text = text[idx+len("小时"):]
+	}
+	if idx := strings.Index(text, "分钟"); idx >= 0 {
+		total += lastNumber(text[:idx])
+	}
+	return total
+}
+
+// lastNumber 取文本中最后一个数字，如"15-20" → 20
+func lastNumber(text string) int {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsDigit(r)
+	})
+	if len(fields) == 0 {
+		return 0
+	}
+	value, _ := strconv.Atoi(fields[len(fields)-1])
+	return value
+}
+
+// cypherTotalTimeExpr 与recipeTotalTime等价的Cypher表达式：totalTime大于0时直接使用，
+// 否则按ParseMinutes的规则解析准备时间和烹饪时间并求和
+func cypherTotalTimeExpr(alias string) string {
+	return fmt.Sprintf("(CASE WHEN coalesce(%s.totalTime, 0) > 0 THEN toInteger(%s.totalTime) ELSE %s + %s END)",
+		alias, alias, cypherMinutesExpr(alias+".prepTime"), cypherMinutesExpr(alias+".cookTime"))
+}
+
+// cypherMinutesExpr 与ParseMinutes等价的Cypher表达式
+//
+// 第一个"小时"之前的最后一个数字乘60，"小时"前以"半"结尾或之后出现"半"各加30分钟，
+// 再加上"小时"之后、"分钟"之前的最后一个数字。
+func cypherMinutesExpr(property string) string {
+	text := fmt.Sprintf("trim(coalesce(%s, ''))", property)
+	hourPart := fmt.Sprintf("split(%s, '小时')[0]", text)
+	hours := fmt.Sprintf("CASE WHEN %[1]s CONTAINS '小时' THEN %[2]s * 60"+
+		" + CASE WHEN %[3]s ENDS WITH '半' THEN 30 ELSE 0 END"+
+		" + CASE WHEN substring(%[1]s, size(%[3]s)) CONTAINS '半' THEN 30 ELSE 0 END ELSE 0 END",
+		text, cypherLastNumberExpr(hourPart), hourPart)
+	rest := fmt.Sprintf("(CASE WHEN %[1]s CONTAINS '小时' THEN substring(%[1]s, size(%[2]s) + 2) ELSE %[1]s END)", text, hourPart)
+	minutes := fmt.Sprintf("CASE WHEN %[1]s CONTAINS '分钟' THEN %[2]s ELSE 0 END",
+		rest, cypherLastNumberExpr(fmt.Sprintf("split(%s, '分钟')[0]", rest)))
+	return fmt.Sprintf("(%s + %s)", hours, minutes)
+}
+
+// cypherLastNumberExpr 与lastNumber等价的Cypher表达式：非数字字符替换为空格后取最后一段数字
+func cypherLastNumberExpr(text string) string {
+	return fmt.Sprintf("coalesce(toInteger(last([part IN split(reduce(digits = '', ch IN split(%s, '') | digits + "+
+		"CASE WHEN ch =~ '[0-9]' THEN ch ELSE ' ' END), ' ') WHERE part <> ''])), 0)", text)
+}
+
+// ========== 上下文传递 ==========
+
+type queryConstraintsKey struct{}
+
+// WithQueryConstraints 将查询约束放入上下文，供下游检索通道使用
+func WithQueryConstraints(ctx context.Context, constraints *QueryConstraints) context.Context {
+	return context.WithValue(ctx, queryConstraintsKey{}, constraints)
+}
+
+// QueryConstraintsFromContext 从上下文中读取查询约束
+func QueryConstraintsFromContext(ctx context.Context) (*QueryConstraints, bool) {
+	constraints, ok := ctx.Value(queryConstraintsKey{}).(*QueryConstraints)
+	return constraints, ok
+}
+
+// ========== 辅助函数 ==========
+
+// boundString 难度边界的可读形式
+func boundString(value int) string {
+	if value == 0 {
+		return "*"
+	}
+	return strconv.Itoa(value)
+}
+
+// boundValue 范围边界，0表示不限制
+func boundValue(value int) interface{} {
+	if value == 0 {
+		return nil
+	}
+	return value
+}
+
+// stringsToValues 将字符串切片转换为过滤值列表
+func stringsToValues(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, v := range values {
+		result[i] = v
+	}
+	return result
+}
+
+// expandCuisines 将规范菜系名展开为数据中的全部写法
+func expandCuisines(cuisines []string) []string {
+	seen := make(map[string]bool)
+	var expanded []string
+	for _, cuisine := range cuisines {
+		variants, ok := cuisineVariants[cuisine]
+		if !ok {
+			variants = []string{cuisine}
+		}
+		for _, variant := range variants {
+			if !seen[variant] {
+				seen[variant] = true
+				expanded = append(expanded, variant)
+			}
+		}
+	}
+	return expanded
+}
+
+// matchMultiValue 判断逗号分隔的字段值中是否有元素与任一候选值完全相等
+func matchMultiValue(field string, values []string) bool {
+	for _, element := range strings.Split(field, multiValueSeparator) {
+		element = strings.TrimSpace(element)
+		for _, value := range values {
+			if element != "" && element == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// multiValueFilter 多值字段的Milvus过滤条件，语义与matchMultiValue一致
+//
+// Milvus不能拆分字符串，按元素在开头、中间、结尾和整个字段四种位置组合like条件。
+func multiValueFilter(field string, values []string) FilterExpr {
+	var exprs []FilterExpr
+	for _, value := range values {
+		escaped := EscapeLikePattern(value)
+		exprs = append(exprs,
+			Eq(field, value),
+			Like(field, escaped+multiValueSeparator+"%"),
+			Like(field, "%"+multiValueSeparator+escaped),
+			Like(field, "%"+multiValueSeparator+escaped+multiValueSeparator+"%"),
+		)
+	}
+	return Or(exprs...)
+}
+
+// cypherMultiValueCondition 多值属性的Cypher条件，语义与matchMultiValue一致
+func cypherMultiValueCondition(property, param string) string {
+	return fmt.Sprintf("any(x IN $%s WHERE x IN [v IN split(coalesce(%s, ''), '%s') | trim(v)])",
+		param, property, multiValueSeparator)
+}
+
+// containsAny 判断文本是否包含任一关键词
+func containsAny(text string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if keyword != "" && strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// metadataNumber 以数值形式读取元数据字段
+func metadataNumber(doc *schema.Document, key string) (float64, bool) {
+	switch v := doc.MetaData[key].(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case string:
+		value, err := strconv.ParseFloat(v, 64)
+		return value, err == nil
+	}
+	return 0, false
+}
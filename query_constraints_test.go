@@ -0,0 +1,83 @@
+package batch_0001
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// totalTimeRecipes 耗时写法不同的菜谱：导入时只有"N分钟"的写法会得到totalTime
+func totalTimeRecipes() []map[string]string {
+	return []map[string]string{
+		{"nodeId": "201000001", "labels": "Recipe", "name": "番茄炒蛋", "prepTime": "5分钟", "cookTime": "10分钟"},
+		{"nodeId": "202000001", "labels": "Recipe", "name": "红烧肉", "prepTime": "约10分钟", "cookTime": "15-20分钟"},
+		{"nodeId": "203000001", "labels": "Recipe", "name": "卤牛肉", "prepTime": "10分钟", "cookTime": "1小时30分钟"},
+		{"nodeId": "204000001", "labels": "Recipe", "name": "凉拌黄瓜"},
+	}
+}
+
+func TestMatchGraphNodeUsesRecipeTotalTime(t *testing.T) {
+	graph := NewCSVGraphFromRows(totalTimeRecipes(), nil)
+	constraints := &QueryConstraints{MaxTotalTime: 30}
+
+	want := map[string]bool{
+		"番茄炒蛋": true,  // totalTime = 15
+		"红烧肉":  true,  // 没有totalTime，10 + 20 = 30
+		"卤牛肉":  false, // 没有totalTime，10 + 90 = 100
+		"凉拌黄瓜": false, // 没有耗时信息
+	}
+	for _, node := range graph.NodesByLabel("Recipe") {
+		// 文档的total_time同样由recipeTotalTime计算，图通道和文档通道的结果应一致
+		doc := &schema.Document{MetaData: map[string]interface{}{"total_time": recipeTotalTime(node.Properties)}}
+		if got := constraints.MatchGraphNode(node, nil); got != want[node.Name] {
+			t.Errorf("MatchGraphNode(%s) = %v，期望 %v", node.Name, got, want[node.Name])
+		}
+		if got := constraints.MatchDocument(doc); got != want[node.Name] {
+			t.Errorf("MatchDocument(%s) = %v，期望 %v", node.Name, got, want[node.Name])
+		}
+	}
+}
+
+func TestMemoryGraphStoreSearchNodesTotalTime(t *testing.T) {
+	store := NewMemoryGraphStore(NewCSVGraphFromRows(totalTimeRecipes(), nil))
+
+	matches, err := store.SearchNodes(context.Background(), NodeSearchQuery{
+		Keywords:    []string{"分钟"},
+		Fields:      []string{"prepTime"},
+		Labels:      []string{"Recipe"},
+		Constraints: &QueryConstraints{MaxTotalTime: 30},
+	})
+	if err != nil {
+		t.Fatalf("SearchNodes: %v", err)
+	}
+	var names []string
+	for _, match := range matches {
+		names = append(names, match.Node.Name)
+	}
+	if got := strings.Join(names, ","); got != "番茄炒蛋,红烧肉" {
+		t.Errorf("30分钟内的菜谱应为番茄炒蛋和红烧肉，得到 %s", got)
+	}
+}
+
+func TestCypherConditionTotalTimeFallsBackToPrepAndCook(t *testing.T) {
+	condition, params := (&QueryConstraints{MaxTotalTime: 30}).CypherCondition("r")
+
+	for _, want := range []string{
+		"coalesce(r.totalTime, 0) > 0",
+		"coalesce(r.prepTime, '')",
+		"coalesce(r.cookTime, '')",
+		"<= $constraint_max_time",
+	} {
+		if !strings.Contains(condition, want) {
+			t.Errorf("耗时条件缺少 %q: %s", want, condition)
+		}
+	}
+	if params["constraint_max_time"] != 30 {
+		t.Errorf("constraint_max_time = %v，期望 30", params["constraint_max_time"])
+	}
+	if strings.Count(condition, "(") != strings.Count(condition, ")") {
+		t.Errorf("耗时条件括号不匹配: %s", condition)
+	}
+}
@@ -0,0 +1,448 @@
+package batch_0001
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/eino-ext/components/model/ark"
+	"github.com/cloudwego/eino/schema"
+	arkModel "github.com/volcengine/volcengine-go-sdk/service/arkruntime/model"
+)
+
+// 重排序器类型
+const (
+	RerankerLexical      = "lexical"       // 本地词汇特征重排序（默认，无需联网）
+	RerankerLLMPointwise = "llm_pointwise" // LLM逐条打分
+	RerankerLLMListwise  = "llm_listwise"  // LLM整体排序打分
+	RerankerNone         = "none"          // 不做重排序
+)
+
+// 重排序相关默认参数
+const (
+	rerankMaxDocRunes      = 400 // 送入LLM的单个文档最大字数
+	rerankLLMConcurrency   = 4   // 逐条打分的最大并发数
+	lexicalCoverageWeight  = 0.45
+	lexicalNameWeight      = 0.25
+	lexicalDensityWeight   = 0.15
+	lexicalPriorWeight     = 0.15
+	rerankScoreScaleDivide = 10.0 // LLM打分范围0-10
+)
+
+// Reranker 重排序器接口
+//
+// 在多路检索结果融合之后使用，为来自不同通道、得分口径不一致的文档
+// 重新计算可比较的相关性得分（写入final_score），并截取前topK个结果。
+type Reranker interface {
+	// Name 重排序器名称，写入文档元数据的reranker字段
+	Name() string
+	// Rerank 对文档重新打分排序，返回按得分降序排列的前topK个文档
+	Rerank(ctx context.Context, query string, documents []*schema.Document, topK int) ([]*schema.Document, error)
+}
+
+// NewReranker 根据类型创建重排序器
+//
+// Args:
+//   - kind: 重排序器类型，为空时使用本地词汇重排序；为none时返回nil表示不重排序
+//   - chatModel: LLM重排序使用的模型，为nil时LLM重排序降级为本地词汇重排序
+func NewReranker(kind string, chatModel *ark.ChatModel) Reranker {
+	switch kind {
+	case RerankerNone:
+		return nil
+	case RerankerLLMPointwise, RerankerLLMListwise:
+		if chatModel == nil {
+			log.Printf("未配置LLM，重排序器 %s 降级为 %s", kind, RerankerLexical)
+			return NewLexicalReranker(nil)
+		}
+		mode := LLMRerankPointwise
+		if kind == RerankerLLMListwise {
+			mode = LLMRerankListwise
+		}
+		return NewLLMReranker(chatModel, mode, NewLexicalReranker(nil))
+	case "", RerankerLexical:
+		return NewLexicalReranker(nil)
+	default:
+		log.Printf("未知重排序器类型: %s，使用 %s", kind, RerankerLexical)
+		return NewLexicalReranker(nil)
+	}
+}
+
+// ========== 本地词汇特征重排序 ==========
+
+// LexicalReranker 基于词汇特征的本地重排序器
+//
+// 不依赖任何外部服务，作为离线默认方案。综合以下特征计算0-1之间的得分：
+// - 查询词覆盖率：查询词项在文档中出现的比例
+// - 菜名匹配度：查询与文档菜名的匹配程度
+// - 词项密度：查询词项在文档中的出现频次（饱和处理）
+// - 原始检索得分：融合前各通道给出的得分，作为先验
+type LexicalReranker struct {
+	tokenizer *ChineseTokenizer
+}
+
+// NewLexicalReranker 创建本地词汇重排序器
+//
+// Args:
+//   - tokenizer: 中文分词器，为nil时使用空词典的分词器（仅字符二元组）
+func NewLexicalReranker(tokenizer *ChineseTokenizer) *LexicalReranker {
+	if tokenizer == nil {
+		tokenizer = NewChineseTokenizer(nil)
+	}
+	return &LexicalReranker{tokenizer: tokenizer}
+}
+
+// Name 重排序器名称
+func (r *LexicalReranker) Name() string {
+	return RerankerLexical
+}
+
+// Rerank 基于词汇特征重排序
+func (r *LexicalReranker) Rerank(ctx context.Context, query string, documents []*schema.Document, topK int) ([]*schema.Document, error) {
+	queryTerms := make(map[string]bool)
+	for _, token := range r.tokenizer.Tokenize(query) {
+		queryTerms[token] = true
+	}
+
+	scores := make([]float64, len(documents))
+	for i, doc := range documents {
+		scores[i] = r.score(query, queryTerms, doc)
+	}
+
+	return applyRerankScores(documents, scores, r.Name(), topK), nil
+}
+
+// score 计算单个文档的词汇特征得分
+func (r *LexicalReranker) score(query string, queryTerms map[string]bool, doc *schema.Document) float64 {
+	if doc == nil {
+		return 0
+	}
+
+	termFreqs := make(map[string]int)
+	for _, token := range r.tokenizer.Tokenize(doc.Content) {
+		termFreqs[token]++
+	}
+
+	// 查询词覆盖率与词项密度
+	coverage, density := 0.0, 0.0
+	if len(queryTerms) > 0 {
+		matched := 0
+		for term := range queryTerms {
+			if tf := termFreqs[term]; tf > 0 {
+				matched++
+				density += float64(tf) / float64(tf+1)
+			}
+		}
+		coverage = float64(matched) / float64(len(queryTerms))
+		density /= float64(len(queryTerms))
+	}
+
+	// 菜名匹配度：查询直接包含菜名得满分，否则按菜名词项被查询覆盖的比例计算
+	nameScore := 0.0
+	if name, ok := doc.MetaData["recipe_name"].(string); ok && name != "" {
+		if strings.Contains(query, name) {
+			nameScore = 1.0
+		} else {
+			nameTerms := r.tokenizer.Tokenize(name)
+			if len(nameTerms) > 0 {
+				hit := 0
+				for _, term := range nameTerms {
+					if queryTerms[term] {
+						hit++
+					}
+				}
+				nameScore = float64(hit) / float64(len(nameTerms))
+			}
+		}
+	}
+
+	return lexicalCoverageWeight*coverage +
+		lexicalNameWeight*nameScore +
+		lexicalDensityWeight*density +
+		lexicalPriorWeight*retrievalScoreOf(doc)
+}
+
+// ========== LLM重排序 ==========
+
+// LLMRerankMode LLM重排序模式
+type LLMRerankMode string
+
+const (
+	// LLMRerankPointwise 逐条打分：每个文档单独请求一次，得分更稳定但调用次数多
+	LLMRerankPointwise LLMRerankMode = "pointwise"
+	// LLMRerankListwise 整体打分：一次请求对全部候选打分，便于横向比较
+	LLMRerankListwise LLMRerankMode = "listwise"
+)
+
+// LLMReranker 基于大语言模型的重排序器
+//
+// 使用LLM判断文档与查询的相关性并给出0-10分的评分，归一化为0-1后作为final_score。
+// LLM调用失败时降级到fallback重排序器，保证检索流程不中断。
+type LLMReranker struct {
+	chatModel *ark.ChatModel
+	mode      LLMRerankMode
+	fallback  Reranker
+}
+
+// NewLLMReranker 创建LLM重排序器
+//
+// Args:
+//   - chatModel: 大语言模型客户端
+//   - mode: 重排序模式（pointwise/listwise）
+//   - fallback: LLM调用失败时使用的降级重排序器，可为nil
+func NewLLMReranker(chatModel *ark.ChatModel, mode LLMRerankMode, fallback Reranker) *LLMReranker {
+	if mode != LLMRerankListwise {
+		mode = LLMRerankPointwise
+	}
+	return &LLMReranker{
+		chatModel: chatModel,
+		mode:      mode,
+		fallback:  fallback,
+	}
+}
+
+// Name 重排序器名称
+func (r *LLMReranker) Name() string {
+	return "llm_" + string(r.mode)
+}
+
+// llmPointwiseResult 逐条打分返回结构
+type llmPointwiseResult struct {
+	Score float64 `json:"score"`
+}
+
+// llmListwiseResult 整体打分返回结构
+type llmListwiseResult struct {
+	Scores []struct {
+		Index int     `json:"index"`
+		Score float64 `json:"score"`
+	} `json:"scores"`
+}
+
+// Rerank 使用LLM重排序
+func (r *LLMReranker) Rerank(ctx context.Context, query string, documents []*schema.Document, topK int) ([]*schema.Document, error) {
+	if len(documents) == 0 {
+		return documents, nil
+	}
+
+	var scores []float64
+	var err error
+	if r.mode == LLMRerankListwise {
+		scores, err = r.listwiseScores(ctx, query, documents)
+	} else {
+		scores, err = r.pointwiseScores(ctx, query, documents)
+	}
+
+	if err != nil {
+		if r.fallback == nil {
+			return nil, fmt.Errorf("LLM重排序失败: %w", err)
+		}
+		log.Printf("LLM重排序失败，降级到 %s: %v", r.fallback.Name(), err)
+		return r.fallback.Rerank(ctx, query, documents, topK)
+	}
+
+	return applyRerankScores(documents, scores, r.Name(), topK), nil
+}
+
+// pointwiseScores 逐条请求LLM打分
+//
+// 单个文档打分失败时使用其原始检索得分，全部失败时返回错误。
+func (r *LLMReranker) pointwiseScores(ctx context.Context, query string, documents []*schema.Document) ([]float64, error) {
+	scores := make([]float64, len(documents))
+	errs := make([]error, len(documents))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, rerankLLMConcurrency)
+	for i, doc := range documents {
+		wg.Add(1)
+		go func(i int, doc *schema.Document) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			userContent := fmt.Sprintf(`请评估下面的文档对回答用户问题的帮助程度，给出0到10之间的分数（10表示完全相关且能直接回答，0表示完全无关）。
+
+用户问题：%s
+
+文档：
+%s
+
+请严格按照JSON格式返回，不要包含多余的文字：
+{"score": 7}`, query, truncateRunes(doc.Content, rerankMaxDocRunes))
+
+			content, err := r.generate(ctx, userContent)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			var result llmPointwiseResult
+			if err := json.Unmarshal([]byte(content), &result); err != nil {
+				errs[i] = fmt.Errorf("JSON解析失败: %w, 响应内容: %s", err, content)
+				return
+			}
+			scores[i] = clampScore(result.Score / rerankScoreScaleDivide)
+		}(i, doc)
+	}
+	wg.Wait()
+
+	failed := 0
+	var lastErr error
+	for i, err := range errs {
+		if err != nil {
+			failed++
+			lastErr = err
+			scores[i] = retrievalScoreOf(documents[i])
+		}
+	}
+	if failed == len(documents) {
+		return nil, lastErr
+	}
+	if failed > 0 {
+		log.Printf("LLM逐条打分部分失败: %d/%d，失败文档使用原始检索得分", failed, len(documents))
+	}
+
+	return scores, nil
+}
+
+// listwiseScores 一次请求LLM对全部候选打分
+func (r *LLMReranker) listwiseScores(ctx context.Context, query string, documents []*schema.Document) ([]float64, error) {
+	var docParts []string
+	for i, doc := range documents {
+		docParts = append(docParts, fmt.Sprintf("[%d] %s", i+1, truncateRunes(doc.Content, rerankMaxDocRunes)))
+	}
+
+	userContent := fmt.Sprintf(`请评估下面每个候选文档对回答用户问题的帮助程度，为每个文档给出0到10之间的分数（10表示完全相关且能直接回答，0表示完全无关）。请在候选之间横向比较后再打分。
+
+用户问题：%s
+
+候选文档：
+%s
+
+请严格按照JSON格式返回每个文档的编号和分数，不要包含多余的文字：
+{"scores": [{"index": 1, "score": 8}, {"index": 2, "score": 3}]}`, query, strings.Join(docParts, "\n\n"))
+
+	content, err := r.generate(ctx, userContent)
+	if err != nil {
+		return nil, err
+	}
+
+	var result llmListwiseResult
+	if err := json.Unmarshal([]byte(content), &result); err != nil {
+		return nil, fmt.Errorf("JSON解析失败: %w, 响应内容: %s", err, content)
+	}
+
+	// 未被打分的文档得0分，排在已打分文档之后
+	scores := make([]float64, len(documents))
+	for _, item := range result.Scores {
+		if item.Index >= 1 && item.Index <= len(documents) {
+			scores[item.Index-1] = clampScore(item.Score / rerankScoreScaleDivide)
+		}
+	}
+	return scores, nil
+}
+
+// generate 调用LLM并返回去除markdown代码块标记后的内容
+func (r *LLMReranker) generate(ctx context.Context, userContent string) (string, error) {
+	messages := []*schema.Message{
+		schema.SystemMessage("你是搜索结果相关性评估专家，只根据文档内容与问题的相关程度打分。"),
+		schema.UserMessage(userContent),
+	}
+
+	thinking := &arkModel.Thinking{
+		Type: arkModel.ThinkingTypeDisabled,
+	}
+
+	response, err := r.chatModel.Generate(ctx, messages, ark.WithThinking(thinking))
+	if err != nil {
+		return "", fmt.Errorf("LLM生成失败: %w", err)
+	}
+
+	return cleanJSONResponse(response.Content), nil
+}
+
+// ========== 辅助函数 ==========
+
+// applyRerankScores 写入重排序得分并按得分降序截取前topK个文档
+//
+// 原有的final_score保存到retrieval_score字段，便于对比重排序前后的得分。
+func applyRerankScores(documents []*schema.Document, scores []float64, rerankerName string, topK int) []*schema.Document {
+	type scoredDoc struct {
+		doc   *schema.Document
+		score float64
+	}
+
+	scored := make([]scoredDoc, 0, len(documents))
+	for i, doc := range documents {
+		if doc == nil {
+			continue
+		}
+		if doc.MetaData == nil {
+			doc.MetaData = make(map[string]interface{})
+		}
+		if _, exists := doc.MetaData["retrieval_score"]; !exists {
+			doc.MetaData["retrieval_score"] = retrievalScoreOf(doc)
+		}
+		doc.MetaData["rerank_score"] = scores[i]
+		doc.MetaData["final_score"] = scores[i]
+		doc.MetaData["reranker"] = rerankerName
+		scored = append(scored, scoredDoc{doc: doc, score: scores[i]})
+	}
+
+	// 稳定排序，同分时保留融合阶段的顺序
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].score > scored[j].score
+	})
+
+	if topK > 0 && len(scored) > topK {
+		scored = scored[:topK]
+	}
+
+	result := make([]*schema.Document, 0, len(scored))
+	for _, item := range scored {
+		result = append(result, item.doc)
+	}
+	return result
+}
+
+// retrievalScoreOf 读取文档在融合阶段的得分，限制在0-1之间
+func retrievalScoreOf(doc *schema.Document) float64 {
+	for _, key := range []string{"retrieval_score", "final_score", "relevance_score"} {
+		if score, ok := doc.MetaData[key].(float64); ok {
+			return clampScore(score)
+		}
+	}
+	return 0
+}
+
+// clampScore 将得分限制在0-1之间
+func clampScore(score float64) float64 {
+	if score < 0 {
+		return 0
+	}
+	if score > 1 {
+		return 1
+	}
+	return score
+}
+
+// truncateRunes 按字符数截断文本
+func truncateRunes(text string, maxRunes int) string {
+	runes := []rune(text)
+	if len(runes) <= maxRunes {
+		return text
+	}
+	return string(runes[:maxRunes]) + "..."
+}
+
+// cleanJSONResponse 去除LLM响应中的markdown代码块标记
+func cleanJSONResponse(content string) string {
+	cleanContent := strings.TrimSpace(content)
+	cleanContent = strings.TrimPrefix(cleanContent, "```json")
+	cleanContent = strings.TrimPrefix(cleanContent, "```")
+	cleanContent = strings.TrimSuffix(cleanContent, "```")
+	return strings.TrimSpace(cleanContent)
+}
@@ -0,0 +1,212 @@
+package batch_0001
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultSecretsDir Docker secrets和Kubernetes secret卷的常用挂载目录
+const DefaultSecretsDir = "/run/secrets"
+
+// ErrSecretNotFound 所有密钥来源中都找不到该密钥
+var ErrSecretNotFound = errors.New("未找到密钥")
+
+// SecretProvider 密钥来源
+//
+// 密钥名使用环境变量风格的大写名称，如ARK_API_KEY，各来源自行映射到自己的命名方式。
+// 找不到时返回ok=false，读取出错时返回error。
+type SecretProvider interface {
+	Name() string
+	Lookup(name string) (value string, ok bool, err error)
+}
+
+// EnvSecretProvider 从环境变量读取密钥
+type EnvSecretProvider struct{}
+
+// Name 来源名称
+func (EnvSecretProvider) Name() string { return "env" }
+
+// Lookup 读取同名环境变量，空值视为未设置
+func (EnvSecretProvider) Lookup(name string) (string, bool, error) {
+	value := strings.TrimSpace(os.Getenv(name))
+	return value, value != "", nil
+}
+
+// EnvFileSecretProvider 从 <NAME>_FILE 环境变量指向的文件读取密钥，常见于Docker镜像约定
+type EnvFileSecretProvider struct{}
+
+// Name 来源名称
+func (EnvFileSecretProvider) Name() string { return "env_file" }
+
+// Lookup 读取 <NAME>_FILE 指向的文件，变量已设置但文件读取失败时返回错误
+func (EnvFileSecretProvider) Lookup(name string) (string, bool, error) {
+	path := strings.TrimSpace(os.Getenv(name + "_FILE"))
+	if path == "" {
+		return "", false, nil
+	}
+	return readSecretFile(path)
+}
+
+// FileSecretProvider 从目录中的文件读取密钥，如Docker secrets或Kubernetes secret卷
+//
+// 文件名依次尝试密钥名的小写形式（ark_api_key）和原样（ARK_API_KEY）。
+type FileSecretProvider struct {
+	Dir string
+}
+
+// NewFileSecretProvider 创建目录密钥来源，dir为空时使用DefaultSecretsDir
+func NewFileSecretProvider(dir string) *FileSecretProvider {
+	if dir == "" {
+		dir = DefaultSecretsDir
+	}
+	return &FileSecretProvider{Dir: dir}
+}
+
+// Name 来源名称
+func (p *FileSecretProvider) Name() string { return "file:" + p.Dir }
+
+// Lookup 读取目录中与密钥同名的文件，文件不存在时视为未设置
+func (p *FileSecretProvider) Lookup(name string) (string, bool, error) {
+	for _, fileName := range []string{strings.ToLower(name), name} {
+		path := filepath.Join(p.Dir, fileName)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		return readSecretFile(path)
+	}
+	return "", false, nil
+}
+
+// readSecretFile 读取密钥文件，去掉首尾空白（挂载的文件通常带换行）
+func readSecretFile(path string) (string, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("读取密钥文件 %s 失败: %w", path, err)
+	}
+	value := strings.TrimSpace(string(data))
+	return value, value != "", nil
+}
+
+// SecretProviders 按顺序查找的密钥来源链
+type SecretProviders []SecretProvider
+
+// DefaultSecretProviders 默认来源链：环境变量 → <NAME>_FILE → 密钥目录
+func DefaultSecretProviders(secretsDir string) SecretProviders {
+	return SecretProviders{
+		EnvSecretProvider{},
+		EnvFileSecretProvider{},
+		NewFileSecretProvider(secretsDir),
+	}
+}
+
+// Resolve 依次查找密钥，找到后登记用于日志脱敏；都找不到时返回ErrSecretNotFound
+func (providers SecretProviders) Resolve(name string) (string, error) {
+	for _, provider := range providers {
+		value, ok, err := provider.Lookup(name)
+		if err != nil {
+			return "", fmt.Errorf("从 %s 读取密钥 %s 失败: %w", provider.Name(), name, err)
+		}
+		if ok {
+			RegisterSecret(value)
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s（可通过%s提供）", ErrSecretNotFound, name, providers.describe(name))
+}
+
+// describe 说明密钥可以从哪些来源提供
+func (providers SecretProviders) describe(name string) string {
+	var sources []string
+	for _, provider := range providers {
+		switch p := provider.(type) {
+		case EnvSecretProvider:
+			sources = append(sources, "环境变量"+name)
+		case EnvFileSecretProvider:
+			sources = append(sources, "环境变量"+name+"_FILE指向的文件")
+		case *FileSecretProvider:
+			sources = append(sources, filepath.Join(p.Dir, strings.ToLower(name)))
+		default:
+			sources = append(sources, provider.Name())
+		}
+	}
+	return strings.Join(sources, "、")
+}
+
+// minRedactedSecretLength 太短的值（如空密码占位）脱敏会误伤正常文本，不登记
+const minRedactedSecretLength = 4
+
+// secretRegistry 已登记的密钥值，日志和错误信息输出前替换为占位符
+var secretRegistry struct {
+	sync.RWMutex
+	values []string
+}
+
+// RedactedSecret 脱敏后的占位符
+const RedactedSecret = "******"
+
+// RegisterSecret 登记密钥值，之后经过RedactSecrets的文本中该值会被替换
+func RegisterSecret(value string) {
+	if len(value) < minRedactedSecretLength {
+		return
+	}
+	secretRegistry.Lock()
+	defer secretRegistry.Unlock()
+	for _, existing := range secretRegistry.values {
+		if existing == value {
+			return
+		}
+	}
+	secretRegistry.values = append(secretRegistry.values, value)
+	// 长的先替换，避免一个密钥是另一个的子串时只替换了一部分
+	sort.Slice(secretRegistry.values, func(i, j int) bool {
+		return len(secretRegistry.values[i]) > len(secretRegistry.values[j])
+	})
+}
+
+// RedactSecrets 把文本中已登记的密钥值替换为占位符
+func RedactSecrets(text string) string {
+	secretRegistry.RLock()
+	defer secretRegistry.RUnlock()
+	for _, value := range secretRegistry.values {
+		text = strings.ReplaceAll(text, value, RedactedSecret)
+	}
+	return text
+}
+
+// RedactError 返回错误信息脱敏后的文本
+func RedactError(err error) string {
+	if err == nil {
+		return ""
+	}
+	return RedactSecrets(err.Error())
+}
+
+// redactingWriter 写入前脱敏的Writer
+type redactingWriter struct {
+	w io.Writer
+}
+
+// NewRedactingWriter 包装w，写入的内容先经过RedactSecrets，用于log.SetOutput
+//
+// log包每条日志只调用一次Write，密钥不会被拆到两次写入中。
+func NewRedactingWriter(w io.Writer) io.Writer {
+	return &redactingWriter{w: w}
+}
+
+// Write 脱敏后写入，返回原始长度以满足io.Writer约定
+func (r *redactingWriter) Write(p []byte) (int, error) {
+	redacted := RedactSecrets(string(p))
+	if redacted == string(p) {
+		return r.w.Write(p)
+	}
+	if _, err := io.WriteString(r.w, redacted); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
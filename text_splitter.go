@@ -0,0 +1,313 @@
+package batch_0001
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ChunkUnit 分块长度的计量单位
+type ChunkUnit string
+
+const (
+	ChunkUnitRune  ChunkUnit = "rune"  // 按字符（rune）计数
+	ChunkUnitToken ChunkUnit = "token" // 按估算的模型token计数
+)
+
+// 默认分块参数
+const (
+	DefaultChunkSize    = 500
+	DefaultChunkOverlap = 50
+)
+
+// defaultSeparators 默认的切分边界，按优先级从高到低：步骤标题 > 段落 > 换行 > 句末标点 > 句内标点
+//
+// 以换行开头的分隔符归入下一段（保持标题在段首），其余分隔符留在上一段末尾。
+var defaultSeparators = []string{
+	"\n### ", "\n\n", "\n",
+	"。", "！", "？", "；", "!", "?", ";",
+	"，", "、", ",",
+}
+
+// TextSplitter 按字符或token计数的文本切分器
+//
+// 优先在步骤、换行、句子边界处切分，单个句子仍超长时才按字符硬切分；
+// 始终在rune边界上切分，保证输出是合法的UTF-8。相邻块之间保留重叠的句子以维持上下文。
+type TextSplitter struct {
+	ChunkSize    int       // 块大小上限
+	ChunkOverlap int       // 相邻块重叠的长度
+	Unit         ChunkUnit // 计量单位
+
+	separators []string
+}
+
+// NewTextSplitter 创建文本切分器
+//
+// chunkSize<=0时使用默认值500；chunkOverlap<0时使用默认值50，
+// 不小于chunkSize时缩小为chunkSize的十分之一；unit为空时按字符计数。
+func NewTextSplitter(chunkSize, chunkOverlap int, unit ChunkUnit) *TextSplitter {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if chunkOverlap < 0 {
+		chunkOverlap = DefaultChunkOverlap
+	}
+	if chunkOverlap >= chunkSize {
+		chunkOverlap = chunkSize / 10
+	}
+	if unit != ChunkUnitToken {
+		unit = ChunkUnitRune
+	}
+	return &TextSplitter{
+		ChunkSize:    chunkSize,
+		ChunkOverlap: chunkOverlap,
+		Unit:         unit,
+		separators:   defaultSeparators,
+	}
+}
+
+// WithSeparators 返回使用指定分隔符优先级的切分器副本
+func (s *TextSplitter) WithSeparators(separators []string) *TextSplitter {
+	clone := *s
+	clone.separators = separators
+	return &clone
+}
+
+// Length 按切分器的计量单位计算文本长度
+func (s *TextSplitter) Length(text string) int {
+	if s.Unit == ChunkUnitToken {
+		return EstimateTokens(text)
+	}
+	return utf8.RuneCountInString(text)
+}
+
+// Split 将文本切分为长度不超过ChunkSize的块
+func (s *TextSplitter) Split(text string) []string {
+	return s.SplitWithLimit(text, s.ChunkSize)
+}
+
+// SplitWithLimit 将文本切分为长度不超过limit的块
+//
+// 用于块内需要额外加入标题等前缀的场景，调用方从ChunkSize中扣除前缀长度后传入。
+func (s *TextSplitter) SplitWithLimit(text string, limit int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if limit <= 0 {
+		limit = s.ChunkSize
+	}
+	overlap := min(s.ChunkOverlap, limit/2)
+
+	segments := s.splitSegments(text, s.separators, limit)
+	return s.mergeSegments(segments, limit, overlap)
+}
+
+// splitSegments 递归地按分隔符切分，直到每段长度不超过limit
+func (s *TextSplitter) splitSegments(text string, separators []string, limit int) []string {
+	if s.Length(text) <= limit {
+		return []string{text}
+	}
+	if len(separators) == 0 {
+		return s.hardSplit(text, limit)
+	}
+
+	pieces := splitKeepSeparator(text, separators[0])
+	if len(pieces) == 1 {
+		return s.splitSegments(text, separators[1:], limit)
+	}
+
+	var segments []string
+	for _, piece := range pieces {
+		segments = append(segments, s.splitSegments(piece, separators[1:], limit)...)
+	}
+	return segments
+}
+
+// mergeSegments 将切分后的小段贪心地合并为块，相邻块之间保留不超过overlap的尾部小段
+func (s *TextSplitter) mergeSegments(segments []string, limit, overlap int) []string {
+	var chunks []string
+	var current []string
+	currentLen := 0
+
+	flush := func() {
+		if chunk := strings.TrimSpace(strings.Join(current, "")); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	for _, segment := range segments {
+		segmentLen := s.Length(segment)
+		if currentLen+segmentLen > limit && len(current) > 0 {
+			flush()
+			last := current[len(current)-1]
+			// 保留尾部小段作为重叠，同时为新小段留出空间
+			for len(current) > 0 && (currentLen > overlap || currentLen+segmentLen > limit) {
+				currentLen -= s.Length(current[0])
+				current = current[1:]
+			}
+			// 最后一段本身就超过重叠长度时，从中截取末尾的句子作为重叠；硬切分的相邻段已自带重叠，不再重复
+			if len(current) == 0 && overlap > 0 && !s.continuesHardSplit(last, segment, overlap) {
+				if tail := s.overlapTail(last, overlap); tail != "" && s.Length(tail)+segmentLen <= limit {
+					current = []string{tail}
+					currentLen = s.Length(tail)
+				}
+			}
+		}
+		current = append(current, segment)
+		currentLen += segmentLen
+	}
+	if len(current) > 0 {
+		flush()
+	}
+	return chunks
+}
+
+// overlapTail 截取文本末尾不超过overlap的完整句子
+func (s *TextSplitter) overlapTail(text string, overlap int) string {
+	pieces := s.splitSegments(text, s.separators, overlap)
+
+	start := len(pieces)
+	total := 0
+	for start > 0 {
+		pieceLen := s.Length(pieces[start-1])
+		if total+pieceLen > overlap {
+			break
+		}
+		total += pieceLen
+		start--
+	}
+	return strings.Join(pieces[start:], "")
+}
+
+// hardSplit 没有可用边界时按rune切分，相邻块保留重叠
+//
+// 扩展和回退时逐个rune累计长度，不重复计算整段文本，长文本上保持线性。
+func (s *TextSplitter) hardSplit(text string, limit int) []string {
+	runes := []rune(text)
+	overlap := min(s.ChunkOverlap, limit/2)
+
+	var pieces []string
+	for start := 0; start < len(runes); {
+		length := lengthCounter{unit: s.Unit}
+		length.add(runes[start])
+		end := start + 1
+		for end < len(runes) {
+			extended := length
+			extended.add(runes[end])
+			if extended.total() > limit {
+				break
+			}
+			length = extended
+			end++
+		}
+		pieces = append(pieces, string(runes[start:end]))
+		if end >= len(runes) {
+			break
+		}
+
+		// 按计量单位回退出重叠部分，且保证向前推进
+		start = s.overlapStart(runes, start, end, overlap)
+	}
+	return pieces
+}
+
+// overlapStart 从runes[start:end]末尾回退出长度不超过overlap的重叠部分，返回重叠的起点（大于start）
+func (s *TextSplitter) overlapStart(runes []rune, start, end, overlap int) int {
+	tail := lengthCounter{unit: s.Unit}
+	next := end
+	for next > start+1 {
+		extended := tail
+		extended.add(runes[next-1])
+		if extended.total() > overlap {
+			break
+		}
+		tail = extended
+		next--
+	}
+	return next
+}
+
+// continuesHardSplit segment是否为last硬切分后的下一段，即开头已带有hardSplit保留的重叠
+func (s *TextSplitter) continuesHardSplit(last, segment string, overlap int) bool {
+	runes := []rune(last)
+	next := s.overlapStart(runes, 0, len(runes), overlap)
+	return next < len(runes) && strings.HasPrefix(segment, string(runes[next:]))
+}
+
+// lengthCounter 逐个rune累计文本长度，结果与Length一致
+//
+// 按token计量时连续字母数字合并计数，与添加顺序无关，正向和反向累计都适用。
+type lengthCounter struct {
+	unit    ChunkUnit
+	runes   int // 已累计的rune数
+	tokens  int // 已结束的token数
+	wordLen int // 当前连续字母数字的长度
+}
+
+// add 累计一个rune
+func (c *lengthCounter) add(r rune) {
+	c.runes++
+	switch {
+	case r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+		c.wordLen++
+	case unicode.IsSpace(r):
+		c.flushWord()
+	default:
+		c.flushWord()
+		c.tokens++
+	}
+}
+
+// flushWord 结束当前单词，按每4个字符计1个token
+func (c *lengthCounter) flushWord() {
+	if c.wordLen > 0 {
+		c.tokens += (c.wordLen + 3) / 4
+		c.wordLen = 0
+	}
+}
+
+// total 当前累计的长度
+func (c lengthCounter) total() int {
+	if c.unit == ChunkUnitToken {
+		return c.tokens + (c.wordLen+3)/4
+	}
+	return c.runes
+}
+
+// splitKeepSeparator 按分隔符切分并保留分隔符
+//
+// 以换行开头的分隔符保留在下一段开头，其余分隔符保留在上一段末尾。
+func splitKeepSeparator(text, separator string) []string {
+	parts := strings.Split(text, separator)
+	if len(parts) == 1 {
+		return parts
+	}
+
+	pieces := make([]string, 0, len(parts))
+	leading := strings.HasPrefix(separator, "\n")
+	for i, part := range parts {
+		switch {
+		case leading && i > 0:
+			part = separator + part
+		case !leading && i < len(parts)-1:
+			part += separator
+		}
+		if part != "" {
+			pieces = append(pieces, part)
+		}
+	}
+	return pieces
+}
+
+// EstimateTokens 估算文本的模型token数
+//
+// 不依赖具体模型的分词表：每个汉字等CJK字符计1个token，连续的字母数字按每4个字符计1个token，
+// 其他非空白字符各计1个token，空白不计。
+func EstimateTokens(text string) int {
+	counter := lengthCounter{unit: ChunkUnitToken}
+	for _, r := range text {
+		counter.add(r)
+	}
+	return counter.total()
+}
@@ -0,0 +1,117 @@
+package batch_0001
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// longChineseRecipe 较长的中文菜谱文档：有超长章节、多个步骤，以及一段没有任何标点的超长句子
+func longChineseRecipe() string {
+	var b strings.Builder
+	b.WriteString("# 红烧肉的做法\n\n")
+	b.WriteString("红烧肉是一道经典的家常菜，色泽红亮，肥而不腻，入口即化。")
+	b.WriteString("选用三层五花肉，肥瘦相间，炖煮1.5小时后口感最佳。\n\n")
+
+	b.WriteString("## 所需食材\n\n")
+	for _, item := range []string{"五花肉 500g", "冰糖 30g", "生抽 2勺", "老抽 1勺", "料酒 3勺", "八角 2个", "桂皮 1小块", "香叶 3片", "生姜 5片", "葱 2根"} {
+		b.WriteString("- " + item + "\n")
+	}
+
+	b.WriteString("\n## 制作步骤\n")
+	steps := []string{
+		"五花肉洗净切成约三厘米见方的块，冷水下锅，加入料酒和姜片焯水，撇去浮沫后捞出沥干。",
+		"锅中放少量油，小火放入冰糖慢慢炒化，炒到冰糖呈枣红色并冒小泡时立即下入肉块，快速翻炒上色。",
+		"加入生抽、老抽、八角、桂皮、香叶，继续翻炒出香味；倒入没过肉块的开水，大火烧开后转小火炖煮一个半小时。",
+		"最后开大火收汁，汤汁浓稠、均匀裹在肉块上即可出锅，撒上葱花点缀",
+	}
+	for i, step := range steps {
+		b.WriteString("\n### 第" + string(rune('1'+i)) + "步\n\n" + step + "\n")
+	}
+
+	b.WriteString("\n## 附加内容\n\n")
+	b.WriteString(strings.Repeat("炖煮时要保持小火不要频繁开盖否则肉质会变柴汤汁也会变少", 4))
+	b.WriteString("。\n")
+	return b.String()
+}
+
+func TestTextSplitterSplitLongChineseText(t *testing.T) {
+	text := longChineseRecipe()
+	for _, unit := range []ChunkUnit{ChunkUnitRune, ChunkUnitToken} {
+		t.Run(string(unit), func(t *testing.T) {
+			splitter := NewTextSplitter(60, 10, unit)
+			chunks := splitter.Split(text)
+			if len(chunks) < 2 {
+				t.Fatalf("长文本应切分为多个块，得到 %d 个", len(chunks))
+			}
+			for i, chunk := range chunks {
+				if !utf8.ValidString(chunk) {
+					t.Errorf("第 %d 块不是合法的UTF-8: %q", i, chunk)
+				}
+				if length := splitter.Length(chunk); length > splitter.ChunkSize {
+					t.Errorf("第 %d 块长度 %d 超过块大小 %d: %s", i, length, splitter.ChunkSize, chunk)
+				}
+			}
+			if !strings.HasPrefix(chunks[0], "# 红烧肉的做法") {
+				t.Errorf("首块应以标题开头: %s", chunks[0])
+			}
+		})
+	}
+}
+
+func TestTextSplitterHardSplitKeepsOverlap(t *testing.T) {
+	// 没有任何分隔符的长句只能按字符硬切分
+	text := strings.Repeat("小火慢炖肉质软烂入味", 10)
+	for _, unit := range []ChunkUnit{ChunkUnitRune, ChunkUnitToken} {
+		t.Run(string(unit), func(t *testing.T) {
+			splitter := NewTextSplitter(30, 5, unit)
+			chunks := splitter.Split(text)
+			if len(chunks) < 2 {
+				t.Fatalf("长句应切分为多个块，得到 %d 个", len(chunks))
+			}
+			for i, chunk := range chunks {
+				if !utf8.ValidString(chunk) || splitter.Length(chunk) > splitter.ChunkSize {
+					t.Errorf("第 %d 块非法或超长: %q", i, chunk)
+				}
+				if i > 0 {
+					prev := []rune(chunks[i-1])
+					overlap := string(prev[len(prev)-splitter.ChunkOverlap:])
+					if !strings.HasPrefix(chunk, overlap) {
+						t.Errorf("第 %d 块应以上一块末尾的 %q 开头: %s", i, overlap, chunk)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestChunkersKeepTitleHeader(t *testing.T) {
+	doc := &schema.Document{
+		Content:  longChineseRecipe(),
+		MetaData: map[string]interface{}{"node_id": "201000001"},
+	}
+	for _, unit := range []ChunkUnit{ChunkUnitRune, ChunkUnitToken} {
+		splitter := NewTextSplitter(80, 10, unit)
+		for _, kind := range []string{ChunkerSection, ChunkerStep, ChunkerIngredient, ChunkerRecursive, ChunkerParentChild} {
+			t.Run(string(unit)+"/"+kind, func(t *testing.T) {
+				chunks := NewChunker(kind, splitter).Chunk(doc)
+				if len(chunks) < 2 {
+					t.Fatalf("长文档应切分为多个块，得到 %d 个", len(chunks))
+				}
+				for i, chunk := range chunks {
+					if !utf8.ValidString(chunk.Content) {
+						t.Errorf("第 %d 块不是合法的UTF-8: %q", i, chunk.Content)
+					}
+					if length := splitter.Length(chunk.Content); length > splitter.ChunkSize {
+						t.Errorf("第 %d 块长度 %d 超过块大小 %d: %s", i, length, splitter.ChunkSize, chunk.Content)
+					}
+					if !strings.HasPrefix(chunk.Content, "# 红烧肉的做法\n") {
+						t.Errorf("第 %d 块应以菜谱标题开头: %s", i, chunk.Content)
+					}
+				}
+			})
+		}
+	}
+}